@@ -3,35 +3,326 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// 运行模式，与 gin 的模式保持一致
+const (
+	ModeDebug   = "debug"
+	ModeRelease = "release"
+	ModeTest    = "test"
 )
 
+// DefaultJWTKey 开发环境默认JWT密钥，release 模式下禁止使用
+const DefaultJWTKey = "your-secret-key"
+
+// DefaultConfigFile 未指定配置文件时尝试加载的默认路径
+const DefaultConfigFile = "config.yaml"
+
 // Config 应用配置
 type Config struct {
-	Port     int
-	MongoURI string
-	MongoDB  string
-	JWTKey   string
-	Debug    bool
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	Mongo     MongoConfig     `yaml:"mongo" toml:"mongo"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+	Scheduler SchedulerConfig `yaml:"scheduler" toml:"scheduler"`
+	AliCloud  AliCloudConfig  `yaml:"aliCloud" toml:"aliCloud"`
+}
+
+// ServerConfig HTTP服务配置
+type ServerConfig struct {
+	Port int    `yaml:"port" toml:"port"`
+	Mode string `yaml:"mode" toml:"mode"` // debug / release / test
+}
+
+// MongoConfig MongoDB连接配置
+type MongoConfig struct {
+	URI      string `yaml:"uri" toml:"uri"`
+	Database string `yaml:"database" toml:"database"`
+
+	// 连接池
+	MaxPoolSize     uint64   `yaml:"maxPoolSize" toml:"maxPoolSize"`
+	MinPoolSize     uint64   `yaml:"minPoolSize" toml:"minPoolSize"`
+	MaxConnIdleTime Duration `yaml:"maxConnIdleTime" toml:"maxConnIdleTime"`
+
+	// 超时
+	ConnectTimeout         Duration `yaml:"connectTimeout" toml:"connectTimeout"`
+	ServerSelectionTimeout Duration `yaml:"serverSelectionTimeout" toml:"serverSelectionTimeout"`
+	// OperationTimeout 单次数据库操作的最长时间，请求上下文的截止时间更早时以请求为准
+	OperationTimeout Duration `yaml:"operationTimeout" toml:"operationTimeout"`
+
+	// AutoMigrate 启动时自动执行未执行的数据库迁移，关闭后需手动执行 crm migrate up
+	AutoMigrate bool `yaml:"autoMigrate" toml:"autoMigrate"`
+}
+
+// AuthConfig 认证配置
+type AuthConfig struct {
+	JWTKey          string   `yaml:"jwtKey" toml:"jwtKey"`
+	AccessTokenTTL  Duration `yaml:"accessTokenTTL" toml:"accessTokenTTL"`
+	RefreshTokenTTL Duration `yaml:"refreshTokenTTL" toml:"refreshTokenTTL"`
+
+	// AdminInitialPassword 首次启动时创建的超级管理员密码，为空时随机生成并输出到日志
+	AdminInitialPassword string `yaml:"adminInitialPassword" toml:"adminInitialPassword"`
+}
+
+// SchedulerConfig 定时任务配置
+type SchedulerConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// AutoTransferAt 客户自动转移任务的执行时间，每日执行时为 HH:MM 或 HH:MM:SS，也可为 cron 表达式
+	AutoTransferAt string `yaml:"autoTransferAt" toml:"autoTransferAt"`
+	// TrashPurgeAt 清理回收站的执行时间，格式同上
+	TrashPurgeAt string `yaml:"trashPurgeAt" toml:"trashPurgeAt"`
+	// PublicPoolRecycleAt 按回收规则将长期未跟进客户移入公海的执行时间，格式同上
+	PublicPoolRecycleAt string `yaml:"publicPoolRecycleAt" toml:"publicPoolRecycleAt"`
+	// TrashRetention 客户在回收站中保留的时长，超过后连同关联数据永久删除
+	TrashRetention Duration `yaml:"trashRetention" toml:"trashRetention"`
+
+	// CatchUp 启动时对停机期间错过的执行的处理：once 补执行最近错过的一次，skip 不补执行
+	CatchUp string `yaml:"catchUp" toml:"catchUp"`
+	// CatchUpWindow 只补执行此时长内错过的执行
+	CatchUpWindow Duration `yaml:"catchUpWindow" toml:"catchUpWindow"`
+	// LockTTL 多实例部署时任务锁的有效期，执行期间定期续期，实例退出后锁在此时长后过期
+	LockTTL Duration `yaml:"lockTTL" toml:"lockTTL"`
+}
+
+// 错过执行的补偿策略
+const (
+	CatchUpOnce = "once"
+	CatchUpSkip = "skip"
+)
+
+// AliCloudConfig 阿里云企业信息查询接口配置，AppCode 为空时不启用公司名称补全
+type AliCloudConfig struct {
+	CompanySearchURL string   `yaml:"companySearchURL" toml:"companySearchURL"`
+	AppCode          string   `yaml:"appCode" toml:"appCode"`
+	Timeout          Duration `yaml:"timeout" toml:"timeout"`
+}
+
+// Duration 支持 "15m"、"168h" 等写法的时长
+type Duration time.Duration
+
+// UnmarshalText 解析 time.ParseDuration 格式的时长
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(strings.TrimSpace(string(text)))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
 }
 
-// LoadConfig 从环境变量加载配置
-func LoadConfig() *Config {
-	port, _ := strconv.Atoi(getEnv("PORT", "8080"))
+// MarshalText 输出 time.Duration 的字符串形式
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Default 返回默认配置，未在配置文件和环境变量中出现的项取此默认值
+// MongoDB 连接串没有默认值，必须显式配置
+func Default() *Config {
 	return &Config{
-		Port:     port,
-		MongoURI: fmt.Sprintf("mongodb://%s:%s@%s:%s/%s?authSource=%s", "qianxin", "QianXin123", "127.0.0.1", "27017", "crm", "admin"),
-		MongoDB:  getEnv("MONGO_DB", "crm"),
-		JWTKey:   getEnv("JWT_KEY", "your-secret-key"), // 实际环境应替换为安全密钥
-		Debug:    getEnv("GIN_MODE", "debug") == "debug",
+		Server: ServerConfig{
+			Port: 8080,
+			Mode: ModeDebug,
+		},
+		Mongo: MongoConfig{
+			Database:               "crm",
+			MaxPoolSize:            100,
+			MaxConnIdleTime:        Duration(5 * time.Minute),
+			ConnectTimeout:         Duration(10 * time.Second),
+			ServerSelectionTimeout: Duration(10 * time.Second),
+			OperationTimeout:       Duration(10 * time.Second),
+			AutoMigrate:            true,
+		},
+		Auth: AuthConfig{
+			JWTKey:          DefaultJWTKey,
+			AccessTokenTTL:  Duration(15 * time.Minute),
+			RefreshTokenTTL: Duration(7 * 24 * time.Hour),
+		},
+		Scheduler: SchedulerConfig{
+			Enabled:             true,
+			AutoTransferAt:      "01:00:00",
+			TrashPurgeAt:        "03:00:00",
+			TrashRetention:      Duration(30 * 24 * time.Hour),
+			PublicPoolRecycleAt: "02:00:00",
+			CatchUp:             CatchUpOnce,
+			CatchUpWindow:       Duration(24 * time.Hour),
+			LockTTL:             Duration(5 * time.Minute),
+		},
+		AliCloud: AliCloudConfig{
+			CompanySearchURL: "https://comserver.market.alicloudapi.com/searchCompany",
+			Timeout:          Duration(15 * time.Second),
+		},
 	}
 }
 
-// getEnv 获取环境变量，如果不存在则返回默认值
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+// Load 加载配置：默认值 <- 配置文件（YAML/TOML） <- 环境变量，最后校验
+// path 为空时依次尝试环境变量 CRM_CONFIG 和当前目录下的 config.yaml，都不存在则只使用环境变量
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	explicit := path != ""
+	if path == "" {
+		path = os.Getenv("CRM_CONFIG")
+		explicit = path != ""
+	}
+	if path == "" {
+		path = DefaultConfigFile
+	}
+
+	if err := cfg.loadFile(path, explicit); err != nil {
+		return nil, err
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile 按扩展名解析配置文件，explicit 为 false 时文件不存在不视为错误
+func (c *Config) loadFile(path string, explicit bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && !explicit {
+			return nil
+		}
+		return fmt.Errorf("读取配置文件 %s 失败: %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".toml":
+		err = toml.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("不支持的配置文件格式: %s（仅支持 .yaml/.yml/.toml）", path)
+	}
+	if err != nil {
+		return fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv 使用环境变量覆盖配置文件中的值
+func (c *Config) applyEnv() error {
+	if v := os.Getenv("PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("环境变量 PORT 无效: %w", err)
+		}
+		c.Server.Port = port
+	}
+	setString(&c.Server.Mode, "GIN_MODE")
+
+	setString(&c.Mongo.URI, "MONGO_URI")
+	setString(&c.Mongo.Database, "MONGO_DB")
+	if err := setUint(&c.Mongo.MaxPoolSize, "MONGO_MAX_POOL_SIZE"); err != nil {
+		return err
+	}
+	if err := setUint(&c.Mongo.MinPoolSize, "MONGO_MIN_POOL_SIZE"); err != nil {
+		return err
+	}
+	for key, target := range map[string]*Duration{
+		"MONGO_MAX_CONN_IDLE_TIME":       &c.Mongo.MaxConnIdleTime,
+		"MONGO_CONNECT_TIMEOUT":          &c.Mongo.ConnectTimeout,
+		"MONGO_SERVER_SELECTION_TIMEOUT": &c.Mongo.ServerSelectionTimeout,
+		"MONGO_OPERATION_TIMEOUT":        &c.Mongo.OperationTimeout,
+	} {
+		if err := setDuration(target, key); err != nil {
+			return err
+		}
+	}
+
+	if err := setBool(&c.Mongo.AutoMigrate, "MONGO_AUTO_MIGRATE"); err != nil {
+		return err
+	}
+
+	setString(&c.Auth.JWTKey, "JWT_KEY")
+	setString(&c.Auth.AdminInitialPassword, "ADMIN_INITIAL_PASSWORD")
+	if err := setDuration(&c.Auth.AccessTokenTTL, "ACCESS_TOKEN_TTL"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.Auth.RefreshTokenTTL, "REFRESH_TOKEN_TTL"); err != nil {
+		return err
+	}
+
+	if err := setBool(&c.Scheduler.Enabled, "SCHEDULER_ENABLED"); err != nil {
+		return err
+	}
+	setString(&c.Scheduler.AutoTransferAt, "AUTO_TRANSFER_AT")
+	setString(&c.Scheduler.TrashPurgeAt, "TRASH_PURGE_AT")
+	setString(&c.Scheduler.PublicPoolRecycleAt, "PUBLIC_POOL_RECYCLE_AT")
+	if err := setDuration(&c.Scheduler.TrashRetention, "TRASH_RETENTION"); err != nil {
+		return err
+	}
+	setString(&c.Scheduler.CatchUp, "SCHEDULER_CATCH_UP")
+	if err := setDuration(&c.Scheduler.CatchUpWindow, "SCHEDULER_CATCH_UP_WINDOW"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.Scheduler.LockTTL, "SCHEDULER_LOCK_TTL"); err != nil {
+		return err
+	}
+
+	setString(&c.AliCloud.CompanySearchURL, "ALICLOUD_COMPANY_SEARCH_URL")
+	setString(&c.AliCloud.AppCode, "ALICLOUD_APPCODE")
+	return setDuration(&c.AliCloud.Timeout, "ALICLOUD_TIMEOUT")
+}
+
+// IsRelease 是否为生产模式
+func (c *Config) IsRelease() bool {
+	return c.Server.Mode == ModeRelease
+}
+
+// setString 环境变量非空时覆盖目标值
+func setString(target *string, key string) {
+	if v := os.Getenv(key); v != "" {
+		*target = v
+	}
+}
+
+// setBool 环境变量非空时解析为布尔值并覆盖目标值
+func setBool(target *bool, key string) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("环境变量 %s 无效: %w", key, err)
+	}
+	*target = b
+	return nil
+}
+
+// setUint 环境变量非空时解析为非负整数并覆盖目标值
+func setUint(target *uint64, key string) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return fmt.Errorf("环境变量 %s 无效: %w", key, err)
+	}
+	*target = n
+	return nil
+}
+
+// setDuration 环境变量非空时解析为时长并覆盖目标值
+func setDuration(target *Duration, key string) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	if err := target.UnmarshalText([]byte(v)); err != nil {
+		return fmt.Errorf("环境变量 %s 无效: %w", key, err)
 	}
-	return value
+	return nil
 }
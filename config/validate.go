@@ -0,0 +1,124 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/scheduler/cron"
+)
+
+// Validate 校验配置，返回所有不合法项
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port 无效: %d", c.Server.Port))
+	}
+	switch c.Server.Mode {
+	case ModeDebug, ModeRelease, ModeTest:
+	default:
+		errs = append(errs, fmt.Errorf("server.mode 必须为 debug/release/test，当前为 %q", c.Server.Mode))
+	}
+
+	if strings.TrimSpace(c.Mongo.URI) == "" {
+		errs = append(errs, errors.New("mongo.uri 未配置（可通过环境变量 MONGO_URI 设置）"))
+	} else if !strings.HasPrefix(c.Mongo.URI, "mongodb://") && !strings.HasPrefix(c.Mongo.URI, "mongodb+srv://") {
+		errs = append(errs, errors.New("mongo.uri 必须以 mongodb:// 或 mongodb+srv:// 开头"))
+	}
+	if strings.TrimSpace(c.Mongo.Database) == "" {
+		errs = append(errs, errors.New("mongo.database 未配置"))
+	}
+	if c.Mongo.MaxPoolSize == 0 {
+		errs = append(errs, errors.New("mongo.maxPoolSize 必须大于0"))
+	} else if c.Mongo.MinPoolSize > c.Mongo.MaxPoolSize {
+		errs = append(errs, errors.New("mongo.minPoolSize 不能大于 mongo.maxPoolSize"))
+	}
+	if c.Mongo.ConnectTimeout <= 0 || c.Mongo.ServerSelectionTimeout <= 0 || c.Mongo.OperationTimeout <= 0 {
+		errs = append(errs, errors.New("mongo.connectTimeout、serverSelectionTimeout、operationTimeout 必须大于0"))
+	}
+	if c.Mongo.MaxConnIdleTime < 0 {
+		errs = append(errs, errors.New("mongo.maxConnIdleTime 不能为负数"))
+	}
+
+	if strings.TrimSpace(c.Auth.JWTKey) == "" {
+		errs = append(errs, errors.New("auth.jwtKey 未配置（可通过环境变量 JWT_KEY 设置）"))
+	} else if c.IsRelease() && c.Auth.JWTKey == DefaultJWTKey {
+		errs = append(errs, errors.New("release 模式下禁止使用默认JWT密钥，请配置 auth.jwtKey 或环境变量 JWT_KEY"))
+	}
+	if c.Auth.AccessTokenTTL <= 0 {
+		errs = append(errs, errors.New("auth.accessTokenTTL 必须大于0"))
+	}
+	if c.Auth.RefreshTokenTTL <= c.Auth.AccessTokenTTL {
+		errs = append(errs, errors.New("auth.refreshTokenTTL 必须大于 auth.accessTokenTTL"))
+	}
+
+	if _, err := c.Scheduler.AutoTransferCron(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.Scheduler.TrashPurgeCron(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.Scheduler.PublicPoolRecycleCron(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Scheduler.TrashRetention < Duration(24*time.Hour) {
+		errs = append(errs, errors.New("scheduler.trashRetention 不能小于 24h"))
+	}
+	switch c.Scheduler.CatchUp {
+	case CatchUpOnce, CatchUpSkip:
+	default:
+		errs = append(errs, fmt.Errorf("scheduler.catchUp 必须为 once/skip，当前为 %q", c.Scheduler.CatchUp))
+	}
+	if c.Scheduler.CatchUpWindow < 0 {
+		errs = append(errs, errors.New("scheduler.catchUpWindow 不能为负数"))
+	}
+	if c.Scheduler.LockTTL < Duration(30*time.Second) {
+		errs = append(errs, errors.New("scheduler.lockTTL 不能小于 30s"))
+	}
+
+	if c.AliCloud.AppCode != "" {
+		if u, err := url.Parse(c.AliCloud.CompanySearchURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("aliCloud.companySearchURL 无效: %q", c.AliCloud.CompanySearchURL))
+		}
+		if c.AliCloud.Timeout <= 0 {
+			errs = append(errs, errors.New("aliCloud.timeout 必须大于0"))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("配置校验失败: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// AutoTransferCron 自动转移任务的 cron 表达式
+func (s SchedulerConfig) AutoTransferCron() (string, error) {
+	return cronSpec("scheduler.autoTransferAt", s.AutoTransferAt)
+}
+
+// TrashPurgeCron 回收站清理任务的 cron 表达式
+func (s SchedulerConfig) TrashPurgeCron() (string, error) {
+	return cronSpec("scheduler.trashPurgeAt", s.TrashPurgeAt)
+}
+
+// PublicPoolRecycleCron 公海自动回收任务的 cron 表达式
+func (s SchedulerConfig) PublicPoolRecycleCron() (string, error) {
+	return cronSpec("scheduler.publicPoolRecycleAt", s.PublicPoolRecycleAt)
+}
+
+// cronSpec 将 HH:MM 或 HH:MM:SS 格式的每日执行时间转为 cron 表达式，其他取值按 cron 表达式校验
+func cronSpec(key string, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return fmt.Sprintf("%d %d %d * * *", t.Second(), t.Minute(), t.Hour()), nil
+		}
+	}
+	if _, err := cron.Parse(value); err != nil {
+		return "", fmt.Errorf("%s 格式无效: %q（应为 HH:MM、HH:MM:SS 或 cron 表达式）: %w", key, value, err)
+	}
+	return value, nil
+}
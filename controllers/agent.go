@@ -8,14 +8,26 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/pagination"
 	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/service"
 	"github.com/BerniceZTT/crm_end/utils"
 )
 
+// agentListSort 代理商列表可排序的字段
+var agentListSort = pagination.Spec{
+	SortFields: map[string]string{
+		"createdAt":   "createdAt",
+		"updatedAt":   "updatedAt",
+		"companyName": "companyName",
+		"status":      "status",
+	},
+	DefaultSort:  "createdAt",
+	DefaultOrder: -1,
+}
+
 // GetAllAgents 获取所有代理商
 func GetAllAgents(c *gin.Context) {
 	// 获取当前用户信息
@@ -24,10 +36,13 @@ func GetAllAgents(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
+	pageReq, err := pagination.FromQuery(c.Request.URL.Query(), agentListSort)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
 
-	// 获取代理商集合
-	agentsCollection := repository.Collection(repository.AgentsCollection)
-	ctx := repository.GetContext()
+	ctx := c.Request.Context()
 
 	// 构建查询条件
 	query := bson.M{}
@@ -36,73 +51,38 @@ func GetAllAgents(c *gin.Context) {
 		query = bson.M{"relatedSalesId": user.ID}
 	}
 
-	// 查询代理商列表，排除密码字段
-	findOptions := options.Find().SetProjection(bson.M{"password": 0})
-	cursor, err := agentsCollection.Find(ctx, query, findOptions)
+	// 查询一页代理商，排除密码字段
+	result, err := pagination.Find(ctx, repository.Agents(), query, pageReq,
+		repository.NewFindOptions().SetProjection(bson.M{"password": 0}))
 	if err != nil {
 		utils.HandleError(c, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取代理商列表失败"})
-		return
-	}
-	defer cursor.Close(ctx)
-
-	// 解析查询结果
-	var agents []models.Agent
-	if err = cursor.All(ctx, &agents); err != nil {
-		utils.HandleError(c, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "解析代理商数据失败"})
 		return
 	}
+	agents := result.Items
 
 	// 如果有代理商数据，关联查询销售人员信息
 	if len(agents) > 0 {
 		// 获取需要查询的销售ID列表
-		var salesIds []primitive.ObjectID
+		var salesIds []string
 		for _, agent := range agents {
 			if agent.RelatedSalesID != "" {
-				objID, err := primitive.ObjectIDFromHex(agent.RelatedSalesID)
-				if err == nil {
-					salesIds = append(salesIds, objID)
-				}
+				salesIds = append(salesIds, agent.RelatedSalesID)
 			}
 		}
 
-		// 如果有关联销售，查询销售人员信息
-		if len(salesIds) > 0 {
-			usersCollection := repository.Collection(repository.UsersCollection)
-			salesQuery := bson.M{"_id": bson.M{"$in": salesIds}}
-			salesOptions := options.Find().SetProjection(bson.M{"_id": 1, "username": 1})
-
-			salesCursor, err := usersCollection.Find(ctx, salesQuery, salesOptions)
-			if err == nil {
-				defer salesCursor.Close(ctx)
-
-				var salesUsers []struct {
-					ID       primitive.ObjectID `bson:"_id"`
-					Username string             `bson:"username"`
-				}
-
-				if err = salesCursor.All(ctx, &salesUsers); err == nil {
-					// 构建销售ID到名称的映射
-					salesMap := make(map[string]string)
-					for _, user := range salesUsers {
-						salesMap[user.ID.Hex()] = user.Username
-					}
-
-					// 将销售名称添加到代理商数据
-					for i := range agents {
-						if agents[i].RelatedSalesID != "" {
-							if name, ok := salesMap[agents[i].RelatedSalesID]; ok {
-								agents[i].RelatedSalesName = name
-							}
-						}
+		// 查询销售人员信息并将销售名称添加到代理商数据
+		if salesMap, err := repository.Users().NamesByIDs(ctx, salesIds); err == nil {
+			for i := range agents {
+				if agents[i].RelatedSalesID != "" {
+					if name, ok := salesMap[agents[i].RelatedSalesID]; ok {
+						agents[i].RelatedSalesName = name
 					}
 				}
 			}
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"agents": agents})
+	utils.PaginatedResponse(c, agents, result.Pagination)
 }
 
 // CreateAgent 创建代理商
@@ -134,8 +114,8 @@ func CreateAgent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "公司名至少2个字符"})
 		return
 	}
-	if agentData.Password == "" || len(agentData.Password) < 6 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "密码至少6个字符"})
+	if err := utils.ValidatePasswordStrength(agentData.Password, agentData.CompanyName); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	if agentData.ContactPerson == "" || len(agentData.ContactPerson) < 2 {
@@ -147,17 +127,15 @@ func CreateAgent(c *gin.Context) {
 		return
 	}
 
-	// 获取代理商集合
-	agentsCollection := repository.Collection(repository.AgentsCollection)
-	ctx := repository.GetContext()
+	ctx := c.Request.Context()
 
 	// 检查公司名称是否重复
-	existingAgent := agentsCollection.FindOne(ctx, bson.M{"companyName": agentData.CompanyName})
-	if existingAgent.Err() == nil {
+	_, err = repository.Agents().FindByCompanyName(ctx, agentData.CompanyName)
+	if err == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "公司名称已存在"})
 		return
-	} else if existingAgent.Err() != mongo.ErrNoDocuments {
-		utils.HandleError(c, existingAgent.Err())
+	} else if err != repository.ErrNotFound {
+		utils.HandleError(c, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建代理商失败"})
 		return
 	}
@@ -173,13 +151,18 @@ func CreateAgent(c *gin.Context) {
 	}
 
 	// 对密码进行加密
-	agentData.Password = utils.HashPassword(agentData.Password)
+	agentData.Password, err = utils.HashPassword(agentData.Password)
+	if err != nil {
+		utils.HandleError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建代理商失败"})
+		return
+	}
 
 	// 设置创建时间
 	agentData.CreatedAt = time.Now()
 
 	// 插入代理商数据
-	result, err := agentsCollection.InsertOne(ctx, agentData)
+	insertedID, err := repository.Agents().Insert(ctx, &agentData)
 	if err != nil {
 		utils.HandleError(c, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建代理商失败"})
@@ -193,7 +176,7 @@ func CreateAgent(c *gin.Context) {
 	}
 
 	// 返回响应
-	agentData.ID = result.InsertedID.(primitive.ObjectID)
+	agentData.ID = insertedID
 	agentData.Password = "" // 不返回密码
 	c.JSON(http.StatusCreated, gin.H{
 		"message": message,
@@ -245,20 +228,13 @@ func UpdateAgent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请输入有效的手机号"})
 		return
 	}
-	if updateData.Password != "" && len(updateData.Password) < 6 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "密码至少6个字符"})
-		return
-	}
 
-	// 获取代理商集合
-	agentsCollection := repository.Collection(repository.AgentsCollection)
-	ctx := repository.GetContext()
+	ctx := c.Request.Context()
 
 	// 查找要更新的代理商
-	var existingAgent models.Agent
-	err = agentsCollection.FindOne(ctx, bson.M{"_id": objID}).Decode(&existingAgent)
+	existingAgent, err := repository.Agents().FindByID(ctx, objID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "代理商不存在"})
 		} else {
 			utils.HandleError(c, err)
@@ -281,12 +257,24 @@ func UpdateAgent(c *gin.Context) {
 		}
 	}
 
+	// 密码不能包含更新后的公司名称
+	if updateData.Password != "" {
+		companyName := existingAgent.CompanyName
+		if updateData.CompanyName != "" {
+			companyName = updateData.CompanyName
+		}
+		if err := utils.ValidatePasswordStrength(updateData.Password, companyName); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// 构建更新数据
 	update := bson.M{}
 	if updateData.CompanyName != "" {
 		// 检查公司名称是否重复
 		if updateData.CompanyName != existingAgent.CompanyName {
-			count, err := agentsCollection.CountDocuments(ctx, bson.M{
+			count, err := repository.Agents().Count(ctx, bson.M{
 				"companyName": updateData.CompanyName,
 				"_id":         bson.M{"$ne": objID},
 			})
@@ -316,7 +304,13 @@ func UpdateAgent(c *gin.Context) {
 	}
 
 	if updateData.Password != "" {
-		update["password"] = utils.HashPassword(updateData.Password)
+		hashedPassword, err := utils.HashPassword(updateData.Password)
+		if err != nil {
+			utils.HandleError(c, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新代理商失败"})
+			return
+		}
+		update["password"] = hashedPassword
 	}
 
 	// 设置更新时间
@@ -326,8 +320,14 @@ func UpdateAgent(c *gin.Context) {
 		update["status"] = updateData.Status
 	}
 
+	// 修改密码或状态后，已签发的令牌全部失效
+	updateDoc := bson.M{"$set": update}
+	if updateData.Password != "" || (updateData.Status != "" && updateData.Status != string(existingAgent.Status)) {
+		updateDoc["$inc"] = bson.M{"tokenVersion": 1}
+	}
+
 	// 执行更新操作
-	result, err := agentsCollection.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": update})
+	result, err := repository.Agents().UpdateByID(ctx, objID, updateDoc)
 	if err != nil {
 		utils.HandleError(c, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新代理商失败"})
@@ -355,16 +355,12 @@ func DeleteAgent(c *gin.Context) {
 		return
 	}
 
-	// 获取代理商和客户集合
-	agentsCollection := repository.Collection(repository.AgentsCollection)
-	customersCollection := repository.Collection(repository.CustomersCollection)
-	ctx := repository.GetContext()
+	ctx := c.Request.Context()
 
 	// 首先检查代理商是否存在
-	var agent models.Agent
-	err = agentsCollection.FindOne(ctx, bson.M{"_id": objID}).Decode(&agent)
+	_, err = repository.Agents().FindByID(ctx, objID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "代理商不存在"})
 		} else {
 			utils.HandleError(c, err)
@@ -374,9 +370,9 @@ func DeleteAgent(c *gin.Context) {
 	}
 
 	// 查询该代理商是否有关联客户
-	customerCount, err := customersCollection.CountDocuments(ctx, bson.M{
-		"ownerid":   id,
-		"ownertype": "AGENT",
+	customerCount, err := repository.Customers().Count(ctx, bson.M{
+		"ownerId":   id,
+		"ownerType": "AGENT",
 	})
 	if err != nil {
 		utils.HandleError(c, err)
@@ -393,18 +389,23 @@ func DeleteAgent(c *gin.Context) {
 	}
 
 	// 删除代理商
-	result, err := agentsCollection.DeleteOne(ctx, bson.M{"_id": objID})
+	deletedCount, err := repository.Agents().DeleteByID(ctx, objID)
 	if err != nil {
 		utils.HandleError(c, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除代理商失败"})
 		return
 	}
 
-	if result.DeletedCount == 0 {
+	if deletedCount == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"error": "代理商不存在或已被删除"})
 		return
 	}
 
+	// 吊销该代理商的登录会话
+	if err := service.InvalidateSessions(ctx, id, models.UserRoleAGENT); err != nil {
+		utils.Logger.Error().Err(err).Str("agentId", id).Msg("吊销代理商登录会话失败")
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "删除代理商成功"})
 }
 
@@ -432,92 +433,47 @@ func GetAgentsBySalesId(c *gin.Context) {
 		return
 	}
 
-	// 获取代理商集合
-	agentsCollection := repository.Collection(repository.AgentsCollection)
-	ctx := repository.GetContext()
+	ctx := c.Request.Context()
 
 	// 查询代理商列表
-	findOptions := options.Find().SetProjection(bson.M{"password": 0})
-	cursor, err := agentsCollection.Find(ctx, bson.M{
+	agents, err := repository.Agents().Find(ctx, bson.M{
 		"relatedSalesId": salesId,
 		"status":         "approved",
-	}, findOptions)
-
+	}, repository.NewFindOptions().SetProjection(bson.M{"password": 0}))
 	if err != nil {
 		utils.HandleError(c, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取代理商列表失败"})
 		return
 	}
-	defer cursor.Close(ctx)
-
-	// 解析查询结果
-	var agents []models.Agent
-	if err = cursor.All(ctx, &agents); err != nil {
-		utils.HandleError(c, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "解析代理商数据失败"})
-		return
-	}
 
 	c.JSON(http.StatusOK, gin.H{"agents": agents})
 }
 
 // ExportAgentsToCSV 导出代理商列表为CSV
 func ExportAgentsToCSV(c *gin.Context) {
-	// 获取代理商集合
-	agentsCollection := repository.Collection(repository.AgentsCollection)
-	ctx := repository.GetContext()
+	ctx := c.Request.Context()
 
 	// 查询代理商列表
-	findOptions := options.Find().SetProjection(bson.M{"password": 0})
-	cursor, err := agentsCollection.Find(ctx, bson.M{}, findOptions)
+	agents, err := repository.Agents().Find(ctx, bson.M{},
+		repository.NewFindOptions().SetProjection(bson.M{"password": 0}))
 	if err != nil {
 		utils.HandleError(c, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "导出代理商失败"})
 		return
 	}
-	defer cursor.Close(ctx)
-
-	// 解析查询结果
-	var agents []models.Agent
-	if err = cursor.All(ctx, &agents); err != nil {
-		utils.HandleError(c, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "解析代理商数据失败"})
-		return
-	}
 
 	// 获取关联销售ID集合
-	var salesIds []primitive.ObjectID
+	var salesIds []string
 	for _, agent := range agents {
 		if agent.RelatedSalesID != "" {
-			objID, err := primitive.ObjectIDFromHex(agent.RelatedSalesID)
-			if err == nil {
-				salesIds = append(salesIds, objID)
-			}
+			salesIds = append(salesIds, agent.RelatedSalesID)
 		}
 	}
 
 	// 查询销售人员信息
-	salesMap := make(map[string]string)
-	if len(salesIds) > 0 {
-		usersCollection := repository.Collection(repository.UsersCollection)
-		salesCursor, err := usersCollection.Find(ctx, bson.M{
-			"_id": bson.M{"$in": salesIds},
-		}, options.Find().SetProjection(bson.M{"_id": 1, "username": 1}))
-
-		if err == nil {
-			defer salesCursor.Close(ctx)
-
-			var salesUsers []struct {
-				ID       primitive.ObjectID `bson:"_id"`
-				Username string             `bson:"username"`
-			}
-
-			if err = salesCursor.All(ctx, &salesUsers); err == nil {
-				for _, user := range salesUsers {
-					salesMap[user.ID.Hex()] = user.Username
-				}
-			}
-		}
+	salesMap, err := repository.Users().NamesByIDs(ctx, salesIds)
+	if err != nil {
+		salesMap = make(map[string]string)
 	}
 
 	// 设置CSV响应头
@@ -589,9 +545,7 @@ func GetAssignableAgents(c *gin.Context) {
 		return
 	}
 
-	// 获取代理商集合
-	agentsCollection := repository.Collection(repository.AgentsCollection)
-	ctx := repository.GetContext()
+	ctx := c.Request.Context()
 
 	// 构建查询条件和投影
 	query := bson.M{"status": "approved"}
@@ -603,33 +557,12 @@ func GetAssignableAgents(c *gin.Context) {
 		"relatedSalesId": 1,
 	}
 
-	var agents []models.Agent
-	var err error
-
 	// 根据不同角色处理查询逻辑
 	if user.Role == "SUPER_ADMIN" {
 		// 超级管理员可以看到所有已批准的代理商
-		cursor, err := agentsCollection.Find(ctx, query, options.Find().SetProjection(projection))
-		if err != nil {
-			utils.HandleError(c, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取可分配代理商列表失败"})
-			return
-		}
-		defer cursor.Close(ctx)
-
-		err = cursor.All(ctx, &agents)
 	} else if user.Role == "FACTORY_SALES" {
 		// 销售只能看到关联的代理商
 		query["relatedSalesId"] = user.ID
-		cursor, err := agentsCollection.Find(ctx, query, options.Find().SetProjection(projection))
-		if err != nil {
-			utils.HandleError(c, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取可分配代理商列表失败"})
-			return
-		}
-		defer cursor.Close(ctx)
-
-		err = cursor.All(ctx, &agents)
 	} else if user.Role == "AGENT" {
 		// 代理商只能看到自己
 		objID, err := primitive.ObjectIDFromHex(user.ID)
@@ -637,23 +570,14 @@ func GetAssignableAgents(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
 			return
 		}
-
 		query["_id"] = objID
-		cursor, err := agentsCollection.Find(ctx, query, options.Find().SetProjection(projection))
-		if err != nil {
-			utils.HandleError(c, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取可分配代理商列表失败"})
-			return
-		}
-		defer cursor.Close(ctx)
-
-		err = cursor.All(ctx, &agents)
 	} else {
 		// 其他角色无权访问
 		c.JSON(http.StatusForbidden, gin.H{"error": "无权获取代理商列表"})
 		return
 	}
 
+	agents, err := repository.Agents().Find(ctx, query, repository.NewFindOptions().SetProjection(projection))
 	if err != nil {
 		utils.HandleError(c, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取可分配代理商列表失败"})
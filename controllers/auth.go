@@ -1,12 +1,15 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/BerniceZTT/crm_end/models"
 	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/service"
 	"github.com/BerniceZTT/crm_end/utils"
 
 	"github.com/gin-gonic/gin"
@@ -16,6 +19,7 @@ import (
 )
 
 // Login 用户登录
+// 登录名不存在与密码错误返回相同提示；连续失败会延迟响应并临时锁定登录名/IP
 func Login(c *gin.Context) {
 	var req models.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -34,16 +38,30 @@ func Login(c *gin.Context) {
 		Bool("isAgent", req.IsAgent).
 		Msg("登录尝试")
 
+	ctx := c.Request.Context()
+
+	// 检查登录名和IP是否处于锁定期
+	retryAfter, err := service.CheckLoginAllowed(ctx, req.Username, c.ClientIP())
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("查询登录失败记录出错")
+		utils.ErrorResponse(c, "登录失败: 数据库错误", http.StatusInternalServerError)
+		return
+	}
+	if retryAfter > 0 {
+		utils.Logger.Info().Str("username", req.Username).Dur("retryAfter", retryAfter).Msg("登录失败: 已锁定")
+		c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+		utils.ErrorResponse(c, service.LoginLockedMessage, http.StatusTooManyRequests)
+		return
+	}
+
 	var user *models.User
 	var agent *models.Agent
 
 	// 先查询用户表
-	usersCollection := repository.Collection(repository.UsersCollection)
-	var userResult models.User
-	err := usersCollection.FindOne(repository.GetContext(), bson.M{"username": req.Username}).Decode(&userResult)
+	userResult, err := repository.Users().FindByUsername(ctx, req.Username)
 	if err == nil {
-		user = &userResult
-	} else if err != mongo.ErrNoDocuments {
+		user = userResult
+	} else if err != repository.ErrNotFound {
 		utils.Logger.Error().Err(err).Msg("查询用户出错")
 		utils.ErrorResponse(c, "登录失败: 数据库错误", http.StatusInternalServerError)
 		return
@@ -51,12 +69,10 @@ func Login(c *gin.Context) {
 
 	// 如果没找到，也尝试查询代理商表
 	if user == nil {
-		agentsCollection := repository.Collection(repository.AgentsCollection)
-		var agentResult models.Agent
-		err := agentsCollection.FindOne(repository.GetContext(), bson.M{"companyName": req.Username}).Decode(&agentResult)
+		agentResult, err := repository.Agents().FindByCompanyName(ctx, req.Username)
 		if err == nil {
-			agent = &agentResult
-		} else if err != mongo.ErrNoDocuments {
+			agent = agentResult
+		} else if err != repository.ErrNotFound {
 			utils.Logger.Error().Err(err).Msg("查询代理商出错")
 			utils.ErrorResponse(c, "登录失败: 数据库错误", http.StatusInternalServerError)
 			return
@@ -65,13 +81,23 @@ func Login(c *gin.Context) {
 
 	// 处理未找到用户或代理商的情况
 	if user == nil && agent == nil {
+		utils.SimulatePasswordCheck(req.Password)
 		utils.Logger.Info().Str("username", req.Username).Msg("登录失败: 用户名不存在")
-		utils.ErrorResponse(c, "用户名不存在，请检查用户名或注册新账号", http.StatusUnauthorized)
+		loginFailed(c, req.Username)
 		return
 	}
 
 	// 处理代理商登录
 	if agent != nil {
+		// 验证密码（先于状态检查，避免未认证请求获知账户状态）
+		ok, needsRehash := utils.VerifyPassword(req.Password, agent.Password)
+		if !ok {
+			utils.Logger.Info().Str("username", req.Username).Msg("代理商登录失败: 密码错误")
+			loginFailed(c, req.Username)
+			return
+		}
+		loginSucceeded(ctx, req.Username)
+
 		// 检查代理商状态
 		if agent.Status == models.UserStatusPENDING {
 			utils.Logger.Info().Str("username", req.Username).Msg("代理商登录失败: 账户待审核")
@@ -86,15 +112,12 @@ func Login(c *gin.Context) {
 			return
 		}
 
-		// 验证密码
-		if !utils.VerifyPassword(req.Password, agent.Password) {
-			utils.Logger.Info().Str("username", req.Username).Msg("代理商登录失败: 密码错误")
-			utils.ErrorResponse(c, "用户名或密码错误", http.StatusUnauthorized)
-			return
+		if needsRehash {
+			rehashPassword(ctx, repository.Agents(), agent.ID, req.Password, req.Username)
 		}
 
-		// 生成JWT令牌
-		token, err := utils.GenerateToken(*agent)
+		// 签发访问令牌和刷新令牌
+		tokens, err := service.IssueTokens(ctx, *agent, sessionMeta(c))
 		if err != nil {
 			utils.Logger.Error().Err(err).Msg("生成代理商token失败")
 			utils.ErrorResponse(c, "生成登录令牌失败，请重试", http.StatusInternalServerError)
@@ -114,14 +137,25 @@ func Login(c *gin.Context) {
 
 		utils.Logger.Info().Str("username", agent.CompanyName).Msg("代理商登录成功")
 		utils.SuccessResponse(c, gin.H{
-			"token": token,
-			"user":  agentUser,
+			"token":        tokens.AccessToken,
+			"refreshToken": tokens.RefreshToken,
+			"expiresIn":    tokens.ExpiresIn,
+			"user":         agentUser,
 		}, "")
 		return
 	}
 
 	// 处理用户登录
 	if user != nil {
+		// 验证密码（先于状态检查，避免未认证请求获知账户状态）
+		ok, needsRehash := utils.VerifyPassword(req.Password, user.Password)
+		if !ok {
+			utils.Logger.Info().Str("username", req.Username).Msg("登录失败: 密码错误")
+			loginFailed(c, req.Username)
+			return
+		}
+		loginSucceeded(ctx, req.Username)
+
 		// 检查用户状态
 		if user.Status == models.UserStatusPENDING {
 			utils.Logger.Info().Str("username", req.Username).Msg("登录失败: 用户账户待审核")
@@ -140,15 +174,12 @@ func Login(c *gin.Context) {
 			return
 		}
 
-		// 验证密码
-		if !utils.VerifyPassword(req.Password, user.Password) {
-			utils.Logger.Info().Str("username", req.Username).Msg("登录失败: 密码错误")
-			utils.ErrorResponse(c, "用户名或密码错误", http.StatusUnauthorized)
-			return
+		if needsRehash {
+			rehashPassword(ctx, repository.Users(), user.ID, req.Password, req.Username)
 		}
 
-		// 生成JWT令牌
-		token, err := utils.GenerateToken(*user)
+		// 签发访问令牌和刷新令牌
+		tokens, err := service.IssueTokens(ctx, *user, sessionMeta(c))
 		if err != nil {
 			utils.Logger.Error().Err(err).Msg("生成token失败")
 			utils.ErrorResponse(c, "生成登录令牌失败，请重试", http.StatusInternalServerError)
@@ -161,8 +192,10 @@ func Login(c *gin.Context) {
 
 		utils.Logger.Info().Str("username", user.Username).Msg("用户登录成功")
 		utils.SuccessResponse(c, gin.H{
-			"token": token,
-			"user":  userWithoutPassword,
+			"token":        tokens.AccessToken,
+			"refreshToken": tokens.RefreshToken,
+			"expiresIn":    tokens.ExpiresIn,
+			"user":         userWithoutPassword,
 		}, "")
 		return
 	}
@@ -172,6 +205,101 @@ func Login(c *gin.Context) {
 	utils.ErrorResponse(c, "登录处理失败，请重试", http.StatusInternalServerError)
 }
 
+// loginFailed 记录登录失败并按失败次数延迟后返回统一的错误提示
+func loginFailed(c *gin.Context, username string) {
+	delay, err := service.RecordLoginFailure(c.Request.Context(), username, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		utils.Logger.Error().Err(err).Str("username", username).Msg("记录登录失败次数出错")
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	utils.ErrorResponse(c, "用户名或密码错误", http.StatusUnauthorized)
+}
+
+// loginSucceeded 密码验证通过后清除该登录名的失败计数
+func loginSucceeded(ctx context.Context, username string) {
+	if err := service.ResetLoginFailures(ctx, username); err != nil {
+		utils.Logger.Error().Err(err).Str("username", username).Msg("清除登录失败次数出错")
+	}
+}
+
+// sessionMeta 提取请求的客户端信息，记录到刷新令牌
+func sessionMeta(c *gin.Context) service.SessionMeta {
+	return service.SessionMeta{
+		ClientIP:  c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+// RefreshToken 使用刷新令牌换取新的访问令牌和刷新令牌
+func RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, "无效的请求参数: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := service.RefreshTokens(c.Request.Context(), req.RefreshToken, sessionMeta(c))
+	if err != nil {
+		if err == service.ErrInvalidRefreshToken || err == service.ErrSessionRevoked {
+			utils.Logger.Info().Err(err).Msg("刷新令牌失败")
+			utils.ErrorResponse(c, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		utils.Logger.Error().Err(err).Msg("刷新令牌失败")
+		utils.ErrorResponse(c, "刷新令牌失败，请重试", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(c, tokens, "")
+}
+
+// Logout 退出登录，吊销刷新令牌；allDevices 为 true 时退出该账户的所有登录
+func Logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, "无效的请求参数: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := service.Logout(c.Request.Context(), req.RefreshToken, req.AllDevices); err != nil {
+		if err == service.ErrInvalidRefreshToken {
+			// 令牌已失效时视为已退出
+			utils.SuccessResponse(c, nil, "已退出登录")
+			return
+		}
+		utils.Logger.Error().Err(err).Msg("退出登录失败")
+		utils.ErrorResponse(c, "退出登录失败，请重试", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(c, nil, "已退出登录")
+}
+
+// passwordUpdater 支持按ID更新的账户仓储（用户/代理商）
+type passwordUpdater interface {
+	UpdateByID(ctx context.Context, id primitive.ObjectID, update bson.M) (*repository.UpdateResult, error)
+}
+
+// rehashPassword 将旧格式的密码哈希升级为当前格式，失败不影响本次登录
+func rehashPassword(ctx context.Context, repo passwordUpdater, id primitive.ObjectID, password string, username string) {
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		utils.Logger.Error().Err(err).Str("username", username).Msg("升级密码哈希失败")
+		return
+	}
+	_, err = repo.UpdateByID(ctx, id, bson.M{"$set": bson.M{
+		"password":  hashedPassword,
+		"updatedAt": time.Now(),
+	}})
+	if err != nil {
+		utils.Logger.Error().Err(err).Str("username", username).Msg("升级密码哈希失败")
+		return
+	}
+	utils.Logger.Info().Str("username", username).Msg("已将旧格式密码升级为 bcrypt")
+}
+
 // AgentLogin 代理商登录 - 兼容旧接口
 func AgentLogin(c *gin.Context) {
 	var req struct {
@@ -226,25 +354,41 @@ func Register(c *gin.Context) {
 		Str("role", string(req.Role)).
 		Msg("用户注册请求")
 
+	if !service.RoleExists(c.Request.Context(), req.Role) {
+		utils.ErrorResponse(c, "无效的用户角色", http.StatusBadRequest)
+		return
+	}
+
+	if err := utils.ValidatePasswordStrength(req.Password, req.Username); err != nil {
+		utils.ErrorResponse(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// 检查用户名是否已存在
-	collection := repository.Collection(repository.UsersCollection)
-	var existingUser models.User
-	err := collection.FindOne(repository.GetContext(), bson.M{"username": req.Username}).Decode(&existingUser)
+	ctx := c.Request.Context()
+	_, err := repository.Users().FindByUsername(ctx, req.Username)
 	if err == nil {
 		utils.Logger.Info().Str("username", req.Username).Msg("注册失败: 用户名已存在")
 		utils.ErrorResponse(c, "用户名已存在", http.StatusConflict)
 		return
-	} else if err != mongo.ErrNoDocuments {
+	} else if err != repository.ErrNotFound {
 		utils.Logger.Error().Err(err).Msg("检查用户名是否存在时出错")
 		utils.ErrorResponse(c, "注册失败: 数据库错误", http.StatusInternalServerError)
 		return
 	}
 
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("生成密码哈希失败")
+		utils.ErrorResponse(c, "注册失败，请重试", http.StatusInternalServerError)
+		return
+	}
+
 	// 创建新用户(待审批状态)
 	now := time.Now()
 	newUser := models.User{
 		Username:  req.Username,
-		Password:  utils.HashPassword(req.Password),
+		Password:  hashedPassword,
 		Phone:     req.Phone,
 		Role:      req.Role,
 		Status:    models.UserStatusPENDING,
@@ -258,7 +402,7 @@ func Register(c *gin.Context) {
 		Msg("准备创建新用户")
 
 	// 插入用户
-	result, err := collection.InsertOne(repository.GetContext(), newUser)
+	insertedID, err := repository.Users().Insert(ctx, &newUser)
 	if err != nil {
 		utils.Logger.Error().Err(err).Msg("创建用户失败")
 
@@ -274,12 +418,11 @@ func Register(c *gin.Context) {
 
 	// 验证是否成功插入
 	utils.Logger.Info().
-		Interface("insertedId", result.InsertedID).
+		Str("insertedId", insertedID.Hex()).
 		Msg("用户创建结果")
 
 	// 额外验证：检查用户是否真的被插入
-	var verifyUser models.User
-	err = collection.FindOne(repository.GetContext(), bson.M{"username": req.Username}).Decode(&verifyUser)
+	verifyUser, err := repository.Users().FindByUsername(ctx, req.Username)
 	if err != nil {
 		utils.Logger.Error().Err(err).Msg("用户创建验证失败")
 		utils.ErrorResponse(c, "注册失败: 无法验证用户是否已创建", http.StatusInternalServerError)
@@ -314,26 +457,37 @@ func AgentRegister(c *gin.Context) {
 		Str("contactPerson", req.ContactPerson).
 		Msg("代理商注册请求")
 
+	if err := utils.ValidatePasswordStrength(req.Password, req.CompanyName); err != nil {
+		utils.ErrorResponse(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// 检查公司名是否已存在
-	collection := repository.Collection(repository.AgentsCollection)
-	var existingAgent models.Agent
-	err := collection.FindOne(repository.GetContext(), bson.M{"companyName": req.CompanyName}).Decode(&existingAgent)
+	ctx := c.Request.Context()
+	_, err := repository.Agents().FindByCompanyName(ctx, req.CompanyName)
 	if err == nil {
 		utils.Logger.Info().Str("companyName", req.CompanyName).Msg("注册失败: 代理商公司名已存在")
 		utils.ErrorResponse(c, "代理商公司名已存在", http.StatusConflict)
 		return
-	} else if err != mongo.ErrNoDocuments {
+	} else if err != repository.ErrNotFound {
 		utils.Logger.Error().Err(err).Msg("检查代理商公司名是否存在时出错")
 		utils.ErrorResponse(c, "注册失败: 数据库错误", http.StatusInternalServerError)
 		return
 	}
 
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("生成密码哈希失败")
+		utils.ErrorResponse(c, "注册失败，请重试", http.StatusInternalServerError)
+		return
+	}
+
 	// 创建新代理商(待审批状态)
 	now := time.Now()
 	newAgent := models.Agent{
 		CompanyName:   req.CompanyName,
 		ContactPerson: req.ContactPerson,
-		Password:      utils.HashPassword(req.Password),
+		Password:      hashedPassword,
 		Phone:         req.Phone,
 		Status:        models.UserStatusPENDING,
 		CreatedAt:     now,
@@ -346,7 +500,7 @@ func AgentRegister(c *gin.Context) {
 		Msg("准备创建新代理商")
 
 	// 插入代理商
-	result, err := collection.InsertOne(repository.GetContext(), newAgent)
+	insertedID, err := repository.Agents().Insert(ctx, &newAgent)
 	if err != nil {
 		utils.Logger.Error().Err(err).Msg("创建代理商失败")
 
@@ -361,7 +515,7 @@ func AgentRegister(c *gin.Context) {
 	}
 
 	utils.Logger.Info().
-		Interface("insertedId", result.InsertedID).
+		Str("insertedId", insertedID.Hex()).
 		Msg("代理商创建结果")
 
 	utils.SuccessResponse(c, nil, "代理商注册申请已提交，请等待审批", http.StatusCreated)
@@ -393,9 +547,7 @@ func ValidateToken(c *gin.Context) {
 
 	if user.Role != string(models.UserRoleAGENT) {
 		// 检查账户是否存在
-		var modelsUser models.User
-		collection := repository.Collection(repository.UsersCollection)
-		err = collection.FindOne(repository.GetContext(), bson.M{"_id": userID}).Decode(&modelsUser)
+		modelsUser, err := repository.Users().FindByID(c.Request.Context(), userID)
 		if err != nil {
 			utils.ErrorResponse(c, "查询用户失败", http.StatusBadRequest)
 			return
@@ -413,9 +565,7 @@ func ValidateToken(c *gin.Context) {
 		}}, "")
 	} else {
 		// 检查代理商是否存在
-		var modelsAgent models.Agent
-		collection := repository.Collection(repository.AgentsCollection)
-		err = collection.FindOne(repository.GetContext(), bson.M{"_id": userID}).Decode(&modelsAgent)
+		modelsAgent, err := repository.Agents().FindByID(c.Request.Context(), userID)
 		if err != nil {
 			utils.ErrorResponse(c, "查询代理商失败", http.StatusBadRequest)
 			return
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/config"
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/service"
+	"github.com/BerniceZTT/crm_end/utils"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// postLogin 调用登录接口
+func postLogin(body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/auth/login", Login)
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// seedLegacyUser 保存一个旧格式（无盐 SHA-256）密码的已审核用户
+func seedLegacyUser(t *testing.T, password string) *models.User {
+	t.Helper()
+	repository.SetRepositories(repository.NewMemoryRepositories())
+	utils.InitAuth(config.AuthConfig{
+		JWTKey:          "test-secret",
+		AccessTokenTTL:  config.Duration(time.Hour),
+		RefreshTokenTTL: config.Duration(24 * time.Hour),
+	})
+	hash := sha256.Sum256([]byte(password))
+	user := &models.User{
+		Username: "sales",
+		Password: hex.EncodeToString(hash[:]),
+		Role:     models.UserRoleFACTORY_SALES,
+		Status:   models.UserStatusAPPROVED,
+	}
+	id, err := repository.Users().Insert(context.Background(), user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.ID = id
+	return user
+}
+
+func TestLoginRehashesLegacyPassword(t *testing.T) {
+	user := seedLegacyUser(t, "secret123")
+	legacyHash := user.Password
+
+	if recorder := postLogin(`{"username":"sales","password":"wrong123"}`); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("密码错误: status = %d", recorder.Code)
+	}
+	stored, err := repository.Users().FindByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Password != legacyHash {
+		t.Fatal("密码错误时不应升级哈希")
+	}
+
+	recorder := postLogin(`{"username":"sales","password":"secret123"}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", recorder.Code, recorder.Body)
+	}
+	if strings.Contains(recorder.Body.String(), legacyHash) {
+		t.Fatal("响应中不应包含密码哈希")
+	}
+	stored, err = repository.Users().FindByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if utils.DetectPasswordScheme(stored.Password) != utils.PasswordSchemeBcrypt {
+		t.Fatalf("登录后密码应升级为 bcrypt: %s", stored.Password)
+	}
+	if cost, _ := bcrypt.Cost([]byte(stored.Password)); cost != utils.PasswordHashCost {
+		t.Fatalf("bcrypt cost = %d, want %d", cost, utils.PasswordHashCost)
+	}
+	if ok, needsRehash := utils.VerifyPassword("secret123", stored.Password); !ok || needsRehash {
+		t.Fatalf("VerifyPassword = (%v, %v)", ok, needsRehash)
+	}
+
+	// 登录成功后失败计数已清除
+	if remaining, _ := service.CheckLoginAllowed(context.Background(), "sales", "192.0.2.1"); remaining != 0 {
+		t.Fatalf("remaining = %s", remaining)
+	}
+}
+
+func TestLoginLockedAccount(t *testing.T) {
+	user := seedLegacyUser(t, "secret123")
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		if _, err := service.RecordLoginFailure(ctx, "sales", "192.0.2.1", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 锁定期内即使密码正确也拒绝登录，且不升级密码
+	recorder := postLogin(`{"username":"sales","password":"secret123"}`)
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", recorder.Code)
+	}
+	if recorder.Header().Get("Retry-After") == "" {
+		t.Fatal("缺少 Retry-After 响应头")
+	}
+	stored, err := repository.Users().FindByID(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Password != user.Password {
+		t.Fatal("锁定期内不应升级密码")
+	}
+
+	if _, err := service.UnlockLogin(ctx, "sales"); err != nil {
+		t.Fatal(err)
+	}
+	if recorder := postLogin(`{"username":"sales","password":"secret123"}`); recorder.Code != http.StatusOK {
+		t.Fatalf("解锁后: status = %d", recorder.Code)
+	}
+}
@@ -5,7 +5,6 @@ import (
 
 	"github.com/gin-gonic/gin"
 
-	"github.com/BerniceZTT/crm_end/repository"
 	"github.com/BerniceZTT/crm_end/service"
 	"github.com/BerniceZTT/crm_end/utils"
 )
@@ -34,6 +33,6 @@ func AssignCustomer(c *gin.Context) {
 		return
 	}
 
-	_, code, ginH := service.AssignCustomer(repository.GetContext(), c, customerId, assignRequest, user)
+	_, code, ginH := service.AssignCustomer(c.Request.Context(), customerId, assignRequest, user)
 	c.JSON(code, ginH)
 }
@@ -2,14 +2,11 @@ package controllers
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"math/rand"
 	"net/http"
-	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -17,15 +14,28 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/pagination"
+	"github.com/BerniceZTT/crm_end/policy"
 	"github.com/BerniceZTT/crm_end/repository"
 	"github.com/BerniceZTT/crm_end/service"
 	"github.com/BerniceZTT/crm_end/utils"
 )
 
+// customerListSort 客户列表可排序的字段
+var customerListSort = pagination.Spec{
+	SortFields: map[string]string{
+		"lastUpdateTime":     "lastUpdateTime",
+		"createdAt":          "createdAt",
+		"initialContactTime": "initialContactTime",
+		"name":               "name",
+		"annualDemand":       "annualDemand",
+	},
+	DefaultSort:  "lastUpdateTime",
+	DefaultOrder: -1,
+}
+
 // GetCustomerList 获取客户列表
 func GetCustomerList(c *gin.Context) {
 	// 获取当前用户信息
@@ -34,110 +44,39 @@ func GetCustomerList(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
-	keyword := c.Query("keyword")
-	nature := c.Query("nature")
-	importance := c.Query("importance")
 	isInPublicPool := c.Query("isInPublicPool")
-	progress := c.Query("progress")
-	relatedSalesId := c.Query("relatedSalesId")
-	relatedAgentId := c.Query("relatedAgentId")
-
-	pageStr := c.DefaultQuery("page", "1")
-	limitStr := c.DefaultQuery("limit", "10")
 
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
-	}
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit < 1 {
-		limit = 10
+	pageReq, err := pagination.FromQuery(c.Request.URL.Query(), customerListSort)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
 	}
-	// skip := (page - 1) * limit
 
 	utils.LogInfo(map[string]interface{}{
 		"user":           user.Username,
-		"page":           page,
-		"limit":          limit,
-		"keyword":        keyword,
-		"nature":         nature,
-		"importance":     importance,
-		"progress":       progress,
+		"page":           pageReq.Page,
+		"limit":          pageReq.Limit,
+		"sortBy":         pageReq.SortBy,
+		"keyword":        c.Query("keyword"),
+		"nature":         c.Query("nature"),
+		"importance":     c.Query("importance"),
+		"progress":       c.Query("progress"),
 		"isInPublicPool": isInPublicPool,
 	}, "获取客户列表")
 
-	filter := bson.M{}
-
-	if keyword != "" {
-		filter["$or"] = []bson.M{
-			{"name": bson.M{"$regex": keyword, "$options": "i"}},
-			{"contactperson": bson.M{"$regex": keyword, "$options": "i"}},
-			{"applicationfield": bson.M{"$regex": keyword, "$options": "i"}},
-		}
-	}
-
-	if nature != "" {
-		filter["nature"] = nature
-	}
-
-	if importance != "" {
-		filter["importance"] = importance
-	}
-
-	filter["isInPublicPool"] = isInPublicPool == "true"
-
-	if models.UserRole(user.Role) == models.UserRoleSUPER_ADMIN {
-		// 超级管理员可以查看所有客户
-	} else if models.UserRole(user.Role) == models.UserRoleFACTORY_SALES {
-		if isInPublicPool != "true" {
-			filter["relatedSalesId"] = user.ID
-		}
-	} else if models.UserRole(user.Role) == models.UserRoleAGENT {
-		if isInPublicPool != "true" {
-			filter["relatedAgentId"] = user.ID
-		}
-	} else {
-		c.JSON(http.StatusForbidden, gin.H{"error": "无权访问客户数据"})
-		return
-	}
-	if relatedSalesId != "" {
-		filter["relatedSalesId"] = relatedSalesId
-
-	}
-	if relatedAgentId != "" {
-		filter["relatedAgentId"] = relatedAgentId
-	}
-
-	if progress != "" {
-		filter["progress"] = progress
-	}
-
-	ctx := repository.GetContext()
-	collection := repository.Collection(repository.CustomersCollection)
-
-	totalCount, err := collection.CountDocuments(ctx, filter)
+	filter, err := customerListFilter(c, user)
 	if err != nil {
-		utils.HandleError(c, err)
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权访问客户数据"})
 		return
 	}
 
-	findOptions := options.Find()
-	findOptions.SetSort(bson.M{"lastupdatetime": -1})
-	// findOptions.SetSkip(int64(skip))
-	// findOptions.SetLimit(int64(limit))
-
-	cursor, err := collection.Find(ctx, filter, findOptions)
+	ctx := c.Request.Context()
+	result, err := pagination.Find(ctx, repository.Customers(), filter, pageReq)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
-	defer cursor.Close(ctx)
-
-	var customers []models.Customer
-	if err := cursor.All(ctx, &customers); err != nil {
-		utils.HandleError(c, err)
-		return
-	}
+	customers := result.Items
 
 	if len(customers) > 0 && isInPublicPool != "true" {
 		salesIds := make(map[string]bool)
@@ -159,60 +98,22 @@ func GetCustomerList(c *gin.Context) {
 			}
 		}
 
-		salesMap := make(map[string]string)
-		if len(salesIds) > 0 {
-			salesIdsArray := make([]primitive.ObjectID, 0, len(salesIds))
-			for id := range salesIds {
-				objectID, err := primitive.ObjectIDFromHex(id)
-				if err == nil {
-					salesIdsArray = append(salesIdsArray, objectID)
-				}
-			}
-
-			usersCollection := repository.Collection(repository.UsersCollection)
-			usersCursor, err := usersCollection.Find(ctx, bson.M{"_id": bson.M{"$in": salesIdsArray}},
-				options.Find().SetProjection(bson.M{"_id": 1, "username": 1}))
-
-			if err == nil {
-				defer usersCursor.Close(ctx)
-				var salesUsers []struct {
-					ID       primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
-					Username string             `bson:"username"`
-				}
-				if err := usersCursor.All(ctx, &salesUsers); err == nil {
-					for _, user := range salesUsers {
-						salesMap[user.ID.Hex()] = user.Username
-					}
-				}
-			}
+		salesIdsArray := make([]string, 0, len(salesIds))
+		for id := range salesIds {
+			salesIdsArray = append(salesIdsArray, id)
+		}
+		salesMap, err := repository.Users().NamesByIDs(ctx, salesIdsArray)
+		if err != nil {
+			salesMap = make(map[string]string)
 		}
 
-		agentMap := make(map[string]string)
-		if len(agentIds) > 0 {
-			agentIdsArray := make([]primitive.ObjectID, 0, len(agentIds))
-			for id := range agentIds {
-				objectID, err := primitive.ObjectIDFromHex(id)
-				if err == nil {
-					agentIdsArray = append(agentIdsArray, objectID)
-				}
-			}
-
-			agentsCollection := repository.Collection(repository.AgentsCollection)
-			agentsCursor, err := agentsCollection.Find(ctx, bson.M{"_id": bson.M{"$in": agentIdsArray}},
-				options.Find().SetProjection(bson.M{"_id": 1, "companyName": 1}))
-
-			if err == nil {
-				defer agentsCursor.Close(ctx)
-				var agentUsers []struct {
-					ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
-					CompanyName string             `bson:"companyName"`
-				}
-				if err := agentsCursor.All(ctx, &agentUsers); err == nil {
-					for _, agent := range agentUsers {
-						agentMap[agent.ID.Hex()] = agent.CompanyName
-					}
-				}
-			}
+		agentIdsArray := make([]string, 0, len(agentIds))
+		for id := range agentIds {
+			agentIdsArray = append(agentIdsArray, id)
+		}
+		agentMap, err := repository.Agents().NamesByIDs(ctx, agentIdsArray)
+		if err != nil {
+			agentMap = make(map[string]string)
 		}
 
 		for i := range customers {
@@ -264,20 +165,63 @@ func GetCustomerList(c *gin.Context) {
 
 	utils.LogInfo(map[string]interface{}{
 		"count": len(customers),
-		"total": totalCount,
-		"page":  page,
-		"limit": limit,
+		"total": result.Pagination.Total,
+		"page":  pageReq.Page,
+		"limit": pageReq.Limit,
 	}, "成功获取客户列表")
 
-	c.JSON(http.StatusOK, gin.H{
-		"customers": customers,
-		"pagination": gin.H{
-			"total": totalCount,
-			"page":  page,
-			"limit": limit,
-			"pages": (totalCount + int64(limit) - 1) / int64(limit),
-		},
-	})
+	utils.PaginatedResponse(c, customers, result.Pagination)
+}
+
+// customerListFilter 按查询参数构建客户列表的查询条件，客户列表和客户导出共用
+// 非公海列表仅返回归属于当前用户的客户
+func customerListFilter(c *gin.Context, user *utils.LoginUser) (bson.M, error) {
+	keyword := c.Query("keyword")
+	nature := c.Query("nature")
+	importance := c.Query("importance")
+	isInPublicPool := c.Query("isInPublicPool")
+	progress := c.Query("progress")
+	relatedSalesId := c.Query("relatedSalesId")
+	relatedAgentId := c.Query("relatedAgentId")
+
+	filter := bson.M{}
+
+	if keyword != "" {
+		filter["$or"] = []bson.M{
+			{"name": bson.M{"$regex": keyword, "$options": "i"}},
+			{"contactPerson": bson.M{"$regex": keyword, "$options": "i"}},
+			{"applicationField": bson.M{"$regex": keyword, "$options": "i"}},
+		}
+	}
+
+	if nature != "" {
+		filter["nature"] = nature
+	}
+
+	if importance != "" {
+		filter["importance"] = importance
+	}
+
+	filter["isInPublicPool"] = isInPublicPool == "true"
+
+	scope, err := policy.OwnerFilter(user)
+	if err != nil {
+		return nil, err
+	}
+	if isInPublicPool == "true" {
+		scope = nil
+	}
+	if relatedSalesId != "" {
+		filter["relatedSalesId"] = relatedSalesId
+	}
+	if relatedAgentId != "" {
+		filter["relatedAgentId"] = relatedAgentId
+	}
+
+	if progress != "" {
+		filter["progress"] = progress
+	}
+	return policy.Merge(filter, scope), nil
 }
 
 // CheckDuplicateCustomer 查重检查客户
@@ -313,8 +257,7 @@ func CheckDuplicateCustomer(c *gin.Context) {
 		"customerNames": customerNames,
 	}, "批量检查客户重复")
 
-	ctx := repository.GetContext()
-	collection := repository.Collection(repository.CustomersCollection)
+	ctx := c.Request.Context()
 
 	// 查询存在的客户
 	filter := bson.M{
@@ -322,23 +265,28 @@ func CheckDuplicateCustomer(c *gin.Context) {
 		"progress": models.CustomerProgressNormal,
 	}
 
-	cursor, err := collection.Find(ctx, filter)
+	existingCustomers, err := repository.Customers().Find(ctx, filter)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
-	defer cursor.Close(ctx)
 
-	var existingCustomers []models.Customer
-	if err = cursor.All(ctx, &existingCustomers); err != nil {
+	// 模糊查重：规范化名称、相似名称
+	rows := make([]service.DuplicateInput, len(customerNames))
+	for i, name := range customerNames {
+		rows[i] = service.DuplicateInput{Name: name}
+	}
+	fuzzyMatches, err := service.FindBulkDuplicates(ctx, rows, service.DuplicateHintScore)
+	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
 	if len(existingCustomers) == 0 {
 		c.JSON(http.StatusOK, gin.H{
-			"exists":     false,
-			"duplicates": []string{},
+			"exists":       false,
+			"duplicates":   []string{},
+			"fuzzyMatches": fuzzyMatches,
 		})
 		return
 	}
@@ -350,9 +298,10 @@ func CheckDuplicateCustomer(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"exists":     true,
-		"duplicates": duplicateNames,
-		"customers":  existingCustomers,
+		"exists":       true,
+		"duplicates":   duplicateNames,
+		"customers":    existingCustomers,
+		"fuzzyMatches": fuzzyMatches,
 	})
 }
 
@@ -383,24 +332,29 @@ func CreateCustomer(c *gin.Context) {
 		"relatedAgentId": requestData.RelatedAgentID,
 	}, "创建客户")
 
-	ctx := repository.GetContext()
-	collection := repository.Collection(repository.CustomersCollection)
-	usersCollection := repository.Collection(repository.UsersCollection)
-	agentsCollection := repository.Collection(repository.AgentsCollection)
+	ctx := c.Request.Context()
 
-	existingCustomer := struct {
-		ID string `json:"_id,omitempty" bson:"_id,omitempty"`
-	}{}
-	err1 := collection.FindOne(ctx, bson.M{"name": requestData.Name, "progress": models.CustomerProgressNormal}).Decode(&existingCustomer)
-	if err1 == nil {
+	exists, err := repository.Customers().ExistsByNameAndProgress(ctx, requestData.Name, models.CustomerProgressNormal)
+	if err == nil && exists {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "客户名称已存在"})
 		return
 	}
 
-	var salesUser struct {
-		ID       primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
-		Username string             `bson:"username"`
+	// 模糊查重：达到拦截分数的需确认后（ignoreDuplicates）才能创建
+	duplicateCandidates, err := service.FindDuplicateCandidates(ctx, requestData.Name, requestData.ContactPhone, service.DuplicateHintScore)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	if !requestData.IgnoreDuplicates && service.HasBlockingDuplicate(duplicateCandidates) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":      "存在疑似重复的客户，请确认后再创建",
+			"code":       "DUPLICATE_CUSTOMER",
+			"candidates": duplicateCandidates,
+		})
+		return
 	}
+
 	var salesUserName string
 	if requestData.RelatedSalesID != "" {
 		salesID, err := primitive.ObjectIDFromHex(requestData.RelatedSalesID)
@@ -409,7 +363,7 @@ func CreateCustomer(c *gin.Context) {
 			return
 		}
 
-		err = usersCollection.FindOne(ctx, bson.M{"_id": salesID}).Decode(&salesUser)
+		salesUser, err := repository.Users().FindByID(ctx, salesID)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "找不到关联销售"})
 			return
@@ -417,10 +371,6 @@ func CreateCustomer(c *gin.Context) {
 		salesUserName = salesUser.Username
 	}
 
-	var agentInfo struct {
-		ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
-		CompanyName string             `bson:"companyName"`
-	}
 	var agentCompanyName string
 	if requestData.RelatedAgentID != "" {
 		agentID, err := primitive.ObjectIDFromHex(requestData.RelatedAgentID)
@@ -429,7 +379,7 @@ func CreateCustomer(c *gin.Context) {
 			return
 		}
 
-		err = agentsCollection.FindOne(ctx, bson.M{"_id": agentID}).Decode(&agentInfo)
+		agentInfo, err := repository.Agents().FindByID(ctx, agentID)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "找不到关联代理商"})
 			return
@@ -465,7 +415,7 @@ func CreateCustomer(c *gin.Context) {
 		UpdatedAt:          now,
 	}
 
-	_, err = collection.InsertOne(ctx, newCustomer)
+	_, err = repository.Customers().Insert(ctx, &newCustomer)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
@@ -500,14 +450,21 @@ func CreateCustomer(c *gin.Context) {
 		}
 	}
 
+	if err := service.RecordProgressTransition(ctx, &newCustomer, "", newCustomer.Progress, user, "新建客户"); err != nil {
+		utils.LogError(err, map[string]interface{}{
+			"customerId": newCustomer.ID.Hex(),
+		}, "添加客户进展历史失败")
+	}
+
 	utils.LogInfo(map[string]interface{}{
 		"id":   newCustomer.ID.Hex(),
 		"name": newCustomer.Name,
 	}, "客户创建成功")
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message":  "创建客户成功",
-		"customer": newCustomer,
+		"message":             "创建客户成功",
+		"customer":            newCustomer,
+		"duplicateCandidates": duplicateCandidates,
 	})
 }
 
@@ -537,6 +494,7 @@ func BulkImportCustomers(c *gin.Context) {
 			RelatedAgentName string   `json:"relatedAgentName"`
 			RelatedAgentId   string   `json:"relatedAgentId"`
 		} `json:"customers"`
+		IgnoreDuplicates bool `json:"ignoreDuplicates"` // 确认疑似重复后仍然导入
 	}
 
 	if err := c.ShouldBindJSON(&requestData); err != nil {
@@ -560,12 +518,7 @@ func BulkImportCustomers(c *gin.Context) {
 		"count":    len(requestData.Customers),
 	}, "开始批量导入客户")
 
-	ctx := context.Background()
-	db := repository.GetDB()
-	customersCollection := db.Collection("customers")
-	usersCollection := db.Collection("users")
-	agentsCollection := db.Collection("agents")
-	productsCollection := db.Collection("products")
+	ctx := c.Request.Context()
 
 	// 验证产品需求
 	utils.LogInfo(nil, "开始验证产品需求合法性...")
@@ -595,21 +548,12 @@ func BulkImportCustomers(c *gin.Context) {
 			productNameArray = append(productNameArray, name)
 		}
 
-		cursor, err := productsCollection.Find(ctx, bson.M{"modelName": bson.M{"$in": productNameArray}},
-			options.Find().SetProjection(bson.M{"_id": 1, "modelName": 1}))
+		existingProducts, err := repository.Products().Find(ctx, bson.M{"modelName": bson.M{"$in": productNameArray}},
+			repository.NewFindOptions().SetProjection(bson.M{"_id": 1, "modelName": 1}))
 		if err != nil {
 			utils.HandleError(c, err)
 			return
 		}
-		defer cursor.Close(ctx)
-
-		var existingProducts []struct {
-			ModelName string `bson:"modelName"`
-		}
-		if err := cursor.All(ctx, &existingProducts); err != nil {
-			utils.HandleError(c, err)
-			return
-		}
 
 		existingProductNames := make(map[string]bool)
 		for _, product := range existingProducts {
@@ -647,21 +591,12 @@ func BulkImportCustomers(c *gin.Context) {
 		customerNames[i] = customer.Name
 	}
 
-	cursor, err := customersCollection.Find(ctx, bson.M{"name": bson.M{"$in": customerNames}},
-		options.Find().SetProjection(bson.M{"name": 1}))
+	existingCustomers, err := repository.Customers().Find(ctx, bson.M{"name": bson.M{"$in": customerNames}},
+		repository.NewFindOptions().SetProjection(bson.M{"name": 1}))
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
-	defer cursor.Close(ctx)
-
-	var existingCustomers []struct {
-		Name string `bson:"name"`
-	}
-	if err := cursor.All(ctx, &existingCustomers); err != nil {
-		utils.HandleError(c, err)
-		return
-	}
 
 	if len(existingCustomers) > 0 {
 		duplicateNames := make([]string, len(existingCustomers))
@@ -675,22 +610,59 @@ func BulkImportCustomers(c *gin.Context) {
 		return
 	}
 
+	// 模糊查重：与已有客户及同批次其他行比对
+	duplicateRows := make([]service.DuplicateInput, len(requestData.Customers))
+	for i, customer := range requestData.Customers {
+		duplicateRows[i] = service.DuplicateInput{Name: customer.Name, Phone: customer.ContactPhone}
+	}
+	fuzzyDuplicates, err := service.FindBulkDuplicates(ctx, duplicateRows, service.DuplicateHintScore)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	if !requestData.IgnoreDuplicates {
+		blocking := []models.BulkImportDuplicate{}
+		for _, duplicate := range fuzzyDuplicates {
+			if service.HasBlockingDuplicate(duplicate.Candidates) {
+				blocking = append(blocking, duplicate)
+			}
+		}
+		if len(blocking) > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":      fmt.Sprintf("%d 行数据存在疑似重复的客户，请确认后再导入", len(blocking)),
+				"code":       "DUPLICATE_CUSTOMER",
+				"duplicates": blocking,
+			})
+			return
+		}
+	}
+
+	// 校验进展状态，未填写时默认为初步接触
+	for i := range requestData.Customers {
+		customer := &requestData.Customers[i]
+		if customer.Progress == "" {
+			customer.Progress = models.CustomerProgressInitialContact
+		}
+		if err := service.ValidateProgressTransition("", customer.Progress); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("第%d行数据中%s", i+1, err.Error()),
+			})
+			return
+		}
+	}
+
 	// 将名称转换为ID
 	for i := range requestData.Customers {
 		customer := &requestData.Customers[i]
 
 		// 将销售名称转换为销售ID
 		if customer.RelatedSalesName != "" && customer.RelatedSalesId == "" {
-			var salesUser struct {
-				ID       primitive.ObjectID `bson:"_id"`
-				Username string             `bson:"username"`
-			}
-			err := usersCollection.FindOne(ctx, bson.M{
+			salesUser, err := repository.Users().FindOne(ctx, bson.M{
 				"username": customer.RelatedSalesName,
 				"role":     models.UserRoleFACTORY_SALES,
-			}).Decode(&salesUser)
+			})
 			if err != nil {
-				if err == mongo.ErrNoDocuments {
+				if err == repository.ErrNotFound {
 					c.JSON(http.StatusBadRequest, gin.H{
 						"error": fmt.Sprintf("第%d行数据中销售名称'%s'不存在", i+1, customer.RelatedSalesName),
 					})
@@ -708,15 +680,9 @@ func BulkImportCustomers(c *gin.Context) {
 
 		// 将代理商名称转换为代理商ID
 		if customer.RelatedAgentName != "" && customer.RelatedAgentId == "" {
-			var agent struct {
-				ID          primitive.ObjectID `bson:"_id"`
-				CompanyName string             `bson:"companyName"`
-			}
-			err := agentsCollection.FindOne(ctx, bson.M{
-				"companyName": customer.RelatedAgentName,
-			}).Decode(&agent)
+			agent, err := repository.Agents().FindByCompanyName(ctx, customer.RelatedAgentName)
 			if err != nil {
-				if err == mongo.ErrNoDocuments {
+				if err == repository.ErrNotFound {
 					c.JSON(http.StatusBadRequest, gin.H{
 						"error": fmt.Sprintf("第%d行数据中代理商名称'%s'不存在", i+1, customer.RelatedAgentName),
 					})
@@ -735,7 +701,7 @@ func BulkImportCustomers(c *gin.Context) {
 
 	// 准备要插入的客户数据
 	now := time.Now()
-	customersToInsert := make([]interface{}, len(requestData.Customers))
+	customersToInsert := make([]models.Customer, len(requestData.Customers))
 	for i, customer := range requestData.Customers {
 		customersToInsert[i] = models.Customer{
 			ID:               primitive.NewObjectID(),
@@ -763,32 +729,32 @@ func BulkImportCustomers(c *gin.Context) {
 	}
 
 	// 批量插入客户数据
-	result, err := customersCollection.InsertMany(ctx, customersToInsert)
+	insertedIDs, err := repository.Customers().InsertMany(ctx, customersToInsert)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
-	if len(result.InsertedIDs) != len(requestData.Customers) {
+	if len(insertedIDs) != len(requestData.Customers) {
 		utils.LogError(nil, map[string]interface{}{
-			"insertedCount": len(result.InsertedIDs),
+			"insertedCount": len(insertedIDs),
 			"expectedCount": len(requestData.Customers),
 		}, "批量插入客户失败")
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":         "批量导入客户失败，请重试",
-			"insertedCount": len(result.InsertedIDs),
+			"insertedCount": len(insertedIDs),
 			"expectedCount": len(requestData.Customers),
 		})
 		return
 	}
 
 	utils.LogInfo(map[string]interface{}{
-		"count": len(result.InsertedIDs),
+		"count": len(insertedIDs),
 	}, "成功插入客户数据")
 
 	// 添加分配历史和进展历史
 	for i, customer := range requestData.Customers {
-		customerId := result.InsertedIDs[i].(primitive.ObjectID).Hex()
+		customerId := insertedIDs[i].Hex()
 
 		// 如果有分配信息，则添加分配历史
 		if customer.RelatedSalesId != "" || customer.RelatedAgentId != "" {
@@ -821,12 +787,18 @@ func BulkImportCustomers(c *gin.Context) {
 			}
 		}
 
+		if err := service.RecordProgressTransition(ctx, &customersToInsert[i], "", customer.Progress, user, "批量导入"); err != nil {
+			utils.LogError(err, map[string]interface{}{
+				"customerId": customerId,
+			}, "添加客户进展历史失败")
+		}
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message":       fmt.Sprintf("成功导入 %d 个客户", len(requestData.Customers)),
-		"insertedCount": len(requestData.Customers),
-		"success":       true,
+		"message":         fmt.Sprintf("成功导入 %d 个客户", len(requestData.Customers)),
+		"insertedCount":   len(requestData.Customers),
+		"success":         true,
+		"fuzzyDuplicates": fuzzyDuplicates,
 	})
 }
 
@@ -851,26 +823,17 @@ func GetCustomerDetail(c *gin.Context) {
 		return
 	}
 
-	ctx := repository.GetContext()
-	collection := repository.Collection(repository.CustomersCollection)
+	ctx := c.Request.Context()
 
-	var customer models.Customer
-	err = collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&customer)
+	customer, err := repository.Customers().FindByID(ctx, objectID)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
-	if models.UserRole(user.Role) != models.UserRoleSUPER_ADMIN && !customer.IsInPublicPool {
-		canAccess := (models.UserRole(user.Role) == models.UserRoleFACTORY_SALES &&
-			(customer.OwnerID == user.ID || customer.RelatedSalesID == user.ID)) ||
-			(models.UserRole(user.Role) == models.UserRoleAGENT &&
-				(customer.OwnerID == user.ID || customer.RelatedAgentID == user.ID))
-
-		if !canAccess {
-			c.JSON(http.StatusForbidden, gin.H{"error": "无权查看该客户"})
-			return
-		}
+	if !policy.CanAccessCustomer(user, customer, policy.ActionRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权查看该客户"})
+		return
 	}
 
 	utils.LogInfo(map[string]interface{}{
@@ -881,6 +844,69 @@ func GetCustomerDetail(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"customer": customer})
 }
 
+// GetCustomerProgressHistory 获取客户进展状态变更历史
+func GetCustomerProgressHistory(c *gin.Context) {
+	id := c.Param("id")
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的客户ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	customer, err := repository.Customers().FindByID(ctx, objectID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "客户不存在"})
+			return
+		}
+		utils.HandleError(c, err)
+		return
+	}
+
+	if !policy.CanAccessCustomer(user, customer, policy.ActionRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权查看该客户"})
+		return
+	}
+
+	history, err := repository.CustomerProgress().FindByCustomerID(ctx, id)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.LogInfo(map[string]interface{}{
+		"customerId": id,
+		"count":      len(history),
+	}, "成功获取客户进展历史")
+
+	c.JSON(http.StatusOK, gin.H{"history": history})
+}
+
+// customerEditableFields 编辑客户时允许客户端修改的字段
+// 关联销售和代理商的名称按 ID 查询填写，其余字段（公海快照、原归属、删除标记、时间戳等）只能通过对应操作修改
+var customerEditableFields = map[string]bool{
+	"name":             true,
+	"nature":           true,
+	"importance":       true,
+	"applicationField": true,
+	"productNeeds":     true,
+	"contactPerson":    true,
+	"contactPhone":     true,
+	"address":          true,
+	"progress":         true,
+	"annualDemand":     true,
+	"relatedSalesId":   true,
+	"relatedAgentId":   true,
+}
+
 // UpdateCustomer 更新客户
 func UpdateCustomer(c *gin.Context) {
 	id := c.Param("id")
@@ -908,25 +934,23 @@ func UpdateCustomer(c *gin.Context) {
 		return
 	}
 
-	ctx := repository.GetContext()
-	collection := repository.Collection(repository.CustomersCollection)
+	ctx := c.Request.Context()
 
-	var customer models.Customer
-	err = collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&customer)
+	customer, err := repository.Customers().FindByID(ctx, objectID)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
-	if models.UserRole(user.Role) != models.UserRoleSUPER_ADMIN && !customer.IsInPublicPool {
-		canAccess := (models.UserRole(user.Role) == models.UserRoleFACTORY_SALES &&
-			(customer.OwnerID == user.ID || customer.RelatedSalesID == user.ID)) ||
-			(models.UserRole(user.Role) == models.UserRoleAGENT &&
-				(customer.OwnerID == user.ID || customer.RelatedAgentID == user.ID))
+	if !policy.CanAccessCustomer(user, customer, policy.ActionUpdate) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权更新该客户"})
+		return
+	}
 
-		if !canAccess {
-			c.JSON(http.StatusForbidden, gin.H{"error": "无权更新该客户"})
-			return
+	// 只保留允许编辑的字段，归属名称、公海快照、删除标记等由服务端维护的字段一律忽略
+	for field := range updateData {
+		if !customerEditableFields[field] {
+			delete(updateData, field)
 		}
 	}
 
@@ -936,18 +960,13 @@ func UpdateCustomer(c *gin.Context) {
 		(updateData["relatedAgentId"] != nil && updateData["relatedAgentId"] != customer.RelatedAgentID)
 
 	if relatedSalesChanged {
-		usersCollection := repository.Collection(repository.UsersCollection)
 		salesID, err := primitive.ObjectIDFromHex(updateData["relatedSalesId"].(string))
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的关联销售ID"})
 			return
 		}
 
-		var salesUser struct {
-			ID       primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
-			Username string             `bson:"username"`
-		}
-		err = usersCollection.FindOne(ctx, bson.M{"_id": salesID}).Decode(&salesUser)
+		salesUser, err := repository.Users().FindByID(ctx, salesID)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "找不到关联销售"})
 			return
@@ -956,18 +975,13 @@ func UpdateCustomer(c *gin.Context) {
 	}
 
 	if relatedAgentChanged && updateData["relatedAgentId"] != nil && updateData["relatedAgentId"] != "" {
-		agentsCollection := repository.Collection(repository.AgentsCollection)
 		agentID, err := primitive.ObjectIDFromHex(updateData["relatedAgentId"].(string))
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的关联代理商ID"})
 			return
 		}
 
-		var agent struct {
-			ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
-			CompanyName string             `bson:"companyName"`
-		}
-		err = agentsCollection.FindOne(ctx, bson.M{"_id": agentID}).Decode(&agent)
+		agent, err := repository.Agents().FindByID(ctx, agentID)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "找不到关联代理商"})
 			return
@@ -978,29 +992,28 @@ func UpdateCustomer(c *gin.Context) {
 		updateData["relatedAgentId"] = ""
 	}
 
-	progressChanged := updateData
+	// 进展状态按状态机校验，移入公海需要清空关联信息，只能通过移入公海操作完成
+	progressChanged := false
+	if rawProgress, ok := updateData["progress"]; ok {
+		progress, isString := rawProgress.(string)
+		if !isString {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的客户进展状态"})
+			return
+		}
+		progressChanged = progress != customer.Progress
+		if progressChanged && progress == models.CustomerProgressPublicPool {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请使用移入公海操作将客户移入公海"})
+			return
+		}
+	}
+
 	// 更新客户数据
 	now := time.Now()
 	updateData["lastUpdateTime"] = now
 	updateData["updatedAt"] = now
 
-	// MongoDB使用$set操作符更新文档
-	result, err := collection.UpdateOne(
-		ctx,
-		bson.M{"_id": objectID},
-		bson.M{"$set": updateData},
-	)
-	if err != nil {
-		utils.HandleError(c, err)
-		return
-	}
-
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "客户不存在"})
-		return
-	}
-
-	// 记录关联变更历史
+	// 关联变更时记录分配历史
+	var history *models.CustomerAssignmentHistory
 	if relatedSalesChanged || relatedAgentChanged {
 		// 获取更新后的销售ID和名称
 		toRelatedSalesID := customer.RelatedSalesID
@@ -1023,8 +1036,7 @@ func UpdateCustomer(c *gin.Context) {
 			}
 		}
 
-		// 使用统一的方法记录分配历史
-		assignmentHistory := models.CustomerAssignmentHistory{
+		history = &models.CustomerAssignmentHistory{
 			CustomerID:           id,
 			CustomerName:         customer.Name,
 			FromRelatedSalesID:   customer.RelatedSalesID,
@@ -1039,11 +1051,39 @@ func UpdateCustomer(c *gin.Context) {
 			OperatorName:         user.Username,
 			OperationType:        "分配",
 		}
+	}
 
-		err = service.AddAssignmentHistory(ctx, assignmentHistory)
+	// 客户更新与分配历史在同一事务中写入
+	var result *repository.UpdateResult
+	err = repository.WithTransaction(ctx, func(txCtx context.Context) error {
+		var err error
+		if progressChanged {
+			current := *customer
+			result, err = service.ChangeCustomerProgress(txCtx, service.ProgressChange{
+				Customer: &current,
+				To:       updateData["progress"].(string),
+				Operator: user,
+				Remark:   "编辑客户信息",
+				Fields:   updateData,
+			})
+		} else {
+			// MongoDB使用$set操作符更新文档
+			result, err = repository.Customers().SetFields(txCtx, objectID, updateData)
+		}
 		if err != nil {
-			utils.HandleError(c, err)
+			return err
 		}
+		if result.MatchedCount == 0 {
+			return utils.CreateNotFoundError("客户")
+		}
+		if history != nil {
+			return service.AddAssignmentHistory(txCtx, *history)
+		}
+		return nil
+	})
+	if err != nil {
+		utils.HandleError(c, err)
+		return
 	}
 
 	utils.LogInfo(map[string]interface{}{
@@ -1083,58 +1123,33 @@ func DeleteCustomer(c *gin.Context) {
 	}
 
 	// 获取数据库上下文
-	ctx := repository.GetContext()
-
-	// 获取客户集合
-	collection := repository.Collection(repository.CustomersCollection)
+	ctx := c.Request.Context()
 
 	// 查询客户
-	var customer models.Customer
-	err = collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&customer)
+	customer, err := repository.Customers().FindByID(ctx, objectID)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
 	// 验证权限
-	if models.UserRole(user.Role) != models.UserRoleSUPER_ADMIN {
-		// 检查是否是自己的客户或关联的客户
-		canDelete := (models.UserRole(user.Role) == models.UserRoleFACTORY_SALES &&
-			(customer.OwnerID == user.ID || customer.RelatedSalesID == user.ID)) ||
-			(models.UserRole(user.Role) == models.UserRoleAGENT &&
-				(customer.OwnerID == user.ID || customer.RelatedAgentID == user.ID))
-
-		if !canDelete || customer.IsInPublicPool {
-			c.JSON(http.StatusForbidden, gin.H{"error": "无权删除该客户"})
-			return
-		}
-	}
-
-	// 删除客户相关的跟进记录
-	followUpCollection := repository.Collection(repository.FollowUpCollection)
-	_, err = followUpCollection.DeleteMany(ctx, bson.M{"customerid": id})
-	if err != nil {
-		utils.HandleError(c, err)
-	}
-
-	// 删除客户
-	result, err := collection.DeleteOne(ctx, bson.M{"_id": objectID})
-	if err != nil {
-		utils.HandleError(c, err)
+	if !policy.CanAccessCustomer(user, customer, policy.ActionDelete) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权删除该客户"})
 		return
 	}
 
-	if result.DeletedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "客户不存在或已被删除"})
+	// 移入回收站，项目、跟进记录和分配历史一并标记删除
+	if err := service.SoftDeleteCustomer(ctx, customer, user); err != nil {
+		utils.HandleError(c, err)
 		return
 	}
 
 	utils.LogInfo(map[string]interface{}{
 		"id":   id,
 		"name": customer.Name,
-	}, "客户删除成功")
+	}, "客户已移入回收站")
 
-	c.JSON(http.StatusOK, gin.H{"message": "客户删除成功"})
+	c.JSON(http.StatusOK, gin.H{"message": "客户已移入回收站"})
 }
 
 // MoveCustomerToPublic 将客户移入公海池
@@ -1160,97 +1175,160 @@ func MoveCustomerToPublic(c *gin.Context) {
 	}
 
 	// 获取数据库上下文
-	ctx := repository.GetContext()
-
-	// 获取客户集合
-	collection := repository.Collection(repository.CustomersCollection)
+	ctx := c.Request.Context()
 
 	// 查询客户
-	var customer models.Customer
-	err = collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&customer)
+	customer, err := repository.Customers().FindByID(ctx, objectID)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
-	// 验证权限
-	if models.UserRole(user.Role) != models.UserRoleSUPER_ADMIN {
-		// 检查是否是自己的客户或关联的客户
-		canMove := (models.UserRole(user.Role) == models.UserRoleFACTORY_SALES &&
-			(customer.OwnerID == user.ID || customer.RelatedSalesID == user.ID)) ||
-			(models.UserRole(user.Role) == models.UserRoleAGENT &&
-				(customer.OwnerID == user.ID || customer.RelatedAgentID == user.ID))
-
-		if !canMove || customer.IsInPublicPool {
-			c.JSON(http.StatusForbidden, gin.H{"error": "无权将该客户移入公海"})
+	// 验证权限：移入公海需要修改权限，且公海客户不能重复移入
+	if !policy.CanAccessCustomer(user, customer, policy.ActionUpdate) || customer.IsInPublicPool {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权将该客户移入公海"})
+		return
+	}
+
+	// 更新客户状态为公海，同时记录分配历史并隐藏客户下的项目
+	if err := service.MoveCustomerToPublicPool(ctx, customer, user, "移入公海"); err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.LogInfo(map[string]interface{}{
+		"id":   id,
+		"name": customer.Name,
+	}, "客户已成功移入公海")
+
+	c.JSON(http.StatusOK, gin.H{"message": "客户已成功移入公海"})
+}
+
+// MergeCustomers 将重复客户合并到保留客户，dryRun 时只预览将要迁移的数据
+func MergeCustomers(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req models.CustomerMergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据: " + err.Error()})
+		return
+	}
+
+	utils.LogInfo(map[string]interface{}{
+		"survivorId":   req.SurvivorID,
+		"duplicateIds": req.DuplicateIDs,
+		"dryRun":       req.DryRun,
+		"username":     user.Username,
+	}, "合并客户")
+
+	result, err := service.MergeCustomers(c.Request.Context(), req, user)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	message := "客户合并成功"
+	if req.DryRun {
+		message = "客户合并预览"
+	}
+	utils.SuccessResponse(c, result, message)
+}
+
+// GetCustomerDuplicateReport 扫描全部客户生成疑似重复分组报告
+func GetCustomerDuplicateReport(c *gin.Context) {
+	minScore := service.DuplicateHintScore
+	if raw := c.Query("minScore"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 || value > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "minScore 必须是 1~100 的整数"})
 			return
 		}
+		minScore = value
 	}
 
-	// 更新客户状态为公海
-	now := time.Now()
-	updateResult, err := collection.UpdateOne(
-		ctx,
-		bson.M{"_id": objectID},
-		bson.M{"$set": bson.M{
-			"isInPublicPool":   true,
-			"progress":         models.CustomerProgressPublicPool,
-			"relatedSalesId":   nil,
-			"relatedSalesName": nil,
-			"relatedAgentId":   nil,
-			"relatedAgentName": nil,
-			"contactperson":    "",
-			"contactphone":     "",
-			"lastupdatetime":   now,
-			"updatedAt":        now,
-		}},
-	)
+	report, err := service.BuildDuplicateReport(c.Request.Context(), minScore)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
-	if updateResult.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "客户不存在或已被修改"})
+	utils.LogInfo(map[string]interface{}{
+		"scanned":  report.Scanned,
+		"groups":   len(report.Groups),
+		"minScore": minScore,
+	}, "生成客户查重报告")
+
+	utils.SuccessResponse(c, report, "")
+}
+
+// GetCustomerMergeRecords 获取客户合并记录，可按保留客户筛选
+func GetCustomerMergeRecords(c *gin.Context) {
+	filter := bson.M{}
+	if survivorID := c.Query("survivorId"); survivorID != "" {
+		filter["survivorId"] = survivorID
+	}
+
+	records, err := repository.CustomerMerges().Find(c.Request.Context(), filter,
+		repository.NewFindOptions().SetSort("createdAt", -1))
+	if err != nil {
+		utils.HandleError(c, err)
 		return
 	}
 
-	// 使用统一的方法记录分配历史
-	assignmentHistory := models.CustomerAssignmentHistory{
-		CustomerID:           id,
-		CustomerName:         customer.Name,
-		FromRelatedSalesID:   customer.RelatedSalesID,
-		FromRelatedSalesName: customer.RelatedSalesName,
-		ToRelatedSalesID:     "",
-		ToRelatedSalesName:   "",
-		FromRelatedAgentID:   customer.RelatedAgentID,
-		FromRelatedAgentName: customer.RelatedAgentName,
-		ToRelatedAgentID:     "",
-		ToRelatedAgentName:   "",
-		OperatorID:           user.ID,
-		OperatorName:         user.Username,
-		OperationType:        "移入公海池",
-	}
-
-	err = service.AddAssignmentHistory(ctx, assignmentHistory)
+	c.JSON(http.StatusOK, gin.H{"records": records})
+}
+
+// GetCustomerTrash 获取回收站中的客户
+func GetCustomerTrash(c *gin.Context) {
+	page, err := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "10"), 10, 64)
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+
+	customers, total, err := service.ListDeletedCustomers(c.Request.Context(), page, limit)
 	if err != nil {
 		utils.HandleError(c, err)
+		return
 	}
 
-	utils.LogInfo(map[string]interface{}{
-		"id":   id,
-		"name": customer.Name,
-	}, "客户已成功移入公海")
+	utils.PaginatedResponse(c, customers, models.NewPagination(total, page, limit))
+}
 
-	// 客户下项目全部变成前端不可展示
-	err = UpdateWebHiddenByCustomerID(ctx, objectID)
+// RestoreCustomer 从回收站恢复客户
+func RestoreCustomer(c *gin.Context) {
+	user, err := utils.GetUser(c)
 	if err != nil {
-		utils.LogError(err, map[string]interface{}{
-			"id": objectID,
-		}, "客户下所有项目不可见更新失败")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "客户已成功移入公海"})
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的客户ID"})
+		return
+	}
+
+	customer, err := service.RestoreCustomer(c.Request.Context(), objectID)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.LogInfo(map[string]interface{}{
+		"id":       customer.ID.Hex(),
+		"name":     customer.Name,
+		"username": user.Username,
+	}, "客户已从回收站恢复")
+
+	c.JSON(http.StatusOK, gin.H{"message": "客户已恢复", "customer": customer})
 }
 
 // 辅助函数：验证客户数据
@@ -1267,6 +1345,9 @@ func validateCustomerData(data models.CustomerCreateRequest) error {
 	if data.Progress == "" {
 		return &utils.AppError{Message: "客户进展状态不能为空", StatusCode: http.StatusBadRequest}
 	}
+	if err := service.ValidateProgressTransition("", data.Progress); err != nil {
+		return err
+	}
 	if data.RelatedSalesID == "" {
 		return &utils.AppError{Message: "关联销售不能为空", StatusCode: http.StatusBadRequest}
 	}
@@ -1283,33 +1364,6 @@ func getMapKeys(m map[string]interface{}) []string {
 	return keys
 }
 
-// 阿里云API配置
-const (
-	AliCloudURL   = "https://comserver.market.alicloudapi.com/searchCompany"
-	AliCloudAppID = "b5849b8dac554e7e93af538180a24382"
-)
-
-// CompanyInfo 公司信息结构体
-type CompanyInfo struct {
-	RegNumber      string `json:"regNumber"`
-	RegType        string `json:"regType"`
-	CompanyName    string `json:"companyName"`
-	CompanyType    string `json:"companyType"`
-	RegMoney       string `json:"regMoney"`
-	FaRen          string `json:"faRen"`
-	IssueTime      string `json:"issueTime"`
-	CreditCode     string `json:"creditCode"`
-	ProvinceName   string `json:"provinceName"`
-	BusinessStatus string `json:"businessStatus"`
-}
-
-// AliCloudResponse 阿里云API响应结构体
-type AliCloudResponse struct {
-	ErrorCode int             `json:"error_code"`
-	Reason    string          `json:"reason"`
-	Result    json.RawMessage `json:"result"` // 使用 json.RawMessage 延迟解析
-}
-
 // APIResponse API响应结构体
 type APIResponse struct {
 	ErrorCode int         `json:"error_code"`
@@ -1318,12 +1372,6 @@ type APIResponse struct {
 	OrderSign string      `json:"ordersign,omitempty"`
 }
 
-// ResultWithData 包含 total 和 data 的结构体
-type ResultWithData struct {
-	Total int           `json:"total"`
-	Data  []CompanyInfo `json:"data"`
-}
-
 func CompleteCompanyNamesHandler(c *gin.Context) {
 	log.Println("[API] 获取公司名称补充请求")
 
@@ -1344,9 +1392,17 @@ func CompleteCompanyNamesHandler(c *gin.Context) {
 	log.Printf("[API] 查询公司名称前缀: %s", prefix)
 
 	// 调用阿里云API
-	companyList, err := callAliCloudAPI(prefix)
+	companyList, err := service.CompanyLookup().SearchCompanies(c.Request.Context(), prefix)
 	if err != nil {
 		log.Printf("[API] 调用阿里云API失败: %v", err)
+		if errors.Is(err, service.ErrCompanyLookupDisabled) {
+			c.JSON(http.StatusServiceUnavailable, APIResponse{
+				ErrorCode: 1,
+				Reason:    err.Error(),
+				Result:    nil,
+			})
+			return
+		}
 		errorMsg := "服务器内部错误"
 		if strings.Contains(err.Error(), "阿里云API请求失败") {
 			errorMsg = "公司信息查询服务暂时不可用"
@@ -1363,7 +1419,7 @@ func CompleteCompanyNamesHandler(c *gin.Context) {
 	}
 
 	// 过滤掉空的公司名称
-	filteredCompanyList := []CompanyInfo{}
+	filteredCompanyList := []service.CompanyInfo{}
 	for _, company := range companyList {
 		if company.CompanyName != "" && strings.TrimSpace(company.CompanyName) != "" {
 			filteredCompanyList = append(filteredCompanyList, company)
@@ -1379,88 +1435,10 @@ func CompleteCompanyNamesHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, APIResponse{
 		ErrorCode: 0,
 		Reason:    "查询成功",
-		Result: ResultWithData{
+		Result: service.ResultWithData{
 			Total: len(filteredCompanyList),
 			Data:  filteredCompanyList,
 		},
 		OrderSign: orderSign,
 	})
 }
-
-func callAliCloudAPI(prefix string) ([]CompanyInfo, error) {
-	// 构建查询参数
-	params := url.Values{}
-	params.Set("com", strings.TrimSpace(prefix))
-	params.Set("page", "1")
-	params.Set("query", "all")
-
-	apiURL := fmt.Sprintf("%s?%s", AliCloudURL, params.Encode())
-	log.Printf("[API] 调用阿里云API: %s", apiURL)
-
-	// 创建HTTP请求
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
-	}
-
-	// 设置请求头
-	req.Header.Set("Authorization", "APPCODE "+AliCloudAppID)
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
-
-	// 发送请求
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("网络连接失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// 检查响应状态
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("阿里云API请求失败: %s", resp.Status)
-	}
-
-	// 读取响应体
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("读取响应体失败: %w", err)
-	}
-
-	log.Printf("[API] 阿里云API原始响应: %s", string(body))
-
-	// 解析响应
-	return parseAliCloudResponse(body)
-}
-
-func parseAliCloudResponse(body []byte) ([]CompanyInfo, error) {
-	var response AliCloudResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, err
-	}
-
-	// 检查错误码
-	if response.ErrorCode != 0 {
-		// 如果是查询无结果的情况，返回空切片和 nil 错误
-		if response.ErrorCode == 50002 && response.Reason == "查询无结果" {
-			return []CompanyInfo{}, nil
-		}
-		// 其他错误情况，返回错误信息
-		return nil, errors.New(response.Reason)
-	}
-
-	// 尝试解析 Result 为 ResultWithData
-	var resultWithData ResultWithData
-	if err := json.Unmarshal(response.Result, &resultWithData); err == nil {
-		return resultWithData.Data, nil
-	}
-
-	// 尝试解析 Result 为空数组
-	var emptyArray []interface{}
-	if err := json.Unmarshal(response.Result, &emptyArray); err == nil {
-		return []CompanyInfo{}, nil
-	}
-
-	// 如果无法解析，返回错误
-	return nil, errors.New("无法解析 Result 字段")
-}
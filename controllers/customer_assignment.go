@@ -6,11 +6,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/BerniceZTT/crm_end/models"
 	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/service"
 	"github.com/BerniceZTT/crm_end/utils"
 )
 
@@ -23,29 +22,14 @@ func GetCustomerAssignmentHistory(c *gin.Context) {
 	}, "获取客户分配历史记录")
 
 	// 获取数据库上下文
-	ctx := repository.GetContext()
+	ctx := c.Request.Context()
 
-	// 获取分配历史集合
-	collection := repository.Collection(repository.CustAssignCollection)
-
-	// 按创建时间降序排序选项
-	findOptions := options.Find()
-	findOptions.SetSort(bson.M{"createdAt": -1})
-
-	// 查询该客户的所有分配历史
-	cursor, err := collection.Find(ctx, bson.M{"customerid": customerId}, findOptions)
+	// 查询该客户的所有分配历史（按创建时间降序）
+	assignmentHistory, err := repository.AssignmentHistory().FindByCustomerID(ctx, customerId)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
-	defer cursor.Close(ctx)
-
-	// 解析结果
-	var assignmentHistory []models.CustomerAssignmentHistory
-	if err := cursor.All(ctx, &assignmentHistory); err != nil {
-		utils.HandleError(c, err)
-		return
-	}
 
 	utils.LogInfo(map[string]interface{}{
 		"customerId": customerId,
@@ -79,20 +63,16 @@ func AddCustomerAssignmentHistory(c *gin.Context) {
 	requestData.UpdatedAt = now
 
 	// 获取数据库上下文
-	ctx := repository.GetContext()
-
-	// 获取分配历史集合
-	collection := repository.Collection(repository.CustAssignCollection)
+	ctx := c.Request.Context()
 
 	// 添加历史记录
-	result, err := collection.InsertOne(ctx, requestData)
+	insertedID, err := repository.AssignmentHistory().Insert(ctx, &requestData)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
 	// 将插入的ID设置回数据结构
-	insertedID := result.InsertedID.(primitive.ObjectID)
 	requestData.ID = insertedID
 
 	utils.LogInfo(map[string]interface{}{
@@ -119,33 +99,19 @@ func GetAllCustomerAssignmentHistory(c *gin.Context) {
 
 	// 应用客户ID过滤
 	if customerId != "" {
-		filter["customerid"] = customerId
+		filter["customerId"] = customerId
 	}
 
 	// 获取数据库上下文
-	ctx := repository.GetContext()
+	ctx := c.Request.Context()
 
-	// 获取分配历史集合
-	collection := repository.Collection(repository.CustAssignCollection)
-
-	// 按创建时间降序排序选项
-	findOptions := options.Find()
-	findOptions.SetSort(bson.M{"createdAt": -1})
-
-	// 查询分配历史记录
-	cursor, err := collection.Find(ctx, filter, findOptions)
+	// 按创建时间降序查询分配历史记录
+	assignmentHistory, err := repository.AssignmentHistory().Find(ctx, filter,
+		repository.NewFindOptions().SetSort("createdAt", -1))
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
-	defer cursor.Close(ctx)
-
-	// 解析结果
-	var assignmentHistory []models.CustomerAssignmentHistory
-	if err := cursor.All(ctx, &assignmentHistory); err != nil {
-		utils.HandleError(c, err)
-		return
-	}
 
 	utils.LogInfo(map[string]interface{}{
 		"count": len(assignmentHistory),
@@ -158,3 +124,30 @@ func GetAllCustomerAssignmentHistory(c *gin.Context) {
 		"history": assignmentHistory,
 	})
 }
+
+// CheckAssignmentConsistency 检查客户当前归属与最近一条分配历史是否一致，仅超级管理员可用
+func CheckAssignmentConsistency(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if models.UserRole(user.Role) != models.UserRoleSUPER_ADMIN {
+		c.JSON(http.StatusForbidden, gin.H{"error": "仅超级管理员可执行一致性检查"})
+		return
+	}
+
+	report, err := service.CheckAssignmentConsistency(c.Request.Context())
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.LogInfo(map[string]interface{}{
+		"checked":         report.Checked,
+		"inconsistencies": len(report.Inconsistencies),
+		"username":        user.Username,
+	}, "客户归属一致性检查完成")
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/service"
+	"github.com/BerniceZTT/crm_end/utils"
+)
+
+// ExportCustomers 导出客户列表为 csv 或 xlsx，查询参数和可见范围与客户列表一致，结果集边读边写
+// 导出属于 GET 请求，不经过操作日志中间件，这里单独记录操作日志和导出行数
+func ExportCustomers(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	format, ok := spreadsheetQueryFormat(c)
+	if !ok {
+		return
+	}
+	filter, err := customerListFilter(c, user)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权访问客户数据"})
+		return
+	}
+	publicPool := c.Query("isInPublicPool") == "true"
+
+	utils.LogInfo(map[string]interface{}{
+		"user":   user.Username,
+		"format": format,
+		"query":  c.Request.URL.RawQuery,
+	}, "导出客户列表")
+
+	startTime := time.Now()
+	setAttachmentHeaders(c, format, fmt.Sprintf("customers_export_%s", startTime.Format("2006-01-02")))
+	writer, err := utils.NewSpreadsheetWriter(c.Writer, format, "客户列表")
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	rows, exportErr := service.ExportCustomers(c.Request.Context(), filter, publicPool, writer)
+	if exportErr != nil {
+		// 响应头已发送，无法再返回错误信息，只记录日志
+		utils.LogError(exportErr, map[string]interface{}{
+			"user": user.Username,
+			"rows": rows,
+		}, "导出客户列表失败")
+	} else {
+		utils.LogInfo(map[string]interface{}{
+			"user": user.Username,
+			"rows": rows,
+		}, "导出客户列表完成")
+	}
+
+	query := map[string]interface{}{}
+	for key, values := range c.Request.URL.Query() {
+		query[key] = values[0]
+	}
+	operationLog := models.OperationLog{
+		Method:        c.Request.Method,
+		Path:          c.Request.URL.Path,
+		OperatorID:    user.ID,
+		OperatorName:  user.Username,
+		OperatorType:  user.Role,
+		RequestBody:   query,
+		ResponseData:  gin.H{"format": format, "rows": rows},
+		StatusCode:    c.Writer.Status(),
+		Success:       exportErr == nil,
+		OperationTime: startTime,
+		ResponseTime:  time.Since(startTime).Milliseconds(),
+		IPAddress:     c.ClientIP(),
+		UserAgent:     c.Request.UserAgent(),
+	}
+	if exportErr != nil {
+		operationLog.ErrorMessage = exportErr.Error()
+	}
+	// 客户端中途断开时也要记录已导出的行数
+	if _, err := repository.OperationLogs().Insert(context.WithoutCancel(c.Request.Context()), &operationLog); err != nil {
+		utils.LogError(err, map[string]interface{}{"user": user.Username}, "保存导出操作日志失败")
+	}
+}
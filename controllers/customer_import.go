@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/service"
+	"github.com/BerniceZTT/crm_end/utils"
+)
+
+// spreadsheetQueryFormat 读取下载格式参数，默认 xlsx
+func spreadsheetQueryFormat(c *gin.Context) (string, bool) {
+	format := c.DefaultQuery("format", utils.SpreadsheetXLSX)
+	if format != utils.SpreadsheetXLSX && format != utils.SpreadsheetCSV {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format 只能是 xlsx 或 csv"})
+		return "", false
+	}
+	return format, true
+}
+
+// setAttachmentHeaders 设置文件下载响应头
+func setAttachmentHeaders(c *gin.Context, format string, baseName string) {
+	c.Header("Content-Type", utils.SpreadsheetContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, baseName, format))
+}
+
+// DownloadCustomerImportTemplate 下载客户导入模板
+func DownloadCustomerImportTemplate(c *gin.Context) {
+	format, ok := spreadsheetQueryFormat(c)
+	if !ok {
+		return
+	}
+
+	setAttachmentHeaders(c, format, "customer_import_template")
+	writer, err := utils.NewSpreadsheetWriter(c.Writer, format, "客户导入")
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	if err := writer.WriteRow(service.CustomerImportTemplateHeaders()); err != nil {
+		utils.LogError(err, nil, "写出客户导入模板失败")
+		return
+	}
+	if err := writer.Close(); err != nil {
+		utils.LogError(err, nil, "写出客户导入模板失败")
+	}
+}
+
+// PreviewCustomerImport 上传 xlsx/csv 文件，逐行校验后返回预览结果，确认后再调用导入接口
+// 表单字段：file 为上传文件；mapping 可选，为列标题到字段的 JSON 映射
+func PreviewCustomerImport(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请上传导入文件"})
+		return
+	}
+	if fileHeader.Size > service.MaxCustomerImportFileSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("导入文件不能超过 %dMB", service.MaxCustomerImportFileSize>>20)})
+		return
+	}
+
+	mapping := map[string]string{}
+	if raw := c.PostForm("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的列映射: " + err.Error()})
+			return
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, service.MaxCustomerImportFileSize))
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.LogInfo(map[string]interface{}{
+		"fileName": fileHeader.Filename,
+		"size":     fileHeader.Size,
+		"username": user.Username,
+	}, "上传客户导入文件")
+
+	record, err := service.PreviewCustomerImport(c.Request.Context(), fileHeader.Filename, content, mapping, user)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"import": record,
+		"fields": service.CustomerImportFields(),
+	}, fmt.Sprintf("共 %d 行，%d 行可导入，%d 行疑似重复，%d 行有错误",
+		record.Summary.Total, record.Summary.Valid, record.Summary.Warning, record.Summary.Invalid))
+}
+
+// parseImportID 读取路径中的导入批次ID
+func parseImportID(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("importId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的导入批次ID"})
+		return primitive.NilObjectID, false
+	}
+	return id, true
+}
+
+// GetCustomerImport 获取导入批次的校验或导入结果
+func GetCustomerImport(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	id, ok := parseImportID(c)
+	if !ok {
+		return
+	}
+
+	record, err := service.GetCustomerImport(c.Request.Context(), id, user)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	utils.SuccessResponse(c, record, "")
+}
+
+// CommitCustomerImport 确认导入，只导入校验通过的行，疑似重复的行需指定 ignoreDuplicates
+func CommitCustomerImport(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	id, ok := parseImportID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CustomerImportCommitRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据: " + err.Error()})
+			return
+		}
+	}
+
+	utils.LogInfo(map[string]interface{}{
+		"importId":         id.Hex(),
+		"ignoreDuplicates": req.IgnoreDuplicates,
+		"rows":             len(req.Rows),
+		"username":         user.Username,
+	}, "确认导入客户")
+
+	record, err := service.CommitCustomerImport(c.Request.Context(), id, req, user)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, record, fmt.Sprintf("成功导入 %d 个客户，%d 行导入失败",
+		record.Summary.Imported, record.Summary.Failed))
+}
+
+// DownloadCustomerImportErrors 下载导入错误报告，包含未导入的行及错误原因
+func DownloadCustomerImportErrors(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	id, ok := parseImportID(c)
+	if !ok {
+		return
+	}
+	format, ok := spreadsheetQueryFormat(c)
+	if !ok {
+		return
+	}
+
+	record, err := service.GetCustomerImport(c.Request.Context(), id, user)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	setAttachmentHeaders(c, format, fmt.Sprintf("customer_import_errors_%s", time.Now().Format("2006-01-02")))
+	writer, err := utils.NewSpreadsheetWriter(c.Writer, format, "导入错误")
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	if err := service.WriteCustomerImportErrors(writer, record); err != nil {
+		utils.LogError(err, map[string]interface{}{"importId": id.Hex()}, "写出导入错误报告失败")
+	}
+}
@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/service"
+	"github.com/BerniceZTT/crm_end/utils"
+)
+
+// SearchCustomers 按名称、联系人和应用领域搜索客户，支持拼音和首字母，返回高亮和分面统计
+func SearchCustomers(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "10"), 10, 64)
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	query := models.CustomerSearchQuery{
+		Keyword:        c.Query("keyword"),
+		Nature:         c.Query("nature"),
+		Importance:     c.Query("importance"),
+		Progress:       c.Query("progress"),
+		RelatedSalesID: c.Query("relatedSalesId"),
+		Page:           page,
+		Limit:          limit,
+	}
+	utils.LogInfo(map[string]interface{}{
+		"user":    user.Username,
+		"keyword": query.Keyword,
+		"page":    page,
+		"limit":   limit,
+	}, "搜索客户")
+
+	result, err := service.SearchCustomers(c.Request.Context(), query, user)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	utils.SuccessResponse(c, result, "")
+}
@@ -11,8 +11,6 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/BerniceZTT/crm_end/models"
 	"github.com/BerniceZTT/crm_end/repository"
@@ -67,7 +65,7 @@ func GetDashboardStats(c *gin.Context) {
 		"endDate":   endDateParam,
 	}, "[数据看板] 获取统计信息")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 	defer cancel()
 
 	// 基于用户角色构建查询条件
@@ -139,31 +137,23 @@ func GetDashboardStats(c *gin.Context) {
 		customerQuery["relatedAgentId"] = currentUser.ID
 	}
 
-	// 获取数据库集合
-	customersCollection := repository.Collection(repository.CustomersCollection)
-	agentsCollection := repository.Collection(repository.AgentsCollection)
-	productsCollection := repository.Collection(repository.ProductsCollection)
-	projectsCollection := repository.Collection(repository.ProjectsCollection)
+	// 获取仓储
+	customers := repository.Customers()
+	agents := repository.Agents()
+	products := repository.Products()
+	projects := repository.Projects()
 
 	// 如果用户有权限限制，需要根据客户权限筛选项目
 	if currentUser.Role != string(models.UserRoleSUPER_ADMIN) {
-		cursor, err := customersCollection.Find(ctx, customerQuery, options.Find().SetProjection(bson.M{"_id": 1}))
+		accessibleCustomers, err := customers.Find(ctx, customerQuery, repository.NewFindOptions().SetProjection(bson.M{"_id": 1}))
 		if err != nil {
 			utils.HandleError(c, fmt.Errorf("查询可访问客户失败: %w", err))
 			return
 		}
 
-		var accessibleCustomers []bson.M
-		if err = cursor.All(ctx, &accessibleCustomers); err != nil {
-			utils.HandleError(c, fmt.Errorf("解析可访问客户失败: %w", err))
-			return
-		}
-
 		customerIDs := make([]string, 0, len(accessibleCustomers))
 		for _, customer := range accessibleCustomers {
-			if id, ok := customer["_id"].(primitive.ObjectID); ok {
-				customerIDs = append(customerIDs, id.Hex())
-			}
+			customerIDs = append(customerIDs, customer.ID.Hex())
 		}
 
 		if len(customerIDs) > 0 {
@@ -175,19 +165,19 @@ func GetDashboardStats(c *gin.Context) {
 	}
 
 	// 基础统计数据
-	customerCount, err := customersCollection.CountDocuments(ctx, customerQuery)
+	customerCount, err := customers.Count(ctx, customerQuery)
 	if err != nil {
 		utils.HandleError(c, fmt.Errorf("统计客户数量失败: %w", err))
 		return
 	}
 
-	productCount, err := productsCollection.CountDocuments(ctx, dateFilter)
+	productCount, err := products.Count(ctx, dateFilter)
 	if err != nil {
 		utils.HandleError(c, fmt.Errorf("统计产品数量失败: %w", err))
 		return
 	}
 
-	projectCount, err := projectsCollection.CountDocuments(ctx, baseProjectQuery)
+	projectCount, err := projects.Count(ctx, baseProjectQuery)
 	if err != nil {
 		utils.HandleError(c, fmt.Errorf("统计项目数量失败: %w", err))
 		return
@@ -200,7 +190,7 @@ func GetDashboardStats(c *gin.Context) {
 		for k, v := range dateFilter {
 			agentQuery[k] = v
 		}
-		agentCount, err = agentsCollection.CountDocuments(ctx, agentQuery)
+		agentCount, err = agents.Count(ctx, agentQuery)
 		if err != nil {
 			utils.HandleError(c, fmt.Errorf("统计代理商数量失败: %w", err))
 			return
@@ -213,7 +203,7 @@ func GetDashboardStats(c *gin.Context) {
 		for k, v := range dateFilter {
 			agentQuery[k] = v
 		}
-		agentCount, err = agentsCollection.CountDocuments(ctx, agentQuery)
+		agentCount, err = agents.Count(ctx, agentQuery)
 		if err != nil {
 			utils.HandleError(c, fmt.Errorf("统计代理商数量失败: %w", err))
 			return
@@ -221,85 +211,29 @@ func GetDashboardStats(c *gin.Context) {
 	}
 
 	// 客户分布统计
-	// 定义聚合结果的结构体
-	type aggResult struct {
-		ID    string `bson:"_id"`
-		Count int    `bson:"count"`
-	}
-
 	// 客户重要性分布
-	importanceAgg, err := customersCollection.Aggregate(ctx, []bson.M{
-		{"$match": customerQuery},
-		{"$group": bson.M{"_id": "$importance", "count": bson.M{"$sum": 1}}},
-	})
+	importanceAggResults, err := customers.GroupCount(ctx, customerQuery, "importance")
 	if err != nil {
 		utils.HandleError(c, fmt.Errorf("统计客户重要性分布失败: %w", err))
 		return
 	}
-	defer importanceAgg.Close(ctx)
-
-	var importanceAggResults []aggResult
-	if err = importanceAgg.All(ctx, &importanceAggResults); err != nil {
-		utils.HandleError(c, fmt.Errorf("解析客户重要性分布失败: %w", err))
-		return
-	}
-
-	var importanceDistribution []models.ChartDataItem
-	for _, res := range importanceAggResults {
-		importanceDistribution = append(importanceDistribution, models.ChartDataItem{
-			Name:  res.ID,
-			Value: res.Count,
-		})
-	}
+	importanceDistribution := toChartData(importanceAggResults)
 
 	// 客户性质分布
-	natureAgg, err := customersCollection.Aggregate(ctx, []bson.M{
-		{"$match": customerQuery},
-		{"$group": bson.M{"_id": "$nature", "count": bson.M{"$sum": 1}}},
-	})
+	natureAggResults, err := customers.GroupCount(ctx, customerQuery, "nature")
 	if err != nil {
 		utils.HandleError(c, fmt.Errorf("统计客户性质分布失败: %w", err))
 		return
 	}
-	defer natureAgg.Close(ctx)
-
-	var natureAggResults []aggResult
-	if err = natureAgg.All(ctx, &natureAggResults); err != nil {
-		utils.HandleError(c, fmt.Errorf("解析客户性质分布失败: %w", err))
-		return
-	}
-
-	var natureDistribution []models.ChartDataItem
-	for _, res := range natureAggResults {
-		natureDistribution = append(natureDistribution, models.ChartDataItem{
-			Name:  res.ID,
-			Value: res.Count,
-		})
-	}
+	natureDistribution := toChartData(natureAggResults)
 
 	// 产品分布统计
-	packageTypeAgg, err := productsCollection.Aggregate(ctx, []bson.M{
-		{"$group": bson.M{"_id": "$packageType", "count": bson.M{"$sum": 1}}},
-	})
+	packageTypeDistributionAggResults, err := products.GroupCount(ctx, bson.M{}, "packageType")
 	if err != nil {
 		utils.HandleError(c, fmt.Errorf("统计产品包装类型分布失败: %w", err))
 		return
 	}
-	defer packageTypeAgg.Close(ctx)
-
-	var packageTypeDistributionAggResults []aggResult
-	if err = packageTypeAgg.All(ctx, &packageTypeDistributionAggResults); err != nil {
-		utils.HandleError(c, fmt.Errorf("解析产品包装类型分布失败: %w", err))
-		return
-	}
-
-	var packageTypeDistribution []models.ChartDataItem
-	for _, res := range packageTypeDistributionAggResults {
-		packageTypeDistribution = append(packageTypeDistribution, models.ChartDataItem{
-			Name:  res.ID,
-			Value: res.Count,
-		})
-	}
+	packageTypeDistribution := toChartData(packageTypeDistributionAggResults)
 
 	// 产品库存等级分布
 	stockLevels := []struct {
@@ -322,7 +256,7 @@ func GetDashboardStats(c *gin.Context) {
 				"stock": bson.M{"$gte": level.min},
 			}
 		}
-		count, err := productsCollection.CountDocuments(ctx, query)
+		count, err := products.Count(ctx, query)
 		if err != nil {
 			utils.HandleError(c, fmt.Errorf("统计产品库存等级失败: %w", err))
 			return
@@ -337,66 +271,50 @@ func GetDashboardStats(c *gin.Context) {
 	}
 
 	// 产品客户关联数量分布
-	productCustomerRelation, err := getProductCustomerRelation(ctx, productsCollection, customersCollection, customerQuery, dateFilter)
+	productCustomerRelation, err := getProductCustomerRelation(ctx, products, customers, customerQuery, dateFilter)
 	if err != nil {
 		utils.HandleError(c, fmt.Errorf("获取产品客户关联失败: %w", err))
 		return
 	}
 
 	// 产品项目关联数量分布
-	productProjectRelation, err := getProductProjectRelation(ctx, baseProjectQuery, productsCollection, projectsCollection)
+	productProjectRelation, err := getProductProjectRelation(ctx, baseProjectQuery, products, projects)
 	if err != nil {
 		utils.HandleError(c, fmt.Errorf("获取产品项目关联失败: %w", err))
 		return
 	}
 
 	// 项目进展分布
-	projectProgressAgg, err := projectsCollection.Aggregate(ctx, []bson.M{
-		{"$match": baseProjectQuery},
-		{"$group": bson.M{"_id": "$projectProgress", "count": bson.M{"$sum": 1}}},
-	})
+	projectProgressDistributionAggResults, err := projects.GroupCount(ctx, baseProjectQuery, "projectProgress")
 	if err != nil {
 		utils.HandleError(c, fmt.Errorf("统计项目进展分布失败: %w", err))
 		return
 	}
-	defer projectProgressAgg.Close(ctx)
-
-	var projectProgressDistributionAggResults []aggResult
-	if err = projectProgressAgg.All(ctx, &projectProgressDistributionAggResults); err != nil {
-		utils.HandleError(c, fmt.Errorf("解析项目进展分布失败: %w", err))
-		return
-	}
-	var projectProgressDistribution []models.ChartDataItem
-	for _, res := range projectProgressDistributionAggResults {
-		projectProgressDistribution = append(projectProgressDistribution, models.ChartDataItem{
-			Name:  res.ID,
-			Value: res.Count,
-		})
-	}
+	projectProgressDistribution := toChartData(projectProgressDistributionAggResults)
 
 	// 批量总额统计
-	projectBatchTotalStats, err := getProjectBatchTotalStats(ctx, baseProjectQuery, projectsCollection)
+	projectBatchTotalStats, err := getProjectBatchTotalStats(ctx, baseProjectQuery, projects)
 	if err != nil {
 		utils.HandleError(c, fmt.Errorf("获取批量总额统计失败: %w", err))
 		return
 	}
 
 	// 小批量总额统计
-	projectSmallBatchTotalStats, err := getProjectSmallBatchTotalStats(ctx, baseProjectQuery, projectsCollection)
+	projectSmallBatchTotalStats, err := getProjectSmallBatchTotalStats(ctx, baseProjectQuery, projects)
 	if err != nil {
 		utils.HandleError(c, fmt.Errorf("获取小批量总额统计失败: %w", err))
 		return
 	}
 
 	// 项目月度统计
-	projectMonthlyStats, err := getProjectMonthlyStats(ctx, baseProjectQuery, projectsCollection)
+	projectMonthlyStats, err := getProjectMonthlyStats(ctx, baseProjectQuery, projects)
 	if err != nil {
 		utils.HandleError(c, fmt.Errorf("获取项目月度统计失败: %w", err))
 		return
 	}
 
 	// 项目价值排行Top10
-	topProjectsByValue, err := getTopProjectsByValue(ctx, baseProjectQuery, projectsCollection, productsCollection)
+	topProjectsByValue, err := getTopProjectsByValue(ctx, baseProjectQuery, projects, products)
 	if err != nil {
 		utils.HandleError(c, fmt.Errorf("获取项目价值排行失败: %w", err))
 		return
@@ -433,69 +351,48 @@ func GetDashboardStats(c *gin.Context) {
 	c.JSON(http.StatusOK, responseData)
 }
 
+// toChartData 将分组计数结果转换为图表数据
+func toChartData(groups []repository.GroupCount) []models.ChartDataItem {
+	var items []models.ChartDataItem
+	for _, g := range groups {
+		items = append(items, models.ChartDataItem{
+			Name:  g.Key,
+			Value: g.Count,
+		})
+	}
+	return items
+}
+
 // getProductProjectRelation 获取产品项目关联数量分布
 func getProductCustomerRelation(ctx context.Context,
-	productsCollection, customersCollection *mongo.Collection,
+	productRepo repository.ProductRepository, customerRepo repository.CustomerRepository,
 	customerQuery, productQuery bson.M) ([]models.ChartDataItem, error) {
 
 	// 获取所有产品列表（应用productQuery过滤）
-	cursor, err := productsCollection.Find(ctx, productQuery, options.Find().SetProjection(bson.M{
+	products, err := productRepo.Find(ctx, productQuery, repository.NewFindOptions().SetProjection(bson.M{
 		"_id":       1,
 		"modelName": 1,
 	}))
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(ctx)
-
-	var products []models.Product
-	if err = cursor.All(ctx, &products); err != nil {
-		return nil, err
-	}
 
 	var productRelationData []models.ChartDataItem
 	for _, product := range products {
-		// 构建聚合管道
-		pipeline := []bson.M{
-			{
-				"$match": bson.M{
-					"$and": []bson.M{
-						{"productneeds": product.ID.Hex()}, // 假设存储的是Hex字符串
-						customerQuery,                      // 合并客户查询条件
-					},
-				},
+		count, err := customerRepo.Count(ctx, bson.M{
+			"$and": []bson.M{
+				{"productNeeds": product.ID.Hex()}, // 假设存储的是Hex字符串
+				customerQuery,                      // 合并客户查询条件
 			},
-			{
-				"$group": bson.M{
-					"_id":   nil,
-					"count": bson.M{"$sum": 1},
-				},
-			},
-		}
-
-		cursor, err := customersCollection.Aggregate(ctx, pipeline)
+		})
 		if err != nil {
 			return nil, err
 		}
 
-		var result []struct {
-			Count int `bson:"count"`
-		}
-		if err = cursor.All(ctx, &result); err != nil {
-			cursor.Close(ctx)
-			return nil, err
-		}
-		cursor.Close(ctx)
-
-		count := 0
-		if len(result) > 0 {
-			count = result[0].Count
-		}
-
 		if count > 0 {
 			productRelationData = append(productRelationData, models.ChartDataItem{
 				Name:  product.ModelName,
-				Value: count,
+				Value: int(count),
 			})
 		}
 	}
@@ -513,26 +410,20 @@ func getProductCustomerRelation(ctx context.Context,
 }
 
 func getProductProjectRelation(ctx context.Context, baseProjectQuery bson.M,
-	productsCollection, projectsCollection *mongo.Collection) ([]models.ChartDataItem, error) {
+	productRepo repository.ProductRepository, projectRepo repository.ProjectRepository) ([]models.ChartDataItem, error) {
 
-	cursor, err := productsCollection.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{
+	products, err := productRepo.Find(ctx, bson.M{}, repository.NewFindOptions().SetProjection(bson.M{
 		"modelName":   1,
 		"packageType": 1,
 	}))
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(ctx)
-
-	var products []models.Product
-	if err = cursor.All(ctx, &products); err != nil {
-		return nil, err
-	}
 
 	var productProjectData []models.ChartDataItem
 
 	for _, product := range products {
-		count, err := projectsCollection.CountDocuments(ctx, bson.M{
+		count, err := projectRepo.Count(ctx, bson.M{
 			"productId": product.ID,
 			"$and":      []bson.M{baseProjectQuery},
 		})
@@ -567,23 +458,17 @@ func getProductProjectRelation(ctx context.Context, baseProjectQuery bson.M,
 
 // getProjectBatchTotalStats 获取批量总额统计
 func getProjectBatchTotalStats(ctx context.Context, baseProjectQuery bson.M,
-	projectsCollection *mongo.Collection) (models.ProjectBatchStats, error) {
+	projectRepo repository.ProjectRepository) (models.ProjectBatchStats, error) {
 
 	query := bson.M{
 		"massProductionTotal": bson.M{"$gt": 0},
 		"$and":                []bson.M{baseProjectQuery},
 	}
 
-	cursor, err := projectsCollection.Find(ctx, query)
+	projects, err := projectRepo.Find(ctx, query)
 	if err != nil {
 		return models.ProjectBatchStats{}, err
 	}
-	defer cursor.Close(ctx)
-
-	var projects []models.Project
-	if err = cursor.All(ctx, &projects); err != nil {
-		return models.ProjectBatchStats{}, err
-	}
 
 	if len(projects) == 0 {
 		return models.ProjectBatchStats{
@@ -622,23 +507,17 @@ func getProjectBatchTotalStats(ctx context.Context, baseProjectQuery bson.M,
 
 // getProjectSmallBatchTotalStats 获取小批量总额统计
 func getProjectSmallBatchTotalStats(ctx context.Context, baseProjectQuery bson.M,
-	projectsCollection *mongo.Collection) (models.ProjectBatchStats, error) {
+	projectRepo repository.ProjectRepository) (models.ProjectBatchStats, error) {
 
 	query := bson.M{
 		"smallBatchTotal": bson.M{"$gt": 0},
 		"$and":            []bson.M{baseProjectQuery},
 	}
 
-	cursor, err := projectsCollection.Find(ctx, query)
+	projects, err := projectRepo.Find(ctx, query)
 	if err != nil {
 		return models.ProjectBatchStats{}, err
 	}
-	defer cursor.Close(ctx)
-
-	var projects []models.Project
-	if err = cursor.All(ctx, &projects); err != nil {
-		return models.ProjectBatchStats{}, err
-	}
 
 	if len(projects) == 0 {
 		return models.ProjectBatchStats{
@@ -677,7 +556,7 @@ func getProjectSmallBatchTotalStats(ctx context.Context, baseProjectQuery bson.M
 
 // getProjectMonthlyStats 获取项目月度统计
 func getProjectMonthlyStats(ctx context.Context, baseProjectQuery bson.M,
-	projectsCollection *mongo.Collection) ([]models.ProjectMonthlyStats, error) {
+	projectRepo repository.ProjectRepository) ([]models.ProjectMonthlyStats, error) {
 
 	oneYearAgo := time.Now().AddDate(-1, 0, 0)
 	query := bson.M{
@@ -685,45 +564,30 @@ func getProjectMonthlyStats(ctx context.Context, baseProjectQuery bson.M,
 		// "$and":      []bson.M{baseProjectQuery},
 	}
 
-	pipeline := []bson.M{
-		{"$match": query},
-		{"$group": bson.M{
-			"_id": bson.M{
-				"year":  bson.M{"$year": "$startDate"},
-				"month": bson.M{"$month": "$startDate"},
-			},
-			"projectCount":    bson.M{"$sum": 1},
-			"totalBatch":      bson.M{"$sum": "$massProductionTotal"},
-			"totalSmallBatch": bson.M{"$sum": "$smallBatchTotal"},
-		}},
-		{"$sort": bson.M{"_id.year": 1, "_id.month": 1}},
-	}
-
-	cursor, err := projectsCollection.Aggregate(ctx, pipeline)
+	projects, err := projectRepo.Find(ctx, query, repository.NewFindOptions().SetProjection(bson.M{
+		"startDate":           1,
+		"massProductionTotal": 1,
+		"smallBatchTotal":     1,
+	}))
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(ctx)
 
 	type monthlyAggResult struct {
-		ID struct {
-			Year  int `bson:"year"`
-			Month int `bson:"month"`
-		} `bson:"_id"`
-		ProjectCount    int     `bson:"projectCount"`
-		TotalBatch      float64 `bson:"totalBatch"`
-		TotalSmallBatch float64 `bson:"totalSmallBatch"`
-	}
-
-	var monthlyData []monthlyAggResult
-	if err = cursor.All(ctx, &monthlyData); err != nil {
-		return nil, err
+		ProjectCount    int
+		TotalBatch      float64
+		TotalSmallBatch float64
 	}
 
-	// 创建月份到数据的映射
+	// 按开始日期所在月份（UTC，与 $year/$month 保持一致）汇总
 	monthMap := make(map[string]monthlyAggResult)
-	for _, data := range monthlyData {
-		monthKey := fmt.Sprintf("%d.%02d", data.ID.Year, data.ID.Month)
+	for _, project := range projects {
+		startDate := project.StartDate.UTC()
+		monthKey := fmt.Sprintf("%d.%02d", startDate.Year(), startDate.Month())
+		data := monthMap[monthKey]
+		data.ProjectCount++
+		data.TotalBatch += safeNumber(project.MassProductionTotal)
+		data.TotalSmallBatch += safeNumber(project.SmallBatchTotal)
 		monthMap[monthKey] = data
 	}
 
@@ -760,18 +624,12 @@ func getProjectMonthlyStats(ctx context.Context, baseProjectQuery bson.M,
 
 // getTopProjectsByValue 获取项目价值排行Top10
 func getTopProjectsByValue(ctx context.Context, baseProjectQuery bson.M,
-	projectsCollection, productsCollection *mongo.Collection) ([]models.ProjectValueItem, error) {
+	projectRepo repository.ProjectRepository, productRepo repository.ProductRepository) ([]models.ProjectValueItem, error) {
 
-	cursor, err := projectsCollection.Find(ctx, baseProjectQuery)
+	projects, err := projectRepo.Find(ctx, baseProjectQuery)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(ctx)
-
-	var projects []models.Project
-	if err = cursor.All(ctx, &projects); err != nil {
-		return nil, err
-	}
 
 	// 计算每个项目的总价值并排序
 	projectsWithValue := make([]models.ProjectValueItem, 0, len(projects))
@@ -814,16 +672,10 @@ func getTopProjectsByValue(ctx context.Context, baseProjectQuery bson.M,
 
 	productMap := make(map[string]models.Product)
 	if len(productIDs) > 0 {
-		cursor, err := productsCollection.Find(ctx, bson.M{"_id": bson.M{"$in": productIDs}})
+		products, err := productRepo.Find(ctx, bson.M{"_id": bson.M{"$in": productIDs}})
 		if err != nil {
 			return nil, err
 		}
-		defer cursor.Close(ctx)
-
-		var products []models.Product
-		if err = cursor.All(ctx, &products); err != nil {
-			return nil, err
-		}
 
 		for _, product := range products {
 			productMap[product.ID.Hex()] = product
@@ -1,34 +1,55 @@
 package controllers
 
 import (
-	"context"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/pagination"
+	"github.com/BerniceZTT/crm_end/policy"
 	"github.com/BerniceZTT/crm_end/repository"
 	"github.com/BerniceZTT/crm_end/utils"
 )
 
+// followUpSort 客户和项目跟进记录可排序的字段
+var followUpSort = pagination.Spec{
+	SortFields: map[string]string{
+		"createdAt": "createdAt",
+		"updatedAt": "updatedAt",
+	},
+	DefaultSort:  "createdAt",
+	DefaultOrder: -1,
+}
+
 // GetCustomerFollowUpRecords 获取某个客户的跟进记录列表
 func GetCustomerFollowUpRecords(c *gin.Context) {
 	customerId := c.Param("customerId")
+
+	// 获取当前用户信息
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 验证客户ID
 	if customerId == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "客户ID不能为空"})
 		return
 	}
+	pageReq, err := pagination.FromQuery(c.Request.URL.Query(), followUpSort)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
 
-	ctx := context.Background()
+	ctx := c.Request.Context()
 
 	// 先验证用户是否有权限查看该客户
-	customersCollection := repository.Collection(repository.CustomersCollection)
-
 	customerObjId, err := primitive.ObjectIDFromHex(customerId)
 	if err != nil {
 		utils.HandleError(c, err)
@@ -38,42 +59,34 @@ func GetCustomerFollowUpRecords(c *gin.Context) {
 		"customerObjId": customerObjId,
 		"customerId":    customerId,
 	}, "GetCustomerFollowUpRecords")
-	var customer bson.M
-	err = customersCollection.FindOne(ctx, bson.M{"_id": customerObjId}).Decode(&customer)
+	customer, err := repository.Customers().FindByID(ctx, customerObjId)
 	if err != nil {
-		if err.Error() == "mongo: no documents in result" {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "客户不存在"})
 			return
 		}
 		utils.HandleError(c, err)
 		return
 	}
-
-	// 查询跟进记录
-	collection := repository.Collection(repository.FollowUpCollection)
-
-	// 设置排序选项，按创建时间倒序
-	opts := options.Find().SetSort(bson.M{"createdAt": -1})
-
-	cursor, err := collection.Find(ctx, bson.M{"customerId": customerId}, opts)
-	if err != nil {
-		utils.HandleError(c, err)
+	if !policy.CanAccessCustomer(user, customer, policy.ActionRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权查看该客户的跟进记录"})
 		return
 	}
-	defer cursor.Close(ctx)
 
-	var records []models.FollowUpRecord
-	if err = cursor.All(ctx, &records); err != nil {
+	// 查询一页跟进记录，默认按创建时间倒序
+	result, err := pagination.Find(ctx, repository.FollowUps(), bson.M{"customerId": customerId}, pageReq)
+	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
 	utils.LogInfo(map[string]interface{}{
 		"customerId":  customerId,
-		"recordCount": len(records),
+		"recordCount": len(result.Items),
+		"total":       result.Pagination.Total,
 	}, "获取客户跟进记录成功")
 
-	c.JSON(http.StatusOK, gin.H{"records": records})
+	utils.PaginatedResponse(c, result.Items, result.Pagination)
 }
 
 // CreateFollowUpRecord 创建跟进记录
@@ -91,30 +104,30 @@ func CreateFollowUpRecord(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx := c.Request.Context()
 
 	// 验证客户是否存在
-	customersCollection := repository.Collection(repository.CustomersCollection)
 	customerObjId, err := primitive.ObjectIDFromHex(input.CustomerId)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
-	var customer bson.M
-	err = customersCollection.FindOne(ctx, bson.M{"_id": customerObjId}).Decode(&customer)
+	customer, err := repository.Customers().FindByID(ctx, customerObjId)
 	if err != nil {
-		if err.Error() == "mongo: no documents in result" {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "客户不存在"})
 			return
 		}
 		utils.HandleError(c, err)
 		return
 	}
+	if !policy.CanAccessCustomer(user, customer, policy.ActionUpdate) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权为该客户添加跟进记录"})
+		return
+	}
 
 	// 创建跟进记录
-	collection := repository.Collection(repository.FollowUpCollection)
-
 	now := time.Now()
 	newRecord := models.FollowUpRecord{
 		CustomerId:  input.CustomerId,
@@ -132,18 +145,14 @@ func CreateFollowUpRecord(c *gin.Context) {
 		newRecord.CreatorName = user.Username
 	}
 
-	result, err := collection.InsertOne(ctx, newRecord)
+	insertedID, err := repository.FollowUps().Insert(ctx, &newRecord)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
 	// 更新客户最后更新时间
-	_, err = customersCollection.UpdateOne(
-		ctx,
-		bson.M{"_id": customerObjId},
-		bson.M{"$set": bson.M{"lastUpdateTime": now}},
-	)
+	_, err = repository.Customers().SetFields(ctx, customerObjId, bson.M{"lastUpdateTime": now})
 	if err != nil {
 		// 只记录错误但不影响主流程
 		utils.LogInfo(map[string]interface{}{
@@ -153,7 +162,7 @@ func CreateFollowUpRecord(c *gin.Context) {
 	}
 
 	// 设置返回记录的ID
-	newRecord.ID = result.InsertedID.(primitive.ObjectID)
+	newRecord.ID = insertedID
 
 	utils.LogInfo(map[string]interface{}{
 		"recordId":   newRecord.ID.Hex(),
@@ -178,20 +187,18 @@ func DeleteFollowUpRecord(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx := c.Request.Context()
 
 	// 查找记录
-	collection := repository.Collection(repository.FollowUpCollection)
 	recordId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
-	var record models.FollowUpRecord
-	err = collection.FindOne(ctx, bson.M{"_id": recordId}).Decode(&record)
+	record, err := repository.FollowUps().FindByID(ctx, recordId)
 	if err != nil {
-		if err.Error() == "mongo: no documents in result" {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "跟进记录不存在"})
 			return
 		}
@@ -206,13 +213,13 @@ func DeleteFollowUpRecord(c *gin.Context) {
 	}
 
 	// 删除记录
-	result, err := collection.DeleteOne(ctx, bson.M{"_id": recordId})
+	deletedCount, err := repository.FollowUps().DeleteByID(ctx, recordId)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
-	if result.DeletedCount == 0 {
+	if deletedCount == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"error": "跟进记录不存在或已被删除"})
 		return
 	}
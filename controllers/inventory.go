@@ -1,54 +1,40 @@
 package controllers
 
 import (
-	"context"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/pagination"
 	"github.com/BerniceZTT/crm_end/repository"
 	"github.com/BerniceZTT/crm_end/utils"
 )
 
+// inventoryRecordSort 库存操作记录可排序的字段
+var inventoryRecordSort = pagination.Spec{
+	SortFields: map[string]string{
+		"operationTime": "operationTime",
+		"quantity":      "quantity",
+		"modelName":     "modelName",
+	},
+	DefaultSort:  "operationTime",
+	DefaultOrder: -1,
+	DefaultLimit: 20,
+}
+
 // GetInventoryRecords 获取库存操作记录
 func GetInventoryRecords(c *gin.Context) {
-	// 验证用户权限
-	// 获取当前用户信息
-	user, err := utils.GetUser(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-		return
-	}
-
-	if user.Role != string(models.UserRoleSUPER_ADMIN) && user.Role != string(models.UserRoleINVENTORY_MANAGER) {
-		utils.ErrorResponse(c, "无权查看库存记录", http.StatusForbidden)
-		return
-	}
-
-	// 获取查询参数
-	pageStr := c.DefaultQuery("page", "1")
-	limitStr := c.DefaultQuery("limit", "20")
-
-	page, err := strconv.ParseInt(pageStr, 10, 64)
-	if err != nil {
-		utils.HandleError(c, err)
-		return
-	}
-
-	limit, err := strconv.ParseInt(limitStr, 10, 64)
+	// 获取分页和排序参数
+	pageReq, err := pagination.FromQuery(c.Request.URL.Query(), inventoryRecordSort)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
-	// skip := (page - 1) * limit
-
 	// 筛选条件
 	searchQuery := bson.M{}
 
@@ -111,156 +97,74 @@ func GetInventoryRecords(c *gin.Context) {
 		"query": searchQuery,
 	}, "库存记录查询条件")
 
-	// 查询数据库
-	collection := repository.Collection(repository.InventoryRecordsCollection)
-
-	// 获取总数
-	ctx := context.Background()
-	total, err := collection.CountDocuments(ctx, searchQuery)
-	if err != nil {
-		utils.HandleError(c, err)
-		return
-	}
-
-	// 设置排序和分页
-	findOptions := options.Find().
-		SetSort(bson.M{"operationTime": -1})
-		// .SetSkip(skip).
-		// SetLimit(limit)
-
-	// 查询数据
-	cursor, err := collection.Find(ctx, searchQuery, findOptions)
+	// 查询一页数据
+	result, err := pagination.Find(c.Request.Context(), repository.Inventory(), searchQuery, pageReq)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
-	defer cursor.Close(ctx)
-
-	var records []models.InventoryRecord
-	if err := cursor.All(ctx, &records); err != nil {
-		utils.HandleError(c, err)
-		return
-	}
 
 	utils.LogInfo(map[string]interface{}{
-		"recordsCount": len(records),
-		"totalRecords": total,
+		"recordsCount": len(result.Items),
+		"totalRecords": result.Pagination.Total,
 	}, "查询库存记录")
 
 	// 返回结果
-	utils.PaginatedResponse(c, records, total, page, limit)
+	utils.PaginatedResponse(c, result.Items, result.Pagination)
 }
 
 // GetInventoryStats 获取库存统计信息
 func GetInventoryStats(c *gin.Context) {
-	user, err := utils.GetUser(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-		return
-	}
 
-	if user.Role != string(models.UserRoleSUPER_ADMIN) && user.Role != string(models.UserRoleINVENTORY_MANAGER) {
-		utils.ErrorResponse(c, "无权查看库存统计", http.StatusForbidden)
-		return
-	}
-
-	ctx := context.Background()
-	productsCollection := repository.Collection(repository.ProductsCollection)
+	ctx := c.Request.Context()
 
 	// 获取总产品数
-	totalProducts, err := productsCollection.CountDocuments(ctx, bson.M{})
+	totalProducts, err := repository.Products().Count(ctx, bson.M{})
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
 	// 获取低库存产品数
-	lowStockProducts, err := productsCollection.CountDocuments(ctx, bson.M{"stock": bson.M{"$lt": 50}})
+	lowStockProducts, err := repository.Products().Count(ctx, bson.M{"stock": bson.M{"$lt": 50}})
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
 	// 获取总库存量
-	var stockResult []bson.M
-	stockPipeline := mongo.Pipeline{
-		{{"$group", bson.M{"_id": nil, "totalStock": bson.M{"$sum": "$stock"}}}},
-	}
-
-	stockCursor, err := productsCollection.Aggregate(ctx, stockPipeline)
+	stockSum, err := repository.Products().Sum(ctx, bson.M{}, "stock")
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
-	defer stockCursor.Close(ctx)
-
-	if err := stockCursor.All(ctx, &stockResult); err != nil {
-		utils.HandleError(c, err)
-		return
-	}
-
-	totalStock := int64(0)
-	if len(stockResult) > 0 {
-		if total, ok := stockResult[0]["totalStock"].(int64); ok {
-			totalStock = total
-		}
-	}
+	totalStock := int64(stockSum)
 
 	// 获取最近30天的库存变动
-	recordsCollection := repository.Collection(repository.InventoryRecordsCollection)
 	fromDate := time.Now().AddDate(0, 0, -30)
 
 	// 获取入库操作总量
-	var inOperations []bson.M
-	inPipeline := mongo.Pipeline{
-		{{"$match", bson.M{"operationType": "in", "operationTime": bson.M{"$gte": fromDate}}}},
-		{{"$group", bson.M{"_id": nil, "total": bson.M{"$sum": "$quantity"}}}},
-	}
-
-	inCursor, err := recordsCollection.Aggregate(ctx, inPipeline)
+	inSum, err := repository.Inventory().Sum(ctx, bson.M{
+		"operationType": "in",
+		"operationTime": bson.M{"$gte": fromDate},
+	}, "quantity")
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
-	defer inCursor.Close(ctx)
-
-	if err := inCursor.All(ctx, &inOperations); err != nil {
-		utils.HandleError(c, err)
-		return
-	}
 
 	// 获取出库操作总量
-	var outOperations []bson.M
-	outPipeline := mongo.Pipeline{
-		{{"$match", bson.M{"operationType": "out", "operationTime": bson.M{"$gte": fromDate}}}},
-		{{"$group", bson.M{"_id": nil, "total": bson.M{"$sum": "$quantity"}}}},
-	}
-
-	outCursor, err := recordsCollection.Aggregate(ctx, outPipeline)
+	outSum, err := repository.Inventory().Sum(ctx, bson.M{
+		"operationType": "out",
+		"operationTime": bson.M{"$gte": fromDate},
+	}, "quantity")
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
-	defer outCursor.Close(ctx)
-
-	if err := outCursor.All(ctx, &outOperations); err != nil {
-		utils.HandleError(c, err)
-		return
-	}
 
-	totalIn := int64(0)
-	if len(inOperations) > 0 {
-		if total, ok := inOperations[0]["total"].(int64); ok {
-			totalIn = total
-		}
-	}
-
-	totalOut := int64(0)
-	if len(outOperations) > 0 {
-		if total, ok := outOperations[0]["total"].(int64); ok {
-			totalOut = total
-		}
-	}
+	totalIn := int64(inSum)
+	totalOut := int64(outSum)
 
 	// 返回结果
 	stats := models.InventoryStats{
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/BerniceZTT/crm_end/pagination"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+)
+
+// notificationSort 站内通知列表可排序的字段
+var notificationSort = pagination.Spec{
+	SortFields: map[string]string{
+		"createdAt": "createdAt",
+	},
+	DefaultSort:  "createdAt",
+	DefaultOrder: -1,
+	DefaultLimit: 20,
+}
+
+// GetNotifications 当前用户的站内通知，unread=true 时只返回未读通知
+func GetNotifications(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	pageReq, err := pagination.FromQuery(c.Request.URL.Query(), notificationSort)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	filter := bson.M{"recipientId": user.ID}
+	if c.Query("unread") == "true" {
+		filter["read"] = false
+	}
+	if notificationType := c.Query("type"); notificationType != "" {
+		filter["type"] = notificationType
+	}
+
+	result, err := pagination.Find(c.Request.Context(), repository.Notifications(), filter, pageReq)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	utils.PaginatedResponse(c, result.Items, result.Pagination)
+}
+
+// GetUnreadNotificationCount 当前用户的未读通知数
+func GetUnreadNotificationCount(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	count, err := repository.Notifications().Count(c.Request.Context(), bson.M{"recipientId": user.ID, "read": false})
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"count": count}, "")
+}
+
+// MarkNotificationRead 将当前用户的一条通知标记为已读
+func MarkNotificationRead(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的通知ID"})
+		return
+	}
+
+	result, err := repository.Notifications().MarkRead(c.Request.Context(), objID, user.ID)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	if result.MatchedCount == 0 {
+		utils.HandleError(c, utils.CreateNotFoundError("通知"))
+		return
+	}
+	utils.SuccessResponse(c, nil, "已标记为已读")
+}
+
+// MarkAllNotificationsRead 将当前用户的全部未读通知标记为已读
+func MarkAllNotificationsRead(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := repository.Notifications().MarkAllRead(c.Request.Context(), user.ID)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"updated": result.ModifiedCount}, "已全部标记为已读")
+}
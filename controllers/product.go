@@ -1,7 +1,6 @@
 package controllers
 
 import (
-	"context"
 	"encoding/csv"
 	"errors"
 	"fmt"
@@ -17,8 +16,6 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func GetProductList(c *gin.Context) {
@@ -49,10 +46,8 @@ func GetProductList(c *gin.Context) {
 		}
 	}
 
-	collection := repository.Collection(repository.ProductsCollection)
-
 	// 获取总数
-	totalCount, err := collection.CountDocuments(context.Background(), searchQuery)
+	totalCount, err := repository.Products().Count(c.Request.Context(), searchQuery)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
@@ -68,7 +63,7 @@ func GetProductList(c *gin.Context) {
 		utils.LogInfo(nil, "产品集合中没有数据")
 
 		// 检查集合是否存在
-		ok, err := repository.CollectionExists(repository.ProductsCollection)
+		ok, err := repository.CollectionExists(c.Request.Context(), repository.ProductsCollection)
 		if err != nil {
 			utils.HandleError(c, err)
 			return
@@ -80,23 +75,17 @@ func GetProductList(c *gin.Context) {
 	}
 
 	// 查询数据
-	findOptions := options.Find().
-		SetSort(bson.D{{"modelName", 1}, {"packageType", 1}})
+	findOptions := repository.NewFindOptions().
+		SetSort("modelName", 1).
+		SetSort("packageType", 1)
 		// .SetSkip(int64(skip)).
 		// SetLimit(int64(limit))
 
-	cursor, err := collection.Find(context.Background(), searchQuery, findOptions)
+	products, err := repository.Products().Find(c.Request.Context(), searchQuery, findOptions)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
-	defer cursor.Close(context.Background())
-
-	var products []models.Product
-	if err := cursor.All(context.Background(), &products); err != nil {
-		utils.HandleError(c, err)
-		return
-	}
 
 	// 详细记录查询结果
 	var firstProduct string
@@ -175,10 +164,7 @@ func GetProduct(c *gin.Context) {
 		return
 	}
 
-	collection := repository.Collection(repository.ProductsCollection)
-
-	var product models.Product
-	err = collection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&product)
+	product, err := repository.Products().FindByID(c.Request.Context(), objectID)
 	if err != nil {
 		utils.ErrorResponse(c, "产品不存在", http.StatusNotFound)
 		return
@@ -197,13 +183,6 @@ func CreateProduct(c *gin.Context) {
 		return
 	}
 
-	// 验证权限
-	if user.Role != string(models.UserRoleSUPER_ADMIN) &&
-		user.Role != string(models.UserRoleINVENTORY_MANAGER) {
-		utils.ErrorResponse(c, "无权创建产品", http.StatusForbidden)
-		return
-	}
-
 	var productData models.Product
 	if err := c.ShouldBindJSON(&productData); err != nil {
 		utils.ErrorResponse(c, "无效的产品数据: "+err.Error(), http.StatusBadRequest)
@@ -232,19 +211,15 @@ func CreateProduct(c *gin.Context) {
 		"packageType": productData.PackageType,
 	}, "开始创建产品")
 
-	collection := repository.Collection(repository.ProductsCollection)
-
 	// 检查产品是否已存在
-	count, err := collection.CountDocuments(context.Background(), bson.M{
-		"modelName":   productData.ModelName,
-		"packageType": productData.PackageType,
-	})
+	exists, err := repository.Products().ExistsByModel(c.Request.Context(),
+		productData.ModelName, productData.PackageType, primitive.NilObjectID)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
-	if count > 0 {
+	if exists {
 		utils.ErrorResponse(c, "该型号产品的封装类型已存在", http.StatusBadRequest)
 		return
 	}
@@ -255,22 +230,19 @@ func CreateProduct(c *gin.Context) {
 	productData.UpdatedAt = now
 
 	// 创建新产品
-	result, err := collection.InsertOne(context.Background(), productData)
+	productID, err := repository.Products().Insert(c.Request.Context(), &productData)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
-	productID := result.InsertedID.(primitive.ObjectID)
 	utils.LogInfo(map[string]interface{}{
 		"productId": productID.Hex(),
 	}, "产品成功插入数据库")
 
 	// 如果有初始库存，创建库存记录
 	if productData.Stock > 0 {
-		recordsCollection := repository.Collection(repository.InventoryRecordsCollection)
-
-		_, err := recordsCollection.InsertOne(context.Background(), models.InventoryRecord{
+		_, err := repository.Inventory().Insert(c.Request.Context(), &models.InventoryRecord{
 			ProductID:     productID.Hex(),
 			ModelName:     productData.ModelName,
 			PackageType:   productData.PackageType,
@@ -297,10 +269,9 @@ func CreateProduct(c *gin.Context) {
 	}
 
 	// 重新查询产品以获取完整信息
-	var newProduct models.Product
-	err = collection.FindOne(context.Background(), bson.M{"_id": productID}).Decode(&newProduct)
+	newProduct, err := repository.Products().FindByID(c.Request.Context(), productID)
 	if err != nil {
-		newProduct = productData
+		newProduct = &productData
 		newProduct.ID = productID
 	}
 
@@ -316,12 +287,6 @@ func BulkImportProducts(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
-	// 验证权限
-	if models.UserRole(user.Role) != models.UserRoleSUPER_ADMIN &&
-		models.UserRole(user.Role) != models.UserRoleINVENTORY_MANAGER {
-		utils.ErrorResponse(c, "无权导入产品", http.StatusForbidden)
-		return
-	}
 
 	var request models.BulkImportProduct
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -334,8 +299,6 @@ func BulkImportProducts(c *gin.Context) {
 		return
 	}
 
-	collection := repository.Collection(repository.ProductsCollection)
-
 	// 检查产品是否已存在
 	var orConditions []bson.M
 	for _, product := range request.Products {
@@ -345,18 +308,12 @@ func BulkImportProducts(c *gin.Context) {
 		})
 	}
 
-	cursor, err := collection.Find(context.Background(), bson.M{"$or": orConditions})
+	existingProducts, err := repository.Products().Find(c.Request.Context(), bson.M{"$or": orConditions})
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
-	var existingProducts []models.Product
-	if err := cursor.All(context.Background(), &existingProducts); err != nil {
-		utils.HandleError(c, err)
-		return
-	}
-
 	if len(existingProducts) > 0 {
 		var duplicateProducts []string
 		for _, p := range existingProducts {
@@ -378,12 +335,7 @@ func BulkImportProducts(c *gin.Context) {
 	}
 
 	// 插入所有产品
-	var productsToInsert []interface{}
-	for _, p := range request.Products {
-		productsToInsert = append(productsToInsert, p)
-	}
-
-	result, err := collection.InsertMany(context.Background(), productsToInsert)
+	insertedIDs, err := repository.Products().InsertMany(c.Request.Context(), request.Products)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
@@ -395,9 +347,9 @@ func BulkImportProducts(c *gin.Context) {
 	}
 
 	var insertedProducts []InsertedProduct
-	for i, insertedID := range result.InsertedIDs {
+	for i, insertedID := range insertedIDs {
 		insertedProducts = append(insertedProducts, InsertedProduct{
-			ProductID: insertedID.(primitive.ObjectID), // 转换为 ObjectID
+			ProductID: insertedID,
 			Product:   request.Products[i],
 		})
 	}
@@ -407,8 +359,7 @@ func BulkImportProducts(c *gin.Context) {
 		productData := p.Product
 		productID := p.ProductID
 		if productData.Stock > 0 {
-			recordsCollection := repository.Collection(repository.InventoryRecordsCollection)
-			_, err := recordsCollection.InsertOne(context.Background(), models.InventoryRecord{
+			_, err := repository.Inventory().Insert(c.Request.Context(), &models.InventoryRecord{
 				ProductID:     productID.Hex(),
 				ModelName:     productData.ModelName,
 				PackageType:   productData.PackageType,
@@ -437,23 +388,12 @@ func BulkImportProducts(c *gin.Context) {
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message":       "批量导入产品成功",
-		"insertedCount": len(result.InsertedIDs),
+		"insertedCount": len(insertedIDs),
 	})
 }
 
 func UpdateProduct(c *gin.Context) {
 	id := c.Param("id")
-	user, err := utils.GetUser(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-		return
-	}
-	// 验证权限
-	if models.UserRole(user.Role) != models.UserRoleSUPER_ADMIN &&
-		models.UserRole(user.Role) != models.UserRoleINVENTORY_MANAGER {
-		utils.ErrorResponse(c, "无权更新产品", http.StatusForbidden)
-		return
-	}
 
 	var updateData map[string]interface{}
 	if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -473,11 +413,8 @@ func UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	collection := repository.Collection(repository.ProductsCollection)
-
 	// 检查产品是否存在
-	var product models.Product
-	err = collection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&product)
+	product, err := repository.Products().FindByID(c.Request.Context(), objectID)
 	if err != nil {
 		utils.ErrorResponse(c, "产品不存在", http.StatusNotFound)
 		return
@@ -499,17 +436,13 @@ func UpdateProduct(c *gin.Context) {
 			newPackageType = packageType
 		}
 
-		count, err := collection.CountDocuments(context.Background(), bson.M{
-			"modelName":   newModelName,
-			"packageType": newPackageType,
-			"_id":         bson.M{"$ne": objectID},
-		})
+		exists, err := repository.Products().ExistsByModel(c.Request.Context(), newModelName, newPackageType, objectID)
 		if err != nil {
 			utils.HandleError(c, err)
 			return
 		}
 
-		if count > 0 {
+		if exists {
 			utils.ErrorResponse(c, "该型号产品的封装类型已存在", http.StatusBadRequest)
 			return
 		}
@@ -519,11 +452,7 @@ func UpdateProduct(c *gin.Context) {
 	updateData["updatedAt"] = time.Now()
 
 	// 更新产品
-	result, err := collection.UpdateOne(
-		context.Background(),
-		bson.M{"_id": objectID},
-		bson.M{"$set": updateData},
-	)
+	result, err := repository.Products().UpdateByID(c.Request.Context(), objectID, bson.M{"$set": updateData})
 	if err != nil {
 		utils.HandleError(c, err)
 		return
@@ -543,17 +472,6 @@ func UpdateProduct(c *gin.Context) {
 
 func DeleteProduct(c *gin.Context) {
 	id := c.Param("id")
-	user, err := utils.GetUser(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-		return
-	}
-
-	// 验证权限
-	if models.UserRole(user.Role) != models.UserRoleSUPER_ADMIN {
-		utils.ErrorResponse(c, "无权删除产品", http.StatusForbidden)
-		return
-	}
 
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -561,19 +479,15 @@ func DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	collection := repository.Collection(repository.ProductsCollection)
-
 	// 检查产品是否存在
-	var product models.Product
-	err = collection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&product)
+	product, err := repository.Products().FindByID(c.Request.Context(), objectID)
 	if err != nil {
 		utils.ErrorResponse(c, "产品不存在", http.StatusNotFound)
 		return
 	}
 
 	// 检查是否有客户正在使用该产品
-	customersCollection := repository.Collection(repository.CustomersCollection)
-	customerCount, err := customersCollection.CountDocuments(context.Background(), bson.M{
+	customerCount, err := repository.Customers().Count(c.Request.Context(), bson.M{
 		"productNeeds": bson.M{
 			"$elemMatch": bson.M{
 				"$regex": product.ModelName,
@@ -594,13 +508,13 @@ func DeleteProduct(c *gin.Context) {
 	}
 
 	// 删除产品
-	result, err := collection.DeleteOne(context.Background(), bson.M{"_id": objectID})
+	deletedCount, err := repository.Products().DeleteByID(c.Request.Context(), objectID)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
-	if result.DeletedCount == 0 {
+	if deletedCount == 0 {
 		utils.ErrorResponse(c, "产品不存在或已被删除", http.StatusNotFound)
 		return
 	}
@@ -617,12 +531,6 @@ func StockInProduct(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
-	// 验证权限
-	if models.UserRole(user.Role) != models.UserRoleSUPER_ADMIN &&
-		models.UserRole(user.Role) != models.UserRoleINVENTORY_MANAGER {
-		utils.ErrorResponse(c, "无权执行入库操作", http.StatusForbidden)
-		return
-	}
 
 	var operation models.StockOperation
 	if err := c.ShouldBindJSON(&operation); err != nil {
@@ -642,11 +550,8 @@ func StockInProduct(c *gin.Context) {
 		return
 	}
 
-	collection := repository.Collection(repository.ProductsCollection)
-
 	// 检查产品是否存在
-	var product models.Product
-	err = collection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&product)
+	product, err := repository.Products().FindByID(c.Request.Context(), objectID)
 	if err != nil {
 		utils.ErrorResponse(c, "产品不存在", http.StatusNotFound)
 		return
@@ -659,35 +564,28 @@ func StockInProduct(c *gin.Context) {
 	operationResult, err := utils.ExecuteInventoryOperation(
 		// 检查此操作是否已经完成
 		func() (bool, error) {
-			recordsCollection := repository.Collection(repository.InventoryRecordsCollection)
-			count, err := recordsCollection.CountDocuments(context.Background(), bson.M{
+			return repository.Inventory().ExistsOperation(c.Request.Context(), bson.M{
 				"productId":     id,
 				"operationType": "in",
 				"quantity":      operation.Quantity,
 				"operationId":   operationId,
 				"operationTime": bson.M{"$gte": time.Now().Add(-5 * time.Minute)},
 			})
-			return count > 0, err
 		},
 		// 实际执行的操作逻辑
 		func() (interface{}, error) {
 			// 第一步：更新库存
-			updateResult, err := collection.UpdateOne(
-				context.Background(),
-				bson.M{"_id": objectID},
-				bson.M{"$inc": bson.M{"stock": operation.Quantity}},
-			)
+			updated, err := repository.Products().IncrementStock(c.Request.Context(), objectID, operation.Quantity)
 			if err != nil {
 				return nil, err
 			}
 
-			if updateResult.ModifiedCount == 0 {
+			if !updated {
 				return nil, errors.New("库存更新失败")
 			}
 
 			// 第二步：创建入库记录
-			recordsCollection := repository.Collection(repository.InventoryRecordsCollection)
-			recordResult, err := recordsCollection.InsertOne(context.Background(), models.InventoryRecord{
+			_, err = repository.Inventory().Insert(c.Request.Context(), &models.InventoryRecord{
 				ProductID:     id,
 				ModelName:     product.ModelName,
 				PackageType:   product.PackageType,
@@ -712,17 +610,8 @@ func StockInProduct(c *gin.Context) {
 				}, nil
 			}
 
-			if recordResult.InsertedID == nil {
-				return map[string]interface{}{
-					"statusUncertain":    true,
-					"inventoryOperation": true,
-					"message":            "入库操作状态不确定，请刷新页面查看最新库存",
-				}, nil
-			}
-
 			// 第三步：重新查询更新后的库存
-			var updatedProduct models.Product
-			err = collection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&updatedProduct)
+			updatedProduct, err := repository.Products().FindByID(c.Request.Context(), objectID)
 			if err != nil {
 				return nil, err
 			}
@@ -786,13 +675,6 @@ func StockOutProduct(c *gin.Context) {
 		return
 	}
 
-	// 验证权限
-	if models.UserRole(user.Role) != models.UserRoleSUPER_ADMIN &&
-		models.UserRole(user.Role) != models.UserRoleINVENTORY_MANAGER {
-		utils.ErrorResponse(c, "无权执行出库操作", http.StatusForbidden)
-		return
-	}
-
 	var operation models.StockOperation
 	if err := c.ShouldBindJSON(&operation); err != nil {
 		utils.ErrorResponse(c, "无效的操作数据: "+err.Error(), http.StatusBadRequest)
@@ -811,11 +693,8 @@ func StockOutProduct(c *gin.Context) {
 		return
 	}
 
-	collection := repository.Collection(repository.ProductsCollection)
-
 	// 检查产品是否存在
-	var product models.Product
-	err = collection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&product)
+	product, err := repository.Products().FindByID(c.Request.Context(), objectID)
 	if err != nil {
 		utils.ErrorResponse(c, "产品不存在", http.StatusNotFound)
 		return
@@ -834,35 +713,25 @@ func StockOutProduct(c *gin.Context) {
 	operationResult, err := utils.ExecuteInventoryOperation(
 		// 检查此操作是否已经完成
 		func() (bool, error) {
-			recordsCollection := repository.Collection(repository.InventoryRecordsCollection)
-			count, err := recordsCollection.CountDocuments(context.Background(), bson.M{
+			return repository.Inventory().ExistsOperation(c.Request.Context(), bson.M{
 				"productId":     id,
 				"operationType": "out",
 				"quantity":      operation.Quantity,
 				"operationId":   operationId,
 				"operationTime": bson.M{"$gte": time.Now().Add(-5 * time.Minute)},
 			})
-			return count > 0, err
 		},
 		// 实际执行的操作逻辑
 		func() (interface{}, error) {
 			// 第一步：更新库存
-			updateResult, err := collection.UpdateOne(
-				context.Background(),
-				bson.M{
-					"_id":   objectID,
-					"stock": bson.M{"$gte": operation.Quantity},
-				},
-				bson.M{"$inc": bson.M{"stock": -operation.Quantity}},
-			)
+			updated, err := repository.Products().IncrementStock(c.Request.Context(), objectID, -operation.Quantity)
 			if err != nil {
 				return nil, err
 			}
 
-			if updateResult.ModifiedCount == 0 {
+			if !updated {
 				// 再次检查是否因为库存不足导致的更新失败
-				var currentProduct models.Product
-				err = collection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&currentProduct)
+				currentProduct, err := repository.Products().FindByID(c.Request.Context(), objectID)
 				if err != nil {
 					return nil, err
 				}
@@ -875,9 +744,7 @@ func StockOutProduct(c *gin.Context) {
 			}
 
 			// 第二步：创建出库记录
-			recordsCollection := repository.Collection(repository.InventoryRecordsCollection)
-
-			recordResult, err := recordsCollection.InsertOne(context.Background(), models.InventoryRecord{
+			_, err = repository.Inventory().Insert(c.Request.Context(), &models.InventoryRecord{
 				ProductID:     id,
 				ModelName:     product.ModelName,
 				PackageType:   product.PackageType,
@@ -902,17 +769,8 @@ func StockOutProduct(c *gin.Context) {
 				}, nil
 			}
 
-			if recordResult.InsertedID == nil {
-				return map[string]interface{}{
-					"statusUncertain":    true,
-					"inventoryOperation": true,
-					"message":            "出库操作状态不确定，请刷新页面查看最新库存",
-				}, nil
-			}
-
 			// 第三步：重新查询更新后的库存
-			var updatedProduct models.Product
-			err = collection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&updatedProduct)
+			updatedProduct, err := repository.Products().FindByID(c.Request.Context(), objectID)
 			if err != nil {
 				return nil, err
 			}
@@ -975,22 +833,12 @@ func BulkStockProduct(c *gin.Context) {
 		return
 	}
 
-	// 验证权限
-	if models.UserRole(user.Role) != models.UserRoleSUPER_ADMIN &&
-		models.UserRole(user.Role) != models.UserRoleINVENTORY_MANAGER {
-		utils.ErrorResponse(c, "无权执行批量库存操作", http.StatusForbidden)
-		return
-	}
-
 	var request models.BulkStockOperation
 	if err := c.ShouldBindJSON(&request); err != nil {
 		utils.ErrorResponse(c, "无效的请求数据: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	collection := repository.Collection(repository.ProductsCollection)
-	recordsCollection := repository.Collection(repository.InventoryRecordsCollection)
-
 	for _, operation := range request.Operations {
 		objectID, err := primitive.ObjectIDFromHex(operation.ProductID)
 		if err != nil {
@@ -998,20 +846,15 @@ func BulkStockProduct(c *gin.Context) {
 			return
 		}
 
-		var product models.Product
-		err = collection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&product)
+		product, err := repository.Products().FindByID(c.Request.Context(), objectID)
 		if err != nil {
 			utils.ErrorResponse(c, fmt.Sprintf("产品 %s 不存在", operation.ProductID), http.StatusNotFound)
 			return
 		}
 
-		var result *mongo.UpdateResult
+		updated := false
 		if operation.Type == "in" {
-			result, err = collection.UpdateOne(
-				context.Background(),
-				bson.M{"_id": objectID},
-				bson.M{"$inc": bson.M{"stock": operation.Quantity}},
-			)
+			updated, err = repository.Products().IncrementStock(c.Request.Context(), objectID, operation.Quantity)
 		} else if operation.Type == "out" {
 			if product.Stock < operation.Quantity {
 				c.JSON(http.StatusBadRequest, gin.H{
@@ -1021,11 +864,7 @@ func BulkStockProduct(c *gin.Context) {
 				return
 			}
 
-			result, err = collection.UpdateOne(
-				context.Background(),
-				bson.M{"_id": objectID},
-				bson.M{"$inc": bson.M{"stock": -operation.Quantity}},
-			)
+			updated, err = repository.Products().IncrementStock(c.Request.Context(), objectID, -operation.Quantity)
 		}
 
 		if err != nil {
@@ -1033,14 +872,14 @@ func BulkStockProduct(c *gin.Context) {
 			return
 		}
 
-		if result.ModifiedCount == 0 {
+		if !updated {
 			c.JSON(http.StatusOK, gin.H{
 				"message": fmt.Sprintf("操作 %s 未生效", operation.ProductID),
 			})
 			return
 		}
 
-		_, err = recordsCollection.InsertOne(context.Background(), models.InventoryRecord{
+		_, err = repository.Inventory().Insert(c.Request.Context(), &models.InventoryRecord{
 			ProductID:     operation.ProductID,
 			ModelName:     product.ModelName,
 			PackageType:   product.PackageType,
@@ -1073,20 +912,12 @@ func ExportProduct(c *gin.Context) {
 		"userId": user.ID,
 	}, "请求产品数据导出 - CSV格式")
 
-	collection := repository.Collection(repository.ProductsCollection)
-
-	cursor, err := collection.Find(context.Background(), bson.M{}, options.Find().SetSort(bson.D{{"modelName", 1}, {"packageType", 1}}))
+	products, err := repository.Products().Find(c.Request.Context(), bson.M{},
+		repository.NewFindOptions().SetSort("modelName", 1).SetSort("packageType", 1))
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
-	defer cursor.Close(context.Background())
-
-	var products []models.Product
-	if err := cursor.All(context.Background(), &products); err != nil {
-		utils.HandleError(c, err)
-		return
-	}
 
 	utils.LogInfo(map[string]interface{}{}, fmt.Sprintf("准备导出 %d 条产品记录", len(products)))
 
@@ -2,7 +2,6 @@ package controllers
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,10 +11,10 @@ import (
 	"github.com/go-playground/validator/v10"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/pagination"
+	"github.com/BerniceZTT/crm_end/policy"
 	"github.com/BerniceZTT/crm_end/repository"
 	"github.com/BerniceZTT/crm_end/service"
 	"github.com/BerniceZTT/crm_end/utils"
@@ -30,6 +29,18 @@ func init() {
 	validate = validator.New()
 }
 
+// projectListSort 项目列表可排序的字段
+var projectListSort = pagination.Spec{
+	SortFields: map[string]string{
+		"createdAt":   "createdAt",
+		"updatedAt":   "updatedAt",
+		"startDate":   "startDate",
+		"projectName": "projectName",
+	},
+	DefaultSort:  "createdAt",
+	DefaultOrder: -1,
+}
+
 // 1. 获取所有项目列表
 func GetAllProjects(c *gin.Context) {
 	currentUser, err := utils.GetUser(c)
@@ -37,32 +48,28 @@ func GetAllProjects(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
+	pageReq, err := pagination.FromQuery(c.Request.URL.Query(), projectListSort)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
 	username := currentUser.Username
 	role := currentUser.Role
 
 	log.Printf("[项目路由] 获取项目列表 - 用户: %s, 角色: %s", username, role)
 
-	// 检查用户是否有权限访问项目管理
-	if !(role == string(models.UserRoleSUPER_ADMIN) || role == string(models.UserRoleFACTORY_SALES) || role == string(models.UserRoleAGENT)) {
-		log.Printf("[项目路由] 用户 %s 无权访问项目管理", username)
-		c.JSON(http.StatusForbidden, gin.H{"error": "无权访问项目管理"})
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 	defer cancel()
 
-	collection := repository.Collection(repository.ProjectsCollection)
-
 	// 获取用户可访问的客户ID列表
-	customerIds, err := getUserAccessibleCustomerIds(currentUser)
+	customerIds, err := getUserAccessibleCustomerIds(ctx, currentUser)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	if role == string(models.UserRoleFACTORY_SALES) && len(customerIds) == 0 {
-		c.JSON(http.StatusOK, models.ProjectListResponse{Projects: []models.ProjectResponse{}})
+		utils.PaginatedResponse(c, []models.ProjectResponse{}, models.NewPagination(0, pageReq.Page, pageReq.Limit))
 		return
 	}
 
@@ -72,25 +79,19 @@ func GetAllProjects(c *gin.Context) {
 		filter["customerId"] = bson.M{"$in": customerIds}
 	}
 
-	// 第一次查询：获取基础项目列表
-	var projects []models.Project
-	opts := options.Find().
-		SetSort(bson.M{"createdAt": -1}).
-		SetLimit(1000).
+	// 第一次查询：获取一页基础项目列表
+	opts := repository.NewFindOptions().
 		SetProjection(bson.M{
 			"smallBatchAttachments":     0,
 			"massProductionAttachments": 0,
 		})
 
-	cursor, err := collection.Find(ctx, filter, opts)
+	result, err := pagination.Find(ctx, repository.Projects(), filter, pageReq, opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取项目列表失败"})
 		return
 	}
-	if err = cursor.All(ctx, &projects); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "解析项目列表失败"})
-		return
-	}
+	projects := result.Items
 
 	// 提取所有关联的客户ID
 	customerIDMap := make(map[primitive.ObjectID]bool)
@@ -106,14 +107,10 @@ func GetAllProjects(c *gin.Context) {
 			customerIDs = append(customerIDs, id)
 		}
 
-		customerFilter := bson.M{"_id": bson.M{"$in": customerIDs}}
-		customerCursor, err := repository.Collection(repository.CustomersCollection).Find(ctx, customerFilter)
+		customers, err := repository.Customers().FindByIDs(ctx, customerIDs)
 		if err == nil {
-			var customers []models.Customer
-			if err = customerCursor.All(ctx, &customers); err == nil {
-				for _, cust := range customers {
-					customerInfos[cust.ID] = cust
-				}
+			for _, cust := range customers {
+				customerInfos[cust.ID] = cust
 			}
 		}
 	}
@@ -132,7 +129,7 @@ func GetAllProjects(c *gin.Context) {
 		normalizedProjects[i] = resp
 	}
 
-	c.JSON(http.StatusOK, models.ProjectListResponse{Projects: normalizedProjects})
+	utils.PaginatedResponse(c, normalizedProjects, result.Pagination)
 }
 
 // 2. 获取客户的项目列表
@@ -148,7 +145,7 @@ func GetCustomerProjects(c *gin.Context) {
 
 	log.Printf("[项目路由] 获取客户项目列表 - 客户ID: %s, 用户: %s", customerID, username)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 	defer cancel()
 
 	// 验证客户ID格式
@@ -160,11 +157,9 @@ func GetCustomerProjects(c *gin.Context) {
 	}
 
 	// 查询客户信息
-	customerCollection := repository.Collection(repository.CustomersCollection)
-	var customer models.Customer
-	err = customerCollection.FindOne(ctx, bson.M{"_id": customerObjID}).Decode(&customer)
+	customer, err := repository.Customers().FindByID(ctx, customerObjID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "客户不存在"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询客户失败"})
@@ -173,40 +168,22 @@ func GetCustomerProjects(c *gin.Context) {
 	}
 
 	// 权限检查
-	if currentUser.Role != string(models.UserRoleSUPER_ADMIN) {
-		switch models.UserRole(currentUser.Role) {
-		case models.UserRoleFACTORY_SALES:
-			if customer.RelatedSalesID != currentUser.ID && !customer.IsInPublicPool {
-				c.JSON(http.StatusForbidden, gin.H{"error": "无权访问该客户的项目"})
-				return
-			}
-		case models.UserRoleAGENT:
-			if customer.RelatedAgentID != currentUser.ID && !customer.IsInPublicPool {
-				c.JSON(http.StatusForbidden, gin.H{"error": "无权访问该客户的项目"})
-				return
-			}
-		}
+	if !policy.CanAccessProject(currentUser, customer, policy.ActionRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权访问该客户的项目"})
+		return
 	}
 
 	// 查询项目列表
-	projectCollection := repository.Collection(repository.ProjectsCollection)
-	opts := options.Find().SetProjection(bson.M{
+	opts := repository.NewFindOptions().SetProjection(bson.M{
 		"smallBatchAttachments":     0,
 		"massProductionAttachments": 0,
 	})
 
-	cursor, err := projectCollection.Find(ctx, bson.M{"customerId": customerObjID, "webHidden": false}, opts)
+	projects, err := repository.Projects().FindVisibleByCustomerID(ctx, customerObjID, opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询项目失败"})
 		return
 	}
-	defer cursor.Close(ctx)
-
-	var projects []models.Project
-	if err = cursor.All(ctx, &projects); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "解析项目失败"})
-		return
-	}
 
 	// 标准化项目数据
 	normalizedProjects := make([]models.ProjectResponse, len(projects))
@@ -233,7 +210,7 @@ func DownloadProjectFile(c *gin.Context) {
 
 	log.Printf("[项目路由] 文件下载请求 - 项目ID: %s, 文件ID: %s, 用户: %s", projectID, fileID, username)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 	defer cancel()
 
 	// 验证项目ID格式
@@ -244,11 +221,9 @@ func DownloadProjectFile(c *gin.Context) {
 	}
 
 	// 查询项目
-	projectCollection := repository.Collection(repository.ProjectsCollection)
-	var project models.Project
-	err = projectCollection.FindOne(ctx, bson.M{"_id": projectObjID}).Decode(&project)
+	project, err := repository.Projects().FindByID(ctx, projectObjID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "项目不存在"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询项目失败"})
@@ -257,12 +232,10 @@ func DownloadProjectFile(c *gin.Context) {
 	}
 
 	// 查询关联客户
-	customerCollection := repository.Collection(repository.CustomersCollection)
 	customerObjID := project.CustomerID
-	var customer models.Customer
-	err = customerCollection.FindOne(ctx, bson.M{"_id": customerObjID}).Decode(&customer)
+	customer, err := repository.Customers().FindByID(ctx, customerObjID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "关联客户不存在"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询客户失败"})
@@ -271,19 +244,9 @@ func DownloadProjectFile(c *gin.Context) {
 	}
 
 	// 权限检查
-	if currentUser.Role != string(models.UserRoleSUPER_ADMIN) {
-		switch models.UserRole(currentUser.Role) {
-		case models.UserRoleFACTORY_SALES:
-			if customer.RelatedSalesID != currentUser.ID && !customer.IsInPublicPool {
-				c.JSON(http.StatusForbidden, gin.H{"error": "无权下载该项目文件"})
-				return
-			}
-		case models.UserRoleAGENT:
-			if customer.RelatedAgentID != currentUser.ID && !customer.IsInPublicPool {
-				c.JSON(http.StatusForbidden, gin.H{"error": "无权下载该项目文件"})
-				return
-			}
-		}
+	if !policy.CanAccessProject(currentUser, customer, policy.ActionRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权下载该项目文件"})
+		return
 	}
 
 	// 查找文件
@@ -330,7 +293,7 @@ func GetProjectDetail(c *gin.Context) {
 	}
 	projectID := c.Param("id")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 	defer cancel()
 
 	// 验证项目ID格式
@@ -342,11 +305,9 @@ func GetProjectDetail(c *gin.Context) {
 	}
 
 	// 查询项目详情
-	projectCollection := repository.Collection(repository.ProjectsCollection)
-	var project models.Project
-	err = projectCollection.FindOne(ctx, bson.M{"_id": projectObjID}).Decode(&project)
+	project, err := repository.Projects().FindByID(ctx, projectObjID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			log.Printf("项目不存在: %s", projectID)
 			c.JSON(http.StatusNotFound, gin.H{"error": "项目不存在"})
 		} else {
@@ -357,12 +318,10 @@ func GetProjectDetail(c *gin.Context) {
 	}
 
 	// 查询关联客户
-	customerCollection := repository.Collection(repository.CustomersCollection)
 	customerObjID := project.CustomerID
-	var customer models.Customer
-	err = customerCollection.FindOne(ctx, bson.M{"_id": customerObjID}).Decode(&customer)
+	customer, err := repository.Customers().FindByID(ctx, customerObjID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			log.Printf("项目关联客户不存在: %s", customerObjID.Hex())
 			c.JSON(http.StatusNotFound, gin.H{"error": "关联客户不存在"})
 		} else {
@@ -373,29 +332,14 @@ func GetProjectDetail(c *gin.Context) {
 	}
 
 	// 权限验证
-	if currentUser.Role != string(models.UserRoleSUPER_ADMIN) {
-		switch models.UserRole(currentUser.Role) {
-		case models.UserRoleFACTORY_SALES:
-			if customer.RelatedSalesID != currentUser.ID && !customer.IsInPublicPool {
-				log.Printf("销售权限不足: 用户%s, 客户销售%s", currentUser.ID, customer.RelatedSalesID)
-				c.JSON(http.StatusForbidden, gin.H{"error": "无权访问该项目"})
-				return
-			}
-		case models.UserRoleAGENT:
-			if customer.RelatedAgentID != currentUser.ID && !customer.IsInPublicPool {
-				log.Printf("代理权限不足: 用户%s, 客户代理%s", currentUser.ID, customer.RelatedAgentID)
-				c.JSON(http.StatusForbidden, gin.H{"error": "无权访问该项目"})
-				return
-			}
-		default:
-			c.JSON(http.StatusForbidden, gin.H{"error": "无权访问该项目"})
-			return
-		}
+	if !policy.CanAccessProject(currentUser, customer, policy.ActionRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权访问该项目"})
+		return
 	}
 
 	c.JSON(http.StatusOK, models.ProjectDetailResponse{
 		Success: true,
-		Project: project,
+		Project: *project,
 	})
 }
 
@@ -432,7 +376,7 @@ func CreateProject(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 	defer cancel()
 
 	// 转换客户ID
@@ -443,11 +387,9 @@ func CreateProject(c *gin.Context) {
 	}
 
 	// 查询客户
-	customerCollection := repository.Collection(repository.CustomersCollection)
-	var customer models.Customer
-	err = customerCollection.FindOne(ctx, bson.M{"_id": customerObjID}).Decode(&customer)
+	customer, err := repository.Customers().FindByID(ctx, customerObjID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "客户不存在"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询客户失败"})
@@ -456,19 +398,15 @@ func CreateProject(c *gin.Context) {
 	}
 
 	// 权限检查
-	if currentUser.Role != string(models.UserRoleSUPER_ADMIN) {
-		switch models.UserRole(currentUser.Role) {
-		case models.UserRoleFACTORY_SALES:
-			if customer.RelatedSalesID != currentUser.ID {
-				c.JSON(http.StatusForbidden, gin.H{"error": "无权为该客户创建项目"})
-				return
-			}
-		case models.UserRoleAGENT:
-			if customer.RelatedAgentID != currentUser.ID {
-				c.JSON(http.StatusForbidden, gin.H{"error": "无权为该客户创建项目"})
-				return
-			}
-		}
+	if !policy.CanAccessProject(currentUser, customer, policy.ActionUpdate) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权为该客户创建项目"})
+		return
+	}
+
+	// 创建项目后客户将转为正常推进，先校验状态变更是否合法
+	if err := service.ValidateProgressTransition(customer.Progress, models.CustomerProgressNormal); err != nil {
+		utils.HandleError(c, err)
+		return
 	}
 
 	// 转换产品ID
@@ -479,11 +417,9 @@ func CreateProject(c *gin.Context) {
 	}
 
 	// 查询产品
-	productCollection := repository.Collection(repository.ProductsCollection)
-	var product models.Product
-	err = productCollection.FindOne(ctx, bson.M{"_id": productObjID}).Decode(&product)
+	product, err := repository.Products().FindByID(ctx, productObjID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "产品不存在"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询产品失败"})
@@ -526,15 +462,13 @@ func CreateProject(c *gin.Context) {
 	}
 
 	// 插入项目
-	projectCollection := repository.Collection(repository.ProjectsCollection)
-	result, err := projectCollection.InsertOne(ctx, newProject)
+	insertedID, err := repository.Projects().Insert(ctx, &newProject)
 	if err != nil {
 		log.Printf("创建项目失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建项目失败: %v", err)})
 		return
 	}
 
-	insertedID := result.InsertedID.(primitive.ObjectID)
 	log.Printf("项目创建成功, ID: %s", insertedID.Hex())
 
 	// 记录项目进展历史
@@ -547,17 +481,17 @@ func CreateProject(c *gin.Context) {
 		OperatorName: currentUser.Username,
 		Remark:       "新建项目",
 	}
-	_, err = AddProjectProgressHistoryFn(context.Background(), input)
+	_, err = AddProjectProgressHistoryFn(c.Request.Context(), input)
 	if err != nil {
 		log.Printf("项目创建成功, AddProjectProgressHistoryFn err: %s", err.Error())
 	}
 
 	// 修改客户状态
-	err1 := service.UpdateCustomerProgress(ctx, customerObjID, models.CustomerProgressNormal)
+	err1 := service.UpdateCustomerProgress(ctx, customer, models.CustomerProgressNormal, currentUser, "新建项目")
 	if err1 != nil {
-		log.Printf("项目创建成功, UpdateCustomerProgress customerObjID: %v, err: %s", customerObjID, err.Error())
+		log.Printf("项目创建成功, UpdateCustomerProgress customerObjID: %v, err: %s", customerObjID, err1.Error())
 	}
-	err2 := service.UpdateCustomerProgressByName(ctx, customer.Name, models.CustomerProgressDisabled)
+	err2 := service.UpdateCustomerProgressByName(ctx, customer.Name, models.CustomerProgressDisabled, currentUser, "同名客户已正常推进")
 	if err2 != nil {
 		utils.HandleError(c, err2)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "客户为正常推进状态，修改其他同名客户信息报错"})
@@ -603,7 +537,7 @@ func UpdateProject(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 	defer cancel()
 
 	// 验证项目ID格式
@@ -615,11 +549,9 @@ func UpdateProject(c *gin.Context) {
 	}
 
 	// 查询现有项目
-	projectCollection := repository.Collection(repository.ProjectsCollection)
-	var existingProject models.Project
-	err = projectCollection.FindOne(ctx, bson.M{"_id": projectObjID}).Decode(&existingProject)
+	existingProject, err := repository.Projects().FindByID(ctx, projectObjID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "项目不存在"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询项目失败"})
@@ -628,11 +560,9 @@ func UpdateProject(c *gin.Context) {
 	}
 
 	// 查询关联客户
-	customerCollection := repository.Collection(repository.CustomersCollection)
-	var customer models.Customer
-	err = customerCollection.FindOne(ctx, bson.M{"_id": existingProject.CustomerID}).Decode(&customer)
+	customer, err := repository.Customers().FindByID(ctx, existingProject.CustomerID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "关联客户不存在"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询客户失败"})
@@ -641,19 +571,9 @@ func UpdateProject(c *gin.Context) {
 	}
 
 	// 权限检查
-	if currentUser.Role != string(models.UserRoleSUPER_ADMIN) {
-		switch models.UserRole(currentUser.Role) {
-		case models.UserRoleFACTORY_SALES:
-			if customer.RelatedSalesID != currentUser.ID {
-				c.JSON(http.StatusForbidden, gin.H{"error": "无权更新该项目"})
-				return
-			}
-		case models.UserRoleAGENT:
-			if customer.RelatedAgentID != currentUser.ID {
-				c.JSON(http.StatusForbidden, gin.H{"error": "无权更新该项目"})
-				return
-			}
-		}
+	if !policy.CanAccessProject(currentUser, customer, policy.ActionUpdate) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权更新该项目"})
+		return
 	}
 
 	// 构建更新数据
@@ -689,11 +609,9 @@ func UpdateProject(c *gin.Context) {
 		}
 
 		// 查询新产品
-		productCollection := repository.Collection(repository.ProductsCollection)
-		var product models.Product
-		err = productCollection.FindOne(ctx, bson.M{"_id": productObjID}).Decode(&product)
+		product, err := repository.Products().FindByID(ctx, productObjID)
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
+			if err == repository.ErrNotFound {
 				c.JSON(http.StatusNotFound, gin.H{"error": "产品不存在"})
 			} else {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "查询产品失败"})
@@ -750,11 +668,7 @@ func UpdateProject(c *gin.Context) {
 	progressChanged := req.ProjectProgress != "" && req.ProjectProgress != existingProject.ProjectProgress
 
 	// 执行更新
-	result, err := projectCollection.UpdateOne(
-		ctx,
-		bson.M{"_id": projectObjID},
-		bson.M{"$set": update},
-	)
+	result, err := repository.Projects().UpdateByID(ctx, projectObjID, bson.M{"$set": update})
 	if err != nil {
 		log.Printf("更新项目失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新项目失败"})
@@ -779,7 +693,7 @@ func UpdateProject(c *gin.Context) {
 			OperatorName: currentUser.Username,
 			Remark:       "更新项目",
 		}
-		AddProjectProgressHistoryFn(context.Background(), input)
+		AddProjectProgressHistoryFn(c.Request.Context(), input)
 	}
 
 	if result.ModifiedCount > 0 {
@@ -802,7 +716,7 @@ func DeleteProject(c *gin.Context) {
 
 	log.Printf("删除项目请求 - 项目ID: %s, 用户: %s", projectID, username)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 	defer cancel()
 
 	// 验证项目ID格式
@@ -814,11 +728,9 @@ func DeleteProject(c *gin.Context) {
 	}
 
 	// 查询项目
-	projectCollection := repository.Collection(repository.ProjectsCollection)
-	var project models.Project
-	err = projectCollection.FindOne(ctx, bson.M{"_id": projectObjID}).Decode(&project)
+	project, err := repository.Projects().FindByID(ctx, projectObjID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "项目不存在"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询项目失败"})
@@ -827,11 +739,9 @@ func DeleteProject(c *gin.Context) {
 	}
 
 	// 查询关联客户
-	customerCollection := repository.Collection(repository.CustomersCollection)
-	var customer models.Customer
-	err = customerCollection.FindOne(ctx, bson.M{"_id": project.CustomerID}).Decode(&customer)
+	customer, err := repository.Customers().FindByID(ctx, project.CustomerID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "关联客户不存在"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询客户失败"})
@@ -840,30 +750,20 @@ func DeleteProject(c *gin.Context) {
 	}
 
 	// 权限检查
-	if currentUser.Role != string(models.UserRoleSUPER_ADMIN) {
-		switch models.UserRole(currentUser.Role) {
-		case models.UserRoleFACTORY_SALES:
-			if customer.RelatedSalesID != currentUser.ID {
-				c.JSON(http.StatusForbidden, gin.H{"error": "无权删除该项目"})
-				return
-			}
-		case models.UserRoleAGENT:
-			if customer.RelatedAgentID != currentUser.ID {
-				c.JSON(http.StatusForbidden, gin.H{"error": "无权删除该项目"})
-				return
-			}
-		}
+	if !policy.CanAccessProject(currentUser, customer, policy.ActionDelete) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权删除该项目"})
+		return
 	}
 
 	// 删除项目
-	result, err := projectCollection.DeleteOne(ctx, bson.M{"_id": projectObjID})
+	deletedCount, err := repository.Projects().DeleteByID(ctx, projectObjID)
 	if err != nil {
 		log.Printf("删除项目失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除项目失败"})
 		return
 	}
 
-	if result.DeletedCount == 0 {
+	if deletedCount == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"error": "项目不存在"})
 		return
 	}
@@ -873,7 +773,7 @@ func DeleteProject(c *gin.Context) {
 }
 
 // 辅助函数：获取用户可访问的客户ID列表
-func getUserAccessibleCustomerIds(user *utils.LoginUser) ([]primitive.ObjectID, error) {
+func getUserAccessibleCustomerIds(ctx context.Context, user *utils.LoginUser) ([]primitive.ObjectID, error) {
 	startTime := time.Now()
 	log.Printf("开始获取用户 %s 的可访问客户列表", user.Username)
 
@@ -882,42 +782,18 @@ func getUserAccessibleCustomerIds(user *utils.LoginUser) ([]primitive.ObjectID,
 		return nil, nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	customerCollection := repository.Collection(repository.CustomersCollection)
-
-	var filter bson.M
-	if user.Role == string(models.UserRoleFACTORY_SALES) {
-		filter = bson.M{
-			"$or": []bson.M{
-				{"relatedSalesId": user.ID},
-				{"isInPublicPool": true},
-			},
-		}
-	} else if user.Role == string(models.UserRoleAGENT) {
-		filter = bson.M{
-			"$or": []bson.M{
-				{"relatedAgentId": user.ID},
-				{"isInPublicPool": true},
-			},
-		}
-	} else {
-		return nil, errors.New("用户角色不支持")
+	filter, err := policy.CustomerFilter(user, policy.ActionRead)
+	if err != nil {
+		return nil, err
 	}
 
-	cursor, err := customerCollection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	results, err := repository.Customers().Find(ctx, filter, repository.NewFindOptions().SetProjection(bson.M{"_id": 1}))
 	if err != nil {
 		return nil, fmt.Errorf("查询客户失败: %w", err)
 	}
-	defer cursor.Close(ctx)
-
-	var results []struct {
-		ID primitive.ObjectID `bson:"_id"`
-	}
-	if err = cursor.All(ctx, &results); err != nil {
-		return nil, fmt.Errorf("解析客户ID失败: %w", err)
-	}
 
 	customerIds := make([]primitive.ObjectID, len(results))
 	for i, r := range results {
@@ -929,13 +805,3 @@ func getUserAccessibleCustomerIds(user *utils.LoginUser) ([]primitive.ObjectID,
 
 	return customerIds, nil
 }
-
-func UpdateWebHiddenByCustomerID(ctx context.Context, customerObjID primitive.ObjectID) error {
-	// 获取 projects 集合
-	projectCollection := repository.Collection(repository.ProjectsCollection)
-	filter := bson.M{"customerId": customerObjID, "webHidden": false}
-	update := bson.M{"$set": bson.M{"webHidden": true}}
-	// 执行更新操作
-	_, err := projectCollection.UpdateMany(ctx, filter, update)
-	return err
-}
@@ -11,9 +11,7 @@ import (
 	"github.com/BerniceZTT/crm_end/repository"
 	"github.com/BerniceZTT/crm_end/utils"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // 文件上传请求结构体
@@ -80,22 +78,15 @@ func UploadFile(c *gin.Context) {
 	}
 
 	// 存储到专用文件集合
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	filesCollection := repository.Collection(repository.ProjectFilesCollection)
-	result, err := filesCollection.InsertOne(ctx, fileRecord)
-	if err != nil {
+	if _, err := repository.ProjectFiles().Insert(ctx, &fileRecord); err != nil {
 		log.Printf("[文件上传] 上传失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "文件保存失败"})
 		return
 	}
 
-	if result.InsertedID == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "文件保存失败"})
-		return
-	}
-
 	log.Printf("[文件上传] 文件上传成功: %s, ID: %s", req.FileName, fileID)
 
 	// 返回文件引用信息（不包含实际文件数据）
@@ -134,14 +125,12 @@ func DownloadFile(c *gin.Context) {
 	fileID := c.Param("fileId")
 	log.Printf("[文件下载] 用户: %s 下载文件: %s", currentUser.Username, fileID)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	filesCollection := repository.Collection("repository.ProjectFilesCollection")
-	var fileRecord models.FileInfo
-	err = filesCollection.FindOne(ctx, bson.M{"id": fileID}).Decode(&fileRecord)
+	fileRecord, err := repository.ProjectFiles().FindByFileID(ctx, fileID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文件失败"})
@@ -183,18 +172,17 @@ func DeleteFile(c *gin.Context) {
 	fileID := c.Param("fileId")
 	log.Printf("[文件删除] 用户: %s 删除文件: %s", currentUser.Username, fileID)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	filesCollection := repository.Collection("repository.ProjectFilesCollection")
-	result, err := filesCollection.DeleteOne(ctx, bson.M{"id": fileID})
+	deletedCount, err := repository.ProjectFiles().DeleteByFileID(ctx, fileID)
 	if err != nil {
 		log.Printf("[文件删除] 删除失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "文件删除失败"})
 		return
 	}
 
-	if result.DeletedCount == 0 {
+	if deletedCount == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
 		return
 	}
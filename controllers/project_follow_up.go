@@ -8,10 +8,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/pagination"
+	"github.com/BerniceZTT/crm_end/policy"
 	"github.com/BerniceZTT/crm_end/repository"
 	"github.com/BerniceZTT/crm_end/utils"
 )
@@ -35,13 +35,16 @@ func GetProjectFollowUpRecords(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "项目ID不能为空"})
 		return
 	}
+	pageReq, err := pagination.FromQuery(c.Request.URL.Query(), followUpSort)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
 	// 验证项目是否存在及权限
-	projectsCollection := repository.Collection(repository.ProjectsCollection)
-
 	projectObjID, err := primitive.ObjectIDFromHex(projectID)
 	if err != nil {
 		utils.LogError(err, make(map[string]interface{}), "无效的项目ID格式")
@@ -49,10 +52,9 @@ func GetProjectFollowUpRecords(c *gin.Context) {
 		return
 	}
 
-	var project models.Project
-	err = projectsCollection.FindOne(ctx, bson.M{"_id": projectObjID}).Decode(&project)
+	project, err := repository.Projects().FindByID(ctx, projectObjID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "项目不存在"})
 			return
 		}
@@ -61,17 +63,9 @@ func GetProjectFollowUpRecords(c *gin.Context) {
 	}
 
 	// 检查关联客户权限
-	customersCollection := repository.Collection(repository.CustomersCollection)
-	customerObjID, err := primitive.ObjectIDFromHex(project.CustomerID.Hex())
-	if err != nil {
-		utils.HandleError(c, err)
-		return
-	}
-
-	var customer models.Customer
-	err = customersCollection.FindOne(ctx, bson.M{"_id": customerObjID}).Decode(&customer)
+	customer, err := repository.Customers().FindByID(ctx, project.CustomerID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "关联客户不存在"})
 			return
 		}
@@ -80,36 +74,25 @@ func GetProjectFollowUpRecords(c *gin.Context) {
 	}
 
 	// 权限验证
-	if currentUser.Role != "SUPER_ADMIN" {
-		if (currentUser.Role == "FACTORY_SALES" && customer.RelatedSalesID != currentUser.ID && !customer.IsInPublicPool) ||
-			(currentUser.Role == "AGENT" && customer.RelatedAgentID != currentUser.ID && !customer.IsInPublicPool) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "无权访问该项目的跟进记录"})
-			return
-		}
-	}
-
-	// 获取跟进记录
-	recordsCollection := repository.Collection(repository.ProjectFollowUpRecordsCollection)
-	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
-	cursor, err := recordsCollection.Find(ctx, bson.M{"projectId": projectID}, opts)
-	if err != nil {
-		utils.HandleError(c, err)
+	if !policy.CanAccessProject(currentUser, customer, policy.ActionRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权访问该项目的跟进记录"})
 		return
 	}
-	defer cursor.Close(ctx)
 
-	var records []models.ProjectFollowUpRecord
-	if err = cursor.All(ctx, &records); err != nil {
+	// 获取一页跟进记录，默认按创建时间倒序
+	result, err := pagination.Find(ctx, repository.ProjectFollowUps(), bson.M{"projectId": projectID}, pageReq)
+	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
 	utils.LogInfo(map[string]interface{}{
 		"projectId":   projectID,
-		"recordCount": len(records),
+		"recordCount": len(result.Items),
+		"total":       result.Pagination.Total,
 	}, "[项目跟进记录] 获取跟进记录成功")
 
-	c.JSON(http.StatusOK, gin.H{"records": records})
+	utils.PaginatedResponse(c, result.Items, result.Pagination)
 }
 
 // 2. 创建项目跟进记录
@@ -137,12 +120,10 @@ func CreateProjectFollowUpRecord(c *gin.Context) {
 		"user":      currentUser.Username,
 	}, "[项目跟进记录] 创建跟进记录")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
 	// 验证项目是否存在及权限
-	projectsCollection := repository.Collection(repository.ProjectsCollection)
-
 	projectObjID, err := primitive.ObjectIDFromHex(input.ProjectID)
 	if err != nil {
 		utils.LogError(err, make(map[string]interface{}), "无效的项目ID格式")
@@ -150,10 +131,9 @@ func CreateProjectFollowUpRecord(c *gin.Context) {
 		return
 	}
 
-	var project models.Project
-	err = projectsCollection.FindOne(ctx, bson.M{"_id": projectObjID}).Decode(&project)
+	project, err := repository.Projects().FindByID(ctx, projectObjID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "项目不存在"})
 			return
 		}
@@ -162,17 +142,9 @@ func CreateProjectFollowUpRecord(c *gin.Context) {
 	}
 
 	// 检查关联客户权限
-	customersCollection := repository.Collection(repository.CustomersCollection)
-	customerObjID, err := primitive.ObjectIDFromHex(project.CustomerID.Hex())
+	customer, err := repository.Customers().FindByID(ctx, project.CustomerID)
 	if err != nil {
-		utils.HandleError(c, err)
-		return
-	}
-
-	var customer models.Customer
-	err = customersCollection.FindOne(ctx, bson.M{"_id": customerObjID}).Decode(&customer)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "关联客户不存在"})
 			return
 		}
@@ -181,16 +153,12 @@ func CreateProjectFollowUpRecord(c *gin.Context) {
 	}
 
 	// 权限验证
-	if currentUser.Role != "SUPER_ADMIN" {
-		if (currentUser.Role == "FACTORY_SALES" && customer.RelatedSalesID != currentUser.ID && !customer.IsInPublicPool) ||
-			(currentUser.Role == "AGENT" && customer.RelatedAgentID != currentUser.ID && !customer.IsInPublicPool) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "无权为该项目添加跟进记录"})
-			return
-		}
+	if !policy.CanAccessProject(currentUser, customer, policy.ActionRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权为该项目添加跟进记录"})
+		return
 	}
 
 	// 创建记录
-	recordsCollection := repository.Collection(repository.ProjectFollowUpRecordsCollection)
 	now := time.Now()
 
 	newRecord := models.ProjectFollowUpRecord{
@@ -204,24 +172,20 @@ func CreateProjectFollowUpRecord(c *gin.Context) {
 		UpdatedAt:   now,
 	}
 
-	result, err := recordsCollection.InsertOne(ctx, newRecord)
+	insertedID, err := repository.ProjectFollowUps().Insert(ctx, &newRecord)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
 	// 更新项目最后更新时间
-	_, err = projectsCollection.UpdateOne(
-		ctx,
-		bson.M{"_id": projectObjID},
-		bson.M{"$set": bson.M{"updatedAt": now}},
-	)
+	_, err = repository.Projects().UpdateByID(ctx, projectObjID, bson.M{"$set": bson.M{"updatedAt": now}})
 	if err != nil {
 		utils.LogError(err, make(map[string]interface{}), "更新项目最后更新时间失败")
 	}
 
 	// 获取插入的ID
-	newRecord.ID = result.InsertedID.(primitive.ObjectID)
+	newRecord.ID = insertedID
 
 	utils.LogInfo(map[string]interface{}{
 		"recordId":  newRecord.ID.Hex(),
@@ -248,11 +212,9 @@ func DeleteProjectFollowUpRecord(c *gin.Context) {
 		"user":     currentUser.Username,
 	}, "[项目跟进记录] 删除跟进记录")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	recordsCollection := repository.Collection(repository.ProjectFollowUpRecordsCollection)
-
 	recordObjID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		utils.LogError(err, make(map[string]interface{}), "无效的记录ID格式")
@@ -261,10 +223,9 @@ func DeleteProjectFollowUpRecord(c *gin.Context) {
 	}
 
 	// 查找记录
-	var record models.ProjectFollowUpRecord
-	err = recordsCollection.FindOne(ctx, bson.M{"_id": recordObjID}).Decode(&record)
+	record, err := repository.ProjectFollowUps().FindByID(ctx, recordObjID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "跟进记录不存在"})
 			return
 		}
@@ -279,13 +240,13 @@ func DeleteProjectFollowUpRecord(c *gin.Context) {
 	}
 
 	// 删除记录
-	result, err := recordsCollection.DeleteOne(ctx, bson.M{"_id": recordObjID})
+	deletedCount, err := repository.ProjectFollowUps().DeleteByID(ctx, recordObjID)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
 
-	if result.DeletedCount == 0 {
+	if deletedCount == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"error": "跟进记录不存在或已被删除"})
 		return
 	}
@@ -8,10 +8,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/policy"
 	"github.com/BerniceZTT/crm_end/repository"
 	"github.com/BerniceZTT/crm_end/utils"
 )
@@ -36,11 +35,10 @@ func GetProjectProgressHistory(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
 	// 验证项目是否存在及权限
-	projectsCollection := repository.Collection(repository.ProjectsCollection)
 	projectObjID, err := primitive.ObjectIDFromHex(projectID)
 	if err != nil {
 		utils.LogError(err, make(map[string]interface{}), "无效的项目ID格式")
@@ -48,10 +46,9 @@ func GetProjectProgressHistory(c *gin.Context) {
 		return
 	}
 
-	var project models.Project
-	err = projectsCollection.FindOne(ctx, bson.M{"_id": projectObjID}).Decode(&project)
+	project, err := repository.Projects().FindByID(ctx, projectObjID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "项目不存在"})
 			return
 		}
@@ -60,17 +57,9 @@ func GetProjectProgressHistory(c *gin.Context) {
 	}
 
 	// 检查关联客户权限
-	customersCollection := repository.Collection(repository.CustomersCollection)
-	customerObjID, err := primitive.ObjectIDFromHex(project.CustomerID.Hex())
+	customer, err := repository.Customers().FindByID(ctx, project.CustomerID)
 	if err != nil {
-		utils.HandleError(c, err)
-		return
-	}
-
-	var customer models.Customer
-	err = customersCollection.FindOne(ctx, bson.M{"_id": customerObjID}).Decode(&customer)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "关联客户不存在"})
 			return
 		}
@@ -79,29 +68,17 @@ func GetProjectProgressHistory(c *gin.Context) {
 	}
 
 	// 权限验证
-	if currentUser.Role != "SUPER_ADMIN" {
-		if (currentUser.Role == "FACTORY_SALES" && customer.RelatedSalesID != currentUser.ID && !customer.IsInPublicPool) ||
-			(currentUser.Role == "AGENT" && customer.RelatedAgentID != currentUser.ID && !customer.IsInPublicPool) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "无权访问该项目的进展历史"})
-			return
-		}
+	if !policy.CanAccessProject(currentUser, customer, policy.ActionRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权访问该项目的进展历史"})
+		return
 	}
 
 	// 获取项目进展历史记录
-	progressCollection := repository.Collection(repository.ProjectProgressHistoryCollection)
-	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
-	cursor, err := progressCollection.Find(ctx, bson.M{"projectId": projectID}, opts)
+	progressHistory, err := repository.ProjectProgress().FindByProjectID(ctx, projectID)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
-	defer cursor.Close(ctx)
-
-	var progressHistory []models.ProjectProgressHistory
-	if err = cursor.All(ctx, &progressHistory); err != nil {
-		utils.HandleError(c, err)
-		return
-	}
 
 	utils.LogInfo(map[string]interface{}{
 		"projectId":   projectID,
@@ -130,7 +107,7 @@ func AddProjectProgressHistory(c *gin.Context) {
 	}
 
 	// 使用工具函数添加历史记录
-	result, err := AddProjectProgressHistoryFn(context.Background(), input)
+	result, err := AddProjectProgressHistoryFn(c.Request.Context(), input)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
@@ -151,7 +128,7 @@ func GetAllProjectProgressHistory(c *gin.Context) {
 		"progress":  progress,
 	}, "[项目进展历史] 获取所有项目进展历史记录")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
 	// 构建查询过滤器
@@ -186,20 +163,12 @@ func GetAllProjectProgressHistory(c *gin.Context) {
 	}
 
 	// 获取项目进展历史记录
-	progressCollection := repository.Collection(repository.ProjectProgressHistoryCollection)
-	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
-	cursor, err := progressCollection.Find(ctx, filter, opts)
+	progressHistory, err := repository.ProjectProgress().Find(ctx, filter,
+		repository.NewFindOptions().SetSort("createdAt", -1))
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
-	defer cursor.Close(ctx)
-
-	var progressHistory []models.ProjectProgressHistory
-	if err = cursor.All(ctx, &progressHistory); err != nil {
-		utils.HandleError(c, err)
-		return
-	}
 
 	utils.LogInfo(map[string]interface{}{
 		"count": len(progressHistory),
@@ -236,17 +205,14 @@ func AddProjectProgressHistoryFn(ctx context.Context, history models.ProjectProg
 	}
 
 	// 插入记录
-	progressCollection := repository.Collection(repository.ProjectProgressHistoryCollection)
-	result, err := progressCollection.InsertOne(ctx, history)
+	insertedID, err := repository.ProjectProgress().Insert(ctx, &history)
 	if err != nil {
 		utils.LogError(err, make(map[string]interface{}), "添加项目进展历史记录失败")
 		return models.ProjectProgressHistory{}, err
 	}
 
 	// 设置返回的ID
-	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
-		history.ID = oid
-	}
+	history.ID = insertedID
 
 	utils.LogInfo(map[string]interface{}{
 		"recordId":    history.ID.Hex(),
@@ -1,18 +1,44 @@
 package controllers
 
 import (
-	"context"
-
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 
 	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/pagination"
 	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/service"
 	"github.com/BerniceZTT/crm_end/utils"
 )
 
+// publicPoolSort 公海客户列表可排序的字段，lastUpdateTime 即进入公海的时间
+var publicPoolSort = pagination.Spec{
+	SortFields: map[string]string{
+		"enterPoolTime": "lastUpdateTime",
+		"createdAt":     "createdAt",
+		"name":          "name",
+	},
+	DefaultSort:  "enterPoolTime",
+	DefaultOrder: -1,
+}
+
 // getPublicPoolCustomers 获取公海客户列表
 func GetPublicPoolCustomers(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	// 移入公海前的联系人等详情只向超级管理员返回
+	showSnapshot := models.UserRole(user.Role) == models.UserRoleSUPER_ADMIN
+
+	// 获取分页和排序参数
+	pageReq, err := pagination.FromQuery(c.Request.URL.Query(), publicPoolSort)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
 	// 获取查询参数
 	keyword := c.Query("keyword")
 	nature := c.Query("nature")
@@ -60,27 +86,21 @@ func GetPublicPoolCustomers(c *gin.Context) {
 
 	utils.LogInfo(map[string]interface{}{"filter": filter}, "最终查询条件")
 
-	// 查询所有公海客户
-	customersCollection := repository.Collection(repository.CustomersCollection)
-	cursor, err := customersCollection.Find(context.Background(), filter)
+	// 查询一页公海客户
+	result, err := pagination.Find(c.Request.Context(), repository.Customers(), filter, pageReq)
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
-	defer cursor.Close(context.Background())
-
-	// 解析查询结果
-	var customers []models.Customer
-	if err := cursor.All(context.Background(), &customers); err != nil {
-		utils.HandleError(c, err)
-		return
-	}
 
-	utils.LogInfo(map[string]interface{}{"count": len(customers)}, "查询到符合条件的公海客户数量")
+	utils.LogInfo(map[string]interface{}{
+		"count": len(result.Items),
+		"total": result.Pagination.Total,
+	}, "查询到符合条件的公海客户数量")
 
 	// 转换为公海客户响应格式
-	var publicCustomers []models.PublicPoolCustomer
-	for _, customer := range customers {
+	publicCustomers := make([]models.PublicPoolCustomer, 0, len(result.Items))
+	for _, customer := range result.Items {
 		// 确定进入公海时间
 		enterPoolTime := customer.UpdatedAt
 		if !customer.LastUpdateTime.IsZero() {
@@ -88,31 +108,34 @@ func GetPublicPoolCustomers(c *gin.Context) {
 		}
 
 		publicCustomer := models.PublicPoolCustomer{
-			ID:                       customer.ID,
-			Name:                     customer.Name,
-			Nature:                   customer.Nature,
-			Importance:               customer.Importance,
-			ApplicationField:         customer.ApplicationField,
-			Progress:                 customer.Progress,
-			Address:                  customer.Address,
-			ProductNeeds:             customer.ProductNeeds,
-			EnterPoolTime:            enterPoolTime,
-			PreviousOwnerName:        customer.PreviousOwnerName,
-			PreviousOwnerType:        customer.PreviousOwnerType,
-			PreviousRelatedSalesName: customer.RelatedSalesName,
-			PreviousRelatedAgentName: customer.RelatedAgentName,
-			CreatorID:                customer.OwnerID,
-			CreatorName:              customer.OwnerName,
-			CreatorType:              customer.OwnerType,
-			CreatedAt:                customer.CreatedAt,
+			ID:                customer.ID,
+			Name:              customer.Name,
+			Nature:            customer.Nature,
+			Importance:        customer.Importance,
+			ApplicationField:  customer.ApplicationField,
+			Progress:          customer.Progress,
+			Address:           customer.Address,
+			ProductNeeds:      customer.ProductNeeds,
+			EnterPoolTime:     enterPoolTime,
+			PreviousOwnerName: customer.PreviousOwnerName,
+			PreviousOwnerType: customer.PreviousOwnerType,
+			CreatorID:         customer.OwnerID,
+			CreatorName:       customer.OwnerName,
+			CreatorType:       customer.OwnerType,
+			CreatedAt:         customer.CreatedAt,
+		}
+		if snapshot := customer.PoolSnapshot; snapshot != nil {
+			publicCustomer.PreviousRelatedSalesName = snapshot.RelatedSalesName
+			publicCustomer.PreviousRelatedAgentName = snapshot.RelatedAgentName
+			if showSnapshot {
+				publicCustomer.PreviousOwnerSnapshot = snapshot
+			}
 		}
 
 		publicCustomers = append(publicCustomers, publicCustomer)
 	}
 
-	c.JSON(200, gin.H{
-		"publicCustomers": publicCustomers,
-	})
+	utils.PaginatedResponse(c, publicCustomers, result.Pagination)
 }
 
 // getAssignableUsers 获取可分配的销售人员列表
@@ -131,48 +154,42 @@ func GetAssignableUsers(c *gin.Context) {
 	}
 
 	// 获取销售人员列表
-	salesCollection := repository.Collection(repository.UsersCollection)
-	salesCursor, err := salesCollection.Find(
-		context.Background(),
+	users, err := repository.Users().Find(
+		c.Request.Context(),
 		bson.M{
 			"role":   models.UserRoleFACTORY_SALES,
 			"status": models.UserStatusAPPROVED,
 		},
-		nil, // 这里简化了，实际应当使用options.Find().SetProjection()
 	)
-
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
-	defer salesCursor.Close(context.Background())
 
-	// 解析销售人员
-	var salesUsers []models.UserBrief
-	if err := salesCursor.All(context.Background(), &salesUsers); err != nil {
-		utils.HandleError(c, err)
-		return
+	salesUsers := make([]models.UserBrief, 0, len(users))
+	for _, u := range users {
+		salesUsers = append(salesUsers, models.UserBrief{ID: u.ID, Username: u.Username, Role: u.Role})
 	}
 
 	// 获取代理商列表
-	agentsCollection := repository.Collection(repository.AgentsCollection)
-	agentsCursor, err := agentsCollection.Find(
-		context.Background(),
+	approvedAgents, err := repository.Agents().Find(
+		c.Request.Context(),
 		bson.M{"status": models.UserStatusAPPROVED},
-		nil,
 	)
-
 	if err != nil {
 		utils.HandleError(c, err)
 		return
 	}
-	defer agentsCursor.Close(context.Background())
 
-	// 解析代理商
-	var agents []models.AgentBrief
-	if err := agentsCursor.All(context.Background(), &agents); err != nil {
-		utils.HandleError(c, err)
-		return
+	agents := make([]models.AgentBrief, 0, len(approvedAgents))
+	for _, a := range approvedAgents {
+		agents = append(agents, models.AgentBrief{
+			ID:               a.ID,
+			CompanyName:      a.CompanyName,
+			ContactPerson:    a.ContactPerson,
+			RelatedSalesID:   a.RelatedSalesID,
+			RelatedSalesName: a.RelatedSalesName,
+		})
 	}
 
 	c.JSON(200, gin.H{
@@ -180,3 +197,51 @@ func GetAssignableUsers(c *gin.Context) {
 		"agents":     agents,
 	})
 }
+
+// ClaimPublicPoolCustomer 销售或代理商认领公海客户，受每日和名下客户总数额度、原归属人冷却期限制
+func ClaimPublicPoolCustomer(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	customer, err := service.ClaimPublicPoolCustomer(c.Request.Context(), c.Param("id"), user)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	utils.SuccessResponse(c, customer, "认领成功")
+}
+
+// GetPublicPoolClaimQuota 获取当前用户的公海认领额度使用情况
+func GetPublicPoolClaimQuota(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	status, err := service.GetPublicPoolClaimQuota(c.Request.Context(), user)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	utils.SuccessResponse(c, status, "")
+}
+
+// RestorePublicPoolCustomer 将公海客户恢复给移入公海前的归属人，联系人和项目一并恢复
+func RestorePublicPoolCustomer(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	customer, err := service.RestoreCustomerFromPublicPool(c.Request.Context(), c.Param("id"), user)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	utils.SuccessResponse(c, customer, "已恢复原归属")
+}
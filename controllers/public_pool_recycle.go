@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/pagination"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+)
+
+// recycleRunSort 公海回收报告列表可排序的字段
+var recycleRunSort = pagination.Spec{
+	SortFields: map[string]string{
+		"startedAt": "startedAt",
+	},
+	DefaultSort:  "startedAt",
+	DefaultOrder: -1,
+}
+
+// GetPublicPoolRecycleRuns 公海自动回收任务的执行报告列表，只返回各类客户数量
+func GetPublicPoolRecycleRuns(c *gin.Context) {
+	pageReq, err := pagination.FromQuery(c.Request.URL.Query(), recycleRunSort)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	filter := bson.M{}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+
+	result, err := pagination.Find(c.Request.Context(), repository.RecycleRuns(), filter, pageReq)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	summaries := make([]models.PublicPoolRecycleRunSummary, 0, len(result.Items))
+	for _, run := range result.Items {
+		summaries = append(summaries, models.PublicPoolRecycleRunSummary{
+			ID:            run.ID,
+			StartedAt:     run.StartedAt,
+			FinishedAt:    run.FinishedAt,
+			Status:        run.Status,
+			Scanned:       run.Scanned,
+			RecycledCount: run.RecycledCount,
+			WarnedCount:   run.WarnedCount,
+			FailedCount:   run.FailedCount,
+			Error:         run.Error,
+		})
+	}
+	utils.PaginatedResponse(c, summaries, result.Pagination)
+}
+
+// GetPublicPoolRecycleRun 公海自动回收任务的执行报告详情，客户明细通过 GetPublicPoolRecycleItems 分页查询
+func GetPublicPoolRecycleRun(c *gin.Context) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的报告ID"})
+		return
+	}
+
+	run, err := repository.RecycleRuns().FindByID(c.Request.Context(), objID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			utils.HandleError(c, utils.CreateNotFoundError("回收报告"))
+			return
+		}
+		utils.HandleError(c, err)
+		return
+	}
+	utils.SuccessResponse(c, run, "")
+}
+
+// recycleItemSort 回收客户明细可排序的字段
+var recycleItemSort = pagination.Spec{
+	SortFields: map[string]string{
+		"dueAt":        "dueAt",
+		"customerName": "customerName",
+	},
+	DefaultSort:  "dueAt",
+	DefaultOrder: 1,
+}
+
+// GetPublicPoolRecycleItems 分页查询回收报告中的客户明细，可按类型（recycled、warned、failed）筛选
+func GetPublicPoolRecycleItems(c *gin.Context) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的报告ID"})
+		return
+	}
+	pageReq, err := pagination.FromQuery(c.Request.URL.Query(), recycleItemSort)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	filter := bson.M{"runId": objID}
+	if kind := c.Query("kind"); kind != "" {
+		filter["kind"] = kind
+	}
+
+	result, err := pagination.Find(c.Request.Context(), repository.RecycleItems(), filter, pageReq)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	utils.PaginatedResponse(c, result.Items, result.Pagination)
+}
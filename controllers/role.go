@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/service"
+	"github.com/BerniceZTT/crm_end/utils"
+)
+
+// roleNamePattern 角色标识格式：大写字母开头，仅含大写字母、数字和下划线
+var roleNamePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]{1,31}$`)
+
+// validatePermissions 校验授权项是否都在权限清单内
+func validatePermissions(permissions []models.Permission) error {
+	for _, p := range permissions {
+		if p.Resource == models.PermissionWildcard {
+			continue
+		}
+		actions, ok := models.PermissionCatalog[p.Resource]
+		if !ok {
+			return fmt.Errorf("未知的资源: %s", p.Resource)
+		}
+		if len(p.Actions) == 0 {
+			return fmt.Errorf("资源 %s 未指定操作", p.Resource)
+		}
+		for _, a := range p.Actions {
+			if a == models.PermissionWildcard || containsString(actions, a) {
+				continue
+			}
+			return fmt.Errorf("资源 %s 不支持操作: %s", p.Resource, a)
+		}
+	}
+	return nil
+}
+
+// containsString 判断切片中是否包含指定字符串
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRoles 获取角色列表
+// GET /api/roles
+func GetRoles(c *gin.Context) {
+	utils.Logger.Info().Msg("[角色管理] 获取角色列表")
+
+	ctx := c.Request.Context()
+
+	findOptions := repository.NewFindOptions().SetSort("createdAt", 1)
+	roles, err := repository.Roles().Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		utils.HandleError(c, err)
+		utils.Logger.Error().Err(err).Msg("[角色管理] 获取角色列表失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取角色列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"roles": roles,
+			"total": len(roles),
+		},
+	})
+}
+
+// GetPermissionCatalog 获取可授权的资源及操作清单
+// GET /api/roles/catalog
+func GetPermissionCatalog(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    models.PermissionCatalog,
+	})
+}
+
+// GetRoleDetail 获取角色详情
+// GET /api/roles/:id
+func GetRoleDetail(c *gin.Context) {
+	roleID := c.Param("id")
+	objID, err := primitive.ObjectIDFromHex(roleID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的角色ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	role, err := repository.Roles().FindByID(ctx, objID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		} else {
+			utils.HandleError(c, err)
+			utils.Logger.Error().Err(err).Str("roleId", roleID).Msg("[角色管理] 获取角色详情失败")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取角色详情失败"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    role,
+	})
+}
+
+// CreateRole 创建自定义角色
+// POST /api/roles
+func CreateRole(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var requestData models.CreateRoleRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据格式不正确"})
+		return
+	}
+
+	utils.Logger.Info().Interface("requestData", requestData).Msg("[角色管理] 创建角色")
+
+	if !roleNamePattern.MatchString(string(requestData.Name)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "角色标识只能包含大写字母、数字和下划线，且以字母开头"})
+		return
+	}
+	if err := validatePermissions(requestData.Permissions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	_, err = repository.Roles().FindByName(ctx, requestData.Name)
+	if err == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该角色标识已存在"})
+		return
+	} else if err != repository.ErrNotFound {
+		utils.HandleError(c, err)
+		utils.Logger.Error().Err(err).Msg("[角色管理] 检查角色是否存在失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建角色失败"})
+		return
+	}
+
+	permissions := requestData.Permissions
+	if permissions == nil {
+		permissions = []models.Permission{}
+	}
+
+	role := models.Role{
+		Name:        requestData.Name,
+		DisplayName: requestData.DisplayName,
+		Description: requestData.Description,
+		Permissions: permissions,
+		IsSystem:    false,
+		CreatorID:   user.ID,
+		CreatorName: user.Username,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	insertedID, err := repository.Roles().Insert(ctx, &role)
+	if err != nil {
+		utils.HandleError(c, err)
+		utils.Logger.Error().Err(err).Msg("[角色管理] 创建角色失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建角色失败"})
+		return
+	}
+	service.InvalidateRoleCache()
+
+	utils.Logger.Info().Str("roleId", insertedID.Hex()).Str("name", string(role.Name)).Msg("[角色管理] 角色创建成功")
+
+	role.ID = insertedID
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "角色创建成功",
+		"data":    role,
+	})
+}
+
+// UpdateRole 更新角色的显示名称、描述和权限
+// PUT /api/roles/:id
+func UpdateRole(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	roleID := c.Param("id")
+	objID, err := primitive.ObjectIDFromHex(roleID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的角色ID"})
+		return
+	}
+
+	var requestData models.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求数据格式不正确"})
+		return
+	}
+
+	utils.Logger.Info().Str("roleId", roleID).Interface("requestData", requestData).Msg("[角色管理] 更新角色")
+
+	ctx := c.Request.Context()
+
+	role, err := repository.Roles().FindByID(ctx, objID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		} else {
+			utils.HandleError(c, err)
+			utils.Logger.Error().Err(err).Str("roleId", roleID).Msg("[角色管理] 获取角色失败")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新角色失败"})
+		}
+		return
+	}
+
+	updateData := bson.M{
+		"updatedAt":   time.Now(),
+		"updaterId":   user.ID,
+		"updaterName": user.Username,
+	}
+	if requestData.DisplayName != "" {
+		updateData["displayName"] = requestData.DisplayName
+	}
+	if requestData.Description != "" {
+		updateData["description"] = requestData.Description
+	}
+	if requestData.Permissions != nil {
+		// 超级管理员权限固定为全部权限，防止误操作导致无人可管理系统
+		if role.Name == models.UserRoleSUPER_ADMIN {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "超级管理员的权限不可修改"})
+			return
+		}
+		if err := validatePermissions(requestData.Permissions); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updateData["permissions"] = requestData.Permissions
+	}
+
+	if _, err := repository.Roles().UpdateByID(ctx, objID, bson.M{"$set": updateData}); err != nil {
+		utils.HandleError(c, err)
+		utils.Logger.Error().Err(err).Str("roleId", roleID).Msg("[角色管理] 更新角色失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新角色失败"})
+		return
+	}
+	service.InvalidateRoleCache()
+
+	utils.Logger.Info().Str("roleId", roleID).Str("name", string(role.Name)).Msg("[角色管理] 角色更新成功")
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "角色更新成功",
+	})
+}
+
+// DeleteRole 删除自定义角色，内置角色及仍有用户使用的角色不可删除
+// DELETE /api/roles/:id
+func DeleteRole(c *gin.Context) {
+	roleID := c.Param("id")
+	objID, err := primitive.ObjectIDFromHex(roleID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的角色ID"})
+		return
+	}
+
+	utils.Logger.Info().Str("roleId", roleID).Msg("[角色管理] 删除角色")
+
+	ctx := c.Request.Context()
+
+	role, err := repository.Roles().FindByID(ctx, objID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		} else {
+			utils.HandleError(c, err)
+			utils.Logger.Error().Err(err).Str("roleId", roleID).Msg("[角色管理] 获取角色失败")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除角色失败"})
+		}
+		return
+	}
+
+	if role.IsSystem {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "内置角色不可删除"})
+		return
+	}
+
+	userCount, err := repository.Users().Count(ctx, bson.M{"role": role.Name})
+	if err != nil {
+		utils.HandleError(c, err)
+		utils.Logger.Error().Err(err).Str("roleId", roleID).Msg("[角色管理] 统计角色用户数失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除角色失败"})
+		return
+	}
+	if userCount > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("仍有 %d 个用户使用该角色，无法删除", userCount)})
+		return
+	}
+
+	if _, err := repository.Roles().DeleteByID(ctx, objID); err != nil {
+		utils.HandleError(c, err)
+		utils.Logger.Error().Err(err).Str("roleId", roleID).Msg("[角色管理] 删除角色失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除角色失败"})
+		return
+	}
+	service.InvalidateRoleCache()
+
+	utils.Logger.Info().Str("roleId", roleID).Str("name", string(role.Name)).Msg("[角色管理] 角色删除成功")
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "角色删除成功",
+	})
+}
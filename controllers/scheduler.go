@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/BerniceZTT/crm_end/pagination"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/service"
+	"github.com/BerniceZTT/crm_end/utils"
+)
+
+// jobRunSort 定时任务执行记录可排序的字段
+var jobRunSort = pagination.Spec{
+	SortFields: map[string]string{
+		"startedAt":  "startedAt",
+		"durationMs": "durationMs",
+	},
+	DefaultSort:  "startedAt",
+	DefaultOrder: -1,
+}
+
+// GetScheduledJobs 已注册的定时任务，包含执行计划、下一次执行时间、是否正在执行和最近一次执行结果
+func GetScheduledJobs(c *gin.Context) {
+	jobs, err := service.ListScheduledJobs(c.Request.Context())
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	utils.SuccessResponse(c, jobs, "")
+}
+
+// GetScheduledJobRuns 定时任务的执行记录，可按状态和触发方式筛选
+func GetScheduledJobRuns(c *gin.Context) {
+	name := c.Param("name")
+	if !service.HasScheduledJob(name) {
+		utils.HandleError(c, utils.CreateNotFoundError("定时任务"))
+		return
+	}
+	pageReq, err := pagination.FromQuery(c.Request.URL.Query(), jobRunSort)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	filter := bson.M{"jobName": name}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+	if trigger := c.Query("trigger"); trigger != "" {
+		filter["trigger"] = trigger
+	}
+
+	result, err := pagination.Find(c.Request.Context(), repository.JobRuns(), filter, pageReq)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	utils.PaginatedResponse(c, result.Items, result.Pagination)
+}
+
+// GetScheduledJobRun 定时任务的单条执行记录，手动执行后可据此查询结果
+func GetScheduledJobRun(c *gin.Context) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的执行记录ID"})
+		return
+	}
+
+	run, err := repository.JobRuns().FindByID(c.Request.Context(), objID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			utils.HandleError(c, utils.CreateNotFoundError("执行记录"))
+			return
+		}
+		utils.HandleError(c, err)
+		return
+	}
+	utils.SuccessResponse(c, run, "")
+}
+
+// RunScheduledJob 手动执行定时任务，任务在后台执行，立即返回执行中的记录
+func RunScheduledJob(c *gin.Context) {
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	run, err := service.TriggerScheduledJob(c.Request.Context(), c.Param("name"), user)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	utils.SuccessResponse(c, run, "任务已开始执行", http.StatusAccepted)
+}
+
+// PreviewAutoTransfer 预览下一次客户自动转移任务将转移的客户及目标销售，同时列出无法使用的规则
+func PreviewAutoTransfer(c *gin.Context) {
+	plan, err := service.PreviewAutoTransfer(c.Request.Context())
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+	utils.SuccessResponse(c, plan, "")
+}
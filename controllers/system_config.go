@@ -7,8 +7,6 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/BerniceZTT/crm_end/models"
 	"github.com/BerniceZTT/crm_end/repository"
@@ -33,29 +31,17 @@ func GetAllConfigs(c *gin.Context) {
 		query["isEnabled"] = isEnabled == "true"
 	}
 
-	// 获取集合
-	collection := repository.Collection(repository.SystemConfigsCollection)
-	ctx := repository.GetContext()
+	ctx := c.Request.Context()
 
 	// 查询配置列表
-	findOptions := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
-	cursor, err := collection.Find(ctx, query, findOptions)
+	findOptions := repository.NewFindOptions().SetSort("createdAt", -1)
+	configs, err := repository.SystemConfigs().Find(ctx, query, findOptions)
 	if err != nil {
 		utils.HandleError(c, err)
 		utils.Logger.Error().Err(err).Msg("[配置管理] 获取配置列表失败")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取配置列表失败"})
 		return
 	}
-	defer cursor.Close(ctx)
-
-	// 解析结果
-	var configs []models.SystemConfig
-	if err := cursor.All(ctx, &configs); err != nil {
-		utils.HandleError(c, err)
-		utils.Logger.Error().Err(err).Msg("[配置管理] 解析配置列表失败")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "解析配置列表失败"})
-		return
-	}
 
 	utils.Logger.Info().Int("count", len(configs)).Msg("[配置管理] 查询到配置记录")
 
@@ -74,33 +60,21 @@ func GetConfigsByType(c *gin.Context) {
 	configType := c.Param("configType")
 	utils.Logger.Info().Str("configType", configType).Msg("[配置管理] 获取配置类型")
 
-	// 获取集合
-	collection := repository.Collection(repository.SystemConfigsCollection)
-	ctx := repository.GetContext()
+	ctx := c.Request.Context()
 
 	// 查询配置列表
 	query := bson.M{
 		"configType": configType,
 		"isEnabled":  true,
 	}
-	findOptions := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
-	cursor, err := collection.Find(ctx, query, findOptions)
+	findOptions := repository.NewFindOptions().SetSort("createdAt", -1)
+	configs, err := repository.SystemConfigs().Find(ctx, query, findOptions)
 	if err != nil {
 		utils.HandleError(c, err)
 		utils.Logger.Error().Err(err).Str("configType", configType).Msg("[配置管理] 获取指定类型配置失败")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取配置失败"})
 		return
 	}
-	defer cursor.Close(ctx)
-
-	// 解析结果
-	var configs []models.SystemConfig
-	if err := cursor.All(ctx, &configs); err != nil {
-		utils.HandleError(c, err)
-		utils.Logger.Error().Err(err).Str("configType", configType).Msg("[配置管理] 解析指定类型配置失败")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取配置失败"})
-		return
-	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -124,15 +98,12 @@ func GetConfigDetail(c *gin.Context) {
 		return
 	}
 
-	// 获取集合
-	collection := repository.Collection(repository.SystemConfigsCollection)
-	ctx := repository.GetContext()
+	ctx := c.Request.Context()
 
 	// 查询配置详情
-	var config models.SystemConfig
-	err = collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&config)
+	config, err := repository.SystemConfigs().FindByID(ctx, objID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			utils.Logger.Warn().Str("configId", configID).Msg("[配置管理] 配置不存在")
 			c.JSON(http.StatusNotFound, gin.H{"error": "配置不存在"})
 		} else {
@@ -174,22 +145,20 @@ func CreateConfig(c *gin.Context) {
 		return
 	}
 
-	// 获取集合
-	collection := repository.Collection(repository.SystemConfigsCollection)
-	ctx := repository.GetContext()
+	ctx := c.Request.Context()
 
 	// 检查配置键是否已存在
-	existingConfig := collection.FindOne(ctx, bson.M{
+	_, err = repository.SystemConfigs().FindOne(ctx, bson.M{
 		"configType": requestData.ConfigType,
 		"configKey":  requestData.ConfigKey,
 	})
 
-	if existingConfig.Err() == nil {
+	if err == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "该配置键已存在，请使用不同的配置键"})
 		return
-	} else if existingConfig.Err() != mongo.ErrNoDocuments {
-		utils.HandleError(c, existingConfig.Err())
-		utils.Logger.Error().Err(existingConfig.Err()).Msg("[配置管理] 检查配置键是否存在失败")
+	} else if err != repository.ErrNotFound {
+		utils.HandleError(c, err)
+		utils.Logger.Error().Err(err).Msg("[配置管理] 检查配置键是否存在失败")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建配置失败"})
 		return
 	}
@@ -208,7 +177,7 @@ func CreateConfig(c *gin.Context) {
 	}
 
 	// 插入配置
-	result, err := collection.InsertOne(ctx, configData)
+	insertedID, err := repository.SystemConfigs().Insert(ctx, &configData)
 	if err != nil {
 		utils.HandleError(c, err)
 		utils.Logger.Error().Err(err).Msg("[配置管理] 创建配置失败")
@@ -216,10 +185,10 @@ func CreateConfig(c *gin.Context) {
 		return
 	}
 
-	utils.Logger.Info().Str("configId", result.InsertedID.(primitive.ObjectID).Hex()).Msg("[配置管理] 配置创建成功")
+	utils.Logger.Info().Str("configId", insertedID.Hex()).Msg("[配置管理] 配置创建成功")
 
 	// 返回响应
-	configData.ID = result.InsertedID.(primitive.ObjectID)
+	configData.ID = insertedID
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
 		"message": "配置创建成功",
@@ -254,15 +223,12 @@ func UpdateConfig(c *gin.Context) {
 		return
 	}
 
-	// 获取集合
-	collection := repository.Collection(repository.SystemConfigsCollection)
-	ctx := repository.GetContext()
+	ctx := c.Request.Context()
 
 	// 检查配置是否存在
-	var existingConfig models.SystemConfig
-	err = collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&existingConfig)
+	_, err = repository.SystemConfigs().FindByID(ctx, objID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "配置不存在"})
 		} else {
 			utils.HandleError(c, err)
@@ -290,11 +256,7 @@ func UpdateConfig(c *gin.Context) {
 	}
 
 	// 执行更新
-	result, err := collection.UpdateOne(
-		ctx,
-		bson.M{"_id": objID},
-		bson.M{"$set": updateData},
-	)
+	result, err := repository.SystemConfigs().UpdateByID(ctx, objID, bson.M{"$set": updateData})
 	if err != nil {
 		utils.HandleError(c, err)
 		utils.Logger.Error().Err(err).Str("configId", configID).Msg("[配置管理] 更新配置失败")
@@ -328,12 +290,10 @@ func DeleteConfig(c *gin.Context) {
 
 	utils.Logger.Info().Str("configId", configID).Msg("[配置管理] 删除配置")
 
-	// 获取集合
-	collection := repository.Collection(repository.SystemConfigsCollection)
-	ctx := repository.GetContext()
+	ctx := c.Request.Context()
 
 	// 执行删除
-	result, err := collection.DeleteOne(ctx, bson.M{"_id": objID})
+	deletedCount, err := repository.SystemConfigs().DeleteByID(ctx, objID)
 	if err != nil {
 		utils.HandleError(c, err)
 		utils.Logger.Error().Err(err).Str("configId", configID).Msg("[配置管理] 删除配置失败")
@@ -341,7 +301,7 @@ func DeleteConfig(c *gin.Context) {
 		return
 	}
 
-	if result.DeletedCount == 0 {
+	if deletedCount == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"error": "配置不存在"})
 		return
 	}
@@ -374,15 +334,12 @@ func ToggleConfigStatus(c *gin.Context) {
 
 	utils.Logger.Info().Str("configId", configID).Msg("[配置管理] 切换配置状态")
 
-	// 获取集合
-	collection := repository.Collection(repository.SystemConfigsCollection)
-	ctx := repository.GetContext()
+	ctx := c.Request.Context()
 
 	// 获取当前配置
-	var existingConfig models.SystemConfig
-	err = collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&existingConfig)
+	existingConfig, err := repository.SystemConfigs().FindByID(ctx, objID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "配置不存在"})
 		} else {
 			utils.HandleError(c, err)
@@ -396,9 +353,7 @@ func ToggleConfigStatus(c *gin.Context) {
 	newStatus := !existingConfig.IsEnabled
 
 	// 执行更新
-	_, err = collection.UpdateOne(
-		ctx,
-		bson.M{"_id": objID},
+	_, err = repository.SystemConfigs().UpdateByID(ctx, objID,
 		bson.M{
 			"$set": bson.M{
 				"isEnabled":   newStatus,
@@ -5,106 +5,76 @@ import (
 	"time"
 
 	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/pagination"
 	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/service"
 	"github.com/BerniceZTT/crm_end/utils"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// GetAllUsers 获取所有用户
+// userListSort 用户列表可排序的字段
+var userListSort = pagination.Spec{
+	SortFields: map[string]string{
+		"createdAt": "createdAt",
+		"updatedAt": "updatedAt",
+		"username":  "username",
+		"role":      "role",
+		"status":    "status",
+	},
+	DefaultSort:  "createdAt",
+	DefaultOrder: -1,
+}
+
+// GetAllUsers 获取用户列表，支持分页和排序
 func GetAllUsers(c *gin.Context) {
 	utils.Logger.Info().Msg("处理获取用户列表请求...")
 
-	// 检查数据库和集合状态
-	collection := repository.Collection(repository.UsersCollection)
-
-	// 检查集合中的总记录数
-	totalCount, err := collection.CountDocuments(repository.GetContext(), bson.M{})
+	pageReq, err := pagination.FromQuery(c.Request.URL.Query(), userListSort)
 	if err != nil {
-		utils.Logger.Error().Err(err).Msg("获取用户总数失败")
-		utils.ErrorResponse(c, "获取用户列表失败: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	utils.Logger.Info().Int64("totalCount", totalCount).Msg("集合总记录数")
-
-	if totalCount == 0 {
-		utils.Logger.Warn().Msg("用户集合中没有任何记录!")
-		utils.SuccessResponse(c, gin.H{"users": []interface{}{}}, "")
+		utils.HandleError(c, err)
 		return
 	}
 
 	// 设置查询选项，排除密码字段
-	findOptions := options.Find().SetProjection(bson.M{"password": 0})
+	findOptions := repository.NewFindOptions().SetProjection(bson.M{"password": 0})
 
 	// 执行查询
-	cursor, err := collection.Find(repository.GetContext(), bson.M{}, findOptions)
+	result, err := pagination.Find(c.Request.Context(), repository.Users(), bson.M{}, pageReq, findOptions)
 	if err != nil {
 		utils.Logger.Error().Err(err).Msg("查询用户失败")
 		utils.ErrorResponse(c, "获取用户列表失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer cursor.Close(repository.GetContext())
-
-	// 解析结果
-	var users []models.User
-	if err := cursor.All(repository.GetContext(), &users); err != nil {
-		utils.Logger.Error().Err(err).Msg("解析用户数据失败")
-		utils.ErrorResponse(c, "获取用户列表失败: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	utils.Logger.Info().Int("count", len(users)).Msg("成功查询到用户记录")
-
-	if len(users) > 0 {
-		utils.Logger.Debug().Interface("firstUser", users[0]).Msg("返回的第一个用户数据示例")
-	}
-
-	// 检查查询结果
-	if int64(len(users)) != totalCount {
-		utils.Logger.Warn().
-			Int("resultCount", len(users)).
-			Int64("totalCount", totalCount).
-			Msg("警告: 查询结果数量与集合总记录数不一致")
-	}
 
-	utils.Logger.Info().Int("count", len(users)).Msg("获取用户列表成功")
-	utils.SuccessResponse(c, gin.H{"users": users}, "")
+	utils.Logger.Info().
+		Int("count", len(result.Items)).
+		Int64("totalCount", result.Pagination.Total).
+		Msg("获取用户列表成功")
+	utils.PaginatedResponse(c, result.Items, result.Pagination)
 }
 
 // GetSalesUsers 获取所有销售人员
 func GetSalesUsers(c *gin.Context) {
 	utils.Logger.Info().Msg("处理获取销售人员列表请求...")
 
-	collection := repository.Collection(repository.UsersCollection)
-
 	// 设置查询条件和选项
 	filter := bson.M{
 		"role":   models.UserRoleFACTORY_SALES,
 		"status": models.UserStatusAPPROVED,
 	}
-	options := options.Find().SetProjection(bson.M{"password": 0})
+	options := repository.NewFindOptions().SetProjection(bson.M{"password": 0})
 
 	// 执行查询
-	cursor, err := collection.Find(repository.GetContext(), filter, options)
+	users, err := repository.Users().Find(c.Request.Context(), filter, options)
 	if err != nil {
 		utils.Logger.Error().Err(err).Msg("查询销售人员失败")
 		utils.ErrorResponse(c, "获取销售人员列表失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer cursor.Close(repository.GetContext())
-
-	// 解析结果
-	var users []models.User
-	if err := cursor.All(repository.GetContext(), &users); err != nil {
-		utils.Logger.Error().Err(err).Msg("解析销售人员数据失败")
-		utils.ErrorResponse(c, "获取销售人员列表失败: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
 
 	utils.Logger.Info().Int("count", len(users)).Msg("成功查询到销售人员记录")
 
@@ -119,28 +89,17 @@ func GetSalesUsers(c *gin.Context) {
 func GetPendingApprovalUsers(c *gin.Context) {
 	utils.Logger.Info().Msg("处理获取待审批用户请求...")
 
-	collection := repository.Collection(repository.UsersCollection)
-
 	// 设置查询条件和选项
 	filter := bson.M{"status": models.UserStatusPENDING}
-	options := options.Find().SetProjection(bson.M{"password": 0})
+	options := repository.NewFindOptions().SetProjection(bson.M{"password": 0})
 
 	// 执行查询
-	cursor, err := collection.Find(repository.GetContext(), filter, options)
+	pendingUsers, err := repository.Users().Find(c.Request.Context(), filter, options)
 	if err != nil {
 		utils.Logger.Error().Err(err).Msg("查询待审批用户失败")
 		utils.ErrorResponse(c, "获取待审批用户失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer cursor.Close(repository.GetContext())
-
-	// 解析结果
-	var pendingUsers []models.User
-	if err := cursor.All(repository.GetContext(), &pendingUsers); err != nil {
-		utils.Logger.Error().Err(err).Msg("解析待审批用户数据失败")
-		utils.ErrorResponse(c, "获取待审批用户失败: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
 
 	utils.Logger.Info().Int("count", len(pendingUsers)).Msg("找到待审批用户")
 
@@ -161,13 +120,7 @@ func ApproveUser(c *gin.Context) {
 		Bool("approved", req.Approved).
 		Msg("处理账户审批请求")
 
-	// 确定集合名称
-	collectionName := repository.UsersCollection
-	if req.Type == "agent" {
-		collectionName = repository.AgentsCollection
-	}
-
-	collection := repository.Collection(collectionName)
+	isAgent := req.Type == "agent"
 
 	// 检查ID格式
 	objectID, err := primitive.ObjectIDFromHex(req.ID)
@@ -178,10 +131,20 @@ func ApproveUser(c *gin.Context) {
 	}
 
 	// 检查账户是否存在
-	var existingAccount bson.M
-	err = collection.FindOne(repository.GetContext(), bson.M{"_id": objectID}).Decode(&existingAccount)
+	var status models.UserStatus
+	if isAgent {
+		var agent *models.Agent
+		if agent, err = repository.Agents().FindByID(c.Request.Context(), objectID); err == nil {
+			status = agent.Status
+		}
+	} else {
+		var account *models.User
+		if account, err = repository.Users().FindByID(c.Request.Context(), objectID); err == nil {
+			status = account.Status
+		}
+	}
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			utils.Logger.Error().Str("id", req.ID).Msg("账户不存在")
 			utils.ErrorResponse(c, "账户不存在", http.StatusNotFound)
 		} else {
@@ -192,8 +155,8 @@ func ApproveUser(c *gin.Context) {
 	}
 
 	// 检查是否已经被审批
-	if status, ok := existingAccount["status"].(string); ok && status != string(models.UserStatusPENDING) {
-		utils.Logger.Error().Str("id", req.ID).Str("status", status).Msg("该账户已经被审批过")
+	if status != models.UserStatusPENDING {
+		utils.Logger.Error().Str("id", req.ID).Str("status", string(status)).Msg("该账户已经被审批过")
 		utils.ErrorResponse(c, "该账户已经被审批过", http.StatusBadRequest)
 		return
 	}
@@ -211,13 +174,21 @@ func ApproveUser(c *gin.Context) {
 		},
 	}
 
-	// 如果拒绝，添加拒绝原因
-	if !req.Approved && req.Reason != "" {
-		update["$set"].(bson.M)["rejectionReason"] = req.Reason
+	// 如果拒绝，添加拒绝原因，并使已签发的令牌失效
+	if !req.Approved {
+		if req.Reason != "" {
+			update["$set"].(bson.M)["rejectionReason"] = req.Reason
+		}
+		update["$inc"] = bson.M{"tokenVersion": 1}
 	}
 
 	// 更新账户
-	result, err := collection.UpdateOne(repository.GetContext(), bson.M{"_id": objectID}, update)
+	var result *repository.UpdateResult
+	if isAgent {
+		result, err = repository.Agents().UpdateByID(c.Request.Context(), objectID, update)
+	} else {
+		result, err = repository.Users().UpdateByID(c.Request.Context(), objectID, update)
+	}
 	if err != nil {
 		utils.Logger.Error().Err(err).Str("id", req.ID).Msg("更新账户状态失败")
 		utils.ErrorResponse(c, "更新账户状态失败: "+err.Error(), http.StatusInternalServerError)
@@ -256,16 +227,25 @@ func CreateUser(c *gin.Context) {
 		Str("role", string(req.Role)).
 		Msg("处理创建用户请求")
 
-	collection := repository.Collection(repository.UsersCollection)
+	// 检查角色是否已定义
+	if !service.RoleExists(c.Request.Context(), req.Role) {
+		utils.ErrorResponse(c, "无效的用户角色", http.StatusBadRequest)
+		return
+	}
+
+	// 检查密码强度
+	if err := utils.ValidatePasswordStrength(req.Password, req.Username); err != nil {
+		utils.ErrorResponse(c, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// 检查用户名是否已存在
-	var existingUser models.User
-	err := collection.FindOne(repository.GetContext(), bson.M{"username": req.Username}).Decode(&existingUser)
+	_, err := repository.Users().FindByUsername(c.Request.Context(), req.Username)
 	if err == nil {
 		utils.Logger.Error().Str("username", req.Username).Msg("用户名已存在")
 		utils.ErrorResponse(c, "用户名已存在", http.StatusBadRequest)
 		return
-	} else if err != mongo.ErrNoDocuments {
+	} else if err != repository.ErrNotFound {
 		utils.Logger.Error().Err(err).Str("username", req.Username).Msg("检查用户名时发生错误")
 		utils.ErrorResponse(c, "创建用户失败: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -274,24 +254,30 @@ func CreateUser(c *gin.Context) {
 	// 检查是否有超级管理员角色
 	if req.Role == models.UserRoleSUPER_ADMIN {
 		// 检查是否已存在超级管理员
-		var existingAdmin models.User
-		err := collection.FindOne(repository.GetContext(), bson.M{"role": models.UserRoleSUPER_ADMIN}).Decode(&existingAdmin)
+		_, err := repository.Users().FindOne(c.Request.Context(), bson.M{"role": models.UserRoleSUPER_ADMIN})
 		if err == nil {
 			utils.Logger.Error().Msg("已存在超级管理员")
 			utils.ErrorResponse(c, "已存在超级管理员", http.StatusBadRequest)
 			return
-		} else if err != mongo.ErrNoDocuments {
+		} else if err != repository.ErrNotFound {
 			utils.Logger.Error().Err(err).Msg("检查超级管理员时发生错误")
 			utils.ErrorResponse(c, "创建用户失败: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 	}
 
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		utils.Logger.Error().Err(err).Str("username", req.Username).Msg("生成密码哈希失败")
+		utils.ErrorResponse(c, "创建用户失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	// 创建新用户(直接批准)
 	now := time.Now()
 	newUser := models.User{
 		Username:  req.Username,
-		Password:  utils.HashPassword(req.Password),
+		Password:  hashedPassword,
 		Phone:     req.Phone,
 		Role:      req.Role,
 		Status:    models.UserStatusAPPROVED,
@@ -300,7 +286,7 @@ func CreateUser(c *gin.Context) {
 	}
 
 	// 插入用户
-	result, err := collection.InsertOne(repository.GetContext(), newUser)
+	insertedID, err := repository.Users().Insert(c.Request.Context(), &newUser)
 	if err != nil {
 		utils.Logger.Error().Err(err).Str("username", req.Username).Msg("插入用户失败")
 
@@ -314,14 +300,6 @@ func CreateUser(c *gin.Context) {
 		return
 	}
 
-	// 验证插入是否成功
-	insertedID, ok := result.InsertedID.(primitive.ObjectID)
-	if !ok {
-		utils.Logger.Error().Msg("获取插入ID失败")
-		utils.ErrorResponse(c, "创建用户成功，但无法获取用户ID", http.StatusInternalServerError)
-		return
-	}
-
 	newUser.ID = insertedID
 	newUser.Password = "" // 不返回密码
 
@@ -365,13 +343,10 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
-	collection := repository.Collection(repository.UsersCollection)
-
 	// 检查用户是否存在
-	var existingUser models.User
-	err = collection.FindOne(repository.GetContext(), bson.M{"_id": objectID}).Decode(&existingUser)
+	existingUser, err := repository.Users().FindByID(c.Request.Context(), objectID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			utils.Logger.Error().Str("id", userID).Msg("用户不存在")
 			utils.ErrorResponse(c, "用户不存在", http.StatusNotFound)
 		} else {
@@ -391,22 +366,33 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
+	// 检查角色是否已定义
+	if req.Role != "" && !service.RoleExists(c.Request.Context(), req.Role) {
+		utils.ErrorResponse(c, "无效的用户角色", http.StatusBadRequest)
+		return
+	}
+
+	// 原厂销售改为其他角色后不再受交接约束，需先完成离职交接
+	if existingUser.Role == models.UserRoleFACTORY_SALES && req.Role != "" && req.Role != existingUser.Role &&
+		!requireHandoverDone(c, objectID, "更新用户失败") {
+		return
+	}
+
 	// 如果更改为超级管理员，检查是否已存在
 	if req.Role == models.UserRoleSUPER_ADMIN && existingUser.Role != models.UserRoleSUPER_ADMIN {
-		var existingAdmin models.User
-		err := collection.FindOne(
-			repository.GetContext(),
+		_, err := repository.Users().FindOne(
+			c.Request.Context(),
 			bson.M{
 				"role": models.UserRoleSUPER_ADMIN,
 				"_id":  bson.M{"$ne": objectID},
 			},
-		).Decode(&existingAdmin)
+		)
 
 		if err == nil {
 			utils.Logger.Error().Msg("已存在超级管理员")
 			utils.ErrorResponse(c, "已存在超级管理员", http.StatusBadRequest)
 			return
-		} else if err != mongo.ErrNoDocuments {
+		} else if err != repository.ErrNotFound {
 			utils.Logger.Error().Err(err).Msg("检查超级管理员时发生错误")
 			utils.ErrorResponse(c, "更新用户失败: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -415,20 +401,19 @@ func UpdateUser(c *gin.Context) {
 
 	// 如果修改了用户名，检查是否已存在
 	if req.Username != "" && req.Username != existingUser.Username {
-		var existingUsername models.User
-		err := collection.FindOne(
-			repository.GetContext(),
+		_, err := repository.Users().FindOne(
+			c.Request.Context(),
 			bson.M{
 				"username": req.Username,
 				"_id":      bson.M{"$ne": objectID},
 			},
-		).Decode(&existingUsername)
+		)
 
 		if err == nil {
 			utils.Logger.Error().Str("username", req.Username).Msg("用户名已存在")
 			utils.ErrorResponse(c, "用户名已存在", http.StatusBadRequest)
 			return
-		} else if err != mongo.ErrNoDocuments {
+		} else if err != repository.ErrNotFound {
 			utils.Logger.Error().Err(err).Str("username", req.Username).Msg("检查用户名时发生错误")
 			utils.ErrorResponse(c, "更新用户失败: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -451,15 +436,31 @@ func UpdateUser(c *gin.Context) {
 	}
 
 	if req.Password != "" {
-		updateData["password"] = utils.HashPassword(req.Password)
+		username := req.Username
+		if username == "" {
+			username = existingUser.Username
+		}
+		if err := utils.ValidatePasswordStrength(req.Password, username); err != nil {
+			utils.ErrorResponse(c, err.Error(), http.StatusBadRequest)
+			return
+		}
+		hashedPassword, err := utils.HashPassword(req.Password)
+		if err != nil {
+			utils.Logger.Error().Err(err).Str("id", userID).Msg("生成密码哈希失败")
+			utils.ErrorResponse(c, "更新用户失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		updateData["password"] = hashedPassword
+	}
+
+	// 修改角色或密码后，已签发的令牌全部失效
+	update := bson.M{"$set": updateData}
+	if (req.Role != "" && req.Role != existingUser.Role) || req.Password != "" {
+		update["$inc"] = bson.M{"tokenVersion": 1}
 	}
 
 	// 更新用户
-	result, err := collection.UpdateOne(
-		repository.GetContext(),
-		bson.M{"_id": objectID},
-		bson.M{"$set": updateData},
-	)
+	result, err := repository.Users().UpdateByID(c.Request.Context(), objectID, update)
 
 	if err != nil {
 		utils.Logger.Error().Err(err).Str("id", userID).Msg("更新用户失败")
@@ -506,13 +507,10 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
-	collection := repository.Collection(repository.UsersCollection)
-
 	// 检查用户是否存在
-	var userToDelete models.User
-	err = collection.FindOne(repository.GetContext(), bson.M{"_id": objectID}).Decode(&userToDelete)
+	userToDelete, err := repository.Users().FindByID(c.Request.Context(), objectID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			utils.Logger.Error().Str("id", userID).Msg("用户不存在")
 			utils.ErrorResponse(c, "用户不存在", http.StatusNotFound)
 		} else {
@@ -529,20 +527,159 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
+	// 原厂销售需先完成离职交接
+	if userToDelete.Role == models.UserRoleFACTORY_SALES && !requireHandoverDone(c, objectID, "删除用户失败") {
+		return
+	}
+
 	// 删除用户
-	result, err := collection.DeleteOne(repository.GetContext(), bson.M{"_id": objectID})
+	deletedCount, err := repository.Users().DeleteByID(c.Request.Context(), objectID)
 	if err != nil {
 		utils.Logger.Error().Err(err).Str("id", userID).Msg("删除用户失败")
 		utils.ErrorResponse(c, "删除用户失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if result.DeletedCount == 0 {
+	if deletedCount == 0 {
 		utils.Logger.Error().Str("id", userID).Msg("用户不存在")
 		utils.ErrorResponse(c, "用户不存在", http.StatusNotFound)
 		return
 	}
 
+	// 吊销该用户的登录会话
+	if err := service.InvalidateSessions(c.Request.Context(), userID, userToDelete.Role); err != nil {
+		utils.Logger.Error().Err(err).Str("id", userID).Msg("吊销用户登录会话失败")
+	}
+
 	utils.Logger.Info().Str("id", userID).Msg("删除用户成功")
 	utils.SuccessResponse(c, nil, "删除用户成功")
 }
+
+// requireHandoverDone 检查原厂销售是否已完成离职交接，未完成时写入冲突响应并返回 false
+func requireHandoverDone(c *gin.Context, objectID primitive.ObjectID, failMsg string) bool {
+	userID := objectID.Hex()
+	pending, err := service.PendingHandover(c.Request.Context(), objectID)
+	if err != nil {
+		utils.Logger.Error().Err(err).Str("id", userID).Msg("查询待交接数据失败")
+		utils.ErrorResponse(c, failMsg+": "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !pending.Empty() {
+		utils.Logger.Warn().Str("id", userID).Interface("pending", pending).Msg("用户尚未完成离职交接")
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error":   "该用户名下仍有客户、代理商或进行中的项目，请先完成离职交接",
+			"code":    service.ErrCodeHandoverRequired,
+			"pending": pending,
+		})
+		return false
+	}
+	return true
+}
+
+// UnlockUser 解除用户/代理商因登录失败次数过多导致的锁定
+func UnlockUser(c *gin.Context) {
+	userID := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		utils.Logger.Error().Err(err).Str("id", userID).Msg("无效的ID格式")
+		utils.ErrorResponse(c, "无效的ID格式", http.StatusBadRequest)
+		return
+	}
+
+	utils.Logger.Info().Str("id", userID).Msg("处理解除登录锁定请求")
+
+	ctx := c.Request.Context()
+
+	// 登录名为用户名或代理商公司名
+	var loginName string
+	if account, err := repository.Users().FindByID(ctx, objectID); err == nil {
+		loginName = account.Username
+	} else if err != repository.ErrNotFound {
+		utils.Logger.Error().Err(err).Str("id", userID).Msg("查询用户失败")
+		utils.ErrorResponse(c, "解除锁定失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	} else if agent, err := repository.Agents().FindByID(ctx, objectID); err == nil {
+		loginName = agent.CompanyName
+	} else if err == repository.ErrNotFound {
+		utils.ErrorResponse(c, "账户不存在", http.StatusNotFound)
+		return
+	} else {
+		utils.Logger.Error().Err(err).Str("id", userID).Msg("查询代理商失败")
+		utils.ErrorResponse(c, "解除锁定失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	unlocked, err := service.UnlockLogin(ctx, loginName)
+	if err != nil {
+		utils.Logger.Error().Err(err).Str("id", userID).Msg("解除登录锁定失败")
+		utils.ErrorResponse(c, "解除锁定失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !unlocked {
+		utils.SuccessResponse(c, nil, "该账户未被锁定")
+		return
+	}
+
+	utils.Logger.Info().Str("id", userID).Str("username", loginName).Msg("解除登录锁定成功")
+	utils.SuccessResponse(c, nil, "已解除账户锁定")
+}
+
+// GetPasswordHashReport 获取密码存储格式迁移报告
+func GetPasswordHashReport(c *gin.Context) {
+	utils.Logger.Info().Msg("处理获取密码迁移报告请求")
+
+	report, err := service.GetPasswordHashReport(c.Request.Context())
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("统计密码存储格式失败")
+		utils.ErrorResponse(c, "获取密码迁移报告失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(c, report, "")
+}
+
+// HandoverUser 离职交接：将销售名下的客户、代理商和进行中的项目分配给接收销售
+func HandoverUser(c *gin.Context) {
+	userID := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		utils.ErrorResponse(c, "无效的ID格式", http.StatusBadRequest)
+		return
+	}
+
+	user, err := utils.GetUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req models.UserHandoverRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, "无效的请求数据: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	utils.Logger.Info().
+		Str("id", userID).
+		Strs("targetSalesIds", req.TargetSalesIDs).
+		Str("strategy", req.Strategy).
+		Bool("dryRun", req.DryRun).
+		Msg("处理离职交接请求")
+
+	result, err := service.HandoverUser(c.Request.Context(), objectID, req, user)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	message := "离职交接完成"
+	switch {
+	case result.DryRun:
+		message = "离职交接预览"
+	case !result.Completed:
+		message = "部分数据交接失败，请重试"
+	}
+	utils.SuccessResponse(c, result, message)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -24,22 +25,43 @@ func main() {
 	// 初始化日志
 	utils.InitLogger()
 
+	// 子命令：crm migrate up|status [-dry-run]
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrateCommand(os.Args[2:]))
+	}
+
 	// 加载配置
-	cfg := config.LoadConfig()
+	configPath := flag.String("config", "", "配置文件路径（.yaml/.yml/.toml），默认读取 CRM_CONFIG 或 ./config.yaml")
+	flag.Parse()
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		utils.Logger.Fatal().Err(err).Msg("加载配置失败")
+	}
+	if cfg.Auth.JWTKey == config.DefaultJWTKey {
+		utils.Logger.Warn().Msg("正在使用默认JWT密钥，仅限开发环境")
+	}
 
 	// 设置Gin模式
-	if cfg.Debug {
-		gin.SetMode(gin.DebugMode)
-	} else {
-		gin.SetMode(gin.ReleaseMode)
-	}
+	gin.SetMode(cfg.Server.Mode)
+
+	// 注入配置
+	utils.InitAuth(cfg.Auth)
+	service.InitCompanyLookup(cfg.AliCloud)
+
+	// 应用生命周期上下文，收到退出信号后取消，定时任务随之停止
+	appCtx, stopApp := context.WithCancel(context.Background())
+	defer stopApp()
 
 	// 初始化数据库
-	if err := repository.InitMongoDB(cfg.MongoURI, cfg.MongoDB); err != nil {
+	if err := repository.InitMongoDB(appCtx, cfg.Mongo); err != nil {
 		utils.Logger.Fatal().Err(err).Msg("Failed to connect to MongoDB")
 	}
 
-	defer repository.CloseMongoDB()
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		repository.CloseMongoDB(closeCtx)
+	}()
 
 	// 创建Gin实例
 	router := gin.New()
@@ -56,22 +78,36 @@ func main() {
 
 	// 初始化系统数据
 	utils.Logger.Info().Msg("开始系统初始化...")
-	if err := repository.InitializeCollections(); err != nil {
+	initCtx, cancelInit := context.WithTimeout(appCtx, 2*time.Minute)
+	if err := repository.InitializeCollections(initCtx); err != nil {
 		utils.Logger.Error().Err(err).Msg("初始化数据库集合失败")
 	}
-	if err := repository.InitializeAdminAccount(); err != nil {
+	if err := repository.InitializeAdminAccount(initCtx, cfg.Auth.AdminInitialPassword); err != nil {
 		utils.Logger.Error().Err(err).Msg("初始化管理员账户失败")
 	}
+	if err := repository.InitializeRoles(initCtx); err != nil {
+		utils.Logger.Error().Err(err).Msg("初始化角色权限失败")
+	}
+	if cfg.Mongo.AutoMigrate {
+		if _, err := repository.MigrateUp(initCtx, false); err != nil {
+			utils.Logger.Error().Err(err).Msg("执行数据库迁移失败")
+		}
+	} else {
+		logPendingMigrations(initCtx)
+	}
+	service.LogPasswordHashReport(initCtx)
+	cancelInit()
 	utils.Logger.Info().Msg("系统初始化完成")
 
 	// 创建定时任务
-	service.ScheduleDailyTaskAt(1, 0, 0, func() {
-		service.ProcessInitialContactCustomers()
-	})
+	service.StartScheduler(appCtx, cfg.Scheduler)
+
+	// 后台预建客户搜索索引
+	service.WarmCustomerSearchIndex(appCtx)
 
 	// 设置HTTP服务器
 	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%d", cfg.Port),
+		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
 		Handler:      router,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
@@ -80,7 +116,7 @@ func main() {
 
 	// 启动服务器
 	go func() {
-		utils.Logger.Info().Msgf("服务器启动，监听端口: %d", cfg.Port)
+		utils.Logger.Info().Msgf("服务器启动，监听端口: %d", cfg.Server.Port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			utils.Logger.Fatal().Err(err).Msg("启动服务器失败")
 		}
@@ -91,6 +127,7 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	utils.Logger.Info().Msg("正在关闭服务器...")
+	stopApp()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -136,8 +136,9 @@ func OperationLoggerMiddleware() gin.HandlerFunc {
 			UserAgent:     c.Request.UserAgent(),
 		}
 
-		// 保存操作日志
-		if err := saveOperationLog(&operationLog); err != nil {
+		// 保存操作日志，客户端断开连接时也要写入，因此不继承请求的取消信号
+		logCtx := context.WithoutCancel(c.Request.Context())
+		if err := saveOperationLog(logCtx, &operationLog); err != nil {
 			utils.Logger.Error().Err(err).Msg("保存操作日志失败")
 			// 尝试保存最小日志
 			minimalLog := operationLog
@@ -146,7 +147,7 @@ func OperationLoggerMiddleware() gin.HandlerFunc {
 			minimalLog.ResponseData = nil
 			minimalLog.ErrorMessage = fmt.Sprintf("保存详细日志失败: %v", err)
 
-			if saveErr := saveOperationLog(&minimalLog); saveErr != nil {
+			if saveErr := saveOperationLog(logCtx, &minimalLog); saveErr != nil {
 				utils.Logger.Error().Err(saveErr).Msg("保存最小日志失败")
 			}
 		}
@@ -301,8 +302,7 @@ func getClientIP(c *gin.Context) string {
 }
 
 // saveOperationLog 保存操作日志到数据库
-func saveOperationLog(log *models.OperationLog) error {
-	apiOperationLogsCollection := repository.Collection(repository.ApiOperationLogsCollection)
-	_, err := apiOperationLogsCollection.InsertOne(context.Background(), *log)
+func saveOperationLog(ctx context.Context, log *models.OperationLog) error {
+	_, err := repository.OperationLogs().Insert(ctx, log)
 	return err
 }
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/service"
 	"github.com/BerniceZTT/crm_end/utils"
 
 	"github.com/gin-gonic/gin"
@@ -74,6 +75,38 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 只接受访问令牌，并校验账户状态与令牌版本（角色/密码变更、删除后旧令牌失效）
+		tokenType, _ := claims["typ"].(string)
+		tokenVersion, hasVersion := claims["tv"].(float64)
+		if tokenType != utils.AccessTokenType || !hasVersion {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "无效的token: 令牌类型错误",
+				"code":    "INVALID_TOKEN",
+			})
+			return
+		}
+		userID, _ := claims["id"].(string)
+		role, _ := claims["role"].(string)
+		if err := service.ValidateAccessToken(c.Request.Context(), userID, models.UserRole(role), int64(tokenVersion)); err != nil {
+			if err != service.ErrSessionRevoked {
+				utils.Logger.Error().Err(err).Str("id", userID).Msg("校验登录状态失败")
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"error":   "校验登录状态失败",
+					"code":    "SESSION_CHECK_FAILED",
+				})
+				return
+			}
+			utils.Logger.Info().Str("id", userID).Msg("Token已失效")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"code":    "TOKEN_REVOKED",
+			})
+			return
+		}
+
 		// 将用户信息存储到上下文
 		c.Set("user", claims)
 
@@ -86,6 +119,7 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// PermissionMiddleware 权限中间件，按角色在数据库中配置的资源/操作授权进行校验
 func PermissionMiddleware(resource string, action string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 获取用户信息
@@ -163,7 +197,7 @@ func PermissionMiddleware(resource string, action string) gin.HandlerFunc {
 		}
 
 		// 检查权限
-		if !utils.HasPermission(role, resource, action) {
+		if !service.HasPermission(c.Request.Context(), role, resource, action) {
 			utils.Logger.Info().
 				Str("username", username).
 				Str("role", string(role)).
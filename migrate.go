@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/config"
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+)
+
+const migrateUsage = `用法: crm migrate [-config 配置文件] [-dry-run] <up|status>
+
+  up       按版本顺序执行所有未执行的迁移
+  status   查看每个迁移的执行状态
+  -dry-run 与 up 一起使用，只输出将要执行的操作和预计影响的记录数，不修改数据
+`
+
+// runMigrateCommand 执行 migrate 子命令，返回进程退出码
+func runMigrateCommand(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, migrateUsage) }
+	configPath := fs.String("config", "", "配置文件路径")
+	dryRun := fs.Bool("dry-run", false, "只演练，不修改数据")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	// 允许参数写在子命令之后，如 crm migrate up -dry-run
+	action := fs.Arg(0)
+	if fs.NArg() > 1 {
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return 2
+		}
+	}
+	if action != "up" && action != "status" {
+		fs.Usage()
+		return 2
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "加载配置失败:", err)
+		return 1
+	}
+
+	// 迁移可能包含耗时的索引创建，不设整体超时，Ctrl+C 可中止
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := repository.InitMongoDB(ctx, cfg.Mongo); err != nil {
+		fmt.Fprintln(os.Stderr, "连接MongoDB失败:", err)
+		return 1
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		repository.CloseMongoDB(closeCtx)
+	}()
+
+	if action == "status" {
+		statuses, err := repository.MigrationStatuses(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "读取迁移状态失败:", err)
+			return 1
+		}
+		printMigrationStatuses(statuses)
+		return 0
+	}
+
+	reports, err := repository.MigrateUp(ctx, *dryRun)
+	printMigrationReports(reports, *dryRun)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "执行迁移失败:", err)
+		return 1
+	}
+	return 0
+}
+
+// printMigrationStatuses 输出迁移状态表
+func printMigrationStatuses(statuses []models.MigrationStatus) {
+	fmt.Printf("%-8s %-8s %-20s %s\n", "版本", "状态", "执行时间", "名称")
+	for _, status := range statuses {
+		appliedAt := "-"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-8d %-8s %-20s %s\n", status.Version, status.State, appliedAt, status.Name)
+	}
+}
+
+// printMigrationReports 输出迁移执行（或演练）结果
+func printMigrationReports(reports []models.MigrationReport, dryRun bool) {
+	if len(reports) == 0 {
+		fmt.Println("没有需要执行的迁移")
+		return
+	}
+	for _, report := range reports {
+		title := "已执行"
+		if dryRun {
+			title = "待执行"
+		} else if !report.Applied {
+			title = "失败"
+		}
+		fmt.Printf("[%s] %d %s\n", title, report.Version, report.Name)
+		for _, action := range report.Actions {
+			fmt.Println("    -", action)
+		}
+		if report.Error != "" {
+			fmt.Println("    ! 问题:", report.Error)
+		}
+	}
+}
+
+// logPendingMigrations 未开启自动迁移时提示尚未执行的迁移
+func logPendingMigrations(ctx context.Context) {
+	statuses, err := repository.MigrationStatuses(ctx)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("读取数据库迁移状态失败")
+		return
+	}
+	for _, status := range statuses {
+		if status.State != models.MigrationStateApplied {
+			utils.Logger.Warn().
+				Int("version", status.Version).
+				Str("name", status.Name).
+				Msg("存在未执行的数据库迁移，请执行 crm migrate up")
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// AssignmentOwner 客户归属（销售和代理商）
+type AssignmentOwner struct {
+	SalesID   string `json:"salesId"`
+	SalesName string `json:"salesName"`
+	AgentID   string `json:"agentId"`
+	AgentName string `json:"agentName"`
+}
+
+// AssignmentInconsistency 客户当前归属与最近一条分配历史不一致
+type AssignmentInconsistency struct {
+	CustomerID     string          `json:"customerId"`
+	CustomerName   string          `json:"customerName"`
+	IsInPublicPool bool            `json:"isInPublicPool"`
+	Reason         string          `json:"reason"`
+	Current        AssignmentOwner `json:"current"` // 客户当前归属
+	History        AssignmentOwner `json:"history"` // 最近一条分配历史的目标归属
+	HistoryID      string          `json:"historyId,omitempty"`
+	OperationType  string          `json:"operationType,omitempty"`
+	HistoryAt      *time.Time      `json:"historyAt,omitempty"`
+}
+
+// AssignmentConsistencyReport 客户归属一致性检查结果
+type AssignmentConsistencyReport struct {
+	CheckedAt       time.Time                 `json:"checkedAt"`
+	Checked         int                       `json:"checked"`
+	Inconsistencies []AssignmentInconsistency `json:"inconsistencies"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AutoTransferRule 客户自动转移规则，存放在 configType 为 customer_auto_transfer 的配置项的 configValue 中
+// 初步接触的客户按优先级匹配第一条适用范围相符的规则，无进展天数达到规则要求后轮流分配给目标销售池中的销售
+type AutoTransferRule struct {
+	Priority            int `bson:"priority" json:"priority"` // 数值越小越优先，相同时按配置键排序
+	DaysWithoutProgress int `bson:"daysWithoutProgress" json:"daysWithoutProgress"`
+
+	// 适用范围，为空表示不限，多项同时配置时需全部满足
+	Importances       []string `bson:"importances,omitempty" json:"importances,omitempty"`
+	Natures           []string `bson:"natures,omitempty" json:"natures,omitempty"`
+	ApplicationFields []string `bson:"applicationFields,omitempty" json:"applicationFields,omitempty"`
+	CurrentSalesIDs   []string `bson:"currentSalesIds,omitempty" json:"currentSalesIds,omitempty"` // 客户当前归属的销售
+
+	// TargetSalesIDs 目标销售池，按顺序轮流分配，跳过已停用的销售
+	TargetSalesIDs []string `bson:"targetSalesIds,omitempty" json:"targetSalesIds,omitempty"`
+	// TargetSalesID、TargetSalesName 旧版配置的单个目标销售，未配置销售池时使用
+	TargetSalesID   string `bson:"targetSalesId,omitempty" json:"targetSalesId,omitempty"`
+	TargetSalesName string `bson:"targetSalesName,omitempty" json:"targetSalesName,omitempty"`
+}
+
+// AutoTransferPlanItem 一个客户的自动转移计划
+type AutoTransferPlanItem struct {
+	CustomerID          string    `json:"customerId"`
+	CustomerName        string    `json:"customerName"`
+	Importance          string    `json:"importance"`
+	FromSalesID         string    `json:"fromSalesId,omitempty"`
+	FromSalesName       string    `json:"fromSalesName,omitempty"`
+	FromAgentName       string    `json:"fromAgentName,omitempty"`
+	ToSalesID           string    `json:"toSalesId"`
+	ToSalesName         string    `json:"toSalesName"`
+	RuleKey             string    `json:"ruleKey"`
+	ReferenceTime       time.Time `json:"referenceTime"` // 进入初步接触的时间，没有时为创建时间
+	DaysWithoutProgress int       `json:"daysWithoutProgress"`
+}
+
+// AutoTransferRuleError 无法使用的自动转移规则
+type AutoTransferRuleError struct {
+	ConfigID  primitive.ObjectID `json:"configId"`
+	ConfigKey string             `json:"configKey"`
+	Error     string             `json:"error"`
+}
+
+// AutoTransferPlan 按规则计算出的一次自动转移计划，预览和定时任务使用同一计算
+type AutoTransferPlan struct {
+	At           time.Time               `json:"at"` // 计算无进展天数的时间点，预览时为下一次执行时间
+	Checked      int                     `json:"checked"`
+	Items        []AutoTransferPlanItem  `json:"items"`
+	InvalidRules []AutoTransferRuleError `json:"invalidRules"`
+}
@@ -7,22 +7,29 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// 公海相关操作在分配历史中的操作类型
+const (
+	MoveToPublicPoolOperationType      = "移入公海池"
+	RestoreFromPublicPoolOperationType = "恢复原归属"
+)
+
 // CustomerAssignmentHistory 客户分配历史记录
 type CustomerAssignmentHistory struct {
 	ID                   primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
-	CustomerID           string             `json:"customerId" bson:"customerid"`
-	CustomerName         string             `json:"customerName" bson:"customername"`
-	FromRelatedSalesID   string             `json:"fromRelatedSalesId,omitempty" bson:"fromrelatedsalesid,omitempty"`
-	FromRelatedSalesName string             `json:"fromRelatedSalesName,omitempty" bson:"fromrelatedsalesname,omitempty"`
-	ToRelatedSalesID     string             `json:"toRelatedSalesId,omitempty" bson:"torelatedsalesid,omitempty"`
-	ToRelatedSalesName   string             `json:"toRelatedSalesName,omitempty" bson:"torelatedsalesname,omitempty"`
-	FromRelatedAgentID   string             `json:"fromRelatedAgentId,omitempty" bson:"fromrelatedagentid,omitempty"`
-	FromRelatedAgentName string             `json:"fromRelatedAgentName,omitempty" bson:"fromrelatedagentname,omitempty"`
-	ToRelatedAgentID     string             `json:"toRelatedAgentId,omitempty" bson:"torelatedagentid,omitempty"`
-	ToRelatedAgentName   string             `json:"toRelatedAgentName,omitempty" bson:"torelatedagentname,omitempty"`
-	OperatorID           string             `json:"operatorId" bson:"operatorid"`
-	OperatorName         string             `json:"operatorName" bson:"operatorname"`
-	OperationType        string             `json:"operationType" bson:"operationtype"`
+	CustomerID           string             `json:"customerId" bson:"customerId"`
+	CustomerName         string             `json:"customerName" bson:"customerName"`
+	FromRelatedSalesID   string             `json:"fromRelatedSalesId,omitempty" bson:"fromRelatedSalesId,omitempty"`
+	FromRelatedSalesName string             `json:"fromRelatedSalesName,omitempty" bson:"fromRelatedSalesName,omitempty"`
+	ToRelatedSalesID     string             `json:"toRelatedSalesId,omitempty" bson:"toRelatedSalesId,omitempty"`
+	ToRelatedSalesName   string             `json:"toRelatedSalesName,omitempty" bson:"toRelatedSalesName,omitempty"`
+	FromRelatedAgentID   string             `json:"fromRelatedAgentId,omitempty" bson:"fromRelatedAgentId,omitempty"`
+	FromRelatedAgentName string             `json:"fromRelatedAgentName,omitempty" bson:"fromRelatedAgentName,omitempty"`
+	ToRelatedAgentID     string             `json:"toRelatedAgentId,omitempty" bson:"toRelatedAgentId,omitempty"`
+	ToRelatedAgentName   string             `json:"toRelatedAgentName,omitempty" bson:"toRelatedAgentName,omitempty"`
+	OperatorID           string             `json:"operatorId" bson:"operatorId"`
+	OperatorName         string             `json:"operatorName" bson:"operatorName"`
+	OperationType        string             `json:"operationType" bson:"operationType"`
 	CreatedAt            time.Time          `json:"createdAt" bson:"createdAt"`
 	UpdatedAt            time.Time          `json:"updatedAt" bson:"updatedAt"`
+	DeletedAt            *time.Time         `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"` // 随客户移入回收站
 }
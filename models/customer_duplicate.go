@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// DuplicateCandidate 疑似重复的客户
+type DuplicateCandidate struct {
+	CustomerID       string   `json:"customerId,omitempty"` // 同批次导入数据中的重复行为空
+	Name             string   `json:"name"`
+	Progress         string   `json:"progress"`
+	RelatedSalesName string   `json:"relatedSalesName"`
+	IsInPublicPool   bool     `json:"isInPublicPool"`
+	Score            int      `json:"score"`   // 0~100，越高越可能重复
+	Reasons          []string `json:"reasons"` // 命中的规则说明
+}
+
+// DuplicatePair 报告中两个客户之间的重复关系
+type DuplicatePair struct {
+	CustomerID      string   `json:"customerId"`
+	OtherCustomerID string   `json:"otherCustomerId"`
+	Score           int      `json:"score"`
+	Reasons         []string `json:"reasons"`
+}
+
+// DuplicateGroup 相互疑似重复的一组客户
+type DuplicateGroup struct {
+	Score     int                  `json:"score"` // 组内最高分
+	Customers []DuplicateCandidate `json:"customers"`
+	Pairs     []DuplicatePair      `json:"pairs"`
+}
+
+// DuplicateReport 全量客户查重报告
+type DuplicateReport struct {
+	GeneratedAt time.Time        `json:"generatedAt"`
+	Scanned     int              `json:"scanned"`
+	MinScore    int              `json:"minScore"`
+	Groups      []DuplicateGroup `json:"groups"`
+}
+
+// BulkImportDuplicate 批量导入中疑似重复的行
+type BulkImportDuplicate struct {
+	Row        int                  `json:"row"` // 从1开始
+	Name       string               `json:"name"`
+	Candidates []DuplicateCandidate `json:"candidates"`
+}
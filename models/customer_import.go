@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 客户导入批次状态
+const (
+	CustomerImportStatusPreviewed = "previewed" // 已解析校验，等待确认导入
+	CustomerImportStatusImported  = "imported"  // 已执行导入
+)
+
+// 导入行状态
+const (
+	ImportRowValid    = "valid"    // 校验通过
+	ImportRowWarning  = "warning"  // 存在疑似重复，确认后可导入
+	ImportRowInvalid  = "invalid"  // 校验未通过
+	ImportRowImported = "imported" // 已导入
+	ImportRowFailed   = "failed"   // 导入时复核未通过或写入失败
+)
+
+// CustomerImportData 从表格行解析出的客户数据，销售和代理商已按名称解析为ID
+type CustomerImportData struct {
+	Name             string   `bson:"name" json:"name"`
+	Nature           string   `bson:"nature" json:"nature"`
+	Importance       string   `bson:"importance" json:"importance"`
+	ApplicationField string   `bson:"applicationField" json:"applicationField"`
+	ProductNeeds     []string `bson:"productNeeds" json:"productNeeds"`
+	ContactPerson    string   `bson:"contactPerson" json:"contactPerson"`
+	ContactPhone     string   `bson:"contactPhone" json:"contactPhone"`
+	Address          string   `bson:"address" json:"address"`
+	Progress         string   `bson:"progress" json:"progress"`
+	AnnualDemand     float64  `bson:"annualDemand" json:"annualDemand"`
+	RelatedSalesID   string   `bson:"relatedSalesId" json:"relatedSalesId"`
+	RelatedSalesName string   `bson:"relatedSalesName" json:"relatedSalesName"`
+	RelatedAgentID   string   `bson:"relatedAgentId" json:"relatedAgentId"`
+	RelatedAgentName string   `bson:"relatedAgentName" json:"relatedAgentName"`
+}
+
+// CustomerImportRow 导入文件中的一行及其校验结果
+type CustomerImportRow struct {
+	Row        int                  `bson:"row" json:"row"`       // 表格中的行号（从1开始，含标题行）
+	Values     []string             `bson:"values" json:"values"` // 原始单元格，用于生成错误报告
+	Data       CustomerImportData   `bson:"data" json:"data"`
+	Status     string               `bson:"status" json:"status"`
+	Errors     []string             `bson:"errors" json:"errors"`
+	Warnings   []string             `bson:"warnings" json:"warnings"`
+	Duplicates []DuplicateCandidate `bson:"duplicates,omitempty" json:"duplicates,omitempty"`
+	CustomerID string               `bson:"customerId,omitempty" json:"customerId,omitempty"` // 导入后的客户ID
+}
+
+// CustomerImportSummary 导入批次各状态的行数
+type CustomerImportSummary struct {
+	Total    int `bson:"total" json:"total"`
+	Valid    int `bson:"valid" json:"valid"`
+	Warning  int `bson:"warning" json:"warning"`
+	Invalid  int `bson:"invalid" json:"invalid"`
+	Imported int `bson:"imported" json:"imported"`
+	Failed   int `bson:"failed" json:"failed"`
+}
+
+// CustomerImportField 导入模板中的一列
+type CustomerImportField struct {
+	Field    string   `json:"field"`
+	Label    string   `json:"label"`
+	Required bool     `json:"required"`
+	Aliases  []string `json:"aliases,omitempty"` // 可识别的其他列标题
+}
+
+// CustomerImport 客户导入批次 (MongoDB文档结构)，预览后保存，确认导入和下载错误报告时使用
+type CustomerImport struct {
+	ID            primitive.ObjectID    `bson:"_id,omitempty" json:"_id,omitempty"`
+	FileName      string                `bson:"fileName" json:"fileName"`
+	Headers       []string              `bson:"headers" json:"headers"`
+	Columns       []string              `bson:"columns" json:"columns"` // 每列对应的字段，空字符串表示忽略该列
+	Rows          []CustomerImportRow   `bson:"rows" json:"rows"`
+	Summary       CustomerImportSummary `bson:"summary" json:"summary"`
+	Status        string                `bson:"status" json:"status"`
+	CreatedByID   string                `bson:"createdById" json:"createdById"`
+	CreatedByName string                `bson:"createdByName" json:"createdByName"`
+	CreatedAt     time.Time             `bson:"createdAt" json:"createdAt"`
+	ImportedAt    *time.Time            `bson:"importedAt,omitempty" json:"importedAt,omitempty"`
+	ExpiresAt     time.Time             `bson:"expiresAt" json:"expiresAt"` // 过期后由TTL索引自动删除
+}
+
+// CustomerImportCommitRequest 确认导入请求
+type CustomerImportCommitRequest struct {
+	IgnoreDuplicates bool  `json:"ignoreDuplicates"` // 疑似重复的行也导入
+	Rows             []int `json:"rows"`             // 只导入指定行号，为空时导入全部可导入的行
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CustomerMergeRequest 合并客户请求
+type CustomerMergeRequest struct {
+	SurvivorID   string   `json:"survivorId" binding:"required"`   // 保留的客户
+	DuplicateIDs []string `json:"duplicateIds" binding:"required"` // 并入后删除的重复客户
+	DryRun       bool     `json:"dryRun"`                          // 只预览将要迁移的数据，不做修改
+	Remark       string   `json:"remark"`
+}
+
+// CustomerMergeCounts 合并时迁移到保留客户名下的记录数
+type CustomerMergeCounts struct {
+	FollowUps         int64 `bson:"followUps" json:"followUps"`
+	Projects          int64 `bson:"projects" json:"projects"`
+	AssignmentHistory int64 `bson:"assignmentHistory" json:"assignmentHistory"`
+	ProgressHistory   int64 `bson:"progressHistory" json:"progressHistory"`
+}
+
+// CustomerMergeRecord 客户合并审计记录 (MongoDB文档结构)
+// Duplicates 保存被合并客户删除前的完整快照
+type CustomerMergeRecord struct {
+	ID           primitive.ObjectID  `bson:"_id,omitempty" json:"_id,omitempty"`
+	SurvivorID   string              `bson:"survivorId" json:"survivorId"`
+	SurvivorName string              `bson:"survivorName" json:"survivorName"`
+	Duplicates   []Customer          `bson:"duplicates" json:"duplicates"`
+	Moved        CustomerMergeCounts `bson:"moved" json:"moved"`
+	ProductNeeds []string            `bson:"productNeeds" json:"productNeeds"` // 合并后保留客户的产品需求
+	OperatorID   string              `bson:"operatorId" json:"operatorId"`
+	OperatorName string              `bson:"operatorName" json:"operatorName"`
+	Remark       string              `bson:"remark,omitempty" json:"remark,omitempty"`
+	CreatedAt    time.Time           `bson:"createdAt" json:"createdAt"`
+}
+
+// CustomerMergeResult 合并（或预览）结果
+type CustomerMergeResult struct {
+	DryRun       bool                `json:"dryRun"`
+	MergeID      string              `json:"mergeId,omitempty"`
+	Survivor     Customer            `json:"survivor"`
+	Duplicates   []Customer          `json:"duplicates"`
+	Moved        CustomerMergeCounts `json:"moved"`
+	ProductNeeds []string            `json:"productNeeds"`
+	// ProgressAfter 合并后保留客户的进展状态（迁入项目后初步接触的客户转为正常推进）
+	ProgressAfter string `json:"progressAfter"`
+}
@@ -17,12 +17,12 @@ const (
 // CustomerProgressHistory 客户进展历史记录
 type CustomerProgressHistory struct {
 	ID           primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
-	CustomerID   string             `json:"customerId" bson:"customerid"`
-	CustomerName string             `json:"customerName" bson:"customername"`
-	FromProgress string             `json:"fromProgress" bson:"fromprogress"`
-	ToProgress   string             `json:"toProgress" bson:"toprogress"`
-	OperatorID   string             `json:"operatorId" bson:"operatorid"`
-	OperatorName string             `json:"operatorName" bson:"operatorname"`
+	CustomerID   string             `json:"customerId" bson:"customerId"`
+	CustomerName string             `json:"customerName" bson:"customerName"`
+	FromProgress string             `json:"fromProgress" bson:"fromProgress"`
+	ToProgress   string             `json:"toProgress" bson:"toProgress"`
+	OperatorID   string             `json:"operatorId" bson:"operatorId"`
+	OperatorName string             `json:"operatorName" bson:"operatorName"`
 	Remark       string             `json:"remark" bson:"remark"`
 	CreatedAt    time.Time          `json:"createdAt" bson:"createdAt"`
 	UpdatedAt    time.Time          `json:"updatedAt" bson:"updatedAt"`
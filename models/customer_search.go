@@ -0,0 +1,42 @@
+package models
+
+// CustomerSearchQuery 客户搜索条件，Keyword 按空白拆分为多个词，每个词都需命中
+type CustomerSearchQuery struct {
+	Keyword        string
+	Nature         string
+	Importance     string
+	Progress       string
+	RelatedSalesID string
+	Page           int64
+	Limit          int64
+}
+
+// CustomerSearchHit 一条搜索结果
+type CustomerSearchHit struct {
+	Customer   Customer          `json:"customer"`
+	Score      int               `json:"score"`
+	Highlights map[string]string `json:"highlights"` // 字段 -> 用 <em> 标记命中部分的文本，其余内容已做 HTML 转义
+}
+
+// CustomerSearchFacetValue 分面中的一个取值及命中数量
+type CustomerSearchFacetValue struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// CustomerSearchFacets 搜索结果的分面统计，每个分面按除自身以外的筛选条件统计，便于切换取值
+type CustomerSearchFacets struct {
+	Nature     []CustomerSearchFacetValue `json:"nature"`
+	Importance []CustomerSearchFacetValue `json:"importance"`
+	Progress   []CustomerSearchFacetValue `json:"progress"`
+	Sales      []CustomerSearchFacetValue `json:"sales"`
+}
+
+// CustomerSearchResult 客户搜索结果，按相关度倒序
+type CustomerSearchResult struct {
+	Keyword    string               `json:"keyword"`
+	Hits       []CustomerSearchHit  `json:"hits"`
+	Facets     CustomerSearchFacets `json:"facets"`
+	Pagination Pagination           `json:"pagination"`
+}
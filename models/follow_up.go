@@ -17,6 +17,7 @@ type FollowUpRecord struct {
 	CreatorType string             `bson:"creatorType" json:"creatorType"`
 	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
 	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+	DeletedAt   *time.Time         `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"` // 随客户移入回收站
 }
 
 // CreateFollowUpRecordInput 创建跟进记录的输入数据
@@ -42,10 +42,21 @@ type PaginatedInventoryResponse struct {
 	Pagination Pagination        `json:"pagination"`
 }
 
-// Pagination 分页信息
+// Pagination 分页信息，游标翻页时 Page 为 0，NextCursor 为下一页的游标
 type Pagination struct {
-	Total int64 `json:"total"`
-	Page  int64 `json:"page"`
-	Limit int64 `json:"limit"`
-	Pages int64 `json:"pages"`
+	Total      int64  `json:"total"`
+	Page       int64  `json:"page"`
+	Limit      int64  `json:"limit"`
+	Pages      int64  `json:"pages"`
+	HasMore    bool   `json:"hasMore"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// NewPagination 按总数和每页数量计算总页数，HasMore 按页码推算
+func NewPagination(total, page, limit int64) Pagination {
+	pages := int64(0)
+	if limit > 0 {
+		pages = (total + limit - 1) / limit
+	}
+	return Pagination{Total: total, Page: page, Limit: limit, Pages: pages, HasMore: page > 0 && page < pages}
 }
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 早期版本写入的全小写字段名 -> 当前字段名
+// 迁移会将存量文档改为当前字段名；灰度期间旧版本实例仍可能写入旧字段名，读取时通过 UnmarshalBSON 兼容
+var (
+	CustomerLegacyFields = map[string]string{
+		"applicationfield": "applicationField",
+		"productneeds":     "productNeeds",
+		"contactperson":    "contactPerson",
+		"contactphone":     "contactPhone",
+		"annualdemand":     "annualDemand",
+		"ownerid":          "ownerId",
+		"ownername":        "ownerName",
+		"ownertype":        "ownerType",
+		"ownertypedisplay": "ownerTypeDisplay",
+		"lastupdatetime":   "lastUpdateTime",
+	}
+
+	AssignmentHistoryLegacyFields = map[string]string{
+		"customerid":           "customerId",
+		"customername":         "customerName",
+		"fromrelatedsalesid":   "fromRelatedSalesId",
+		"fromrelatedsalesname": "fromRelatedSalesName",
+		"torelatedsalesid":     "toRelatedSalesId",
+		"torelatedsalesname":   "toRelatedSalesName",
+		"fromrelatedagentid":   "fromRelatedAgentId",
+		"fromrelatedagentname": "fromRelatedAgentName",
+		"torelatedagentid":     "toRelatedAgentId",
+		"torelatedagentname":   "toRelatedAgentName",
+		"operatorid":           "operatorId",
+		"operatorname":         "operatorName",
+		"operationtype":        "operationType",
+	}
+
+	ProgressHistoryLegacyFields = map[string]string{
+		"customerid":   "customerId",
+		"customername": "customerName",
+		"fromprogress": "fromProgress",
+		"toprogress":   "toProgress",
+		"operatorid":   "operatorId",
+		"operatorname": "operatorName",
+	}
+)
+
+// decodeWithLegacyFields 解码文档，当前字段名缺失而旧字段名存在时改用旧字段的值
+func decodeWithLegacyFields(data []byte, out interface{}, legacyFields map[string]string) error {
+	raw := bson.Raw(data)
+	if err := raw.Validate(); err != nil {
+		return err
+	}
+
+	var renamed bson.D
+	for legacy, current := range legacyFields {
+		if _, err := raw.LookupErr(current); err == nil {
+			continue
+		}
+		value, err := raw.LookupErr(legacy)
+		if err != nil {
+			continue
+		}
+		renamed = append(renamed, bson.E{Key: current, Value: value})
+	}
+	if len(renamed) == 0 {
+		return bson.Unmarshal(data, out)
+	}
+
+	// 将旧字段的值以当前字段名追加到文档后再解码
+	elements, err := raw.Elements()
+	if err != nil {
+		return err
+	}
+	doc := make(bson.D, 0, len(elements)+len(renamed))
+	for _, element := range elements {
+		doc = append(doc, bson.E{Key: element.Key(), Value: element.Value()})
+	}
+	doc = append(doc, renamed...)
+
+	merged, err := bson.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("兼容旧字段名失败: %w", err)
+	}
+	return bson.Unmarshal(merged, out)
+}
+
+// UnmarshalBSON 兼容旧字段名读取客户文档
+func (c *Customer) UnmarshalBSON(data []byte) error {
+	type customerDoc Customer
+	var doc customerDoc
+	if err := decodeWithLegacyFields(data, &doc, CustomerLegacyFields); err != nil {
+		return err
+	}
+	*c = Customer(doc)
+	return nil
+}
+
+// UnmarshalBSON 兼容旧字段名读取分配历史
+func (h *CustomerAssignmentHistory) UnmarshalBSON(data []byte) error {
+	type historyDoc CustomerAssignmentHistory
+	var doc historyDoc
+	if err := decodeWithLegacyFields(data, &doc, AssignmentHistoryLegacyFields); err != nil {
+		return err
+	}
+	*h = CustomerAssignmentHistory(doc)
+	return nil
+}
+
+// UnmarshalBSON 兼容旧字段名读取进展历史
+func (h *CustomerProgressHistory) UnmarshalBSON(data []byte) error {
+	type historyDoc CustomerProgressHistory
+	var doc historyDoc
+	if err := decodeWithLegacyFields(data, &doc, ProgressHistoryLegacyFields); err != nil {
+		return err
+	}
+	*h = CustomerProgressHistory(doc)
+	return nil
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginAttemptKeyType 登录失败计数的维度
+type LoginAttemptKeyType string
+
+const (
+	LoginAttemptKeyUsername LoginAttemptKeyType = "username" // 按登录名计数
+	LoginAttemptKeyIP       LoginAttemptKeyType = "ip"       // 按客户端IP计数
+)
+
+// LoginAttempt 登录失败计数 (MongoDB文档结构)
+// 每个登录名/IP 一条记录，最后一次失败一段时间后由TTL索引自动删除
+type LoginAttempt struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	Key            string              `bson:"key" json:"key"` // 如 username:admin、ip:10.0.0.1
+	KeyType        LoginAttemptKeyType `bson:"keyType" json:"keyType"`
+	Failures       int                 `bson:"failures" json:"failures"`   // 当前窗口内的连续失败次数
+	LockCount      int                 `bson:"lockCount" json:"lockCount"` // 已被锁定的次数，锁定时长随之递增
+	FirstFailureAt time.Time           `bson:"firstFailureAt" json:"firstFailureAt"`
+	LastFailureAt  time.Time           `bson:"lastFailureAt" json:"lastFailureAt"`
+	LockedUntil    *time.Time          `bson:"lockedUntil,omitempty" json:"lockedUntil,omitempty"`
+	ExpiresAt      time.Time           `bson:"expiresAt" json:"expiresAt"` // TTL 索引字段
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MigrationState 迁移执行状态
+type MigrationState string
+
+const (
+	MigrationStatePending MigrationState = "pending" // 尚未执行
+	MigrationStateRunning MigrationState = "running" // 正在执行（或上次执行中断）
+	MigrationStateApplied MigrationState = "applied" // 已执行
+)
+
+// MigrationRecord 已执行的迁移记录 (MongoDB文档结构)
+// 版本号唯一，多个实例同时启动时只有一个能写入 running 记录并执行该迁移
+type MigrationRecord struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Version    int                `bson:"version" json:"version"`
+	Name       string             `bson:"name" json:"name"`
+	State      MigrationState     `bson:"state" json:"state"`
+	StartedAt  time.Time          `bson:"startedAt" json:"startedAt"`
+	AppliedAt  *time.Time         `bson:"appliedAt,omitempty" json:"appliedAt,omitempty"`
+	DurationMs int64              `bson:"durationMs" json:"durationMs"`
+	Affected   int64              `bson:"affected" json:"affected"` // 回填修改的记录数
+}
+
+// MigrationStatus 迁移状态（已声明的迁移与执行记录合并）
+type MigrationStatus struct {
+	Version   int            `json:"version"`
+	Name      string         `json:"name"`
+	State     MigrationState `json:"state"`
+	StartedAt *time.Time     `json:"startedAt,omitempty"`
+	AppliedAt *time.Time     `json:"appliedAt,omitempty"`
+}
+
+// MigrationReport 一次迁移的执行（或演练）结果
+type MigrationReport struct {
+	Version  int      `json:"version"`
+	Name     string   `json:"name"`
+	Actions  []string `json:"actions"`         // 将要/已经执行的操作说明
+	Affected int64    `json:"affected"`        // 回填影响的记录数（演练时为预计数量）
+	Applied  bool     `json:"applied"`         // 演练时为 false
+	Error    string   `json:"error,omitempty"` // 演练时发现的问题（如唯一索引存在重复数据）
+}
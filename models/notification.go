@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 站内通知类型
+const (
+	NotificationTypeRecycleWarning = "public_pool_recycle_warning" // 客户即将被回收到公海
+	NotificationTypeRecycled       = "public_pool_recycled"        // 客户已被回收到公海
+)
+
+// Notification 发给用户或代理商的站内通知
+type Notification struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	RecipientID  string             `bson:"recipientId" json:"recipientId"`
+	Type         string             `bson:"type" json:"type"`
+	Title        string             `bson:"title" json:"title"`
+	Content      string             `bson:"content" json:"content"`
+	CustomerID   string             `bson:"customerId,omitempty" json:"customerId,omitempty"`
+	CustomerName string             `bson:"customerName,omitempty" json:"customerName,omitempty"`
+	// DedupeKey 同一接收人同一键只发送一次，用于避免每天重复提醒
+	DedupeKey string     `bson:"dedupeKey,omitempty" json:"-"`
+	Read      bool       `bson:"read" json:"read"`
+	ReadAt    *time.Time `bson:"readAt,omitempty" json:"readAt,omitempty"`
+	CreatedAt time.Time  `bson:"createdAt" json:"createdAt"`
+}
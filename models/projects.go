@@ -45,6 +45,7 @@ type Project struct {
 	UpdatedAt                 time.Time          `json:"updatedAt" bson:"updatedAt"`
 	StartDate                 time.Time          `json:"startDate" bson:"startDate"`
 	WebHidden                 bool               `json:"webHidden" bson:"webHidden"`
+	DeletedAt                 *time.Time         `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"` // 随客户移入回收站
 }
 
 func ConvertProjectToResponse(project Project) ProjectResponse {
@@ -21,10 +21,39 @@ type PublicPoolCustomer struct {
 	PreviousOwnerType        string             `json:"previousOwnerType,omitempty"`
 	PreviousRelatedSalesName string             `json:"previousRelatedSalesName,omitempty"`
 	PreviousRelatedAgentName string             `json:"previousRelatedAgentName,omitempty"`
-	CreatorID                string             `json:"creatorId"`
-	CreatorName              string             `json:"creatorName"`
-	CreatorType              string             `json:"creatorType"`
-	CreatedAt                time.Time          `json:"createdAt"`
+	// PreviousOwnerSnapshot 移入公海前的归属和联系人详情，仅超级管理员可见
+	PreviousOwnerSnapshot *CustomerPoolSnapshot `json:"previousOwnerSnapshot,omitempty"`
+	CreatorID             string                `json:"creatorId"`
+	CreatorName           string                `json:"creatorName"`
+	CreatorType           string                `json:"creatorType"`
+	CreatedAt             time.Time             `json:"createdAt"`
+}
+
+// CustomerPoolSnapshot 客户移入公海时保存的原归属和联系人信息，用于展示原归属人和恢复原归属
+type CustomerPoolSnapshot struct {
+	RelatedSalesID   string               `bson:"relatedSalesId,omitempty" json:"relatedSalesId,omitempty"`
+	RelatedSalesName string               `bson:"relatedSalesName,omitempty" json:"relatedSalesName,omitempty"`
+	RelatedAgentID   string               `bson:"relatedAgentId,omitempty" json:"relatedAgentId,omitempty"`
+	RelatedAgentName string               `bson:"relatedAgentName,omitempty" json:"relatedAgentName,omitempty"`
+	ContactPerson    string               `bson:"contactPerson,omitempty" json:"contactPerson,omitempty"`
+	ContactPhone     string               `bson:"contactPhone,omitempty" json:"contactPhone,omitempty"`
+	Progress         string               `bson:"progress,omitempty" json:"progress,omitempty"`
+	HiddenProjectIDs []primitive.ObjectID `bson:"hiddenProjectIds,omitempty" json:"hiddenProjectIds,omitempty"` // 移入公海时被隐藏的项目，恢复时重新展示
+	MovedAt          time.Time            `bson:"movedAt" json:"movedAt"`
+	MovedByID        string               `bson:"movedById,omitempty" json:"movedById,omitempty"`
+	MovedByName      string               `bson:"movedByName,omitempty" json:"movedByName,omitempty"`
+	Remark           string               `bson:"remark,omitempty" json:"remark,omitempty"`
+}
+
+// PreviousOwner 原归属人：有关联销售时为销售，否则为关联代理商
+func (s *CustomerPoolSnapshot) PreviousOwner() (id, name string, ownerType UserRole) {
+	if s.RelatedSalesID != "" {
+		return s.RelatedSalesID, s.RelatedSalesName, UserRoleFACTORY_SALES
+	}
+	if s.RelatedAgentID != "" {
+		return s.RelatedAgentID, s.RelatedAgentName, UserRoleAGENT
+	}
+	return "", "", ""
 }
 
 // AssignPublicPoolRequest 公海客户分配请求
@@ -33,6 +62,16 @@ type AssignPublicPoolRequest struct {
 	TargetType string `json:"targetType" binding:"required,oneof=FACTORY_SALES AGENT"`
 }
 
+// PublicPoolClaimQuotaStatus 当前用户的公海认领额度使用情况，Limit 为 0 表示不限
+type PublicPoolClaimQuotaStatus struct {
+	Role         string `json:"role"`
+	DailyLimit   int    `json:"dailyLimit"`
+	DailyClaimed int64  `json:"dailyClaimed"`
+	TotalLimit   int    `json:"totalLimit"`
+	TotalOwned   int64  `json:"totalOwned"`
+	CooldownDays int    `json:"cooldownDays"`
+}
+
 // UserBrief 用户简要信息
 type UserBrief struct {
 	ID       primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
@@ -48,3 +87,13 @@ type AgentBrief struct {
 	RelatedSalesID   string             `json:"relatedSalesId,omitempty"`
 	RelatedSalesName string             `json:"relatedSalesName,omitempty"`
 }
+
+// PublicPoolClaimCounter 用户当天的公海认领计数，认领时以条件递增校验每日额度，
+// 同一用户的并发认领在该文档上产生写冲突，保证额度校验串行执行
+type PublicPoolClaimCounter struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserID    string             `bson:"userId" json:"userId"`
+	Day       time.Time          `bson:"day" json:"day"` // 当天零点
+	Claimed   int64              `bson:"claimed" json:"claimed"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PublicPoolRecycleRule 一个重要程度的公海回收规则，天数为 0 表示不按该条件判断
+// 配置了多个条件时需全部超期才回收
+type PublicPoolRecycleRule struct {
+	Importance            string `bson:"importance" json:"importance"`                       // 客户重要程度，为空表示适用于其它未单独配置的重要程度
+	NoFollowUpDays        int    `bson:"noFollowUpDays" json:"noFollowUpDays"`               // 连续多少天没有跟进记录（含项目跟进）
+	NoProjectProgressDays int    `bson:"noProjectProgressDays" json:"noProjectProgressDays"` // 连续多少天项目没有进展
+}
+
+// PublicPoolRecycleConfig 公海回收配置，存放在 configType 为 public_pool_recycle 的配置项的 configValue 中
+type PublicPoolRecycleConfig struct {
+	Rules       []PublicPoolRecycleRule `bson:"rules" json:"rules"`
+	WarningDays int                     `bson:"warningDays" json:"warningDays"` // 回收前多少天提醒归属人，0 表示不提醒
+}
+
+// 公海回收任务执行状态
+const (
+	RecycleRunStatusRunning = "running" // 执行中
+	RecycleRunStatusSuccess = "success" // 全部处理成功
+	RecycleRunStatusPartial = "partial" // 部分客户回收失败
+	RecycleRunStatusFailed  = "failed"  // 任务中途出错
+	RecycleRunStatusSkipped = "skipped" // 未配置回收规则
+)
+
+// 回收报告客户明细的类型
+const (
+	RecycleItemKindRecycled = "recycled" // 已回收至公海
+	RecycleItemKindWarned   = "warned"   // 已提醒归属人
+	RecycleItemKindFailed   = "failed"   // 回收失败
+)
+
+// PublicPoolRecycleItem 回收报告中的一个客户，单独存放并按报告ID关联，避免报告文档超过大小限制
+type PublicPoolRecycleItem struct {
+	ID                    primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	RunID                 primitive.ObjectID `bson:"runId" json:"runId"`
+	Kind                  string             `bson:"kind" json:"kind"`
+	CustomerID            string             `bson:"customerId" json:"customerId"`
+	CustomerName          string             `bson:"customerName" json:"customerName"`
+	Importance            string             `bson:"importance" json:"importance"`
+	RelatedSalesID        string             `bson:"relatedSalesId,omitempty" json:"relatedSalesId,omitempty"`
+	RelatedSalesName      string             `bson:"relatedSalesName,omitempty" json:"relatedSalesName,omitempty"`
+	RelatedAgentID        string             `bson:"relatedAgentId,omitempty" json:"relatedAgentId,omitempty"`
+	RelatedAgentName      string             `bson:"relatedAgentName,omitempty" json:"relatedAgentName,omitempty"`
+	LastFollowUpAt        time.Time          `bson:"lastFollowUpAt" json:"lastFollowUpAt"`
+	LastProjectProgressAt time.Time          `bson:"lastProjectProgressAt" json:"lastProjectProgressAt"`
+	DueAt                 time.Time          `bson:"dueAt" json:"dueAt"` // 满足回收条件的时间
+	Reason                string             `bson:"reason" json:"reason"`
+	Error                 string             `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// PublicPoolRecycleRun 一次公海回收任务的执行报告，客户明细见 PublicPoolRecycleItem
+type PublicPoolRecycleRun struct {
+	ID            primitive.ObjectID      `bson:"_id,omitempty" json:"_id,omitempty"`
+	StartedAt     time.Time               `bson:"startedAt" json:"startedAt"`
+	FinishedAt    time.Time               `bson:"finishedAt" json:"finishedAt"`
+	Status        string                  `bson:"status" json:"status"`
+	Config        PublicPoolRecycleConfig `bson:"config" json:"config"` // 执行时使用的规则快照
+	Scanned       int                     `bson:"scanned" json:"scanned"`
+	RecycledCount int                     `bson:"recycledCount" json:"recycledCount"`
+	WarnedCount   int                     `bson:"warnedCount" json:"warnedCount"`
+	FailedCount   int                     `bson:"failedCount" json:"failedCount"`
+	Error         string                  `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// PublicPoolRecycleRunSummary 回收报告列表项，不含客户明细
+type PublicPoolRecycleRunSummary struct {
+	ID            primitive.ObjectID `json:"_id"`
+	StartedAt     time.Time          `json:"startedAt"`
+	FinishedAt    time.Time          `json:"finishedAt"`
+	Status        string             `json:"status"`
+	Scanned       int                `json:"scanned"`
+	RecycledCount int                `json:"recycledCount"`
+	WarnedCount   int                `json:"warnedCount"`
+	FailedCount   int                `json:"failedCount"`
+	Error         string             `json:"error,omitempty"`
+}
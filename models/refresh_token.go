@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken 刷新令牌 (MongoDB文档结构)，仅保存令牌的哈希值
+// 每次刷新都会签发新令牌并将旧令牌标记为已使用，同一次登录产生的令牌属于同一家族，
+// 已使用的令牌被再次提交时视为泄露，整个家族会被吊销
+type RefreshToken struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TokenHash    string             `bson:"tokenHash" json:"-"`
+	FamilyID     string             `bson:"familyId" json:"familyId"`
+	AccountID    string             `bson:"accountId" json:"accountId"`
+	Role         UserRole           `bson:"role" json:"role"`                 // 角色为 AGENT 时对应代理商账户
+	TokenVersion int64              `bson:"tokenVersion" json:"tokenVersion"` // 签发时账户的令牌版本
+	ClientIP     string             `bson:"clientIp,omitempty" json:"clientIp,omitempty"`
+	UserAgent    string             `bson:"userAgent,omitempty" json:"userAgent,omitempty"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt    time.Time          `bson:"expiresAt" json:"expiresAt"` // TTL 索引字段，过期后自动删除
+	UsedAt       *time.Time         `bson:"usedAt,omitempty" json:"usedAt,omitempty"`
+	RevokedAt    *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
+
+// TokenPair 登录/刷新后返回的令牌
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"` // 访问令牌有效期（秒）
+}
+
+// RefreshTokenRequest 刷新令牌请求
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// LogoutRequest 退出登录请求
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+	AllDevices   bool   `json:"allDevices"` // 为 true 时退出该账户的所有登录
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PermissionWildcard 通配符，表示全部资源或全部操作
+const PermissionWildcard = "*"
+
+// Permission 角色在某一资源上被授予的操作
+type Permission struct {
+	Resource string   `bson:"resource" json:"resource"`
+	Actions  []string `bson:"actions" json:"actions"`
+}
+
+// Role 角色模型 (MongoDB文档结构)
+type Role struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name        UserRole           `bson:"name" json:"name"`               // 角色标识，与用户的 role 字段对应
+	DisplayName string             `bson:"displayName" json:"displayName"` // 角色显示名称
+	Description string             `bson:"description" json:"description"`
+	Permissions []Permission       `bson:"permissions" json:"permissions"`
+	IsSystem    bool               `bson:"isSystem" json:"isSystem"` // 内置角色不可删除、不可改名
+
+	CreatorID   string    `bson:"creatorId,omitempty" json:"creatorId,omitempty"`
+	CreatorName string    `bson:"creatorName,omitempty" json:"creatorName,omitempty"`
+	CreatedAt   time.Time `bson:"createdAt" json:"createdAt"`
+	UpdaterID   string    `bson:"updaterId,omitempty" json:"updaterId,omitempty"`
+	UpdaterName string    `bson:"updaterName,omitempty" json:"updaterName,omitempty"`
+	UpdatedAt   time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Allows 判断角色是否被授予指定资源上的操作
+func (r *Role) Allows(resource string, action string) bool {
+	for _, p := range r.Permissions {
+		if p.Resource != PermissionWildcard && p.Resource != resource {
+			continue
+		}
+		for _, a := range p.Actions {
+			if a == PermissionWildcard || a == action {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CreateRoleRequest 创建角色请求
+type CreateRoleRequest struct {
+	Name        UserRole     `json:"name" binding:"required"`
+	DisplayName string       `json:"displayName" binding:"required"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// UpdateRoleRequest 更新角色请求
+type UpdateRoleRequest struct {
+	DisplayName string       `json:"displayName,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Permissions []Permission `json:"permissions,omitempty"`
+}
+
+// PermissionCatalog 可授权的资源及其操作清单，新增受控路由时需同步维护
+var PermissionCatalog = map[string][]string{
+	"customers":     {"read", "create", "update", "delete", "assign", "import", "merge", "export", "trash"},
+	"followUps":     {"read", "create", "delete"},
+	"publicPool":    {"read", "claim", "recycle", "restore"},
+	"agents":        {"read", "create", "update", "delete", "export"},
+	"users":         {"read", "lookup", "create", "update", "delete"},
+	"products":      {"read", "create", "update", "delete", "import", "export"},
+	"inventory":     {"read", "create"},
+	"projects":      {"read", "create", "update", "delete"},
+	"files":         {"read", "create", "delete"},
+	"dashboard":     {"read"},
+	"systemConfigs": {"read", "create", "update", "delete"},
+	"roles":         {"read", "create", "update", "delete"},
+	"system":        {"read"},
+	"scheduler":     {"read", "run"},
+	"notifications": {"read", "update"},
+}
+
+// DefaultRoles 内置角色及其默认权限，系统初始化时写入数据库
+func DefaultRoles() []Role {
+	customerWork := []Permission{
+		{Resource: "customers", Actions: []string{"read", "create", "update", "delete", "assign", "import", "export"}},
+		{Resource: "followUps", Actions: []string{"read", "create", "delete"}},
+		{Resource: "publicPool", Actions: []string{"read", "claim"}},
+		{Resource: "users", Actions: []string{"lookup"}},
+		{Resource: "products", Actions: []string{"read", "export"}},
+		{Resource: "projects", Actions: []string{"read", "create", "update", "delete"}},
+		{Resource: "files", Actions: []string{"read", "create", "delete"}},
+		{Resource: "dashboard", Actions: []string{"read"}},
+		{Resource: "systemConfigs", Actions: []string{"read"}},
+		{Resource: "notifications", Actions: []string{"read", "update"}},
+	}
+
+	salesPermissions := append([]Permission{
+		{Resource: "agents", Actions: []string{"read", "create", "update"}},
+	}, customerWork...)
+	agentPermissions := append([]Permission{
+		{Resource: "agents", Actions: []string{"read"}},
+	}, customerWork...)
+
+	return []Role{
+		{
+			Name:        UserRoleSUPER_ADMIN,
+			DisplayName: "超级管理员",
+			Description: "拥有系统全部权限",
+			Permissions: []Permission{{Resource: PermissionWildcard, Actions: []string{PermissionWildcard}}},
+			IsSystem:    true,
+		},
+		{
+			Name:        UserRoleFACTORY_SALES,
+			DisplayName: "原厂销售",
+			Description: "管理自己的客户、代理商和项目",
+			Permissions: salesPermissions,
+			IsSystem:    true,
+		},
+		{
+			Name:        UserRoleAGENT,
+			DisplayName: "代理商",
+			Description: "管理自己关联的客户和项目",
+			Permissions: agentPermissions,
+			IsSystem:    true,
+		},
+		{
+			Name:        UserRoleINVENTORY_MANAGER,
+			DisplayName: "库存管理员",
+			Description: "管理产品和库存",
+			Permissions: []Permission{
+				{Resource: "products", Actions: []string{"read", "create", "update", "import", "export"}},
+				{Resource: "inventory", Actions: []string{"read", "create"}},
+				{Resource: "dashboard", Actions: []string{"read"}},
+				{Resource: "notifications", Actions: []string{"read", "update"}},
+			},
+			IsSystem: true,
+		},
+	}
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 定时任务的触发方式
+const (
+	JobTriggerSchedule = "schedule" // 按 cron 表达式到点执行
+	JobTriggerCatchUp  = "catch_up" // 启动时补执行停机期间错过的一次
+	JobTriggerManual   = "manual"   // 管理员手动执行
+)
+
+// 定时任务执行状态
+const (
+	JobRunStatusRunning     = "running"
+	JobRunStatusSuccess     = "success"
+	JobRunStatusFailed      = "failed"
+	JobRunStatusInterrupted = "interrupted" // 执行实例中途退出，锁过期后由下一次执行标记
+)
+
+// ScheduledJobState 定时任务在数据库中的状态，同时作为多实例部署时的分布式锁
+// 锁在 LockedUntil 前有效，执行期间持有锁的实例定期续期
+type ScheduledJobState struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	Name        string             `bson:"name" json:"name"`
+	LockedBy    string             `bson:"lockedBy" json:"lockedBy,omitempty"`
+	LockedUntil time.Time          `bson:"lockedUntil" json:"lockedUntil"`
+	// LastScheduledAt 最近一次已被某个实例领取的计划执行时间，同一时间点只执行一次，也用于判断停机期间错过的执行
+	LastScheduledAt time.Time          `bson:"lastScheduledAt" json:"lastScheduledAt"`
+	LastRunID       primitive.ObjectID `bson:"lastRunId,omitempty" json:"lastRunId,omitempty"`
+	LastRunAt       time.Time          `bson:"lastRunAt" json:"lastRunAt"`
+	LastStatus      string             `bson:"lastStatus" json:"lastStatus,omitempty"`
+	CreatedAt       time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt       time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// JobRun 定时任务的一次执行记录
+type JobRun struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	JobName         string             `bson:"jobName" json:"jobName"`
+	Trigger         string             `bson:"trigger" json:"trigger"`
+	ScheduledAt     *time.Time         `bson:"scheduledAt,omitempty" json:"scheduledAt,omitempty"` // 手动执行时为空
+	TriggeredByID   string             `bson:"triggeredById,omitempty" json:"triggeredById,omitempty"`
+	TriggeredByName string             `bson:"triggeredByName,omitempty" json:"triggeredByName,omitempty"`
+	Instance        string             `bson:"instance" json:"instance"`
+	Status          string             `bson:"status" json:"status"`
+	StartedAt       time.Time          `bson:"startedAt" json:"startedAt"`
+	FinishedAt      *time.Time         `bson:"finishedAt,omitempty" json:"finishedAt,omitempty"`
+	DurationMs      int64              `bson:"durationMs" json:"durationMs"`
+	Counts          map[string]int64   `bson:"counts,omitempty" json:"counts,omitempty"` // 任务自定义的处理数量，如检查、转移、失败的客户数
+	Error           string             `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// ScheduledJobInfo 定时任务列表项
+type ScheduledJobInfo struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Schedule    string     `json:"schedule"`
+	Enabled     bool       `json:"enabled"` // 定时执行是否启用，停用时仍可手动执行
+	NextRunAt   *time.Time `json:"nextRunAt,omitempty"`
+	Running     bool       `json:"running"`
+	RunningOn   string     `json:"runningOn,omitempty"`
+	LastRunAt   *time.Time `json:"lastRunAt,omitempty"`
+	LastStatus  string     `json:"lastStatus,omitempty"`
+	LastRunID   string     `json:"lastRunId,omitempty"`
+}
@@ -10,8 +10,12 @@ import (
 type ConfigType string
 
 const (
-	// ConfigTypeCustomerAutoTransfer 客户自动转移配置
+	// ConfigTypeCustomerAutoTransfer 客户自动转移规则，每个启用的配置项为一条规则
 	ConfigTypeCustomerAutoTransfer ConfigType = "customer_auto_transfer"
+	// ConfigTypePublicPoolClaim 公海认领额度和冷却期配置
+	ConfigTypePublicPoolClaim ConfigType = "public_pool_claim"
+	// ConfigTypePublicPoolRecycle 长期未跟进客户自动回收公海的规则
+	ConfigTypePublicPoolRecycle ConfigType = "public_pool_recycle"
 )
 
 type ConfigItem struct {
@@ -19,10 +23,16 @@ type ConfigItem struct {
 	Value interface{} `bson:"Value" json:"Value"`
 }
 
-type AutoTransferConfig struct {
-	TargetSalesID       string `json:"targetSalesId"`
-	TargetSalesName     string `json:"targetSalesName"`
-	DaysWithoutProgress int    `json:"daysWithoutProgress"`
+// PublicPoolClaimQuota 单个角色的公海认领额度，0 表示不限
+type PublicPoolClaimQuota struct {
+	DailyLimit int `bson:"dailyLimit" json:"dailyLimit"` // 每天最多认领的客户数
+	TotalLimit int `bson:"totalLimit" json:"totalLimit"` // 认领后名下（关联或创建）非公海客户总数上限
+}
+
+// PublicPoolClaimConfig 公海认领配置，存放在 configType 为 public_pool_claim 的配置项的 configValue 中
+type PublicPoolClaimConfig struct {
+	Quotas       map[string]PublicPoolClaimQuota `bson:"quotas" json:"quotas"`             // 角色 -> 额度
+	CooldownDays int                             `bson:"cooldownDays" json:"cooldownDays"` // 移入公海后原归属人不能重新认领的天数
 }
 
 // SystemConfig 系统配置模型 (MongoDB文档结构)
@@ -34,6 +44,9 @@ type SystemConfig struct {
 	Description string             `bson:"description" json:"description"`
 	IsEnabled   bool               `bson:"isEnabled" json:"isEnabled"`
 
+	// RoundRobinCursor 自动转移规则在目标销售池中下一次分配的位置，由定时任务维护
+	RoundRobinCursor int `bson:"roundRobinCursor,omitempty" json:"roundRobinCursor,omitempty"`
+
 	// 创建信息
 	CreatorID   string    `bson:"creatorId" json:"creatorId"`
 	CreatorName string    `bson:"creatorName" json:"creatorName"`
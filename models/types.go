@@ -38,6 +38,7 @@ type User struct {
 	RejectionReason  string             `bson:"rejectionReason,omitempty" json:"rejectionReason,omitempty"`
 	RelatedSalesID   string             `bson:"relatedSalesId,omitempty" json:"relatedSalesId,omitempty"`
 	RelatedSalesName string             `bson:"relatedSalesName,omitempty" json:"relatedSalesName,omitempty"`
+	TokenVersion     int64              `bson:"tokenVersion" json:"-"` // 令牌版本，递增后已签发的令牌全部失效
 }
 
 // Agent 代理商类型
@@ -52,6 +53,7 @@ type Agent struct {
 	Status           UserStatus         `bson:"status" json:"status"`
 	CreatedAt        time.Time          `bson:"createdAt" json:"createdAt"`
 	UpdatedAt        time.Time          `bson:"updatedAt" json:"updatedAt"`
+	TokenVersion     int64              `bson:"tokenVersion" json:"-"` // 令牌版本，递增后已签发的令牌全部失效
 }
 
 // Customer 客户模型
@@ -60,20 +62,20 @@ type Customer struct {
 	Name               string             `json:"name" bson:"name"`
 	Nature             string             `json:"nature" bson:"nature"`
 	Importance         string             `json:"importance" bson:"importance"`
-	ApplicationField   string             `json:"applicationField" bson:"applicationfield"`
-	ProductNeeds       []string           `json:"productNeeds" bson:"productneeds"`
-	ContactPerson      string             `json:"contactPerson" bson:"contactperson"`
-	ContactPhone       string             `json:"contactPhone" bson:"contactphone"`
+	ApplicationField   string             `json:"applicationField" bson:"applicationField"`
+	ProductNeeds       []string           `json:"productNeeds" bson:"productNeeds"`
+	ContactPerson      string             `json:"contactPerson" bson:"contactPerson"`
+	ContactPhone       string             `json:"contactPhone" bson:"contactPhone"`
 	Address            string             `json:"address" bson:"address"`
 	Progress           string             `json:"progress" bson:"progress"`
 	InitialContactTime time.Time          `json:"initialContactTime" bson:"initialContactTime"`
-	AnnualDemand       float64            `json:"annualDemand" bson:"annualdemand"`
+	AnnualDemand       float64            `json:"annualDemand" bson:"annualDemand"`
 
 	// 创建人信息
-	OwnerID          string `json:"ownerId" bson:"ownerid"`
-	OwnerName        string `json:"ownerName" bson:"ownername"`
-	OwnerType        string `json:"ownerType" bson:"ownertype"`
-	OwnerTypeDisplay string `json:"ownerTypeDisplay" bson:"ownertypedisplay,omitempty"`
+	OwnerID          string `json:"ownerId" bson:"ownerId"`
+	OwnerName        string `json:"ownerName" bson:"ownerName"`
+	OwnerType        string `json:"ownerType" bson:"ownerType"`
+	OwnerTypeDisplay string `json:"ownerTypeDisplay" bson:"ownerTypeDisplay,omitempty"`
 
 	// 关联销售信息
 	RelatedSalesID   string `json:"relatedSalesId" bson:"relatedSalesId"`
@@ -84,7 +86,7 @@ type Customer struct {
 	RelatedAgentName string `json:"relatedAgentName" bson:"relatedAgentName"`
 
 	IsInPublicPool bool      `json:"isInPublicPool" bson:"isInPublicPool"`
-	LastUpdateTime time.Time `json:"lastUpdateTime" bson:"lastupdatetime"`
+	LastUpdateTime time.Time `json:"lastUpdateTime" bson:"lastUpdateTime"`
 	CreatedAt      time.Time `json:"createdAt" bson:"createdAt"`
 	UpdatedAt      time.Time `json:"updatedAt" bson:"updatedAt"`
 
@@ -92,6 +94,13 @@ type Customer struct {
 	PreviousOwnerID   string `json:"previousOwnerId,omitempty" bson:"previousOwnerId,omitempty"`
 	PreviousOwnerName string `json:"previousOwnerName,omitempty" bson:"previousOwnerName,omitempty"`
 	PreviousOwnerType string `json:"previousOwnerType,omitempty" bson:"previousOwnerType,omitempty"`
+	// PoolSnapshot 移入公海前的归属和联系人，只在公海接口中向超级管理员返回
+	PoolSnapshot *CustomerPoolSnapshot `json:"-" bson:"poolSnapshot,omitempty"`
+
+	// 软删除信息，DeletedAt 非空表示客户在回收站中
+	DeletedAt     *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+	DeletedByID   string     `json:"deletedById,omitempty" bson:"deletedById,omitempty"`
+	DeletedByName string     `json:"deletedByName,omitempty" bson:"deletedByName,omitempty"`
 }
 
 // CustomerCreateRequest 创建客户请求
@@ -108,6 +117,7 @@ type CustomerCreateRequest struct {
 	AnnualDemand     float64  `json:"annualDemand"`
 	RelatedSalesID   string   `json:"relatedSalesId"`
 	RelatedAgentID   string   `json:"relatedAgentId,omitempty"`
+	IgnoreDuplicates bool     `json:"ignoreDuplicates"` // 确认疑似重复后仍然创建
 }
 
 // 各种请求和响应结构
@@ -179,3 +189,20 @@ type FileInfo struct {
 	URL          string             `json:"url" bson:"url"`                   // 文件URL或存储路径
 	CreatedAt    time.Time          `json:"createdAt" bson:"createdAt"`       // 创建时间
 }
+
+// PasswordHashReport 密码存储格式迁移报告
+type PasswordHashReport struct {
+	Users          map[string]int64        `json:"users"`  // 各存储格式的用户数
+	Agents         map[string]int64        `json:"agents"` // 各存储格式的代理商数
+	LegacyTotal    int64                   `json:"legacyTotal"`
+	LegacyAccounts []LegacyPasswordAccount `json:"legacyAccounts"` // 仍使用旧格式或无法识别格式的账户
+	GeneratedAt    time.Time               `json:"generatedAt"`
+}
+
+// LegacyPasswordAccount 仍使用旧格式密码的账户
+type LegacyPasswordAccount struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	AccountType string `json:"accountType"` // user / agent
+	Scheme      string `json:"scheme"`
+}
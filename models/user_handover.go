@@ -0,0 +1,67 @@
+package models
+
+// 离职交接分配策略
+const (
+	HandoverStrategyRoundRobin = "roundRobin" // 按客户轮流分配给接收销售
+	HandoverStrategyRule       = "rule"       // 先按规则匹配，未匹配的客户再轮流分配
+)
+
+// HandoverOperationType 离职交接在分配历史中的操作类型
+const HandoverOperationType = "离职交接"
+
+// UserHandoverRule 按客户属性指定接收销售，条件为空表示不限；多条规则按顺序匹配第一条
+type UserHandoverRule struct {
+	Importance    string `json:"importance"`
+	Nature        string `json:"nature"`
+	TargetSalesID string `json:"targetSalesId" binding:"required"`
+}
+
+// UserHandoverRequest 离职交接请求
+type UserHandoverRequest struct {
+	TargetSalesIDs []string           `json:"targetSalesIds" binding:"required"` // 接收交接的销售
+	Strategy       string             `json:"strategy"`                          // roundRobin（默认）或 rule
+	Rules          []UserHandoverRule `json:"rules"`
+	DryRun         bool               `json:"dryRun"` // 只预览分配结果，不做修改
+}
+
+// UserHandoverAssignment 单条交接分配结果
+type UserHandoverAssignment struct {
+	Type        string `json:"type"` // customer / agent / project
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	ToSalesID   string `json:"toSalesId"`
+	ToSalesName string `json:"toSalesName"`
+	Error       string `json:"error,omitempty"`
+}
+
+// UserHandoverTargetSummary 每个接收销售分到的数量
+type UserHandoverTargetSummary struct {
+	SalesID   string `json:"salesId"`
+	SalesName string `json:"salesName"`
+	Customers int    `json:"customers"`
+	Agents    int    `json:"agents"`
+	Projects  int    `json:"projects"`
+}
+
+// UserHandoverResult 离职交接结果
+type UserHandoverResult struct {
+	DryRun      bool                        `json:"dryRun"`
+	UserID      string                      `json:"userId"`
+	Username    string                      `json:"username"`
+	Completed   bool                        `json:"completed"` // 全部交接成功，可以删除用户
+	Failed      int                         `json:"failed"`
+	Targets     []UserHandoverTargetSummary `json:"targets"`
+	Assignments []UserHandoverAssignment    `json:"assignments"`
+}
+
+// UserHandoverPending 用户名下尚未交接的数据
+type UserHandoverPending struct {
+	Customers int64 `json:"customers"`
+	Agents    int64 `json:"agents"`
+	Projects  int64 `json:"projects"`
+}
+
+// Empty 是否已全部交接
+func (p UserHandoverPending) Empty() bool {
+	return p.Customers == 0 && p.Agents == 0 && p.Projects == 0
+}
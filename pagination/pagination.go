@@ -0,0 +1,242 @@
+package pagination
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/policy"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 列表接口统一的分页和排序参数：
+//   page/limit    页码分页，兼容现有前端
+//   cursor        上一页返回的 nextCursor，传入后按游标（keyset）翻页，忽略 page
+//   sortBy        排序字段，只能使用接口声明的字段
+//   sortOrder     asc 或 desc
+// 排序总是追加 _id 作为第二排序键，保证顺序稳定，游标记录最后一条记录的排序值和 _id
+
+const (
+	// DefaultLimit 未指定每页数量时的默认值
+	DefaultLimit int64 = 10
+	// MaxLimit 每页数量上限
+	MaxLimit int64 = 200
+)
+
+// Spec 列表接口的排序配置
+type Spec struct {
+	// SortFields 允许排序的字段：查询参数中的名称 -> 文档字段
+	SortFields map[string]string
+	// DefaultSort 默认排序字段（SortFields 中的名称）
+	DefaultSort string
+	// DefaultOrder 默认排序方向，1 升序，-1 降序
+	DefaultOrder int
+	// DefaultLimit 默认每页数量，为 0 时使用 DefaultLimit
+	DefaultLimit int64
+}
+
+// Request 解析后的分页请求
+type Request struct {
+	Page   int64
+	Limit  int64
+	SortBy string
+	Order  int
+
+	field  string
+	cursor *cursor
+}
+
+// cursor 游标内容，以 BSON 编码后做 base64，对调用方不透明
+type cursor struct {
+	SortBy string      `bson:"s"`
+	Order  int         `bson:"o"`
+	Value  interface{} `bson:"v"`
+	ID     interface{} `bson:"id"`
+}
+
+// FromQuery 从查询参数解析分页和排序，参数不合法时返回 400 错误
+func FromQuery(query url.Values, spec Spec) (*Request, error) {
+	req := &Request{Page: 1, Limit: spec.DefaultLimit, SortBy: spec.DefaultSort, Order: spec.DefaultOrder}
+	if req.Limit <= 0 {
+		req.Limit = DefaultLimit
+	}
+	if req.Order == 0 {
+		req.Order = -1
+	}
+
+	if raw := query.Get("page"); raw != "" {
+		page, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || page < 1 {
+			return nil, utils.CreateBadRequestError("页码必须是正整数")
+		}
+		req.Page = page
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || limit < 1 {
+			return nil, utils.CreateBadRequestError("每页数量必须是正整数")
+		}
+		req.Limit = min(limit, MaxLimit)
+	}
+	if sortBy := query.Get("sortBy"); sortBy != "" {
+		if _, ok := spec.SortFields[sortBy]; !ok {
+			return nil, utils.CreateBadRequestError(fmt.Sprintf("不支持按 %s 排序，可选字段: %s", sortBy, strings.Join(sortNames(spec), ", ")))
+		}
+		req.SortBy = sortBy
+	}
+	switch strings.ToLower(query.Get("sortOrder")) {
+	case "":
+	case "asc":
+		req.Order = 1
+	case "desc":
+		req.Order = -1
+	default:
+		return nil, utils.CreateBadRequestError("排序方向只能是 asc 或 desc")
+	}
+	req.field = spec.SortFields[req.SortBy]
+
+	if token := query.Get("cursor"); token != "" {
+		cur, err := decodeCursor(token)
+		if err != nil {
+			return nil, utils.CreateBadRequestError("无效的分页游标")
+		}
+		if cur.SortBy != req.SortBy || cur.Order != req.Order {
+			return nil, utils.CreateBadRequestError("分页游标与排序条件不一致，请从第一页重新查询")
+		}
+		req.cursor = cur
+		req.Page = 0
+	}
+	return req, nil
+}
+
+func sortNames(spec Spec) []string {
+	names := make([]string, 0, len(spec.SortFields))
+	for name := range spec.SortFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Result 一页查询结果
+type Result[T any] struct {
+	Items      []T
+	Pagination models.Pagination
+}
+
+// Find 按分页请求查询一页记录，total 按 filter 统计；opts 可指定投影等其它查询选项，其排序和分页设置会被覆盖
+func Find[T any](ctx context.Context, repo repository.Repository[T], filter bson.M, req *Request, opts ...*repository.FindOptions) (*Result[T], error) {
+	total, err := repo.Count(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	findOptions := repository.NewFindOptions()
+	if len(opts) > 0 && opts[0] != nil {
+		findOptions.SetProjection(opts[0].Projection)
+	}
+	findOptions.SetSort(req.field, req.Order).SetSort("_id", req.Order).SetLimit(req.Limit + 1)
+	pageFilter := filter
+	if req.cursor != nil {
+		pageFilter = policy.Merge(filter, req.keysetFilter())
+	} else if req.Page > 1 {
+		findOptions.SetSkip((req.Page - 1) * req.Limit)
+	}
+
+	items, err := repo.Find(ctx, pageFilter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	pagination := models.NewPagination(total, req.Page, req.Limit)
+	pagination.HasMore = int64(len(items)) > req.Limit
+	if pagination.HasMore {
+		items = items[:req.Limit]
+		if pagination.NextCursor, err = req.nextCursor(&items[len(items)-1]); err != nil {
+			return nil, err
+		}
+	}
+	return &Result[T]{Items: items, Pagination: pagination}, nil
+}
+
+// keysetFilter 游标之后的记录：排序值在游标之后，或排序值相同且 _id 在游标之后
+// MongoDB 中 null（含字段不存在）排在所有值之前，需要单独处理
+func (r *Request) keysetFilter() bson.M {
+	after, rangeOp := "$gt", "$gt"
+	if r.Order < 0 {
+		after, rangeOp = "$lt", "$lt"
+	}
+	cur := r.cursor
+	sameValue := bson.M{r.field: bson.M{"$eq": cur.Value}, "_id": bson.M{after: cur.ID}}
+	if cur.Value == nil {
+		if r.Order > 0 {
+			return bson.M{"$or": []bson.M{{r.field: bson.M{"$ne": nil}}, sameValue}}
+		}
+		return sameValue
+	}
+	conditions := []bson.M{{r.field: bson.M{rangeOp: cur.Value}}, sameValue}
+	if r.Order < 0 {
+		conditions = append(conditions, bson.M{r.field: nil})
+	}
+	return bson.M{"$or": conditions}
+}
+
+// nextCursor 以一页最后一条记录生成下一页的游标
+func (r *Request) nextCursor(last interface{}) (string, error) {
+	raw, err := bson.Marshal(last)
+	if err != nil {
+		return "", fmt.Errorf("生成分页游标失败: %w", err)
+	}
+	doc := bson.Raw(raw)
+	cur := cursor{SortBy: r.SortBy, Order: r.Order, ID: doc.Lookup("_id")}
+	if value, err := doc.LookupErr(strings.Split(r.field, ".")...); err == nil {
+		cur.Value = value
+	}
+	data, err := bson.Marshal(cur)
+	if err != nil {
+		return "", fmt.Errorf("生成分页游标失败: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor 解码游标，排序值只接受标量类型，避免把客户端传入的文档拼进查询条件
+func decodeCursor(token string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	raw := bson.Raw(data)
+	if err := raw.Validate(); err != nil {
+		return nil, err
+	}
+	cur := &cursor{}
+	if err := bson.Unmarshal(data, cur); err != nil {
+		return nil, err
+	}
+	if _, ok := cur.ID.(primitive.ObjectID); !ok {
+		return nil, fmt.Errorf("游标缺少记录ID")
+	}
+	value, err := raw.LookupErr("v")
+	if err != nil {
+		return nil, err
+	}
+	switch value.Type {
+	case bsontype.Null, bsontype.Undefined:
+		cur.Value = nil
+	case bsontype.String, bsontype.Int32, bsontype.Int64, bsontype.Double, bsontype.Boolean,
+		bsontype.DateTime, bsontype.ObjectID, bsontype.Decimal128:
+	default:
+		return nil, fmt.Errorf("不支持的游标值类型: %s", value.Type)
+	}
+	return cur, nil
+}
@@ -0,0 +1,64 @@
+package policy
+
+import (
+	"errors"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrUnsupportedRole 当前用户无客户数据访问权限
+var ErrUnsupportedRole = errors.New("用户角色不支持")
+
+// OwnerFilter 生成“归属于该用户”的客户查询条件，不包含公海条件
+// 非归属人限定的角色返回空条件（可访问全部客户）
+func OwnerFilter(user *utils.LoginUser) (bson.M, error) {
+	if user == nil {
+		return nil, ErrUnsupportedRole
+	}
+	switch models.UserRole(user.Role) {
+	case models.UserRoleFACTORY_SALES:
+		return bson.M{"$or": []bson.M{
+			{"ownerId": user.ID},
+			{"relatedSalesId": user.ID},
+		}}, nil
+	case models.UserRoleAGENT:
+		return bson.M{"$or": []bson.M{
+			{"ownerId": user.ID},
+			{"relatedAgentId": user.ID},
+		}}, nil
+	}
+	return bson.M{}, nil
+}
+
+// CustomerFilter 生成与 CanAccessCustomer 等价的客户列表查询条件
+func CustomerFilter(user *utils.LoginUser, action Action) (bson.M, error) {
+	owner, err := OwnerFilter(user)
+	if err != nil {
+		return nil, err
+	}
+	if len(owner) == 0 {
+		return owner, nil
+	}
+
+	switch action {
+	case ActionRead, ActionAssign:
+		return bson.M{"$or": []bson.M{owner, {"isInPublicPool": true}}}, nil
+	case ActionUpdate, ActionDelete:
+		return bson.M{"$and": []bson.M{owner, {"isInPublicPool": false}}}, nil
+	}
+	return nil, ErrUnsupportedRole
+}
+
+// Merge 将权限条件与业务查询条件合并，避免 $or 等顶层操作符相互覆盖
+func Merge(filter bson.M, scope bson.M) bson.M {
+	if len(scope) == 0 {
+		return filter
+	}
+	if len(filter) == 0 {
+		return scope
+	}
+	return bson.M{"$and": []bson.M{filter, scope}}
+}
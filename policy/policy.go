@@ -0,0 +1,73 @@
+package policy
+
+import (
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/utils"
+)
+
+// Action 数据行级操作类型
+type Action string
+
+const (
+	ActionRead   Action = "read"   // 查看
+	ActionUpdate Action = "update" // 修改（含移入公海、创建/修改项目）
+	ActionDelete Action = "delete" // 删除
+	ActionAssign Action = "assign" // 分配/认领
+)
+
+// IsOwnerScoped 判断角色的客户数据是否按归属人限定
+// 原厂销售和代理商只能访问自己名下的客户；其他角色（超级管理员及自定义角色）
+// 能否访问客户数据由路由上的角色权限决定，通过后不再做行级限制
+func IsOwnerScoped(role string) bool {
+	switch models.UserRole(role) {
+	case models.UserRoleFACTORY_SALES, models.UserRoleAGENT:
+		return true
+	}
+	return false
+}
+
+// IsCustomerOwner 判断用户是否为客户的归属人
+// 原厂销售：创建人或关联销售；代理商：创建人或关联代理商
+func IsCustomerOwner(user *utils.LoginUser, customer *models.Customer) bool {
+	if user == nil || customer == nil || user.ID == "" {
+		return false
+	}
+	switch models.UserRole(user.Role) {
+	case models.UserRoleFACTORY_SALES:
+		return customer.OwnerID == user.ID || customer.RelatedSalesID == user.ID
+	case models.UserRoleAGENT:
+		return customer.OwnerID == user.ID || customer.RelatedAgentID == user.ID
+	}
+	return false
+}
+
+// CanAccessCustomer 判断用户能否对客户执行指定操作
+//   - 非归属人限定的角色：全部允许
+//   - 查看/分配：归属人，或客户位于公海池
+//   - 修改/删除：归属人，且客户不在公海池
+func CanAccessCustomer(user *utils.LoginUser, customer *models.Customer, action Action) bool {
+	if user == nil || customer == nil {
+		return false
+	}
+	if !IsOwnerScoped(user.Role) {
+		return true
+	}
+
+	owner := IsCustomerOwner(user, customer)
+	switch action {
+	case ActionRead, ActionAssign:
+		return owner || customer.IsInPublicPool
+	case ActionUpdate, ActionDelete:
+		return owner && !customer.IsInPublicPool
+	}
+	return false
+}
+
+// CanAccessProject 判断用户能否对项目执行指定操作，项目权限继承自所属客户
+// 查看项目需要客户的查看权限，创建/修改/删除项目需要客户的修改权限
+func CanAccessProject(user *utils.LoginUser, customer *models.Customer, action Action) bool {
+	if action == ActionRead {
+		return CanAccessCustomer(user, customer, ActionRead)
+	}
+	return CanAccessCustomer(user, customer, ActionUpdate)
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrClaimCounterConflict 并发创建同一用户当天的认领计数，事务已中止，需要重新发起认领
+var ErrClaimCounterConflict = errors.New("认领请求冲突，请稍后重试")
+
+// ClaimCounterRepository 公海认领计数仓储，按用户和日期各一条记录
+type ClaimCounterRepository interface {
+	Repository[models.PublicPoolClaimCounter]
+	// Increment 用户当天的认领数小于 limit 时加一并返回 true，已达上限时返回 false；limit 为 0 表示不限
+	// 与其他请求同时创建当天的记录时返回 ErrClaimCounterConflict
+	Increment(ctx context.Context, userID string, day time.Time, limit int) (bool, error)
+	// ClaimedOn 用户当天的认领数，没有记录时为 0
+	ClaimedOn(ctx context.Context, userID string, day time.Time) (int64, error)
+}
+
+type claimCounterRepository struct {
+	Repository[models.PublicPoolClaimCounter]
+}
+
+func (r *claimCounterRepository) Increment(ctx context.Context, userID string, day time.Time, limit int) (bool, error) {
+	filter := bson.M{"userId": userID, "day": day}
+	if limit > 0 {
+		filter["claimed"] = bson.M{"$lt": limit}
+	}
+	result, err := r.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"claimed": 1}, "$set": bson.M{"updatedAt": time.Now()}})
+	if err != nil {
+		return false, err
+	}
+	if result.MatchedCount > 0 {
+		return true, nil
+	}
+	// 当天已有记录说明已达上限，否则创建当天的记录
+	if _, err := r.FindOne(ctx, bson.M{"userId": userID, "day": day}); err != ErrNotFound {
+		return false, err
+	}
+	// 唯一索引冲突时所在事务已中止，不能在同一事务中重试
+	_, err = r.Insert(ctx, &models.PublicPoolClaimCounter{UserID: userID, Day: day, Claimed: 1, UpdatedAt: time.Now()})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, ErrClaimCounterConflict
+	}
+	return err == nil, err
+}
+
+func (r *claimCounterRepository) ClaimedOn(ctx context.Context, userID string, day time.Time) (int64, error) {
+	counter, err := r.FindOne(ctx, bson.M{"userId": userID, "day": day})
+	if err == ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return counter.Claimed, nil
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/BerniceZTT/crm_end/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CustomerRepository 客户仓储
+type CustomerRepository interface {
+	SoftDeleteRepository[models.Customer]
+	// FindByIDs 批量按ID查询客户
+	FindByIDs(ctx context.Context, ids []primitive.ObjectID, opts ...*FindOptions) ([]models.Customer, error)
+	// ExistsByNameAndProgress 判断是否存在指定名称和进展状态的客户
+	ExistsByNameAndProgress(ctx context.Context, name string, progress string) (bool, error)
+	// SetFields 按ID设置客户字段
+	SetFields(ctx context.Context, id primitive.ObjectID, fields bson.M) (*UpdateResult, error)
+}
+
+type customerRepository struct {
+	SoftDeleteRepository[models.Customer]
+}
+
+func (r *customerRepository) FindByIDs(ctx context.Context, ids []primitive.ObjectID, opts ...*FindOptions) ([]models.Customer, error) {
+	if len(ids) == 0 {
+		return []models.Customer{}, nil
+	}
+	return r.Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, opts...)
+}
+
+func (r *customerRepository) ExistsByNameAndProgress(ctx context.Context, name string, progress string) (bool, error) {
+	count, err := r.Count(ctx, bson.M{"name": name, "progress": progress})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *customerRepository) SetFields(ctx context.Context, id primitive.ObjectID, fields bson.M) (*UpdateResult, error) {
+	return r.UpdateByID(ctx, id, bson.M{"$set": fields})
+}
+
+// AssignmentHistoryRepository 客户分配历史仓储
+type AssignmentHistoryRepository interface {
+	SoftDeleteRepository[models.CustomerAssignmentHistory]
+	// FindByCustomerID 按客户ID查询分配历史，按创建时间倒序
+	FindByCustomerID(ctx context.Context, customerID string) ([]models.CustomerAssignmentHistory, error)
+}
+
+type assignmentHistoryRepository struct {
+	SoftDeleteRepository[models.CustomerAssignmentHistory]
+}
+
+func (r *assignmentHistoryRepository) FindByCustomerID(ctx context.Context, customerID string) ([]models.CustomerAssignmentHistory, error) {
+	return r.Find(ctx, bson.M{"customerId": customerID}, NewFindOptions().SetSort("createdAt", -1))
+}
+
+// CustomerProgressRepository 客户进展历史仓储
+type CustomerProgressRepository interface {
+	Repository[models.CustomerProgressHistory]
+	// FindByCustomerID 按客户ID查询进展历史，按创建时间倒序
+	FindByCustomerID(ctx context.Context, customerID string) ([]models.CustomerProgressHistory, error)
+}
+
+type customerProgressRepository struct {
+	Repository[models.CustomerProgressHistory]
+}
+
+func (r *customerProgressRepository) FindByCustomerID(ctx context.Context, customerID string) ([]models.CustomerProgressHistory, error) {
+	return r.Find(ctx, bson.M{"customerId": customerID}, NewFindOptions().SetSort("createdAt", -1))
+}
+
+// CustomerMergeRepository 客户合并记录仓储
+type CustomerMergeRepository interface {
+	Repository[models.CustomerMergeRecord]
+}
+
+type customerMergeRepository struct {
+	Repository[models.CustomerMergeRecord]
+}
+
+// CustomerImportRepository 客户导入批次仓储
+type CustomerImportRepository interface {
+	Repository[models.CustomerImport]
+}
+
+type customerImportRepository struct {
+	Repository[models.CustomerImport]
+}
+
+// FollowUpRepository 客户跟进记录仓储
+type FollowUpRepository interface {
+	SoftDeleteRepository[models.FollowUpRecord]
+	// FindByCustomerID 按客户ID查询跟进记录，按创建时间倒序
+	FindByCustomerID(ctx context.Context, customerID string) ([]models.FollowUpRecord, error)
+	// DeleteByCustomerID 删除客户的所有跟进记录
+	DeleteByCustomerID(ctx context.Context, customerID string) (int64, error)
+}
+
+type followUpRepository struct {
+	SoftDeleteRepository[models.FollowUpRecord]
+}
+
+func (r *followUpRepository) FindByCustomerID(ctx context.Context, customerID string) ([]models.FollowUpRecord, error) {
+	return r.Find(ctx, bson.M{"customerId": customerID}, NewFindOptions().SetSort("createdAt", -1))
+}
+
+func (r *followUpRepository) DeleteByCustomerID(ctx context.Context, customerID string) (int64, error) {
+	return r.DeleteMany(ctx, bson.M{"customerId": customerID})
+}
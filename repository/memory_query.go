@@ -0,0 +1,634 @@
+package repository
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 内存仓储使用的查询/更新求值逻辑，支持的操作符：
+//   查询：$and $or $nor $eq $ne $gt $gte $lt $lte $in $nin $exists $regex $options $elemMatch $size $not
+//   更新：$set $unset $inc $push $addToSet $pull（$each 修饰符）
+
+// toDocument 将任意实体转换为 bson.M，字段类型与写入MongoDB后读出的一致
+func toDocument(v interface{}) (bson.M, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	doc := bson.M{}
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// normalizeFilter 将查询条件中的Go类型（time.Time、自定义字符串类型、切片等）统一转换为bson类型
+func normalizeFilter(filter bson.M) (bson.M, error) {
+	if filter == nil {
+		return bson.M{}, nil
+	}
+	doc, err := toDocument(filter)
+	if err != nil {
+		return nil, fmt.Errorf("无效的查询条件: %w", err)
+	}
+	return doc, nil
+}
+
+// asMap 将文档类型的值转换为 bson.M
+func asMap(v interface{}) (bson.M, bool) {
+	switch m := v.(type) {
+	case bson.M:
+		return m, true
+	case map[string]interface{}:
+		return bson.M(m), true
+	case primitive.D:
+		result := bson.M{}
+		for _, e := range m {
+			result[e.Key] = e.Value
+		}
+		return result, true
+	}
+	return nil, false
+}
+
+// asArray 将数组类型的值转换为切片
+func asArray(v interface{}) ([]interface{}, bool) {
+	switch a := v.(type) {
+	case primitive.A:
+		return []interface{}(a), true
+	case []interface{}:
+		return a, true
+	}
+	return nil, false
+}
+
+// isOperatorDoc 判断值是否为操作符文档（所有键均以 $ 开头）
+func isOperatorDoc(v interface{}) (bson.M, bool) {
+	m, ok := asMap(v)
+	if !ok || len(m) == 0 {
+		return nil, false
+	}
+	for k := range m {
+		if !strings.HasPrefix(k, "$") {
+			return nil, false
+		}
+	}
+	return m, true
+}
+
+// lookupPath 按点分路径取值，路径中遇到数组时展开匹配各元素
+func lookupPath(doc interface{}, path string) ([]interface{}, bool) {
+	return walkPath(doc, strings.Split(path, "."))
+}
+
+func walkPath(value interface{}, parts []string) ([]interface{}, bool) {
+	if len(parts) == 0 {
+		return []interface{}{value}, true
+	}
+
+	if m, ok := asMap(value); ok {
+		next, exists := m[parts[0]]
+		if !exists {
+			return nil, false
+		}
+		return walkPath(next, parts[1:])
+	}
+
+	if arr, ok := asArray(value); ok {
+		if idx, err := strconv.Atoi(parts[0]); err == nil {
+			if idx < 0 || idx >= len(arr) {
+				return nil, false
+			}
+			return walkPath(arr[idx], parts[1:])
+		}
+		var results []interface{}
+		found := false
+		for _, item := range arr {
+			if values, ok := walkPath(item, parts); ok {
+				results = append(results, values...)
+				found = true
+			}
+		}
+		return results, found
+	}
+
+	return nil, false
+}
+
+// firstValue 返回第一个值，不存在时返回nil
+func firstValue(values []interface{}) interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// expandValues 将数组值展开，用于与数组元素比较
+func expandValues(values []interface{}) []interface{} {
+	result := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		result = append(result, v)
+		if arr, ok := asArray(v); ok {
+			result = append(result, arr...)
+		}
+	}
+	return result
+}
+
+// matchDocument 判断文档是否满足查询条件
+func matchDocument(doc bson.M, filter bson.M) bool {
+	for key, cond := range filter {
+		switch key {
+		case "$and":
+			subs, _ := asArray(cond)
+			for _, sub := range subs {
+				m, _ := asMap(sub)
+				if !matchDocument(doc, m) {
+					return false
+				}
+			}
+		case "$or":
+			subs, _ := asArray(cond)
+			matched := false
+			for _, sub := range subs {
+				m, _ := asMap(sub)
+				if matchDocument(doc, m) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		case "$nor":
+			subs, _ := asArray(cond)
+			for _, sub := range subs {
+				m, _ := asMap(sub)
+				if matchDocument(doc, m) {
+					return false
+				}
+			}
+		default:
+			values, found := lookupPath(doc, key)
+			if !matchCondition(values, found, cond) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchCondition 判断字段值是否满足条件（条件可以是字面值或操作符文档）
+func matchCondition(values []interface{}, found bool, cond interface{}) bool {
+	ops, isOps := isOperatorDoc(cond)
+	if !isOps {
+		return matchEquals(values, found, cond)
+	}
+
+	for op, arg := range ops {
+		switch op {
+		case "$eq":
+			if !matchEquals(values, found, arg) {
+				return false
+			}
+		case "$ne":
+			if matchEquals(values, found, arg) {
+				return false
+			}
+		case "$gt", "$gte", "$lt", "$lte":
+			if !matchCompare(values, op, arg) {
+				return false
+			}
+		case "$in":
+			list, _ := asArray(arg)
+			matched := false
+			for _, item := range list {
+				if matchEquals(values, found, item) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		case "$nin":
+			list, _ := asArray(arg)
+			for _, item := range list {
+				if matchEquals(values, found, item) {
+					return false
+				}
+			}
+		case "$exists":
+			if found != truthy(arg) {
+				return false
+			}
+		case "$regex":
+			options, _ := ops["$options"].(string)
+			if !matchRegex(values, arg, options) {
+				return false
+			}
+		case "$options":
+			// 与 $regex 一起处理
+		case "$elemMatch":
+			if !matchElem(values, arg) {
+				return false
+			}
+		case "$size":
+			size, _ := toFloat(arg)
+			matched := false
+			for _, v := range values {
+				if arr, ok := asArray(v); ok && len(arr) == int(size) {
+					matched = true
+				}
+			}
+			if !matched {
+				return false
+			}
+		case "$not":
+			if matchCondition(values, found, arg) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// matchEquals 相等匹配，字段为数组时匹配任一元素
+func matchEquals(values []interface{}, found bool, expected interface{}) bool {
+	if expected == nil {
+		if !found {
+			return true
+		}
+		for _, v := range values {
+			if v == nil {
+				return true
+			}
+		}
+		return false
+	}
+	if re, ok := expected.(primitive.Regex); ok {
+		return matchRegex(values, re, "")
+	}
+	for _, v := range expandValues(values) {
+		if valuesEqual(v, expected) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchCompare 大小比较匹配
+func matchCompare(values []interface{}, op string, arg interface{}) bool {
+	for _, v := range expandValues(values) {
+		if typeRank(v) != typeRank(arg) {
+			continue
+		}
+		cmp := compareValues(v, arg)
+		switch op {
+		case "$gt":
+			if cmp > 0 {
+				return true
+			}
+		case "$gte":
+			if cmp >= 0 {
+				return true
+			}
+		case "$lt":
+			if cmp < 0 {
+				return true
+			}
+		case "$lte":
+			if cmp <= 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchRegex 正则匹配
+func matchRegex(values []interface{}, pattern interface{}, options string) bool {
+	var expr string
+	switch p := pattern.(type) {
+	case string:
+		expr = p
+	case primitive.Regex:
+		expr = p.Pattern
+		if options == "" {
+			options = p.Options
+		}
+	default:
+		return false
+	}
+	if strings.Contains(options, "i") {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return false
+	}
+	for _, v := range expandValues(values) {
+		if s, ok := v.(string); ok && re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchElem $elemMatch 匹配
+func matchElem(values []interface{}, cond interface{}) bool {
+	for _, v := range values {
+		arr, ok := asArray(v)
+		if !ok {
+			continue
+		}
+		for _, item := range arr {
+			if _, isOps := isOperatorDoc(cond); isOps {
+				if matchCondition([]interface{}{item}, true, cond) {
+					return true
+				}
+				continue
+			}
+			itemDoc, ok := asMap(item)
+			condDoc, _ := asMap(cond)
+			if ok && matchDocument(itemDoc, condDoc) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// typeRank 按MongoDB的类型排序规则给出类型序号
+func typeRank(v interface{}) int {
+	switch v.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return 0
+	case int, int32, int64, float64:
+		return 1
+	case string:
+		return 2
+	case bson.M, map[string]interface{}, primitive.D:
+		return 3
+	case primitive.A, []interface{}:
+		return 4
+	case primitive.ObjectID:
+		return 5
+	case bool:
+		return 6
+	case primitive.DateTime:
+		return 7
+	default:
+		return 8
+	}
+}
+
+// compareValues 比较两个值，返回 -1/0/1
+func compareValues(a, b interface{}) int {
+	ra, rb := typeRank(a), typeRank(b)
+	if ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+
+	switch av := a.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return 0
+	case string:
+		return strings.Compare(av, b.(string))
+	case primitive.ObjectID:
+		bv := b.(primitive.ObjectID)
+		return bytes.Compare(av[:], bv[:])
+	case bool:
+		bv := b.(bool)
+		if av == bv {
+			return 0
+		}
+		if !av {
+			return -1
+		}
+		return 1
+	case primitive.DateTime:
+		bv := b.(primitive.DateTime)
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		}
+		return 0
+	}
+
+	if fa, ok := toFloat(a); ok {
+		fb, _ := toFloat(b)
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return 0
+	}
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+// valuesEqual 判断两个bson值是否相等
+func valuesEqual(a, b interface{}) bool {
+	if typeRank(a) != typeRank(b) {
+		return false
+	}
+	switch typeRank(a) {
+	case 3, 4, 8:
+		return reflect.DeepEqual(a, b)
+	}
+	return compareValues(a, b) == 0
+}
+
+// toFloat 将数值类型转换为float64
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// truthy 判断投影/$exists 参数是否为真
+func truthy(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case nil:
+		return false
+	}
+	if n, ok := toFloat(v); ok {
+		return n != 0
+	}
+	return true
+}
+
+// applyUpdate 对文档应用更新操作符，返回文档是否发生变化
+func applyUpdate(doc bson.M, update bson.M) (bool, error) {
+	before, err := bson.Marshal(doc)
+	if err != nil {
+		return false, err
+	}
+
+	for op, arg := range update {
+		fields, ok := asMap(arg)
+		if !ok {
+			return false, fmt.Errorf("不支持的更新格式: %s", op)
+		}
+		switch op {
+		case "$set":
+			for path, value := range fields {
+				setPath(doc, path, value)
+			}
+		case "$unset":
+			for path := range fields {
+				unsetPath(doc, path)
+			}
+		case "$inc":
+			for path, delta := range fields {
+				current, _ := lookupPath(doc, path)
+				setPath(doc, path, addNumbers(firstValue(current), delta))
+			}
+		case "$push", "$addToSet":
+			for path, value := range fields {
+				items := []interface{}{value}
+				if each, ok := asMap(value); ok {
+					if list, ok := asArray(each["$each"]); ok {
+						items = list
+					}
+				}
+				current, _ := lookupPath(doc, path)
+				arr, _ := asArray(firstValue(current))
+				next := append(primitive.A{}, arr...)
+				for _, item := range items {
+					if op == "$addToSet" && containsValue(next, item) {
+						continue
+					}
+					next = append(next, item)
+				}
+				setPath(doc, path, next)
+			}
+		case "$pull":
+			for path, cond := range fields {
+				current, _ := lookupPath(doc, path)
+				arr, ok := asArray(firstValue(current))
+				if !ok {
+					continue
+				}
+				next := primitive.A{}
+				for _, item := range arr {
+					if pullMatches(item, cond) {
+						continue
+					}
+					next = append(next, item)
+				}
+				setPath(doc, path, next)
+			}
+		case "$setOnInsert":
+			// 内存仓储不支持upsert，忽略
+		default:
+			return false, fmt.Errorf("不支持的更新操作符: %s", op)
+		}
+	}
+
+	after, err := bson.Marshal(doc)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(before, after), nil
+}
+
+// pullMatches 判断数组元素是否满足 $pull 条件
+func pullMatches(item interface{}, cond interface{}) bool {
+	if _, isOps := isOperatorDoc(cond); isOps {
+		return matchCondition([]interface{}{item}, true, cond)
+	}
+	if condDoc, ok := asMap(cond); ok {
+		itemDoc, ok := asMap(item)
+		return ok && matchDocument(itemDoc, condDoc)
+	}
+	return valuesEqual(item, cond)
+}
+
+// containsValue 判断数组是否包含指定值
+func containsValue(arr []interface{}, value interface{}) bool {
+	for _, item := range arr {
+		if valuesEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// addNumbers $inc 数值相加，尽量保持整数类型
+func addNumbers(current, delta interface{}) interface{} {
+	c, _ := toFloat(current)
+	d, _ := toFloat(delta)
+	_, currentFloat := current.(float64)
+	_, deltaFloat := delta.(float64)
+	if currentFloat || deltaFloat {
+		return c + d
+	}
+	_, currentInt64 := current.(int64)
+	_, deltaInt64 := delta.(int64)
+	if currentInt64 || deltaInt64 {
+		return int64(c + d)
+	}
+	return int32(c + d)
+}
+
+// setPath 按点分路径设置字段，中间层不存在时自动创建
+func setPath(doc bson.M, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	current := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := asMap(current[part])
+		if !ok {
+			next = bson.M{}
+		}
+		current[part] = next
+		current = next
+	}
+	current[parts[len(parts)-1]] = value
+}
+
+// unsetPath 按点分路径删除字段
+func unsetPath(doc bson.M, path string) {
+	parts := strings.Split(path, ".")
+	current := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := asMap(current[part])
+		if !ok {
+			return
+		}
+		current = next
+	}
+	delete(current, parts[len(parts)-1])
+}
@@ -0,0 +1,365 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryRepository 基于内存的通用仓储实现
+// 文档以 bson.M 形式保存，查询条件和更新操作符支持业务中用到的常用子集，
+// 主要用于单元测试中替代MongoDB
+type memoryRepository[T any] struct {
+	mu   sync.RWMutex
+	docs []bson.M
+}
+
+// newMemoryRepository 创建内存通用仓储
+func newMemoryRepository[T any]() *memoryRepository[T] {
+	return &memoryRepository[T]{}
+}
+
+// decodeDocument 将 bson.M 解码为实体
+func decodeDocument[T any](doc bson.M) (*T, error) {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var entity T
+	if err := bson.Unmarshal(data, &entity); err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+// matching 返回符合条件的文档（调用方需持有锁）
+func (r *memoryRepository[T]) matching(filter bson.M) ([]bson.M, error) {
+	normalized, err := normalizeFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]bson.M, 0)
+	for _, doc := range r.docs {
+		if matchDocument(doc, normalized) {
+			result = append(result, doc)
+		}
+	}
+	return result, nil
+}
+
+// snapshot 复制当前全部文档，返回恢复到该状态的函数
+func (r *memoryRepository[T]) snapshot() func() {
+	r.mu.RLock()
+	docs := make([]bson.M, 0, len(r.docs))
+	for _, doc := range r.docs {
+		if copied, err := toDocument(doc); err == nil {
+			docs = append(docs, copied)
+		}
+	}
+	r.mu.RUnlock()
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.docs = docs
+	}
+}
+
+func (r *memoryRepository[T]) FindByID(ctx context.Context, id primitive.ObjectID) (*T, error) {
+	return r.FindOne(ctx, bson.M{"_id": id})
+}
+
+func (r *memoryRepository[T]) FindOne(ctx context.Context, filter bson.M, opts ...*FindOptions) (*T, error) {
+	merged := mergeFindOptions(opts)
+	merged.Limit = 1
+	docs, err := r.Find(ctx, filter, merged)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, ErrNotFound
+	}
+	return &docs[0], nil
+}
+
+func (r *memoryRepository[T]) Find(ctx context.Context, filter bson.M, opts ...*FindOptions) ([]T, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	docs, err := r.matching(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := mergeFindOptions(opts)
+	if len(merged.Sort) > 0 {
+		sortDocuments(docs, merged.Sort)
+	}
+	if merged.Skip > 0 {
+		if merged.Skip >= int64(len(docs)) {
+			docs = docs[:0]
+		} else {
+			docs = docs[merged.Skip:]
+		}
+	}
+	if merged.Limit > 0 && merged.Limit < int64(len(docs)) {
+		docs = docs[:merged.Limit]
+	}
+
+	result := make([]T, 0, len(docs))
+	for _, doc := range docs {
+		entity, err := decodeDocument[T](applyProjection(doc, merged.Projection))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *entity)
+	}
+	return result, nil
+}
+
+// Each 先复制查询结果再逐条回调，回调中可以写入同一仓储
+func (r *memoryRepository[T]) Each(ctx context.Context, filter bson.M, fn func(doc *T) error, opts ...*FindOptions) error {
+	docs, err := r.Find(ctx, filter, opts...)
+	if err != nil {
+		return err
+	}
+	for i := range docs {
+		if err := fn(&docs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepository[T]) Count(ctx context.Context, filter bson.M) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	docs, err := r.matching(filter)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(docs)), nil
+}
+
+// insertLocked 插入单条文档（调用方需持有写锁）
+func (r *memoryRepository[T]) insertLocked(entity interface{}) (primitive.ObjectID, error) {
+	doc, err := toDocument(entity)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	id, ok := doc["_id"].(primitive.ObjectID)
+	if !ok || id.IsZero() {
+		id = primitive.NewObjectID()
+		doc["_id"] = id
+	}
+	for _, existing := range r.docs {
+		if existingID, ok := existing["_id"].(primitive.ObjectID); ok && existingID == id {
+			return primitive.NilObjectID, fmt.Errorf("重复的主键: %s", id.Hex())
+		}
+	}
+
+	r.docs = append(r.docs, doc)
+	return id, nil
+}
+
+func (r *memoryRepository[T]) Insert(ctx context.Context, doc *T) (primitive.ObjectID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.insertLocked(doc)
+}
+
+func (r *memoryRepository[T]) InsertMany(ctx context.Context, docs []T) ([]primitive.ObjectID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]primitive.ObjectID, 0, len(docs))
+	for i := range docs {
+		id, err := r.insertLocked(&docs[i])
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (r *memoryRepository[T]) UpdateByID(ctx context.Context, id primitive.ObjectID, update bson.M) (*UpdateResult, error) {
+	return r.UpdateOne(ctx, bson.M{"_id": id}, update)
+}
+
+// update 对符合条件的文档执行更新，many 为 false 时只更新第一条
+func (r *memoryRepository[T]) update(filter bson.M, update bson.M, many bool) (*UpdateResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	normalizedUpdate, err := normalizeFilter(update)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := r.matching(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UpdateResult{}
+	for _, doc := range docs {
+		result.MatchedCount++
+		changed, err := applyUpdate(doc, normalizedUpdate)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			result.ModifiedCount++
+		}
+		if !many {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (r *memoryRepository[T]) UpdateOne(ctx context.Context, filter bson.M, update bson.M) (*UpdateResult, error) {
+	return r.update(filter, update, false)
+}
+
+func (r *memoryRepository[T]) UpdateMany(ctx context.Context, filter bson.M, update bson.M) (*UpdateResult, error) {
+	return r.update(filter, update, true)
+}
+
+func (r *memoryRepository[T]) DeleteByID(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	return r.DeleteMany(ctx, bson.M{"_id": id})
+}
+
+func (r *memoryRepository[T]) DeleteMany(ctx context.Context, filter bson.M) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	normalized, err := normalizeFilter(filter)
+	if err != nil {
+		return 0, err
+	}
+	kept := r.docs[:0]
+	var deleted int64
+	for _, doc := range r.docs {
+		if matchDocument(doc, normalized) {
+			deleted++
+			continue
+		}
+		kept = append(kept, doc)
+	}
+	r.docs = kept
+	return deleted, nil
+}
+
+func (r *memoryRepository[T]) GroupCount(ctx context.Context, filter bson.M, field string) ([]GroupCount, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	docs, err := r.matching(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int)
+	results := make([]GroupCount, 0)
+	for _, doc := range docs {
+		var key interface{}
+		if values, found := lookupPath(doc, field); found && len(values) > 0 {
+			key = values[0]
+		}
+		name := groupKeyString(key)
+		if i, ok := index[name]; ok {
+			results[i].Count++
+			continue
+		}
+		index[name] = len(results)
+		results = append(results, GroupCount{Key: name, Count: 1})
+	}
+	return results, nil
+}
+
+func (r *memoryRepository[T]) Sum(ctx context.Context, filter bson.M, field string) (float64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	docs, err := r.matching(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0.0
+	for _, doc := range docs {
+		values, _ := lookupPath(doc, field)
+		for _, v := range values {
+			if n, ok := toFloat(v); ok {
+				total += n
+			}
+		}
+	}
+	return total, nil
+}
+
+// sortDocuments 按排序字段对文档排序
+func sortDocuments(docs []bson.M, fields []SortField) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, f := range fields {
+			a, _ := lookupPath(docs[i], f.Field)
+			b, _ := lookupPath(docs[j], f.Field)
+			cmp := compareValues(firstValue(a), firstValue(b))
+			if cmp == 0 {
+				continue
+			}
+			if f.Order < 0 {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+}
+
+// applyProjection 按顶层字段应用投影
+func applyProjection(doc bson.M, projection bson.M) bson.M {
+	if len(projection) == 0 {
+		return doc
+	}
+
+	include := false
+	for k, v := range projection {
+		if k != "_id" && truthy(v) {
+			include = true
+			break
+		}
+	}
+
+	result := bson.M{}
+	if include {
+		for k, v := range projection {
+			if truthy(v) {
+				if value, ok := doc[k]; ok {
+					result[k] = value
+				}
+			}
+		}
+		if idFlag, ok := projection["_id"]; !ok || truthy(idFlag) {
+			if id, ok := doc["_id"]; ok {
+				result["_id"] = id
+			}
+		}
+		return result
+	}
+
+	for k, v := range doc {
+		if flag, ok := projection[k]; ok && !truthy(flag) {
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,439 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migrationStaleAfter running 状态超过该时长视为上次执行中断，允许重新执行
+const migrationStaleAfter = 30 * time.Minute
+
+// ErrMigrationRunning 其他实例正在执行迁移
+var ErrMigrationRunning = errors.New("其他实例正在执行数据库迁移，请稍后重试")
+
+// Migration 一个版本化的数据库变更步骤，按 Version 递增顺序执行且只执行一次
+// 先执行数据回填，再创建索引；唯一索引创建前会检查重复数据
+type Migration struct {
+	Version   int
+	Name      string
+	Backfills []Backfill
+	Indexes   []IndexSpec
+}
+
+// IndexSpec 迁移中声明的索引
+type IndexSpec struct {
+	Collection string
+	Keys       bson.D
+	Unique     bool
+	// ExpireAfter 大于等于0时创建TTL索引，nil 表示普通索引
+	ExpireAfter *time.Duration
+}
+
+// String 索引说明，用于演练输出和日志
+func (s IndexSpec) String() string {
+	fields := make([]string, 0, len(s.Keys))
+	for _, key := range s.Keys {
+		fields = append(fields, fmt.Sprintf("%s:%v", key.Key, key.Value))
+	}
+	desc := fmt.Sprintf("%s(%s)", s.Collection, strings.Join(fields, ", "))
+	if s.Unique {
+		desc += " 唯一"
+	}
+	if s.ExpireAfter != nil {
+		desc += fmt.Sprintf(" TTL=%s", *s.ExpireAfter)
+	}
+	return desc
+}
+
+// model 转换为驱动的索引模型
+func (s IndexSpec) model() mongo.IndexModel {
+	opts := options.Index()
+	if s.Unique {
+		opts.SetUnique(true)
+	}
+	if s.ExpireAfter != nil {
+		opts.SetExpireAfterSeconds(int32(s.ExpireAfter.Seconds()))
+	}
+	return mongo.IndexModel{Keys: s.Keys, Options: opts}
+}
+
+// Backfill 数据回填步骤，dryRun 为 true 时只统计将受影响的记录数
+type Backfill struct {
+	Description string
+	Run         func(ctx context.Context, database *mongo.Database, dryRun bool) (int64, error)
+}
+
+// registeredMigrations 已声明的迁移，见 migrations.go
+var registeredMigrations []Migration
+
+// sortedMigrations 按版本号排序并校验版本号不重复
+func sortedMigrations() ([]Migration, error) {
+	list := make([]Migration, len(registeredMigrations))
+	copy(list, registeredMigrations)
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	for i := 1; i < len(list); i++ {
+		if list[i].Version == list[i-1].Version {
+			return nil, fmt.Errorf("迁移版本号重复: %d", list[i].Version)
+		}
+	}
+	return list, nil
+}
+
+// migrationsCollection 迁移记录集合，版本号唯一
+func migrationsCollection(ctx context.Context) (*mongo.Collection, error) {
+	if db == nil {
+		return nil, errors.New("MongoDB未初始化，请先调用InitMongoDB")
+	}
+	coll := db.Collection(MigrationsCollection)
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建迁移记录索引失败: %w", err)
+	}
+	return coll, nil
+}
+
+// loadMigrationRecords 读取全部迁移记录，返回 版本号 -> 记录
+func loadMigrationRecords(ctx context.Context, coll *mongo.Collection) (map[int]models.MigrationRecord, error) {
+	cursor, err := coll.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []models.MigrationRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	result := make(map[int]models.MigrationRecord, len(records))
+	for _, record := range records {
+		result[record.Version] = record
+	}
+	return result, nil
+}
+
+// MigrationStatuses 返回所有已声明迁移的执行状态
+func MigrationStatuses(ctx context.Context) ([]models.MigrationStatus, error) {
+	list, err := sortedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	coll, err := migrationsCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records, err := loadMigrationRecords(ctx, coll)
+	if err != nil {
+		return nil, fmt.Errorf("读取迁移记录失败: %w", err)
+	}
+
+	statuses := make([]models.MigrationStatus, 0, len(list))
+	for _, m := range list {
+		status := models.MigrationStatus{Version: m.Version, Name: m.Name, State: models.MigrationStatePending}
+		if record, ok := records[m.Version]; ok {
+			startedAt := record.StartedAt
+			status.State = record.State
+			status.StartedAt = &startedAt
+			status.AppliedAt = record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// MigrateUp 按版本顺序执行所有未执行的迁移，遇到错误立即停止
+// dryRun 为 true 时不修改数据，只返回将要执行的操作和预计影响的记录数
+func MigrateUp(ctx context.Context, dryRun bool) ([]models.MigrationReport, error) {
+	list, err := sortedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	coll, err := migrationsCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records, err := loadMigrationRecords(ctx, coll)
+	if err != nil {
+		return nil, fmt.Errorf("读取迁移记录失败: %w", err)
+	}
+
+	reports := []models.MigrationReport{}
+	for _, m := range list {
+		if record, ok := records[m.Version]; ok {
+			if record.State == models.MigrationStateApplied {
+				continue
+			}
+			if time.Since(record.StartedAt) < migrationStaleAfter {
+				return reports, fmt.Errorf("迁移 %d %s: %w", m.Version, m.Name, ErrMigrationRunning)
+			}
+		}
+
+		if dryRun {
+			reports = append(reports, planMigration(ctx, m))
+			continue
+		}
+
+		report, err := applyMigration(ctx, coll, m, records)
+		reports = append(reports, report)
+		if err != nil {
+			return reports, fmt.Errorf("执行迁移 %d %s 失败: %w", m.Version, m.Name, err)
+		}
+	}
+	return reports, nil
+}
+
+// planMigration 演练迁移：统计回填数量并检查唯一索引的重复数据
+func planMigration(ctx context.Context, m Migration) models.MigrationReport {
+	report := models.MigrationReport{Version: m.Version, Name: m.Name, Actions: []string{}}
+	var problems []string
+	for _, backfill := range m.Backfills {
+		count, err := backfill.Run(ctx, db, true)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", backfill.Description, err))
+			continue
+		}
+		report.Affected += count
+		report.Actions = append(report.Actions, fmt.Sprintf("回填 %s（预计 %d 条）", backfill.Description, count))
+	}
+	for _, index := range m.Indexes {
+		report.Actions = append(report.Actions, "创建索引 "+index.String())
+		if index.Unique {
+			if err := checkDuplicates(ctx, index); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+	report.Error = strings.Join(problems, "; ")
+	return report
+}
+
+// applyMigration 执行单个迁移：先写入 running 记录占位，成功后标记为 applied，失败时删除记录以便重试
+func applyMigration(ctx context.Context, coll *mongo.Collection, m Migration, records map[int]models.MigrationRecord) (models.MigrationReport, error) {
+	report := models.MigrationReport{Version: m.Version, Name: m.Name, Actions: []string{}}
+	startedAt := time.Now()
+
+	// 清理中断的记录后重新占位
+	if record, ok := records[m.Version]; ok {
+		if _, err := coll.DeleteOne(ctx, bson.M{"_id": record.ID, "state": models.MigrationStateRunning}); err != nil {
+			return report, err
+		}
+	}
+	_, err := coll.InsertOne(ctx, models.MigrationRecord{
+		Version:   m.Version,
+		Name:      m.Name,
+		State:     models.MigrationStateRunning,
+		StartedAt: startedAt,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return report, ErrMigrationRunning
+	}
+	if err != nil {
+		return report, err
+	}
+
+	utils.Logger.Info().Int("version", m.Version).Str("name", m.Name).Msg("开始执行数据库迁移")
+	if err := runMigration(ctx, m, &report); err != nil {
+		if _, delErr := coll.DeleteOne(context.WithoutCancel(ctx), bson.M{"version": m.Version}); delErr != nil {
+			utils.Logger.Error().Err(delErr).Int("version", m.Version).Msg("清理失败的迁移记录出错")
+		}
+		return report, err
+	}
+
+	appliedAt := time.Now()
+	_, err = coll.UpdateOne(ctx, bson.M{"version": m.Version}, bson.M{"$set": bson.M{
+		"state":      models.MigrationStateApplied,
+		"appliedAt":  appliedAt,
+		"durationMs": appliedAt.Sub(startedAt).Milliseconds(),
+		"affected":   report.Affected,
+	}})
+	if err != nil {
+		return report, err
+	}
+	report.Applied = true
+
+	utils.Logger.Info().
+		Int("version", m.Version).
+		Str("name", m.Name).
+		Int64("affected", report.Affected).
+		Dur("duration", appliedAt.Sub(startedAt)).
+		Msg("数据库迁移完成")
+	return report, nil
+}
+
+// runMigration 依次执行回填和索引创建
+func runMigration(ctx context.Context, m Migration, report *models.MigrationReport) error {
+	for _, backfill := range m.Backfills {
+		count, err := backfill.Run(ctx, db, false)
+		if err != nil {
+			return fmt.Errorf("回填 %s 失败: %w", backfill.Description, err)
+		}
+		report.Affected += count
+		report.Actions = append(report.Actions, fmt.Sprintf("回填 %s（%d 条）", backfill.Description, count))
+	}
+
+	for _, index := range m.Indexes {
+		if index.Unique {
+			if err := checkDuplicates(ctx, index); err != nil {
+				return err
+			}
+		}
+		if _, err := db.Collection(index.Collection).Indexes().CreateOne(ctx, index.model()); err != nil {
+			return fmt.Errorf("创建索引 %s 失败: %w", index, err)
+		}
+		report.Actions = append(report.Actions, "创建索引 "+index.String())
+	}
+	return nil
+}
+
+// checkDuplicates 检查唯一索引字段是否存在重复数据，存在时列出部分重复值
+func checkDuplicates(ctx context.Context, index IndexSpec) error {
+	groupID := bson.M{}
+	for _, key := range index.Keys {
+		groupID[key.Key] = "$" + key.Key
+	}
+	cursor, err := db.Collection(index.Collection).Aggregate(ctx, []bson.M{
+		{"$group": bson.M{"_id": groupID, "count": bson.M{"$sum": 1}}},
+		{"$match": bson.M{"count": bson.M{"$gt": 1}}},
+		{"$limit": 10},
+	})
+	if err != nil {
+		return fmt.Errorf("检查 %s 重复数据失败: %w", index, err)
+	}
+	defer cursor.Close(ctx)
+
+	var duplicates []bson.M
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return fmt.Errorf("检查 %s 重复数据失败: %w", index, err)
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(duplicates))
+	for _, dup := range duplicates {
+		values = append(values, fmt.Sprintf("%v×%v", dup["_id"], dup["count"]))
+	}
+	return fmt.Errorf("无法创建唯一索引 %s，存在重复数据（请先处理）: %s", index, strings.Join(values, ", "))
+}
+
+// setDefaultBackfill 为缺少字段的文档补充默认值
+func setDefaultBackfill(collection string, field string, value interface{}) Backfill {
+	return Backfill{
+		Description: fmt.Sprintf("%s.%s 缺失时设为 %v", collection, field, value),
+		Run: func(ctx context.Context, database *mongo.Database, dryRun bool) (int64, error) {
+			filter := bson.M{field: bson.M{"$exists": false}}
+			coll := database.Collection(collection)
+			if dryRun {
+				return coll.CountDocuments(ctx, filter)
+			}
+			result, err := coll.UpdateMany(ctx, filter, bson.M{"$set": bson.M{field: value}})
+			if err != nil {
+				return 0, err
+			}
+			return result.ModifiedCount, nil
+		},
+	}
+}
+
+// grantPermissionBackfill 为已授予资源部分权限的角色追加一个操作，未授予该资源任何权限的角色不受影响
+func grantPermissionBackfill(roles []models.UserRole, resource string, action string) Backfill {
+	filter := bson.M{
+		"name": bson.M{"$in": roles},
+		"permissions": bson.M{"$elemMatch": bson.M{
+			"resource": resource,
+			"actions":  bson.M{"$ne": action},
+		}},
+	}
+	return Backfill{
+		Description: fmt.Sprintf("角色 %v 授予 %s.%s 权限", roles, resource, action),
+		Run: func(ctx context.Context, database *mongo.Database, dryRun bool) (int64, error) {
+			coll := database.Collection(RolesCollection)
+			if dryRun {
+				return coll.CountDocuments(ctx, filter)
+			}
+			result, err := coll.UpdateMany(ctx, filter, bson.M{
+				"$addToSet": bson.M{"permissions.$.actions": action},
+				"$set":      bson.M{"updatedAt": time.Now()},
+			})
+			if err != nil {
+				return 0, err
+			}
+			return result.ModifiedCount, nil
+		},
+	}
+}
+
+// grantResourceBackfill 为尚未授予资源任何权限的角色添加该资源的权限，已授予部分权限的角色不受影响
+func grantResourceBackfill(roles []models.UserRole, resource string, actions []string) Backfill {
+	filter := bson.M{
+		"name":                 bson.M{"$in": roles},
+		"permissions.resource": bson.M{"$ne": resource},
+	}
+	return Backfill{
+		Description: fmt.Sprintf("角色 %v 授予 %s.%v 权限", roles, resource, actions),
+		Run: func(ctx context.Context, database *mongo.Database, dryRun bool) (int64, error) {
+			coll := database.Collection(RolesCollection)
+			if dryRun {
+				return coll.CountDocuments(ctx, filter)
+			}
+			result, err := coll.UpdateMany(ctx, filter, bson.M{
+				"$push": bson.M{"permissions": models.Permission{Resource: resource, Actions: actions}},
+				"$set":  bson.M{"updatedAt": time.Now()},
+			})
+			if err != nil {
+				return 0, err
+			}
+			return result.ModifiedCount, nil
+		},
+	}
+}
+
+// renameFieldsBackfill 将旧字段名改为当前字段名，两者同时存在时保留当前字段的值（需要 MongoDB 4.2+ 的管道更新）
+func renameFieldsBackfill(collection string, legacyFields map[string]string) Backfill {
+	legacyNames := make([]string, 0, len(legacyFields))
+	for legacy := range legacyFields {
+		legacyNames = append(legacyNames, legacy)
+	}
+	sort.Strings(legacyNames)
+
+	exists := make([]bson.M, 0, len(legacyNames))
+	set := bson.M{}
+	for _, legacy := range legacyNames {
+		current := legacyFields[legacy]
+		exists = append(exists, bson.M{legacy: bson.M{"$exists": true}})
+		set[current] = bson.M{"$ifNull": bson.A{"$" + current, "$" + legacy}}
+	}
+	filter := bson.M{"$or": exists}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: set}},
+		{{Key: "$unset", Value: legacyNames}},
+	}
+
+	return Backfill{
+		Description: fmt.Sprintf("%s 字段名改为驼峰命名（%s）", collection, strings.Join(legacyNames, ", ")),
+		Run: func(ctx context.Context, database *mongo.Database, dryRun bool) (int64, error) {
+			coll := database.Collection(collection)
+			if dryRun {
+				return coll.CountDocuments(ctx, filter)
+			}
+			result, err := coll.UpdateMany(ctx, filter, pipeline)
+			if err != nil {
+				return 0, err
+			}
+			return result.ModifiedCount, nil
+		},
+	}
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRegisteredMigrations(t *testing.T) {
+	migrations, err := sortedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("没有已声明的迁移")
+	}
+
+	indexes := map[string]int{}
+	for i, migration := range migrations {
+		// 版本号从 1 开始连续递增，迁移状态和回滚依赖版本顺序
+		if migration.Version != i+1 {
+			t.Fatalf("第 %d 个迁移的版本号为 %d，版本号应从 1 开始连续递增", i+1, migration.Version)
+		}
+		if strings.TrimSpace(migration.Name) == "" {
+			t.Errorf("迁移 %d 缺少名称", migration.Version)
+		}
+		if len(migration.Backfills) == 0 && len(migration.Indexes) == 0 {
+			t.Errorf("迁移 %d 没有回填步骤也没有索引", migration.Version)
+		}
+		for _, backfill := range migration.Backfills {
+			if backfill.Description == "" || backfill.Run == nil {
+				t.Errorf("迁移 %d 的回填步骤不完整: %q", migration.Version, backfill.Description)
+			}
+		}
+		for _, spec := range migration.Indexes {
+			if spec.Collection == "" || len(spec.Keys) == 0 {
+				t.Errorf("迁移 %d 的索引不完整: %s", migration.Version, spec)
+			}
+			// 同一索引在不同迁移中重复声明时，选项不同会导致创建失败
+			if previous, ok := indexes[spec.String()]; ok {
+				t.Errorf("索引 %s 在迁移 %d 和 %d 中重复声明", spec, previous, migration.Version)
+			}
+			indexes[spec.String()] = migration.Version
+		}
+	}
+}
+
+func TestSortedMigrations(t *testing.T) {
+	saved := registeredMigrations
+	t.Cleanup(func() { registeredMigrations = saved })
+
+	registeredMigrations = []Migration{{Version: 3, Name: "c"}, {Version: 1, Name: "a"}, {Version: 2, Name: "b"}}
+	migrations, err := sortedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Fatalf("排序后第 %d 个版本为 %d", i, migration.Version)
+		}
+	}
+	if registeredMigrations[0].Version != 3 {
+		t.Fatal("排序不应修改已声明的迁移列表")
+	}
+
+	registeredMigrations = []Migration{{Version: 1, Name: "a"}, {Version: 2, Name: "b"}, {Version: 1, Name: "c"}}
+	if _, err := sortedMigrations(); err == nil {
+		t.Fatal("版本号重复时应返回错误")
+	}
+}
+
+func TestIndexSpec(t *testing.T) {
+	ttl := 7 * 24 * time.Hour
+	cases := []struct {
+		name       string
+		spec       IndexSpec
+		want       string
+		wantUnique bool
+		wantTTL    int32 // -1 表示不是 TTL 索引
+	}{
+		{
+			name:    "普通索引",
+			spec:    IndexSpec{Collection: "customers", Keys: bson.D{{Key: "name", Value: 1}, {Key: "createdAt", Value: -1}}},
+			want:    "customers(name:1, createdAt:-1)",
+			wantTTL: -1,
+		},
+		{
+			name:       "唯一索引",
+			spec:       IndexSpec{Collection: "users", Keys: bson.D{{Key: "username", Value: 1}}, Unique: true},
+			want:       "users(username:1) 唯一",
+			wantUnique: true,
+			wantTTL:    -1,
+		},
+		{
+			name:    "TTL 索引",
+			spec:    IndexSpec{Collection: "claims", Keys: bson.D{{Key: "day", Value: 1}}, ExpireAfter: &ttl},
+			want:    "claims(day:1) TTL=168h0m0s",
+			wantTTL: int32(ttl.Seconds()),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.spec.String(); got != tc.want {
+				t.Fatalf("String() = %q, want %q", got, tc.want)
+			}
+			model := tc.spec.model()
+			if unique := model.Options.Unique != nil && *model.Options.Unique; unique != tc.wantUnique {
+				t.Fatalf("unique = %v, want %v", unique, tc.wantUnique)
+			}
+			ttl := int32(-1)
+			if model.Options.ExpireAfterSeconds != nil {
+				ttl = *model.Options.ExpireAfterSeconds
+			}
+			if ttl != tc.wantTTL {
+				t.Fatalf("expireAfterSeconds = %d, want %d", ttl, tc.wantTTL)
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 新的迁移追加到列表末尾，版本号递增；已发布的迁移不要修改，需要调整时新增一个版本
+func init() {
+	// TTL 为0：到达文档中 expiresAt 字段的时间即删除
+	expireAtField := time.Duration(0)
+	// 认领计数只用于当天的额度校验
+	claimCounterRetention := 7 * 24 * time.Hour
+
+	registeredMigrations = []Migration{
+		{
+			Version: 1,
+			Name:    "刷新令牌与登录失败计数索引",
+			Indexes: []IndexSpec{
+				{Collection: RefreshTokensCollection, Keys: bson.D{{Key: "tokenHash", Value: 1}}, Unique: true},
+				{Collection: RefreshTokensCollection, Keys: bson.D{{Key: "expiresAt", Value: 1}}, ExpireAfter: &expireAtField},
+				{Collection: RefreshTokensCollection, Keys: bson.D{{Key: "accountId", Value: 1}}},
+				{Collection: RefreshTokensCollection, Keys: bson.D{{Key: "familyId", Value: 1}}},
+				{Collection: LoginAttemptsCollection, Keys: bson.D{{Key: "key", Value: 1}}, Unique: true},
+				{Collection: LoginAttemptsCollection, Keys: bson.D{{Key: "expiresAt", Value: 1}}, ExpireAfter: &expireAtField},
+			},
+		},
+		{
+			Version: 2,
+			Name:    "热点查询字段索引",
+			Indexes: []IndexSpec{
+				{Collection: CustomersCollection, Keys: bson.D{{Key: "relatedSalesId", Value: 1}}},
+				{Collection: CustomersCollection, Keys: bson.D{{Key: "relatedAgentId", Value: 1}}},
+				{Collection: CustomersCollection, Keys: bson.D{{Key: "name", Value: 1}}},
+				{Collection: CustomersCollection, Keys: bson.D{{Key: "isInPublicPool", Value: 1}}},
+				{Collection: ProjectsCollection, Keys: bson.D{{Key: "customerId", Value: 1}}},
+				{Collection: InventoryRecordsCollection, Keys: bson.D{{Key: "productId", Value: 1}, {Key: "operationTime", Value: -1}}},
+				{Collection: InventoryRecordsCollection, Keys: bson.D{{Key: "operationTime", Value: -1}}},
+				{Collection: ApiOperationLogsCollection, Keys: bson.D{{Key: "operationTime", Value: -1}}},
+			},
+		},
+		{
+			Version: 3,
+			Name:    "产品型号与用户名唯一约束",
+			Indexes: []IndexSpec{
+				{Collection: ProductsCollection, Keys: bson.D{{Key: "modelName", Value: 1}, {Key: "packageType", Value: 1}}, Unique: true},
+				{Collection: UsersCollection, Keys: bson.D{{Key: "username", Value: 1}}, Unique: true},
+			},
+		},
+		{
+			Version: 4,
+			Name:    "回填缺失的默认字段",
+			Backfills: []Backfill{
+				setDefaultBackfill(UsersCollection, "tokenVersion", int64(0)),
+				setDefaultBackfill(AgentsCollection, "tokenVersion", int64(0)),
+				setDefaultBackfill(CustomersCollection, "isInPublicPool", false),
+			},
+		},
+		{
+			Version: 5,
+			Name:    "客户及其历史记录字段名统一为驼峰命名",
+			Backfills: []Backfill{
+				// 跟进记录曾写入 lastUpdateTime 而模型读取 lastupdatetime，两者并存时取较晚的时间
+				{
+					Description: "customers.lastUpdateTime 与 lastupdatetime 并存时取较晚的时间",
+					Run: func(ctx context.Context, database *mongo.Database, dryRun bool) (int64, error) {
+						coll := database.Collection(CustomersCollection)
+						filter := bson.M{"lastupdatetime": bson.M{"$exists": true}, "lastUpdateTime": bson.M{"$exists": true}}
+						if dryRun {
+							return coll.CountDocuments(ctx, filter)
+						}
+						result, err := coll.UpdateMany(ctx, filter, mongo.Pipeline{
+							{{Key: "$set", Value: bson.M{"lastUpdateTime": bson.M{"$max": bson.A{"$lastUpdateTime", "$lastupdatetime"}}}}},
+							{{Key: "$unset", Value: "lastupdatetime"}},
+						})
+						if err != nil {
+							return 0, err
+						}
+						return result.ModifiedCount, nil
+					},
+				},
+				renameFieldsBackfill(CustomersCollection, models.CustomerLegacyFields),
+				renameFieldsBackfill(CustAssignCollection, models.AssignmentHistoryLegacyFields),
+				renameFieldsBackfill(CustomerProgressCollection, models.ProgressHistoryLegacyFields),
+			},
+			Indexes: []IndexSpec{
+				{Collection: CustomersCollection, Keys: bson.D{{Key: "ownerId", Value: 1}}},
+				{Collection: CustomersCollection, Keys: bson.D{{Key: "lastUpdateTime", Value: -1}}},
+				{Collection: CustAssignCollection, Keys: bson.D{{Key: "customerId", Value: 1}, {Key: "createdAt", Value: -1}}},
+				{Collection: CustomerProgressCollection, Keys: bson.D{{Key: "customerId", Value: 1}, {Key: "createdAt", Value: -1}}},
+				{Collection: FollowUpCollection, Keys: bson.D{{Key: "customerId", Value: 1}}},
+			},
+		},
+		{
+			Version: 6,
+			Name:    "客户合并记录索引",
+			Indexes: []IndexSpec{
+				{Collection: CustomerMergesCollection, Keys: bson.D{{Key: "survivorId", Value: 1}, {Key: "createdAt", Value: -1}}},
+			},
+		},
+		{
+			Version: 7,
+			Name:    "客户回收站索引",
+			Indexes: []IndexSpec{
+				{Collection: CustomersCollection, Keys: bson.D{{Key: DeletedAtField, Value: 1}}},
+			},
+		},
+		{
+			Version: 8,
+			Name:    "离职交接查询索引",
+			Indexes: []IndexSpec{
+				{Collection: AgentsCollection, Keys: bson.D{{Key: "relatedSalesId", Value: 1}}},
+				{Collection: ProjectsCollection, Keys: bson.D{{Key: "creatorId", Value: 1}}},
+			},
+		},
+		{
+			Version: 9,
+			Name:    "客户导入批次索引",
+			Indexes: []IndexSpec{
+				{Collection: CustomerImportsCollection, Keys: bson.D{{Key: "expiresAt", Value: 1}}, ExpireAfter: &expireAtField},
+				{Collection: CustomerImportsCollection, Keys: bson.D{{Key: "createdById", Value: 1}, {Key: "createdAt", Value: -1}}},
+			},
+		},
+		{
+			Version: 10,
+			Name:    "内置销售和代理商角色授予客户导出权限",
+			Backfills: []Backfill{
+				grantPermissionBackfill([]models.UserRole{models.UserRoleFACTORY_SALES, models.UserRoleAGENT}, "customers", "export"),
+			},
+		},
+		{
+			Version: 11,
+			Name:    "客户更新时间索引，用于搜索索引增量同步",
+			Indexes: []IndexSpec{
+				{Collection: CustomersCollection, Keys: bson.D{{Key: "updatedAt", Value: 1}}},
+				{Collection: CustomersCollection, Keys: bson.D{{Key: "createdAt", Value: 1}}},
+			},
+		},
+		{
+			Version: 12,
+			Name:    "列表默认排序的复合索引，支持游标分页",
+			Indexes: []IndexSpec{
+				{Collection: CustomersCollection, Keys: bson.D{{Key: "lastUpdateTime", Value: -1}, {Key: "_id", Value: -1}}},
+				{Collection: ProjectsCollection, Keys: bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+				{Collection: FollowUpCollection, Keys: bson.D{{Key: "customerId", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+				{Collection: ProjectFollowUpRecordsCollection, Keys: bson.D{{Key: "projectId", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+				{Collection: AgentsCollection, Keys: bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+				{Collection: UsersCollection, Keys: bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+			},
+		},
+		{
+			Version: 13,
+			Name:    "内置销售和代理商角色授予公海认领权限，认领额度统计索引",
+			Backfills: []Backfill{
+				grantPermissionBackfill([]models.UserRole{models.UserRoleFACTORY_SALES, models.UserRoleAGENT}, "publicPool", "claim"),
+			},
+			Indexes: []IndexSpec{
+				{Collection: CustAssignCollection, Keys: bson.D{{Key: "operatorId", Value: 1}, {Key: "operationType", Value: 1}, {Key: "createdAt", Value: -1}}},
+			},
+		},
+		{
+			Version: 14,
+			Name:    "公海自动回收：站内通知、回收报告和项目进展查询索引",
+			Indexes: []IndexSpec{
+				{Collection: NotificationsCollection, Keys: bson.D{{Key: "recipientId", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+				{Collection: NotificationsCollection, Keys: bson.D{{Key: "recipientId", Value: 1}, {Key: "dedupeKey", Value: 1}}},
+				{Collection: PublicPoolRecycleRunsCollection, Keys: bson.D{{Key: "startedAt", Value: -1}, {Key: "_id", Value: -1}}},
+				{Collection: ProjectProgressHistoryCollection, Keys: bson.D{{Key: "projectId", Value: 1}, {Key: "createdAt", Value: -1}}},
+			},
+		},
+		{
+			Version: 15,
+			Name:    "公海客户补充原归属快照",
+			Backfills: []Backfill{
+				// 此前移入公海时未保存原归属，按最近一次移入公海的分配历史补充；联系人已被清空，无法恢复
+				{
+					Description: "customers.poolSnapshot 按分配历史补充原销售和代理商",
+					Run: func(ctx context.Context, database *mongo.Database, dryRun bool) (int64, error) {
+						coll := database.Collection(CustomersCollection)
+						filter := bson.M{"isInPublicPool": true, "poolSnapshot": bson.M{"$exists": false}}
+						if dryRun {
+							return coll.CountDocuments(ctx, filter)
+						}
+						cursor, err := coll.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+						if err != nil {
+							return 0, err
+						}
+						defer cursor.Close(ctx)
+
+						histories := database.Collection(CustAssignCollection)
+						var updated int64
+						for cursor.Next(ctx) {
+							var doc struct {
+								ID primitive.ObjectID `bson:"_id"`
+							}
+							if err := cursor.Decode(&doc); err != nil {
+								return updated, err
+							}
+							var history models.CustomerAssignmentHistory
+							err := histories.FindOne(ctx,
+								bson.M{"customerId": doc.ID.Hex(), "operationType": models.MoveToPublicPoolOperationType},
+								options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}}),
+							).Decode(&history)
+							if err == mongo.ErrNoDocuments {
+								continue
+							}
+							if err != nil {
+								return updated, err
+							}
+
+							snapshot := models.CustomerPoolSnapshot{
+								RelatedSalesID:   history.FromRelatedSalesID,
+								RelatedSalesName: history.FromRelatedSalesName,
+								RelatedAgentID:   history.FromRelatedAgentID,
+								RelatedAgentName: history.FromRelatedAgentName,
+								MovedAt:          history.CreatedAt,
+								MovedByID:        history.OperatorID,
+								MovedByName:      history.OperatorName,
+							}
+							ownerID, ownerName, ownerType := snapshot.PreviousOwner()
+							if _, err := coll.UpdateOne(ctx, bson.M{"_id": doc.ID}, bson.M{"$set": bson.M{
+								"poolSnapshot":      snapshot,
+								"previousOwnerId":   ownerID,
+								"previousOwnerName": ownerName,
+								"previousOwnerType": string(ownerType),
+							}}); err != nil {
+								return updated, err
+							}
+							updated++
+						}
+						return updated, cursor.Err()
+					},
+				},
+			},
+		},
+		{
+			Version: 16,
+			Name:    "定时任务状态和执行记录索引",
+			Indexes: []IndexSpec{
+				{Collection: ScheduledJobsCollection, Keys: bson.D{{Key: "name", Value: 1}}, Unique: true},
+				{Collection: JobRunsCollection, Keys: bson.D{{Key: "jobName", Value: 1}, {Key: "startedAt", Value: -1}, {Key: "_id", Value: -1}}},
+				{Collection: JobRunsCollection, Keys: bson.D{{Key: "startedAt", Value: -1}, {Key: "_id", Value: -1}}},
+			},
+		},
+		{
+			Version: 17,
+			Name:    "回收站改用独立的 customers.trash 权限",
+			Backfills: []Backfill{
+				// 此前回收站仅限超级管理员；超级管理员角色被改为逐项授权时补充该权限，通配权限不受影响
+				grantPermissionBackfill([]models.UserRole{models.UserRoleSUPER_ADMIN}, "customers", "trash"),
+			},
+		},
+		{
+			Version: 18,
+			Name:    "公海回收报告的客户明细拆分到独立集合",
+			Backfills: []Backfill{
+				recycleItemsBackfill(),
+			},
+			Indexes: []IndexSpec{
+				{Collection: PublicPoolRecycleItemsCollection, Keys: bson.D{{Key: "runId", Value: 1}, {Key: "kind", Value: 1}, {Key: "dueAt", Value: 1}, {Key: "_id", Value: 1}}},
+				{Collection: PublicPoolRecycleItemsCollection, Keys: bson.D{{Key: "runId", Value: 1}, {Key: "dueAt", Value: 1}, {Key: "_id", Value: 1}}},
+			},
+		},
+		{
+			Version: 19,
+			Name:    "公海认领计数索引，过期的计数保留 7 天后删除",
+			Indexes: []IndexSpec{
+				{Collection: ClaimCountersCollection, Keys: bson.D{{Key: "userId", Value: 1}, {Key: "day", Value: 1}}, Unique: true},
+				{Collection: ClaimCountersCollection, Keys: bson.D{{Key: "day", Value: 1}}, ExpireAfter: &claimCounterRetention},
+			},
+		},
+		{
+			Version: 20,
+			Name:    "站内通知改用独立的 notifications 权限",
+			Backfills: []Backfill{
+				// 此前登录即可查看通知；超级管理员角色被改为逐项授权时同样补充
+				grantResourceBackfill([]models.UserRole{
+					models.UserRoleSUPER_ADMIN,
+					models.UserRoleFACTORY_SALES,
+					models.UserRoleAGENT,
+					models.UserRoleINVENTORY_MANAGER,
+				}, "notifications", []string{"read", "update"}),
+			},
+		},
+	}
+}
+
+// recycleItemsBackfill 将旧回收报告中内嵌的 recycled、warned、failed 客户列表移到明细集合，报告中改为记录数量
+func recycleItemsBackfill() Backfill {
+	kinds := []string{models.RecycleItemKindRecycled, models.RecycleItemKindWarned, models.RecycleItemKindFailed}
+	legacy := make([]bson.M, 0, len(kinds))
+	for _, kind := range kinds {
+		legacy = append(legacy, bson.M{kind: bson.M{"$exists": true}})
+	}
+	filter := bson.M{"$or": legacy}
+
+	return Backfill{
+		Description: "publicPoolRecycleRuns 内嵌的客户明细移到 publicPoolRecycleItems",
+		Run: func(ctx context.Context, database *mongo.Database, dryRun bool) (int64, error) {
+			runs := database.Collection(PublicPoolRecycleRunsCollection)
+			if dryRun {
+				return runs.CountDocuments(ctx, filter)
+			}
+			cursor, err := runs.Find(ctx, filter)
+			if err != nil {
+				return 0, err
+			}
+			defer cursor.Close(ctx)
+
+			items := database.Collection(PublicPoolRecycleItemsCollection)
+			var updated int64
+			for cursor.Next(ctx) {
+				var doc struct {
+					ID       primitive.ObjectID             `bson:"_id"`
+					Recycled []models.PublicPoolRecycleItem `bson:"recycled"`
+					Warned   []models.PublicPoolRecycleItem `bson:"warned"`
+					Failed   []models.PublicPoolRecycleItem `bson:"failed"`
+				}
+				if err := cursor.Decode(&doc); err != nil {
+					return updated, err
+				}
+				// 中途失败重跑时先清除该报告已写入的明细，避免重复
+				if _, err := items.DeleteMany(ctx, bson.M{"runId": doc.ID}); err != nil {
+					return updated, err
+				}
+				var batch []interface{}
+				for kind, list := range map[string][]models.PublicPoolRecycleItem{
+					models.RecycleItemKindRecycled: doc.Recycled,
+					models.RecycleItemKindWarned:   doc.Warned,
+					models.RecycleItemKindFailed:   doc.Failed,
+				} {
+					for _, item := range list {
+						item.ID = primitive.NilObjectID
+						item.RunID = doc.ID
+						item.Kind = kind
+						batch = append(batch, item)
+					}
+				}
+				if len(batch) > 0 {
+					if _, err := items.InsertMany(ctx, batch); err != nil {
+						return updated, err
+					}
+				}
+				if _, err := runs.UpdateOne(ctx, bson.M{"_id": doc.ID}, bson.M{
+					"$set": bson.M{
+						"recycledCount": len(doc.Recycled),
+						"warnedCount":   len(doc.Warned),
+						"failedCount":   len(doc.Failed),
+					},
+					"$unset": bson.M{"recycled": "", "warned": "", "failed": ""},
+				}); err != nil {
+					return updated, err
+				}
+				updated++
+			}
+			return updated, cursor.Err()
+		},
+	}
+}
@@ -0,0 +1,285 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoRepository 基于MongoDB集合的通用仓储实现
+type mongoRepository[T any] struct {
+	coll    *mongo.Collection
+	timeout time.Duration
+}
+
+// newMongoRepository 创建MongoDB通用仓储，timeout 为单次操作的最长时间（0 表示只受调用方上下文约束）
+func newMongoRepository[T any](database *mongo.Database, name string, timeout time.Duration) *mongoRepository[T] {
+	return &mongoRepository[T]{coll: database.Collection(name), timeout: timeout}
+}
+
+// withTimeout 为单次操作设置截止时间，调用方上下文的截止时间更早时以调用方为准
+func (r *mongoRepository[T]) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, r.timeout)
+}
+
+// toMongoFindOptions 转换为驱动的查询选项
+func toMongoFindOptions(opts []*FindOptions) *options.FindOptions {
+	merged := mergeFindOptions(opts)
+	findOptions := options.Find()
+	if len(merged.Sort) > 0 {
+		sort := bson.D{}
+		for _, s := range merged.Sort {
+			sort = append(sort, bson.E{Key: s.Field, Value: s.Order})
+		}
+		findOptions.SetSort(sort)
+	}
+	if merged.Skip > 0 {
+		findOptions.SetSkip(merged.Skip)
+	}
+	if merged.Limit > 0 {
+		findOptions.SetLimit(merged.Limit)
+	}
+	if merged.Projection != nil {
+		findOptions.SetProjection(merged.Projection)
+	}
+	return findOptions
+}
+
+func (r *mongoRepository[T]) FindByID(ctx context.Context, id primitive.ObjectID) (*T, error) {
+	return r.FindOne(ctx, bson.M{"_id": id})
+}
+
+func (r *mongoRepository[T]) FindOne(ctx context.Context, filter bson.M, opts ...*FindOptions) (*T, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	findOptions := toMongoFindOptions(opts)
+	findOneOptions := options.FindOne()
+	if findOptions.Sort != nil {
+		findOneOptions.SetSort(findOptions.Sort)
+	}
+	if findOptions.Skip != nil {
+		findOneOptions.SetSkip(*findOptions.Skip)
+	}
+	if findOptions.Projection != nil {
+		findOneOptions.SetProjection(findOptions.Projection)
+	}
+
+	var doc T
+	if err := r.coll.FindOne(ctx, filter, findOneOptions).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func (r *mongoRepository[T]) Find(ctx context.Context, filter bson.M, opts ...*FindOptions) ([]T, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	cursor, err := r.coll.Find(ctx, filter, toMongoFindOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	docs := []T{}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// Each 使用游标逐条读取，读取时间随结果集大小变化，因此只受调用方上下文约束，不使用单次操作超时
+func (r *mongoRepository[T]) Each(ctx context.Context, filter bson.M, fn func(doc *T) error, opts ...*FindOptions) error {
+	cursor, err := r.coll.Find(ctx, filter, toMongoFindOptions(opts))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc T
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		if err := fn(&doc); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (r *mongoRepository[T]) Count(ctx context.Context, filter bson.M) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.coll.CountDocuments(ctx, filter)
+}
+
+func (r *mongoRepository[T]) Insert(ctx context.Context, doc *T) (primitive.ObjectID, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.coll.InsertOne(ctx, doc)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	id, ok := result.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return primitive.NilObjectID, fmt.Errorf("插入记录返回的ID类型无效: %T", result.InsertedID)
+	}
+	return id, nil
+}
+
+func (r *mongoRepository[T]) InsertMany(ctx context.Context, docs []T) ([]primitive.ObjectID, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	if len(docs) == 0 {
+		return []primitive.ObjectID{}, nil
+	}
+	items := make([]interface{}, len(docs))
+	for i := range docs {
+		items[i] = docs[i]
+	}
+
+	result, err := r.coll.InsertMany(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(result.InsertedIDs))
+	for _, insertedID := range result.InsertedIDs {
+		if id, ok := insertedID.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *mongoRepository[T]) UpdateByID(ctx context.Context, id primitive.ObjectID, update bson.M) (*UpdateResult, error) {
+	return r.UpdateOne(ctx, bson.M{"_id": id}, update)
+}
+
+func (r *mongoRepository[T]) UpdateOne(ctx context.Context, filter bson.M, update bson.M) (*UpdateResult, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return nil, err
+	}
+	return &UpdateResult{MatchedCount: result.MatchedCount, ModifiedCount: result.ModifiedCount}, nil
+}
+
+func (r *mongoRepository[T]) UpdateMany(ctx context.Context, filter bson.M, update bson.M) (*UpdateResult, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return nil, err
+	}
+	return &UpdateResult{MatchedCount: result.MatchedCount, ModifiedCount: result.ModifiedCount}, nil
+}
+
+func (r *mongoRepository[T]) DeleteByID(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.coll.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (r *mongoRepository[T]) DeleteMany(ctx context.Context, filter bson.M) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.coll.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (r *mongoRepository[T]) GroupCount(ctx context.Context, filter bson.M, field string) ([]GroupCount, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	if filter == nil {
+		filter = bson.M{}
+	}
+	cursor, err := r.coll.Aggregate(ctx, []bson.M{
+		{"$match": filter},
+		{"$group": bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var raw []struct {
+		ID    interface{} `bson:"_id"`
+		Count int         `bson:"count"`
+	}
+	if err := cursor.All(ctx, &raw); err != nil {
+		return nil, err
+	}
+
+	results := make([]GroupCount, 0, len(raw))
+	for _, item := range raw {
+		results = append(results, GroupCount{Key: groupKeyString(item.ID), Count: item.Count})
+	}
+	return results, nil
+}
+
+func (r *mongoRepository[T]) Sum(ctx context.Context, filter bson.M, field string) (float64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	if filter == nil {
+		filter = bson.M{}
+	}
+	cursor, err := r.coll.Aggregate(ctx, []bson.M{
+		{"$match": filter},
+		{"$group": bson.M{"_id": nil, "total": bson.M{"$sum": "$" + field}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var raw []bson.M
+	if err := cursor.All(ctx, &raw); err != nil {
+		return 0, err
+	}
+	if len(raw) == 0 {
+		return 0, nil
+	}
+	total, _ := toFloat(raw[0]["total"])
+	return total, nil
+}
+
+// groupKeyString 将分组键转换为字符串
+func groupKeyString(v interface{}) string {
+	switch key := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return key
+	case primitive.ObjectID:
+		return key.Hex()
+	default:
+		return fmt.Sprintf("%v", key)
+	}
+}
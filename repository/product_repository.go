@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/BerniceZTT/crm_end/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ProductRepository 产品仓储
+type ProductRepository interface {
+	Repository[models.Product]
+	// ExistsByModel 判断型号+封装是否已存在，excludeID 非空时排除该产品
+	ExistsByModel(ctx context.Context, modelName, packageType string, excludeID primitive.ObjectID) (bool, error)
+	// IncrementStock 调整库存，delta 为负数时要求库存充足，返回是否更新成功
+	IncrementStock(ctx context.Context, id primitive.ObjectID, delta int) (bool, error)
+}
+
+type productRepository struct {
+	Repository[models.Product]
+}
+
+func (r *productRepository) ExistsByModel(ctx context.Context, modelName, packageType string, excludeID primitive.ObjectID) (bool, error) {
+	filter := bson.M{
+		"modelName":   modelName,
+		"packageType": packageType,
+	}
+	if !excludeID.IsZero() {
+		filter["_id"] = bson.M{"$ne": excludeID}
+	}
+	count, err := r.Count(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *productRepository) IncrementStock(ctx context.Context, id primitive.ObjectID, delta int) (bool, error) {
+	filter := bson.M{"_id": id}
+	if delta < 0 {
+		filter["stock"] = bson.M{"$gte": -delta}
+	}
+	result, err := r.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"stock": delta}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// InventoryRepository 库存操作记录仓储
+type InventoryRepository interface {
+	Repository[models.InventoryRecord]
+	// ExistsOperation 判断指定操作ID的库存记录是否已存在（用于幂等检查）
+	ExistsOperation(ctx context.Context, filter bson.M) (bool, error)
+}
+
+type inventoryRepository struct {
+	Repository[models.InventoryRecord]
+}
+
+func (r *inventoryRepository) ExistsOperation(ctx context.Context, filter bson.M) (bool, error) {
+	count, err := r.Count(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
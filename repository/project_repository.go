@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/BerniceZTT/crm_end/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ProjectRepository 项目仓储
+type ProjectRepository interface {
+	SoftDeleteRepository[models.Project]
+	// FindVisibleByCustomerID 查询客户下前端可见的项目
+	FindVisibleByCustomerID(ctx context.Context, customerID primitive.ObjectID, opts ...*FindOptions) ([]models.Project, error)
+	// HideByCustomerID 将客户下所有项目设为前端不可见
+	HideByCustomerID(ctx context.Context, customerID primitive.ObjectID) (*UpdateResult, error)
+}
+
+type projectRepository struct {
+	SoftDeleteRepository[models.Project]
+}
+
+func (r *projectRepository) FindVisibleByCustomerID(ctx context.Context, customerID primitive.ObjectID, opts ...*FindOptions) ([]models.Project, error) {
+	return r.Find(ctx, bson.M{"customerId": customerID, "webHidden": false}, opts...)
+}
+
+func (r *projectRepository) HideByCustomerID(ctx context.Context, customerID primitive.ObjectID) (*UpdateResult, error) {
+	return r.UpdateMany(ctx,
+		bson.M{"customerId": customerID, "webHidden": false},
+		bson.M{"$set": bson.M{"webHidden": true}},
+	)
+}
+
+// ProjectFollowUpRepository 项目跟进记录仓储
+type ProjectFollowUpRepository interface {
+	Repository[models.ProjectFollowUpRecord]
+	// FindByProjectID 按项目ID查询跟进记录，按创建时间倒序
+	FindByProjectID(ctx context.Context, projectID string) ([]models.ProjectFollowUpRecord, error)
+}
+
+type projectFollowUpRepository struct {
+	Repository[models.ProjectFollowUpRecord]
+}
+
+func (r *projectFollowUpRepository) FindByProjectID(ctx context.Context, projectID string) ([]models.ProjectFollowUpRecord, error) {
+	return r.Find(ctx, bson.M{"projectId": projectID}, NewFindOptions().SetSort("createdAt", -1))
+}
+
+// ProjectProgressRepository 项目进展历史仓储
+type ProjectProgressRepository interface {
+	Repository[models.ProjectProgressHistory]
+	// FindByProjectID 按项目ID查询进展历史，按创建时间倒序
+	FindByProjectID(ctx context.Context, projectID string) ([]models.ProjectProgressHistory, error)
+}
+
+type projectProgressRepository struct {
+	Repository[models.ProjectProgressHistory]
+}
+
+func (r *projectProgressRepository) FindByProjectID(ctx context.Context, projectID string) ([]models.ProjectProgressHistory, error) {
+	return r.Find(ctx, bson.M{"projectId": projectID}, NewFindOptions().SetSort("createdAt", -1))
+}
+
+// ProjectFileRepository 项目文件仓储
+type ProjectFileRepository interface {
+	Repository[models.FileInfo]
+	// FindByFileID 按文件ID（id字段）查询文件
+	FindByFileID(ctx context.Context, fileID string) (*models.FileInfo, error)
+	// DeleteByFileID 按文件ID（id字段）删除文件
+	DeleteByFileID(ctx context.Context, fileID string) (int64, error)
+}
+
+type projectFileRepository struct {
+	Repository[models.FileInfo]
+}
+
+func (r *projectFileRepository) FindByFileID(ctx context.Context, fileID string) (*models.FileInfo, error) {
+	return r.FindOne(ctx, bson.M{"id": fileID})
+}
+
+func (r *projectFileRepository) DeleteByFileID(ctx context.Context, fileID string) (int64, error) {
+	return r.DeleteMany(ctx, bson.M{"id": fileID})
+}
@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotFound 记录不存在，与 mongo.ErrNoDocuments 保持一致，便于沿用原有判断
+var ErrNotFound = mongo.ErrNoDocuments
+
+// Repository 通用的实体仓储接口，具体实体仓储在此基础上扩展业务查询方法
+type Repository[T any] interface {
+	// FindByID 按 _id 查询单条记录，不存在时返回 ErrNotFound
+	FindByID(ctx context.Context, id primitive.ObjectID) (*T, error)
+	// FindOne 按条件查询单条记录，不存在时返回 ErrNotFound
+	FindOne(ctx context.Context, filter bson.M, opts ...*FindOptions) (*T, error)
+	// Find 按条件查询多条记录
+	Find(ctx context.Context, filter bson.M, opts ...*FindOptions) ([]T, error)
+	// Each 按条件逐条读取记录并调用 fn，不在内存中缓存全部结果；fn 返回错误时停止读取并返回该错误
+	Each(ctx context.Context, filter bson.M, fn func(doc *T) error, opts ...*FindOptions) error
+	// Count 统计符合条件的记录数
+	Count(ctx context.Context, filter bson.M) (int64, error)
+	// Insert 插入单条记录，返回记录ID
+	Insert(ctx context.Context, doc *T) (primitive.ObjectID, error)
+	// InsertMany 批量插入记录，返回记录ID列表
+	InsertMany(ctx context.Context, docs []T) ([]primitive.ObjectID, error)
+	// UpdateByID 按 _id 更新记录，update 为完整的更新文档（如 {"$set": ...}）
+	UpdateByID(ctx context.Context, id primitive.ObjectID, update bson.M) (*UpdateResult, error)
+	// UpdateOne 按条件更新第一条匹配的记录
+	UpdateOne(ctx context.Context, filter bson.M, update bson.M) (*UpdateResult, error)
+	// UpdateMany 按条件更新所有匹配的记录
+	UpdateMany(ctx context.Context, filter bson.M, update bson.M) (*UpdateResult, error)
+	// DeleteByID 按 _id 删除记录，返回删除数量
+	DeleteByID(ctx context.Context, id primitive.ObjectID) (int64, error)
+	// DeleteMany 按条件删除记录，返回删除数量
+	DeleteMany(ctx context.Context, filter bson.M) (int64, error)
+	// GroupCount 按字段分组计数，相当于 $match + $group{_id: "$field", count: {$sum: 1}}
+	GroupCount(ctx context.Context, filter bson.M, field string) ([]GroupCount, error)
+	// Sum 对符合条件记录的数值字段求和
+	Sum(ctx context.Context, filter bson.M, field string) (float64, error)
+}
+
+// UpdateResult 更新结果
+type UpdateResult struct {
+	MatchedCount  int64
+	ModifiedCount int64
+}
+
+// GroupCount 分组计数结果
+type GroupCount struct {
+	Key   string `bson:"_id" json:"name"`
+	Count int    `bson:"count" json:"value"`
+}
+
+// SortField 排序字段
+type SortField struct {
+	Field string
+	Order int // 1 升序，-1 降序
+}
+
+// FindOptions 查询选项，与 options.FindOptions 的常用子集保持一致
+type FindOptions struct {
+	Sort       []SortField
+	Skip       int64
+	Limit      int64
+	Projection bson.M
+}
+
+// NewFindOptions 创建查询选项
+func NewFindOptions() *FindOptions {
+	return &FindOptions{}
+}
+
+// SetSort 追加排序字段，可多次调用组成多字段排序
+func (o *FindOptions) SetSort(field string, order int) *FindOptions {
+	o.Sort = append(o.Sort, SortField{Field: field, Order: order})
+	return o
+}
+
+// SetSkip 设置跳过的记录数
+func (o *FindOptions) SetSkip(skip int64) *FindOptions {
+	o.Skip = skip
+	return o
+}
+
+// SetLimit 设置返回的最大记录数
+func (o *FindOptions) SetLimit(limit int64) *FindOptions {
+	o.Limit = limit
+	return o
+}
+
+// SetProjection 设置返回字段
+func (o *FindOptions) SetProjection(projection bson.M) *FindOptions {
+	o.Projection = projection
+	return o
+}
+
+// mergeFindOptions 合并多个查询选项，后者覆盖前者
+func mergeFindOptions(opts []*FindOptions) *FindOptions {
+	merged := &FindOptions{}
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if len(o.Sort) > 0 {
+			merged.Sort = o.Sort
+		}
+		if o.Skip > 0 {
+			merged.Skip = o.Skip
+		}
+		if o.Limit > 0 {
+			merged.Limit = o.Limit
+		}
+		if o.Projection != nil {
+			merged.Projection = o.Projection
+		}
+	}
+	return merged
+}
+
+// Repositories 所有实体仓储的集合
+type Repositories struct {
+	Users             UserRepository
+	Agents            AgentRepository
+	Customers         CustomerRepository
+	AssignmentHistory AssignmentHistoryRepository
+	CustomerProgress  CustomerProgressRepository
+	FollowUps         FollowUpRepository
+	Products          ProductRepository
+	Inventory         InventoryRepository
+	Projects          ProjectRepository
+	ProjectFollowUps  ProjectFollowUpRepository
+	ProjectProgress   ProjectProgressRepository
+	ProjectFiles      ProjectFileRepository
+	SystemConfigs     SystemConfigRepository
+	OperationLogs     OperationLogRepository
+	Roles             RoleRepository
+	RefreshTokens     RefreshTokenRepository
+	LoginAttempts     LoginAttemptRepository
+	CustomerMerges    CustomerMergeRepository
+	CustomerImports   CustomerImportRepository
+	Notifications     NotificationRepository
+	RecycleRuns       RecycleRunRepository
+	RecycleItems      RecycleItemRepository
+	ClaimCounters     ClaimCounterRepository
+	ScheduledJobs     ScheduledJobRepository
+	JobRuns           JobRunRepository
+
+	// Transactions 多文档事务执行器
+	Transactions TransactionRunner
+}
+
+// NewMongoRepositories 基于MongoDB数据库创建仓储集合，timeout 为单次操作的最长时间
+func NewMongoRepositories(database *mongo.Database, timeout time.Duration) *Repositories {
+	return &Repositories{
+		Users:             &userRepository{newMongoRepository[models.User](database, UsersCollection, timeout)},
+		Agents:            &agentRepository{newMongoRepository[models.Agent](database, AgentsCollection, timeout)},
+		Customers:         &customerRepository{newSoftDeleteRepository(newMongoRepository[models.Customer](database, CustomersCollection, timeout))},
+		AssignmentHistory: &assignmentHistoryRepository{newSoftDeleteRepository(newMongoRepository[models.CustomerAssignmentHistory](database, CustAssignCollection, timeout))},
+		CustomerProgress:  &customerProgressRepository{newMongoRepository[models.CustomerProgressHistory](database, CustomerProgressCollection, timeout)},
+		FollowUps:         &followUpRepository{newSoftDeleteRepository(newMongoRepository[models.FollowUpRecord](database, FollowUpCollection, timeout))},
+		Products:          &productRepository{newMongoRepository[models.Product](database, ProductsCollection, timeout)},
+		Inventory:         &inventoryRepository{newMongoRepository[models.InventoryRecord](database, InventoryRecordsCollection, timeout)},
+		Projects:          &projectRepository{newSoftDeleteRepository(newMongoRepository[models.Project](database, ProjectsCollection, timeout))},
+		ProjectFollowUps:  &projectFollowUpRepository{newMongoRepository[models.ProjectFollowUpRecord](database, ProjectFollowUpRecordsCollection, timeout)},
+		ProjectProgress:   &projectProgressRepository{newMongoRepository[models.ProjectProgressHistory](database, ProjectProgressHistoryCollection, timeout)},
+		ProjectFiles:      &projectFileRepository{newMongoRepository[models.FileInfo](database, ProjectFilesCollection, timeout)},
+		SystemConfigs:     &systemConfigRepository{newMongoRepository[models.SystemConfig](database, SystemConfigsCollection, timeout)},
+		OperationLogs:     &operationLogRepository{newMongoRepository[models.OperationLog](database, ApiOperationLogsCollection, timeout)},
+		Roles:             &roleRepository{newMongoRepository[models.Role](database, RolesCollection, timeout)},
+		RefreshTokens:     &refreshTokenRepository{newMongoRepository[models.RefreshToken](database, RefreshTokensCollection, timeout)},
+		LoginAttempts:     &loginAttemptRepository{newMongoRepository[models.LoginAttempt](database, LoginAttemptsCollection, timeout)},
+		CustomerMerges:    &customerMergeRepository{newMongoRepository[models.CustomerMergeRecord](database, CustomerMergesCollection, timeout)},
+		CustomerImports:   &customerImportRepository{newMongoRepository[models.CustomerImport](database, CustomerImportsCollection, timeout)},
+		Notifications:     &notificationRepository{newMongoRepository[models.Notification](database, NotificationsCollection, timeout)},
+		RecycleRuns:       &recycleRunRepository{newMongoRepository[models.PublicPoolRecycleRun](database, PublicPoolRecycleRunsCollection, timeout)},
+		RecycleItems:      &recycleItemRepository{newMongoRepository[models.PublicPoolRecycleItem](database, PublicPoolRecycleItemsCollection, timeout)},
+		ClaimCounters:     &claimCounterRepository{newMongoRepository[models.PublicPoolClaimCounter](database, ClaimCountersCollection, timeout)},
+		ScheduledJobs:     &scheduledJobRepository{newMongoRepository[models.ScheduledJobState](database, ScheduledJobsCollection, timeout)},
+		JobRuns:           &jobRunRepository{newMongoRepository[models.JobRun](database, JobRunsCollection, timeout)},
+
+		Transactions: newMongoTransactionRunner(database),
+	}
+}
+
+// NewMemoryRepositories 创建内存版仓储集合，用于单元测试和本地调试，无需MongoDB
+func NewMemoryRepositories() *Repositories {
+	tx := &memoryTransactionRunner{}
+	return &Repositories{
+		Users:             &userRepository{trackMemory(tx, newMemoryRepository[models.User]())},
+		Agents:            &agentRepository{trackMemory(tx, newMemoryRepository[models.Agent]())},
+		Customers:         &customerRepository{newSoftDeleteRepository(trackMemory(tx, newMemoryRepository[models.Customer]()))},
+		AssignmentHistory: &assignmentHistoryRepository{newSoftDeleteRepository(trackMemory(tx, newMemoryRepository[models.CustomerAssignmentHistory]()))},
+		CustomerProgress:  &customerProgressRepository{trackMemory(tx, newMemoryRepository[models.CustomerProgressHistory]())},
+		FollowUps:         &followUpRepository{newSoftDeleteRepository(trackMemory(tx, newMemoryRepository[models.FollowUpRecord]()))},
+		Products:          &productRepository{trackMemory(tx, newMemoryRepository[models.Product]())},
+		Inventory:         &inventoryRepository{trackMemory(tx, newMemoryRepository[models.InventoryRecord]())},
+		Projects:          &projectRepository{newSoftDeleteRepository(trackMemory(tx, newMemoryRepository[models.Project]()))},
+		ProjectFollowUps:  &projectFollowUpRepository{trackMemory(tx, newMemoryRepository[models.ProjectFollowUpRecord]())},
+		ProjectProgress:   &projectProgressRepository{trackMemory(tx, newMemoryRepository[models.ProjectProgressHistory]())},
+		ProjectFiles:      &projectFileRepository{trackMemory(tx, newMemoryRepository[models.FileInfo]())},
+		SystemConfigs:     &systemConfigRepository{trackMemory(tx, newMemoryRepository[models.SystemConfig]())},
+		OperationLogs:     &operationLogRepository{trackMemory(tx, newMemoryRepository[models.OperationLog]())},
+		Roles:             &roleRepository{trackMemory(tx, newMemoryRepository[models.Role]())},
+		RefreshTokens:     &refreshTokenRepository{trackMemory(tx, newMemoryRepository[models.RefreshToken]())},
+		LoginAttempts:     &loginAttemptRepository{trackMemory(tx, newMemoryRepository[models.LoginAttempt]())},
+		CustomerMerges:    &customerMergeRepository{trackMemory(tx, newMemoryRepository[models.CustomerMergeRecord]())},
+		CustomerImports:   &customerImportRepository{trackMemory(tx, newMemoryRepository[models.CustomerImport]())},
+		Notifications:     &notificationRepository{trackMemory(tx, newMemoryRepository[models.Notification]())},
+		RecycleRuns:       &recycleRunRepository{trackMemory(tx, newMemoryRepository[models.PublicPoolRecycleRun]())},
+		RecycleItems:      &recycleItemRepository{trackMemory(tx, newMemoryRepository[models.PublicPoolRecycleItem]())},
+		ClaimCounters:     &claimCounterRepository{trackMemory(tx, newMemoryRepository[models.PublicPoolClaimCounter]())},
+		ScheduledJobs:     &scheduledJobRepository{trackMemory(tx, newMemoryRepository[models.ScheduledJobState]())},
+		JobRuns:           &jobRunRepository{trackMemory(tx, newMemoryRepository[models.JobRun]())},
+
+		Transactions: tx,
+	}
+}
+
+var (
+	reposMu sync.RWMutex
+	repos   *Repositories
+)
+
+// SetRepositories 替换全局仓储集合（测试中可注入 NewMemoryRepositories()）
+func SetRepositories(r *Repositories) {
+	reposMu.Lock()
+	defer reposMu.Unlock()
+	repos = r
+}
+
+// GetRepositories 返回全局仓储集合，由 InitMongoDB 或 SetRepositories 设置，未设置时终止程序
+func GetRepositories() *Repositories {
+	reposMu.RLock()
+	defer reposMu.RUnlock()
+	if repos == nil {
+		log.Fatal("仓储未初始化，请先调用InitMongoDB")
+	}
+	return repos
+}
+
+// Users 用户仓储
+func Users() UserRepository { return GetRepositories().Users }
+
+// Agents 代理商仓储
+func Agents() AgentRepository { return GetRepositories().Agents }
+
+// Customers 客户仓储
+func Customers() CustomerRepository { return GetRepositories().Customers }
+
+// AssignmentHistory 客户分配历史仓储
+func AssignmentHistory() AssignmentHistoryRepository { return GetRepositories().AssignmentHistory }
+
+// CustomerProgress 客户进展历史仓储
+func CustomerProgress() CustomerProgressRepository { return GetRepositories().CustomerProgress }
+
+// FollowUps 客户跟进记录仓储
+func FollowUps() FollowUpRepository { return GetRepositories().FollowUps }
+
+// Products 产品仓储
+func Products() ProductRepository { return GetRepositories().Products }
+
+// Inventory 库存记录仓储
+func Inventory() InventoryRepository { return GetRepositories().Inventory }
+
+// Projects 项目仓储
+func Projects() ProjectRepository { return GetRepositories().Projects }
+
+// ProjectFollowUps 项目跟进记录仓储
+func ProjectFollowUps() ProjectFollowUpRepository { return GetRepositories().ProjectFollowUps }
+
+// ProjectProgress 项目进展历史仓储
+func ProjectProgress() ProjectProgressRepository { return GetRepositories().ProjectProgress }
+
+// ProjectFiles 项目文件仓储
+func ProjectFiles() ProjectFileRepository { return GetRepositories().ProjectFiles }
+
+// SystemConfigs 系统配置仓储
+func SystemConfigs() SystemConfigRepository { return GetRepositories().SystemConfigs }
+
+// OperationLogs 接口操作日志仓储
+func OperationLogs() OperationLogRepository { return GetRepositories().OperationLogs }
+
+// Roles 角色仓储
+func Roles() RoleRepository { return GetRepositories().Roles }
+
+// RefreshTokens 刷新令牌仓储
+func RefreshTokens() RefreshTokenRepository { return GetRepositories().RefreshTokens }
+
+// LoginAttempts 登录失败计数仓储
+func LoginAttempts() LoginAttemptRepository { return GetRepositories().LoginAttempts }
+
+// CustomerMerges 客户合并记录仓储
+func CustomerMerges() CustomerMergeRepository { return GetRepositories().CustomerMerges }
+
+// CustomerImports 客户导入批次仓储
+func CustomerImports() CustomerImportRepository { return GetRepositories().CustomerImports }
+
+// Notifications 站内通知仓储
+func Notifications() NotificationRepository { return GetRepositories().Notifications }
+
+// RecycleRuns 公海回收任务报告仓储
+func RecycleRuns() RecycleRunRepository { return GetRepositories().RecycleRuns }
+
+// RecycleItems 公海回收报告客户明细仓储
+func RecycleItems() RecycleItemRepository { return GetRepositories().RecycleItems }
+
+// ClaimCounters 公海认领计数仓储
+func ClaimCounters() ClaimCounterRepository { return GetRepositories().ClaimCounters }
+
+// ScheduledJobs 定时任务状态和锁仓储
+func ScheduledJobs() ScheduledJobRepository { return GetRepositories().ScheduledJobs }
+
+// JobRuns 定时任务执行记录仓储
+func JobRuns() JobRunRepository { return GetRepositories().JobRuns }
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ScheduledJobRepository 定时任务状态仓储，按任务名维护分布式锁
+type ScheduledJobRepository interface {
+	Repository[models.ScheduledJobState]
+	// FindByName 按任务名查询状态
+	FindByName(ctx context.Context, name string) (*models.ScheduledJobState, error)
+	// Ensure 任务状态不存在时创建，首次创建时以 now 作为已领取的计划执行时间，不补执行此前的计划
+	Ensure(ctx context.Context, name string, now time.Time) (*models.ScheduledJobState, error)
+	// TryLock 锁空闲（或已过期）时由 owner 获取锁直到 until；scheduledAt 非零时要求该计划时间尚未被领取并同时领取，
+	// 保证多个实例中同一计划时间只执行一次
+	TryLock(ctx context.Context, name string, owner string, now time.Time, until time.Time, scheduledAt time.Time) (bool, error)
+	// ExtendLock 锁仍由 owner 持有时续期到 until
+	ExtendLock(ctx context.Context, name string, owner string, until time.Time) (bool, error)
+	// Unlock 释放 owner 持有的锁并记录最近一次执行结果
+	Unlock(ctx context.Context, name string, owner string, run *models.JobRun) error
+}
+
+type scheduledJobRepository struct {
+	Repository[models.ScheduledJobState]
+}
+
+func (r *scheduledJobRepository) FindByName(ctx context.Context, name string) (*models.ScheduledJobState, error) {
+	return r.FindOne(ctx, bson.M{"name": name})
+}
+
+func (r *scheduledJobRepository) Ensure(ctx context.Context, name string, now time.Time) (*models.ScheduledJobState, error) {
+	state, err := r.FindByName(ctx, name)
+	if err != ErrNotFound {
+		return state, err
+	}
+	_, err = r.Insert(ctx, &models.ScheduledJobState{
+		Name:            name,
+		LastScheduledAt: now,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	})
+	// 多个实例同时首次启动时唯一索引冲突，使用已创建的记录
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+	return r.FindByName(ctx, name)
+}
+
+func (r *scheduledJobRepository) TryLock(ctx context.Context, name string, owner string, now time.Time, until time.Time, scheduledAt time.Time) (bool, error) {
+	filter := bson.M{"name": name, "lockedUntil": bson.M{"$lte": now}}
+	set := bson.M{"lockedBy": owner, "lockedUntil": until, "updatedAt": now}
+	if !scheduledAt.IsZero() {
+		filter["lastScheduledAt"] = bson.M{"$lt": scheduledAt}
+		set["lastScheduledAt"] = scheduledAt
+	}
+	result, err := r.UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *scheduledJobRepository) ExtendLock(ctx context.Context, name string, owner string, until time.Time) (bool, error) {
+	result, err := r.UpdateOne(ctx, bson.M{"name": name, "lockedBy": owner}, bson.M{"$set": bson.M{"lockedUntil": until}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *scheduledJobRepository) Unlock(ctx context.Context, name string, owner string, run *models.JobRun) error {
+	_, err := r.UpdateOne(ctx, bson.M{"name": name, "lockedBy": owner}, bson.M{"$set": bson.M{
+		"lockedBy":    "",
+		"lockedUntil": time.Time{},
+		"lastRunId":   run.ID,
+		"lastRunAt":   run.StartedAt,
+		"lastStatus":  run.Status,
+		"updatedAt":   time.Now(),
+	}})
+	return err
+}
+
+// JobRunRepository 定时任务执行记录仓储
+type JobRunRepository interface {
+	Repository[models.JobRun]
+	// MarkInterrupted 将任务遗留的执行中记录标记为中断，需在持有该任务的锁时调用
+	MarkInterrupted(ctx context.Context, jobName string, now time.Time) (*UpdateResult, error)
+}
+
+type jobRunRepository struct {
+	Repository[models.JobRun]
+}
+
+func (r *jobRunRepository) MarkInterrupted(ctx context.Context, jobName string, now time.Time) (*UpdateResult, error) {
+	return r.UpdateMany(ctx, bson.M{"jobName": jobName, "status": models.JobRunStatusRunning}, bson.M{"$set": bson.M{
+		"status":     models.JobRunStatusInterrupted,
+		"finishedAt": now,
+		"error":      "执行实例中途退出，任务未完成",
+	}})
+}
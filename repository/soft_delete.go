@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DeletedAtField 软删除标记字段，存在即表示记录已删除
+const DeletedAtField = "deletedAt"
+
+// NotDeletedFilter 未删除记录的条件
+func NotDeletedFilter() bson.M {
+	return bson.M{DeletedAtField: bson.M{"$exists": false}}
+}
+
+// DeletedFilter 已删除记录的条件
+func DeletedFilter() bson.M {
+	return bson.M{DeletedAtField: bson.M{"$exists": true}}
+}
+
+// excludeDeleted 在业务条件上追加未删除条件
+func excludeDeleted(filter bson.M) bson.M {
+	if len(filter) == 0 {
+		return NotDeletedFilter()
+	}
+	return bson.M{"$and": []bson.M{filter, NotDeletedFilter()}}
+}
+
+// SoftDeleteRepository 支持软删除的仓储：查询类方法自动排除已删除的记录，
+// 更新和删除方法不做限制（由调用方先查询确认记录未删除）
+type SoftDeleteRepository[T any] interface {
+	Repository[T]
+	// Unscoped 返回包含已删除记录的仓储，用于回收站、恢复和清理
+	Unscoped() Repository[T]
+}
+
+type softDeleteRepository[T any] struct {
+	Repository[T]
+}
+
+// newSoftDeleteRepository 为通用仓储增加软删除过滤
+func newSoftDeleteRepository[T any](inner Repository[T]) SoftDeleteRepository[T] {
+	return &softDeleteRepository[T]{inner}
+}
+
+func (r *softDeleteRepository[T]) Unscoped() Repository[T] {
+	return r.Repository
+}
+
+func (r *softDeleteRepository[T]) FindByID(ctx context.Context, id primitive.ObjectID) (*T, error) {
+	return r.Repository.FindOne(ctx, excludeDeleted(bson.M{"_id": id}))
+}
+
+func (r *softDeleteRepository[T]) FindOne(ctx context.Context, filter bson.M, opts ...*FindOptions) (*T, error) {
+	return r.Repository.FindOne(ctx, excludeDeleted(filter), opts...)
+}
+
+func (r *softDeleteRepository[T]) Find(ctx context.Context, filter bson.M, opts ...*FindOptions) ([]T, error) {
+	return r.Repository.Find(ctx, excludeDeleted(filter), opts...)
+}
+
+func (r *softDeleteRepository[T]) Each(ctx context.Context, filter bson.M, fn func(doc *T) error, opts ...*FindOptions) error {
+	return r.Repository.Each(ctx, excludeDeleted(filter), fn, opts...)
+}
+
+func (r *softDeleteRepository[T]) Count(ctx context.Context, filter bson.M) (int64, error) {
+	return r.Repository.Count(ctx, excludeDeleted(filter))
+}
+
+func (r *softDeleteRepository[T]) GroupCount(ctx context.Context, filter bson.M, field string) ([]GroupCount, error) {
+	return r.Repository.GroupCount(ctx, excludeDeleted(filter), field)
+}
+
+func (r *softDeleteRepository[T]) Sum(ctx context.Context, filter bson.M, field string) (float64, error) {
+	return r.Repository.Sum(ctx, excludeDeleted(filter), field)
+}
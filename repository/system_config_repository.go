@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SystemConfigRepository 系统配置仓储
+type SystemConfigRepository interface {
+	Repository[models.SystemConfig]
+	// FindEnabledByType 查询指定类型的已启用配置
+	FindEnabledByType(ctx context.Context, configType models.ConfigType) ([]models.SystemConfig, error)
+}
+
+type systemConfigRepository struct {
+	Repository[models.SystemConfig]
+}
+
+func (r *systemConfigRepository) FindEnabledByType(ctx context.Context, configType models.ConfigType) ([]models.SystemConfig, error) {
+	return r.Find(ctx, bson.M{"configType": configType, "isEnabled": true})
+}
+
+// OperationLogRepository 接口操作日志仓储
+type OperationLogRepository interface {
+	Repository[models.OperationLog]
+}
+
+type operationLogRepository struct {
+	Repository[models.OperationLog]
+}
+
+// RoleRepository 角色仓储
+type RoleRepository interface {
+	Repository[models.Role]
+	// FindByName 按角色标识查询角色
+	FindByName(ctx context.Context, name models.UserRole) (*models.Role, error)
+}
+
+type roleRepository struct {
+	Repository[models.Role]
+}
+
+func (r *roleRepository) FindByName(ctx context.Context, name models.UserRole) (*models.Role, error) {
+	return r.FindOne(ctx, bson.M{"name": name})
+}
+
+// NotificationRepository 站内通知仓储
+type NotificationRepository interface {
+	Repository[models.Notification]
+	// MarkRead 将接收人的一条通知标记为已读
+	MarkRead(ctx context.Context, id primitive.ObjectID, recipientID string) (*UpdateResult, error)
+	// MarkAllRead 将接收人的全部未读通知标记为已读
+	MarkAllRead(ctx context.Context, recipientID string) (*UpdateResult, error)
+}
+
+type notificationRepository struct {
+	Repository[models.Notification]
+}
+
+func (r *notificationRepository) MarkRead(ctx context.Context, id primitive.ObjectID, recipientID string) (*UpdateResult, error) {
+	return r.UpdateOne(ctx, bson.M{"_id": id, "recipientId": recipientID}, bson.M{"$set": bson.M{"read": true, "readAt": time.Now()}})
+}
+
+func (r *notificationRepository) MarkAllRead(ctx context.Context, recipientID string) (*UpdateResult, error) {
+	return r.UpdateMany(ctx, bson.M{"recipientId": recipientID, "read": false}, bson.M{"$set": bson.M{"read": true, "readAt": time.Now()}})
+}
+
+// RecycleRunRepository 公海回收任务报告仓储
+type RecycleRunRepository interface {
+	Repository[models.PublicPoolRecycleRun]
+}
+
+type recycleRunRepository struct {
+	Repository[models.PublicPoolRecycleRun]
+}
+
+// RecycleItemRepository 公海回收报告客户明细仓储，按报告ID关联
+type RecycleItemRepository interface {
+	Repository[models.PublicPoolRecycleItem]
+}
+
+type recycleItemRepository struct {
+	Repository[models.PublicPoolRecycleItem]
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/BerniceZTT/crm_end/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TransactionRunner 多文档事务执行器
+type TransactionRunner interface {
+	// WithTransaction 在事务中执行 fn，fn 返回错误时回滚全部写入；
+	// fn 中的仓储操作必须使用传入的 ctx，且 fn 可能因临时错误被重试，不应修改外部状态
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// transactionKey 标记上下文已处于事务中，嵌套调用时直接复用外层事务
+type transactionKey struct{}
+
+func inTransaction(ctx context.Context) bool {
+	return ctx.Value(transactionKey{}) != nil
+}
+
+// WithTransaction 在全局仓储的事务中执行 fn
+func WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if inTransaction(ctx) {
+		return fn(ctx)
+	}
+	return GetRepositories().Transactions.WithTransaction(ctx, fn)
+}
+
+// mongoTransactionRunner 基于MongoDB会话的事务执行器
+// 事务需要副本集或分片集群，单机部署时退化为顺序执行并记录警告
+type mongoTransactionRunner struct {
+	database *mongo.Database
+
+	mu        sync.Mutex
+	checked   bool
+	supported bool
+}
+
+func newMongoTransactionRunner(database *mongo.Database) *mongoTransactionRunner {
+	return &mongoTransactionRunner{database: database}
+}
+
+// transactionsSupported 通过 hello 命令判断部署是否支持事务，结果在首次成功查询后缓存
+func (r *mongoTransactionRunner) transactionsSupported(ctx context.Context) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.checked {
+		return r.supported, nil
+	}
+
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := r.database.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false, fmt.Errorf("查询MongoDB部署类型失败: %w", err)
+	}
+	r.checked = true
+	r.supported = hello.SetName != "" || hello.Msg == "isdbgrid"
+	if !r.supported {
+		utils.Logger.Warn().Msg("MongoDB为单机部署，不支持多文档事务，相关写入将顺序执行，失败时无法回滚")
+	}
+	return r.supported, nil
+}
+
+func (r *mongoTransactionRunner) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	supported, err := r.transactionsSupported(ctx)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return fn(context.WithValue(ctx, transactionKey{}, true))
+	}
+
+	session, err := r.database.Client().StartSession()
+	if err != nil {
+		return fmt.Errorf("创建MongoDB会话失败: %w", err)
+	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		// 会话随上下文传递，仓储中派生的超时上下文同样在事务内执行
+		return nil, fn(context.WithValue(sc, transactionKey{}, true))
+	})
+	return err
+}
+
+// memorySnapshotter 可保存并恢复数据的内存仓储
+type memorySnapshotter interface {
+	snapshot() func()
+}
+
+// memoryTransactionRunner 内存仓储的事务执行器：事务串行执行，失败时恢复执行前的全部数据
+type memoryTransactionRunner struct {
+	mu     sync.Mutex
+	stores []memorySnapshotter
+}
+
+// trackMemory 登记参与事务回滚的内存仓储
+func trackMemory[T any](tx *memoryTransactionRunner, repo *memoryRepository[T]) *memoryRepository[T] {
+	tx.stores = append(tx.stores, repo)
+	return repo
+}
+
+func (r *memoryTransactionRunner) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	restores := make([]func(), len(r.stores))
+	for i, store := range r.stores {
+		restores[i] = store.snapshot()
+	}
+	if err := fn(context.WithValue(ctx, transactionKey{}, true)); err != nil {
+		for _, restore := range restores {
+			restore()
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// UserRepository 用户仓储
+type UserRepository interface {
+	Repository[models.User]
+	// FindByUsername 按用户名查询用户
+	FindByUsername(ctx context.Context, username string) (*models.User, error)
+	// NamesByIDs 批量查询用户名，返回 ID(Hex) -> 用户名 映射，无效ID会被忽略
+	NamesByIDs(ctx context.Context, ids []string) (map[string]string, error)
+}
+
+type userRepository struct {
+	Repository[models.User]
+}
+
+func (r *userRepository) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.FindOne(ctx, bson.M{"username": username})
+}
+
+func (r *userRepository) NamesByIDs(ctx context.Context, ids []string) (map[string]string, error) {
+	names := make(map[string]string)
+	objectIDs := toObjectIDs(ids)
+	if len(objectIDs) == 0 {
+		return names, nil
+	}
+	users, err := r.Find(ctx, bson.M{"_id": bson.M{"$in": objectIDs}},
+		NewFindOptions().SetProjection(bson.M{"_id": 1, "username": 1}))
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		names[user.ID.Hex()] = user.Username
+	}
+	return names, nil
+}
+
+// AgentRepository 代理商仓储
+type AgentRepository interface {
+	Repository[models.Agent]
+	// FindByCompanyName 按公司名称查询代理商
+	FindByCompanyName(ctx context.Context, companyName string) (*models.Agent, error)
+	// NamesByIDs 批量查询代理商公司名称，返回 ID(Hex) -> 公司名称 映射，无效ID会被忽略
+	NamesByIDs(ctx context.Context, ids []string) (map[string]string, error)
+}
+
+type agentRepository struct {
+	Repository[models.Agent]
+}
+
+func (r *agentRepository) FindByCompanyName(ctx context.Context, companyName string) (*models.Agent, error) {
+	return r.FindOne(ctx, bson.M{"companyName": companyName})
+}
+
+func (r *agentRepository) NamesByIDs(ctx context.Context, ids []string) (map[string]string, error) {
+	names := make(map[string]string)
+	objectIDs := toObjectIDs(ids)
+	if len(objectIDs) == 0 {
+		return names, nil
+	}
+	agents, err := r.Find(ctx, bson.M{"_id": bson.M{"$in": objectIDs}},
+		NewFindOptions().SetProjection(bson.M{"_id": 1, "companyName": 1}))
+	if err != nil {
+		return nil, err
+	}
+	for _, agent := range agents {
+		names[agent.ID.Hex()] = agent.CompanyName
+	}
+	return names, nil
+}
+
+// toObjectIDs 将Hex字符串转换为ObjectID，忽略无效值
+func toObjectIDs(ids []string) []primitive.ObjectID {
+	objectIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if objectID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objectIDs = append(objectIDs, objectID)
+		}
+	}
+	return objectIDs
+}
+
+// RefreshTokenRepository 刷新令牌仓储
+type RefreshTokenRepository interface {
+	Repository[models.RefreshToken]
+	// FindByHash 按令牌哈希查询
+	FindByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	// MarkUsed 将未使用且未吊销的令牌标记为已使用，返回是否标记成功（并发刷新时只有一个请求成功）
+	MarkUsed(ctx context.Context, id primitive.ObjectID, usedAt time.Time) (bool, error)
+	// RevokeFamily 吊销同一家族的全部令牌
+	RevokeFamily(ctx context.Context, familyID string, revokedAt time.Time) (int64, error)
+	// RevokeByAccount 吊销账户的全部令牌
+	RevokeByAccount(ctx context.Context, accountID string, revokedAt time.Time) (int64, error)
+}
+
+type refreshTokenRepository struct {
+	Repository[models.RefreshToken]
+}
+
+func (r *refreshTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	return r.FindOne(ctx, bson.M{"tokenHash": tokenHash})
+}
+
+func (r *refreshTokenRepository) MarkUsed(ctx context.Context, id primitive.ObjectID, usedAt time.Time) (bool, error) {
+	result, err := r.UpdateOne(ctx, bson.M{
+		"_id":       id,
+		"usedAt":    bson.M{"$exists": false},
+		"revokedAt": bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{"usedAt": usedAt}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, familyID string, revokedAt time.Time) (int64, error) {
+	return r.revoke(ctx, bson.M{"familyId": familyID}, revokedAt)
+}
+
+func (r *refreshTokenRepository) RevokeByAccount(ctx context.Context, accountID string, revokedAt time.Time) (int64, error) {
+	return r.revoke(ctx, bson.M{"accountId": accountID}, revokedAt)
+}
+
+func (r *refreshTokenRepository) revoke(ctx context.Context, filter bson.M, revokedAt time.Time) (int64, error) {
+	filter["revokedAt"] = bson.M{"$exists": false}
+	result, err := r.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revokedAt": revokedAt}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// LoginAttemptRepository 登录失败计数仓储
+type LoginAttemptRepository interface {
+	Repository[models.LoginAttempt]
+	// FindByKey 按计数键查询
+	FindByKey(ctx context.Context, key string) (*models.LoginAttempt, error)
+	// RecordFailure 失败次数加一并返回最新记录，记录不存在时创建
+	RecordFailure(ctx context.Context, key string, keyType models.LoginAttemptKeyType, now time.Time, expiresAt time.Time) (*models.LoginAttempt, error)
+	// Lock 锁定到指定时间
+	Lock(ctx context.Context, key string, until time.Time, expiresAt time.Time) error
+	// DeleteByKey 清除计数（登录成功或管理员解锁）
+	DeleteByKey(ctx context.Context, key string) (int64, error)
+}
+
+type loginAttemptRepository struct {
+	Repository[models.LoginAttempt]
+}
+
+func (r *loginAttemptRepository) FindByKey(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	return r.FindOne(ctx, bson.M{"key": key})
+}
+
+func (r *loginAttemptRepository) RecordFailure(ctx context.Context, key string, keyType models.LoginAttemptKeyType, now time.Time, expiresAt time.Time) (*models.LoginAttempt, error) {
+	update := bson.M{
+		"$inc": bson.M{"failures": 1},
+		"$set": bson.M{"lastFailureAt": now, "expiresAt": expiresAt},
+	}
+	result, err := r.UpdateOne(ctx, bson.M{"key": key}, update)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		_, err = r.Insert(ctx, &models.LoginAttempt{
+			Key:            key,
+			KeyType:        keyType,
+			Failures:       1,
+			FirstFailureAt: now,
+			LastFailureAt:  now,
+			ExpiresAt:      expiresAt,
+		})
+		// 并发创建时唯一索引冲突，改为累加已创建的记录
+		if mongo.IsDuplicateKeyError(err) {
+			_, err = r.UpdateOne(ctx, bson.M{"key": key}, update)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r.FindByKey(ctx, key)
+}
+
+func (r *loginAttemptRepository) Lock(ctx context.Context, key string, until time.Time, expiresAt time.Time) error {
+	_, err := r.UpdateOne(ctx, bson.M{"key": key}, bson.M{
+		"$set": bson.M{"lockedUntil": until, "expiresAt": expiresAt},
+		"$inc": bson.M{"lockCount": 1},
+	})
+	return err
+}
+
+func (r *loginAttemptRepository) DeleteByKey(ctx context.Context, key string) (int64, error) {
+	return r.DeleteMany(ctx, bson.M{"key": key})
+}
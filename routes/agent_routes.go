@@ -14,23 +14,23 @@ func RegisterAgentRoutes(router *gin.Engine) {
 	agentRoutes.Use(middleware.AuthMiddleware())
 
 	// 获取所有代理商
-	agentRoutes.GET("", controllers.GetAllAgents)
+	agentRoutes.GET("", middleware.PermissionMiddleware("agents", "read"), controllers.GetAllAgents)
 
 	// 创建代理商(仅超级管理员)
 	agentRoutes.POST("", middleware.PermissionMiddleware("agents", "create"), controllers.CreateAgent)
 
 	// 更新代理商
-	agentRoutes.PUT("/:id", controllers.UpdateAgent)
+	agentRoutes.PUT("/:id", middleware.PermissionMiddleware("agents", "update"), controllers.UpdateAgent)
 
 	// 删除代理商(仅超级管理员)
 	agentRoutes.DELETE("/:id", middleware.PermissionMiddleware("agents", "delete"), controllers.DeleteAgent)
 
 	// 获取特定销售的代理商列表
-	agentRoutes.GET("/by-sales/:salesId", controllers.GetAgentsBySalesId)
+	agentRoutes.GET("/by-sales/:salesId", middleware.PermissionMiddleware("agents", "read"), controllers.GetAgentsBySalesId)
 
 	// 导出代理商列表为CSV(仅超级管理员)
-	agentRoutes.GET("/export/csv", middleware.PermissionMiddleware("exports", "agents"), controllers.ExportAgentsToCSV)
+	agentRoutes.GET("/export/csv", middleware.PermissionMiddleware("agents", "export"), controllers.ExportAgentsToCSV)
 
 	// 获取可分配的代理商列表
-	agentRoutes.GET("/assignable", controllers.GetAssignableAgents)
+	agentRoutes.GET("/assignable", middleware.PermissionMiddleware("agents", "read"), controllers.GetAssignableAgents)
 }
@@ -16,6 +16,8 @@ func RegisterAuthRoutes(router *gin.Engine) {
 	auth.POST("/agent/login", controllers.AgentLogin)
 	auth.POST("/register", controllers.Register)
 	auth.POST("/agent/register", controllers.AgentRegister)
+	auth.POST("/refresh", controllers.RefreshToken)
+	auth.POST("/logout", controllers.Logout)
 
 	// 需要认证的路由
 	auth.GET("/validate", middleware.AuthMiddleware(), controllers.ValidateToken)
@@ -14,5 +14,5 @@ func RegisterChangeCustomerRoutes(router *gin.Engine) {
 	changeRoutes.Use(middleware.AuthMiddleware())
 
 	// 客户分配路由
-	changeRoutes.POST("/:id/assign", controllers.AssignCustomer)
+	changeRoutes.POST("/:id/assign", middleware.PermissionMiddleware("customers", "assign"), controllers.AssignCustomer)
 }
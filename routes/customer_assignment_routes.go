@@ -11,12 +11,15 @@ func RegisterCustomerAssignmentRoutes(router *gin.Engine) {
 	assignmentRoutes := router.Group("/api/customer-assignments")
 	assignmentRoutes.Use(middleware.AuthMiddleware())
 
+	// 检查客户当前归属与最近分配历史是否一致
+	assignmentRoutes.GET("/consistency", middleware.PermissionMiddleware("customers", "assign"), controllers.CheckAssignmentConsistency)
+
 	// 获取指定客户的分配历史记录
-	assignmentRoutes.GET("/:customerId", controllers.GetCustomerAssignmentHistory)
+	assignmentRoutes.GET("/:customerId", middleware.PermissionMiddleware("customers", "read"), controllers.GetCustomerAssignmentHistory)
 
 	// // 添加客户分配历史记录
 	// assignmentRoutes.POST("/", controllers.AddCustomerAssignmentHistory)
 
 	// 获取所有客户分配历史记录（可按条件筛选）
-	assignmentRoutes.GET("/", controllers.GetAllCustomerAssignmentHistory)
+	assignmentRoutes.GET("/", middleware.PermissionMiddleware("customers", "read"), controllers.GetAllCustomerAssignmentHistory)
 }
@@ -12,13 +12,26 @@ func RegisterCustomerRoutes(router *gin.Engine) {
 	customerRoutes := router.Group("/api/customers")
 	customerRoutes.Use(middleware.AuthMiddleware())
 
-	customerRoutes.GET("/", controllers.GetCustomerList)
-	customerRoutes.POST("/check-duplicates", controllers.CheckDuplicateCustomer)
-	customerRoutes.GET("/complete-company-names", controllers.CompleteCompanyNamesHandler)
-	customerRoutes.POST("/", controllers.CreateCustomer)
-	customerRoutes.POST("/bulk-import", controllers.BulkImportCustomers)
-	customerRoutes.GET("/:id", controllers.GetCustomerDetail)
-	customerRoutes.PUT("/:id", controllers.UpdateCustomer)
-	customerRoutes.DELETE("/:id", controllers.DeleteCustomer)
-	customerRoutes.POST("/:id/move-to-public", controllers.MoveCustomerToPublic)
+	customerRoutes.GET("/", middleware.PermissionMiddleware("customers", "read"), controllers.GetCustomerList)
+	customerRoutes.GET("/search", middleware.PermissionMiddleware("customers", "read"), controllers.SearchCustomers)
+	customerRoutes.GET("/export", middleware.PermissionMiddleware("customers", "export"), controllers.ExportCustomers)
+	customerRoutes.POST("/check-duplicates", middleware.PermissionMiddleware("customers", "read"), controllers.CheckDuplicateCustomer)
+	customerRoutes.GET("/complete-company-names", middleware.PermissionMiddleware("customers", "create"), controllers.CompleteCompanyNamesHandler)
+	customerRoutes.POST("/", middleware.PermissionMiddleware("customers", "create"), controllers.CreateCustomer)
+	customerRoutes.POST("/bulk-import", middleware.PermissionMiddleware("customers", "import"), controllers.BulkImportCustomers)
+	customerRoutes.GET("/import/template", middleware.PermissionMiddleware("customers", "import"), controllers.DownloadCustomerImportTemplate)
+	customerRoutes.POST("/import/preview", middleware.PermissionMiddleware("customers", "import"), controllers.PreviewCustomerImport)
+	customerRoutes.GET("/import/:importId", middleware.PermissionMiddleware("customers", "import"), controllers.GetCustomerImport)
+	customerRoutes.POST("/import/:importId/commit", middleware.PermissionMiddleware("customers", "import"), controllers.CommitCustomerImport)
+	customerRoutes.GET("/import/:importId/errors", middleware.PermissionMiddleware("customers", "import"), controllers.DownloadCustomerImportErrors)
+	customerRoutes.POST("/merge", middleware.PermissionMiddleware("customers", "merge"), controllers.MergeCustomers)
+	customerRoutes.GET("/merges", middleware.PermissionMiddleware("customers", "merge"), controllers.GetCustomerMergeRecords)
+	customerRoutes.GET("/trash", middleware.PermissionMiddleware("customers", "trash"), controllers.GetCustomerTrash)
+	customerRoutes.GET("/duplicates/report", middleware.PermissionMiddleware("customers", "merge"), controllers.GetCustomerDuplicateReport)
+	customerRoutes.GET("/:id", middleware.PermissionMiddleware("customers", "read"), controllers.GetCustomerDetail)
+	customerRoutes.GET("/:id/progress-history", middleware.PermissionMiddleware("customers", "read"), controllers.GetCustomerProgressHistory)
+	customerRoutes.PUT("/:id", middleware.PermissionMiddleware("customers", "update"), controllers.UpdateCustomer)
+	customerRoutes.DELETE("/:id", middleware.PermissionMiddleware("customers", "delete"), controllers.DeleteCustomer)
+	customerRoutes.POST("/:id/move-to-public", middleware.PermissionMiddleware("customers", "update"), controllers.MoveCustomerToPublic)
+	customerRoutes.POST("/:id/restore", middleware.PermissionMiddleware("customers", "trash"), controllers.RestoreCustomer)
 }
@@ -12,5 +12,5 @@ func RegisterDashboardStatsRoutes(router *gin.Engine) {
 	dashboardStatsRoutes := router.Group("/api/dashboard-stats")
 	dashboardStatsRoutes.Use(middleware.AuthMiddleware())
 
-	dashboardStatsRoutes.GET("", controllers.GetDashboardStats)
+	dashboardStatsRoutes.GET("", middleware.PermissionMiddleware("dashboard", "read"), controllers.GetDashboardStats)
 }
@@ -13,11 +13,11 @@ func RegisterFollowUpRoutes(router *gin.Engine) {
 	followUpGroup.Use(middleware.AuthMiddleware())
 
 	// 获取某个客户的跟进记录列表
-	followUpGroup.GET("/:customerId", controllers.GetCustomerFollowUpRecords)
+	followUpGroup.GET("/:customerId", middleware.PermissionMiddleware("followUps", "read"), controllers.GetCustomerFollowUpRecords)
 
 	// 创建跟进记录
-	followUpGroup.POST("/", controllers.CreateFollowUpRecord)
+	followUpGroup.POST("/", middleware.PermissionMiddleware("followUps", "create"), controllers.CreateFollowUpRecord)
 
 	// 删除跟进记录
-	followUpGroup.DELETE("/:id", controllers.DeleteFollowUpRecord)
+	followUpGroup.DELETE("/:id", middleware.PermissionMiddleware("followUps", "delete"), controllers.DeleteFollowUpRecord)
 }
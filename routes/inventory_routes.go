@@ -14,8 +14,8 @@ func RegisterInventoryRoutes(router *gin.Engine) {
 	inventoryRoutes.Use(middleware.AuthMiddleware())
 
 	// 获取库存操作记录
-	inventoryRoutes.GET("/records", controllers.GetInventoryRecords)
+	inventoryRoutes.GET("/records", middleware.PermissionMiddleware("inventory", "read"), controllers.GetInventoryRecords)
 
 	// 获取库存统计信息
-	inventoryRoutes.GET("/stats", controllers.GetInventoryStats)
+	inventoryRoutes.GET("/stats", middleware.PermissionMiddleware("inventory", "read"), controllers.GetInventoryStats)
 }
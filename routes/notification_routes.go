@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/BerniceZTT/crm_end/controllers"
+	"github.com/BerniceZTT/crm_end/middleware"
+)
+
+// RegisterNotificationRoutes 注册站内通知路由，只能查看和处理自己的通知
+func RegisterNotificationRoutes(router *gin.Engine) {
+	notificationGroup := router.Group("/api/notifications")
+
+	notificationGroup.Use(middleware.AuthMiddleware())
+
+	notificationGroup.GET("", middleware.PermissionMiddleware("notifications", "read"), controllers.GetNotifications)
+	notificationGroup.GET("/unread-count", middleware.PermissionMiddleware("notifications", "read"), controllers.GetUnreadNotificationCount)
+	notificationGroup.POST("/read-all", middleware.PermissionMiddleware("notifications", "update"), controllers.MarkAllNotificationsRead)
+	notificationGroup.POST("/:id/read", middleware.PermissionMiddleware("notifications", "update"), controllers.MarkNotificationRead)
+}
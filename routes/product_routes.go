@@ -12,32 +12,32 @@ func RegisterProductRoutes(router *gin.Engine) {
 	productGroup.Use(middleware.AuthMiddleware())
 
 	// 获取产品列表
-	productGroup.GET("", controllers.GetProductList)
+	productGroup.GET("", middleware.PermissionMiddleware("products", "read"), controllers.GetProductList)
 
 	// 获取单个产品
-	productGroup.GET("/:id", controllers.GetProduct)
+	productGroup.GET("/:id", middleware.PermissionMiddleware("products", "read"), controllers.GetProduct)
 
 	// 创建产品
-	productGroup.POST("", controllers.CreateProduct)
+	productGroup.POST("", middleware.PermissionMiddleware("products", "create"), controllers.CreateProduct)
 
 	// 批量导入产品
-	productGroup.POST("/bulk-import", controllers.BulkImportProducts)
+	productGroup.POST("/bulk-import", middleware.PermissionMiddleware("products", "import"), controllers.BulkImportProducts)
 
 	// 更新产品
-	productGroup.PUT("/:id", controllers.UpdateProduct)
+	productGroup.PUT("/:id", middleware.PermissionMiddleware("products", "update"), controllers.UpdateProduct)
 
 	// 删除产品
-	productGroup.DELETE("/:id", controllers.DeleteProduct)
+	productGroup.DELETE("/:id", middleware.PermissionMiddleware("products", "delete"), controllers.DeleteProduct)
 
 	// 入库操作
-	productGroup.POST("/:id/stock-in", controllers.StockInProduct)
+	productGroup.POST("/:id/stock-in", middleware.PermissionMiddleware("inventory", "create"), controllers.StockInProduct)
 
 	// 出库操作
-	productGroup.POST("/:id/stock-out", controllers.StockOutProduct)
+	productGroup.POST("/:id/stock-out", middleware.PermissionMiddleware("inventory", "create"), controllers.StockOutProduct)
 
 	// 批量库存操作
-	productGroup.POST("/bulk-stock", controllers.BulkStockProduct)
+	productGroup.POST("/bulk-stock", middleware.PermissionMiddleware("inventory", "create"), controllers.BulkStockProduct)
 
 	// 产品数据导出
-	productGroup.GET("/export", controllers.ExportProduct)
+	productGroup.GET("/export", middleware.PermissionMiddleware("products", "export"), controllers.ExportProduct)
 }
@@ -11,12 +11,12 @@ func RegisterProjectFilesRoutes(router *gin.Engine) {
 	projectFilesGroup.Use(middleware.AuthMiddleware())
 
 	// 专用文件上传接口
-	projectFilesGroup.POST("/upload", controllers.UploadFile)
+	projectFilesGroup.POST("/upload", middleware.PermissionMiddleware("files", "create"), controllers.UploadFile)
 
 	// 文件下载接口
-	projectFilesGroup.GET("/download/:fileId", controllers.DownloadFile)
+	projectFilesGroup.GET("/download/:fileId", middleware.PermissionMiddleware("files", "read"), controllers.DownloadFile)
 
 	// 删除文件接口
-	projectFilesGroup.DELETE("/:fileId", controllers.DeleteFile)
+	projectFilesGroup.DELETE("/:fileId", middleware.PermissionMiddleware("files", "delete"), controllers.DeleteFile)
 
 }
@@ -12,7 +12,7 @@ func RegisterProjectFollowUpRoutes(router *gin.Engine) {
 	followUpGroup := router.Group("/api/projectFollowUpRecords")
 	followUpGroup.Use(middleware.AuthMiddleware())
 
-	followUpGroup.GET("/:projectId", controllers.GetProjectFollowUpRecords)
-	followUpGroup.POST("/", controllers.CreateProjectFollowUpRecord)
-	followUpGroup.DELETE("/:id", controllers.DeleteProjectFollowUpRecord)
+	followUpGroup.GET("/:projectId", middleware.PermissionMiddleware("projects", "read"), controllers.GetProjectFollowUpRecords)
+	followUpGroup.POST("/", middleware.PermissionMiddleware("projects", "read"), controllers.CreateProjectFollowUpRecord)
+	followUpGroup.DELETE("/:id", middleware.PermissionMiddleware("projects", "read"), controllers.DeleteProjectFollowUpRecord)
 }
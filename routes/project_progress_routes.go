@@ -12,7 +12,7 @@ func RegisterProjectProgressRoutes(router *gin.Engine) {
 
 	projectProgressGroup.Use(middleware.AuthMiddleware())
 
-	projectProgressGroup.GET("/:projectId", controllers.GetProjectProgressHistory)
-	projectProgressGroup.GET("/", controllers.GetAllProjectProgressHistory)
-	projectProgressGroup.POST("/", controllers.AddProjectProgressHistory)
+	projectProgressGroup.GET("/:projectId", middleware.PermissionMiddleware("projects", "read"), controllers.GetProjectProgressHistory)
+	projectProgressGroup.GET("/", middleware.PermissionMiddleware("projects", "read"), controllers.GetAllProjectProgressHistory)
+	projectProgressGroup.POST("/", middleware.PermissionMiddleware("projects", "update"), controllers.AddProjectProgressHistory)
 }
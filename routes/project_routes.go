@@ -12,11 +12,11 @@ func RegisterProjectRoutes(router *gin.Engine) {
 
 	projectGroup.Use(middleware.AuthMiddleware())
 
-	projectGroup.GET("", controllers.GetAllProjects)
-	projectGroup.GET("/customer/:customerId", controllers.GetCustomerProjects)
-	projectGroup.GET("/download/:projectId/:fileId", controllers.DownloadProjectFile)
-	projectGroup.GET("/:id", controllers.GetProjectDetail)
-	projectGroup.POST("", controllers.CreateProject)
-	projectGroup.PUT("/:id", controllers.UpdateProject)
-	projectGroup.DELETE("/:id", controllers.DeleteProject)
+	projectGroup.GET("", middleware.PermissionMiddleware("projects", "read"), controllers.GetAllProjects)
+	projectGroup.GET("/customer/:customerId", middleware.PermissionMiddleware("projects", "read"), controllers.GetCustomerProjects)
+	projectGroup.GET("/download/:projectId/:fileId", middleware.PermissionMiddleware("projects", "read"), controllers.DownloadProjectFile)
+	projectGroup.GET("/:id", middleware.PermissionMiddleware("projects", "read"), controllers.GetProjectDetail)
+	projectGroup.POST("", middleware.PermissionMiddleware("projects", "create"), controllers.CreateProject)
+	projectGroup.PUT("/:id", middleware.PermissionMiddleware("projects", "update"), controllers.UpdateProject)
+	projectGroup.DELETE("/:id", middleware.PermissionMiddleware("projects", "delete"), controllers.DeleteProject)
 }
@@ -16,8 +16,22 @@ func RegisterPublicPoolRoutes(router *gin.Engine) {
 	publicPoolGroup.Use(middleware.AuthMiddleware())
 
 	// 获取公海客户列表
-	publicPoolGroup.GET("", controllers.GetPublicPoolCustomers)
+	publicPoolGroup.GET("", middleware.PermissionMiddleware("publicPool", "read"), controllers.GetPublicPoolCustomers)
+
+	// 当前用户的认领额度
+	publicPoolGroup.GET("/claim-quota", middleware.PermissionMiddleware("publicPool", "claim"), controllers.GetPublicPoolClaimQuota)
+
+	// 认领公海客户
+	publicPoolGroup.POST("/:id/claim", middleware.PermissionMiddleware("publicPool", "claim"), controllers.ClaimPublicPoolCustomer)
+
+	// 恢复公海客户的原归属
+	publicPoolGroup.POST("/:id/restore", middleware.PermissionMiddleware("publicPool", "restore"), controllers.RestorePublicPoolCustomer)
+
+	// 公海自动回收报告
+	publicPoolGroup.GET("/recycle-runs", middleware.PermissionMiddleware("publicPool", "recycle"), controllers.GetPublicPoolRecycleRuns)
+	publicPoolGroup.GET("/recycle-runs/:id", middleware.PermissionMiddleware("publicPool", "recycle"), controllers.GetPublicPoolRecycleRun)
+	publicPoolGroup.GET("/recycle-runs/:id/items", middleware.PermissionMiddleware("publicPool", "recycle"), controllers.GetPublicPoolRecycleItems)
 
 	// 获取可分配的销售人员列表
-	publicPoolGroup.GET("/assignable-users", controllers.GetAssignableUsers)
+	publicPoolGroup.GET("/assignable-users", middleware.PermissionMiddleware("customers", "assign"), controllers.GetAssignableUsers)
 }
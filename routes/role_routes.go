@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/BerniceZTT/crm_end/controllers"
+	"github.com/BerniceZTT/crm_end/middleware"
+)
+
+// RegisterRoleRoutes 注册角色权限管理路由
+func RegisterRoleRoutes(router *gin.Engine) {
+	roleRoutes := router.Group("/api/roles")
+
+	roleRoutes.Use(middleware.AuthMiddleware())
+
+	roleRoutes.GET("", middleware.PermissionMiddleware("roles", "read"), controllers.GetRoles)
+	roleRoutes.GET("/catalog", middleware.PermissionMiddleware("roles", "read"), controllers.GetPermissionCatalog)
+	roleRoutes.GET("/:id", middleware.PermissionMiddleware("roles", "read"), controllers.GetRoleDetail)
+	roleRoutes.POST("", middleware.PermissionMiddleware("roles", "create"), controllers.CreateRole)
+	roleRoutes.PUT("/:id", middleware.PermissionMiddleware("roles", "update"), controllers.UpdateRole)
+	roleRoutes.DELETE("/:id", middleware.PermissionMiddleware("roles", "delete"), controllers.DeleteRole)
+}
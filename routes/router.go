@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"github.com/BerniceZTT/crm_end/middleware"
 	"github.com/BerniceZTT/crm_end/repository"
 	"github.com/BerniceZTT/crm_end/utils"
 
@@ -29,6 +30,9 @@ func RegisterRoutes(router *gin.Engine) {
 	RegisterProjectFollowUpRoutes(router)
 	RegisterProjectProgressRoutes(router)
 	RegisterSystemConfigtRoutes(router)
+	RegisterRoleRoutes(router)
+	RegisterNotificationRoutes(router)
+	RegisterSchedulerRoutes(router)
 
 	// 健康检查路由
 	router.GET("/api/health", func(c *gin.Context) {
@@ -36,8 +40,8 @@ func RegisterRoutes(router *gin.Engine) {
 	})
 
 	// 数据库状态检查路由
-	router.GET("/api/db-status", func(c *gin.Context) {
-		status, err := repository.GetDatabaseStatus()
+	router.GET("/api/db-status", middleware.AuthMiddleware(), middleware.PermissionMiddleware("system", "read"), func(c *gin.Context) {
+		status, err := repository.GetDatabaseStatus(c.Request.Context())
 		if err != nil {
 			utils.ErrorResponse(c, "获取数据库状态失败: "+err.Error(), 500)
 			return
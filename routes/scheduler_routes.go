@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/BerniceZTT/crm_end/controllers"
+	"github.com/BerniceZTT/crm_end/middleware"
+)
+
+// RegisterSchedulerRoutes 注册定时任务管理路由
+func RegisterSchedulerRoutes(router *gin.Engine) {
+	schedulerGroup := router.Group("/api/scheduler")
+
+	// 所有路由都需要认证
+	schedulerGroup.Use(middleware.AuthMiddleware())
+
+	// 定时任务列表
+	schedulerGroup.GET("/jobs", middleware.PermissionMiddleware("scheduler", "read"), controllers.GetScheduledJobs)
+
+	// 定时任务执行记录
+	schedulerGroup.GET("/jobs/:name/runs", middleware.PermissionMiddleware("scheduler", "read"), controllers.GetScheduledJobRuns)
+	schedulerGroup.GET("/runs/:id", middleware.PermissionMiddleware("scheduler", "read"), controllers.GetScheduledJobRun)
+
+	// 预览下一次客户自动转移，涉及全公司客户，只对定时任务管理员开放
+	schedulerGroup.GET("/auto-transfer/preview", middleware.PermissionMiddleware("scheduler", "read"), controllers.PreviewAutoTransfer)
+
+	// 手动执行定时任务
+	schedulerGroup.POST("/jobs/:name/run", middleware.PermissionMiddleware("scheduler", "run"), controllers.RunScheduledJob)
+}
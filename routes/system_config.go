@@ -13,11 +13,11 @@ func RegisterSystemConfigtRoutes(router *gin.Engine) {
 
 	systemConfigtRoutes.Use(middleware.AuthMiddleware())
 
-	systemConfigtRoutes.GET("", controllers.GetAllConfigs)
-	systemConfigtRoutes.GET("/type/:configType", controllers.GetConfigsByType)
-	systemConfigtRoutes.GET("/:id", controllers.GetConfigDetail)
-	systemConfigtRoutes.POST("", controllers.CreateConfig)
-	systemConfigtRoutes.PUT("/:id", controllers.UpdateConfig)
-	systemConfigtRoutes.DELETE("/:id", controllers.DeleteConfig)
-	systemConfigtRoutes.PATCH("/:id/toggle", controllers.ToggleConfigStatus)
+	systemConfigtRoutes.GET("", middleware.PermissionMiddleware("systemConfigs", "read"), controllers.GetAllConfigs)
+	systemConfigtRoutes.GET("/type/:configType", middleware.PermissionMiddleware("systemConfigs", "read"), controllers.GetConfigsByType)
+	systemConfigtRoutes.GET("/:id", middleware.PermissionMiddleware("systemConfigs", "read"), controllers.GetConfigDetail)
+	systemConfigtRoutes.POST("", middleware.PermissionMiddleware("systemConfigs", "create"), controllers.CreateConfig)
+	systemConfigtRoutes.PUT("/:id", middleware.PermissionMiddleware("systemConfigs", "update"), controllers.UpdateConfig)
+	systemConfigtRoutes.DELETE("/:id", middleware.PermissionMiddleware("systemConfigs", "delete"), controllers.DeleteConfig)
+	systemConfigtRoutes.PATCH("/:id/toggle", middleware.PermissionMiddleware("systemConfigs", "update"), controllers.ToggleConfigStatus)
 }
@@ -16,11 +16,14 @@ func RegisterUserRoutes(router *gin.Engine) {
 	users.GET("/", middleware.PermissionMiddleware("users", "read"), controllers.GetAllUsers)
 
 	// 获取所有销售人员
-	users.GET("/sales", controllers.GetSalesUsers)
+	users.GET("/sales", middleware.PermissionMiddleware("users", "lookup"), controllers.GetSalesUsers)
 
 	// 获取待审批用户 (仅超级管理员)
 	users.GET("/pending/approval", middleware.PermissionMiddleware("users", "read"), controllers.GetPendingApprovalUsers)
 
+	// 密码存储格式迁移报告 (仅超级管理员)
+	users.GET("/password-hash-report", middleware.PermissionMiddleware("users", "read"), controllers.GetPasswordHashReport)
+
 	// 审批用户 (仅超级管理员)
 	users.POST("/approve", middleware.PermissionMiddleware("users", "update"), controllers.ApproveUser)
 
@@ -30,6 +33,12 @@ func RegisterUserRoutes(router *gin.Engine) {
 	// 更新用户 (仅超级管理员)
 	users.PUT("/:id", middleware.PermissionMiddleware("users", "update"), controllers.UpdateUser)
 
+	// 解除登录锁定 (仅超级管理员)
+	users.POST("/:id/unlock", middleware.PermissionMiddleware("users", "update"), controllers.UnlockUser)
+
+	// 离职交接 (仅超级管理员)
+	users.POST("/:id/handover", middleware.PermissionMiddleware("users", "update"), controllers.HandoverUser)
+
 	// 删除用户 (仅超级管理员)
 	users.DELETE("/:id", middleware.PermissionMiddleware("users", "delete"), controllers.DeleteUser)
 }
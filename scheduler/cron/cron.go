@@ -0,0 +1,314 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 表达式格式：
+//   5 段  分 时 日 月 周
+//   6 段  秒 分 时 日 月 周
+// 每段支持 *、?、数字、a-b 范围、,列表 和 /步长，月份和星期可用英文缩写（jan、mon），星期 0 和 7 都表示周日
+// 另支持 @yearly @monthly @weekly @daily @hourly 简写
+// 日和周都不是 * 时按标准 cron 语义取并集：满足其中之一即执行
+// 夏令时切换时，指定了小时的任务在跳过的时段改在切换后执行，在重复的时段只执行一次；
+// 小时为 * 的任务按实际经过的时间执行
+
+// searchYears 查找下一次执行时间的最大跨度，超过视为永不执行（如 2 月 30 日）
+const searchYears = 5
+
+// allHours 小时段为 * 时的位图
+const allHours = 1<<24 - 1
+
+// Schedule 解析后的 cron 表达式，按所传时间的时区计算
+type Schedule struct {
+	expr string
+
+	second, minute, hour, dom, month, dow uint64
+	domStar, dowStar                      bool
+}
+
+type bounds struct {
+	name     string
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	secondBounds = bounds{name: "秒", min: 0, max: 59}
+	minuteBounds = bounds{name: "分", min: 0, max: 59}
+	hourBounds   = bounds{name: "时", min: 0, max: 23}
+	domBounds    = bounds{name: "日", min: 1, max: 31}
+	monthBounds  = bounds{name: "月", min: 1, max: 12, names: map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowBounds = bounds{name: "周", min: 0, max: 7, names: map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var shorthands = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// Parse 解析 cron 表达式
+func Parse(expr string) (*Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if full, ok := shorthands[strings.ToLower(spec)]; ok {
+		spec = full
+	}
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron 表达式 %q 无效: 应为 5 段（分 时 日 月 周）或 6 段（秒 分 时 日 月 周）", expr)
+	}
+
+	s := &Schedule{expr: strings.TrimSpace(expr)}
+	targets := []struct {
+		bits   *uint64
+		bounds bounds
+	}{
+		{&s.second, secondBounds},
+		{&s.minute, minuteBounds},
+		{&s.hour, hourBounds},
+		{&s.dom, domBounds},
+		{&s.month, monthBounds},
+		{&s.dow, dowBounds},
+	}
+	for i, target := range targets {
+		bits, err := parseField(fields[i], target.bounds)
+		if err != nil {
+			return nil, fmt.Errorf("cron 表达式 %q 无效: %w", expr, err)
+		}
+		*target.bits = bits
+	}
+	// 星期 7 与 0 同为周日
+	if s.dow&(1<<7) != 0 {
+		s.dow = (s.dow | 1) &^ (1 << 7)
+	}
+	s.domStar = isStar(fields[3])
+	s.dowStar = isStar(fields[5])
+	return s, nil
+}
+
+// String 原始表达式
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+func isStar(field string) bool {
+	return field == "*" || field == "?"
+}
+
+// parseField 解析一段，返回允许取值的位图
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		partBits, err := parseRange(part, b)
+		if err != nil {
+			return 0, err
+		}
+		bits |= partBits
+	}
+	return bits, nil
+}
+
+// parseRange 解析 *、n、a-b 及其 /step 形式
+func parseRange(expr string, b bounds) (uint64, error) {
+	rangeAndStep := strings.Split(expr, "/")
+	if len(rangeAndStep) > 2 {
+		return 0, fmt.Errorf("%s段 %q 格式错误", b.name, expr)
+	}
+	lowAndHigh := strings.Split(rangeAndStep[0], "-")
+	if len(lowAndHigh) > 2 {
+		return 0, fmt.Errorf("%s段 %q 格式错误", b.name, expr)
+	}
+
+	var start, end uint
+	var err error
+	if isStar(lowAndHigh[0]) {
+		if len(lowAndHigh) > 1 {
+			return 0, fmt.Errorf("%s段 %q 格式错误", b.name, expr)
+		}
+		start, end = b.min, b.max
+		if b.max == 7 {
+			// 星期的 * 不重复包含 7
+			end = 6
+		}
+	} else {
+		if start, err = parseValue(lowAndHigh[0], b); err != nil {
+			return 0, err
+		}
+		end = start
+		if len(lowAndHigh) == 2 {
+			if end, err = parseValue(lowAndHigh[1], b); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	step := uint(1)
+	if len(rangeAndStep) == 2 {
+		n, err := strconv.ParseUint(rangeAndStep[1], 10, 32)
+		if err != nil || n == 0 {
+			return 0, fmt.Errorf("%s段 %q 的步长必须是正整数", b.name, expr)
+		}
+		step = uint(n)
+		// n/step 表示从 n 开始到最大值
+		if len(lowAndHigh) == 1 && !isStar(lowAndHigh[0]) {
+			end = b.max
+		}
+	}
+
+	if start > end {
+		return 0, fmt.Errorf("%s段 %q 起始值大于结束值", b.name, expr)
+	}
+	var bits uint64
+	for i := start; i <= end; i += step {
+		bits |= 1 << i
+	}
+	return bits, nil
+}
+
+// parseValue 解析数字或英文缩写并检查范围
+func parseValue(value string, b bounds) (uint, error) {
+	if n, ok := b.names[strings.ToLower(value)]; ok {
+		return n, nil
+	}
+	n, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%s段 %q 不是有效的数值", b.name, value)
+	}
+	if uint(n) < b.min || uint(n) > b.max {
+		return 0, fmt.Errorf("%s段 %d 超出范围 %d-%d", b.name, n, b.min, b.max)
+	}
+	return uint(n), nil
+}
+
+// Next 严格晚于 t 的下一次执行时间，使用 t 的时区；找不到时返回零值
+func (s *Schedule) Next(t time.Time) time.Time {
+	for {
+		next := s.next(t)
+		// 夏令时结束时同一钟面时间出现两次，指定了小时的任务只在第一次执行
+		if next.IsZero() || s.hour == allHours || !repeatedWallClock(next) {
+			return next
+		}
+		t = next
+	}
+}
+
+// repeatedWallClock t 的钟面时间是否在更早的时刻（夏令时结束前）已经出现过
+func repeatedWallClock(t time.Time) bool {
+	_, offset := t.Zone()
+	_, before := t.Add(-2 * time.Hour).Zone()
+	if before <= offset {
+		return false
+	}
+	earlier := t.Add(-time.Duration(before-offset) * time.Second)
+	return earlier.Hour() == t.Hour() && earlier.Minute() == t.Minute() && earlier.Second() == t.Second()
+}
+
+// next 按钟面时间逐级查找下一次执行时间
+func (s *Schedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	// 从下一整秒开始查找
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	added := false
+	yearLimit := t.Year() + searchYears
+
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for s.month&(1<<uint(t.Month())) == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+
+	for !s.dayMatches(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 0, 1)
+		// 夏令时切换可能使零点不存在，校正回当天零点附近
+		if t.Hour() != 0 {
+			if t.Hour() > 12 {
+				t = t.Add(time.Duration(24-t.Hour()) * time.Hour)
+			} else {
+				t = t.Add(-time.Duration(t.Hour()) * time.Hour)
+			}
+		}
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+
+	for s.hour&(1<<uint(t.Hour())) == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+		day, skipped := t.Day(), (t.Hour()+1)%24
+		t = t.Add(time.Hour)
+		if t.Day() != day {
+			goto WRAP
+		}
+		// 夏令时开始时跳过的小时在当天不存在，计划在该小时的任务改在切换后执行
+		if t.Hour() != skipped && s.hour&(1<<uint(skipped)) != 0 {
+			break
+		}
+	}
+
+	for s.minute&(1<<uint(t.Minute())) == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+
+	for s.second&(1<<uint(t.Second())) == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+	return t
+}
+
+// dayMatches 日和周都有限定时满足其一即可，否则两者都要满足
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,167 @@
+package cron
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// at 按给定时区解析 "2006-01-02 15:04:05" 格式的时间
+func at(t *testing.T, loc *time.Location, value string) time.Time {
+	t.Helper()
+	parsed, err := time.ParseInLocation("2006-01-02 15:04:05", value, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestNext(t *testing.T) {
+	cases := []struct {
+		name string
+		spec string
+		from string
+		want string
+	}{
+		{name: "5 段", spec: "0 9 * * *", from: "2024-01-15 08:30:00", want: "2024-01-15 09:00:00"},
+		{name: "5 段严格晚于起点", spec: "0 9 * * *", from: "2024-01-15 09:00:00", want: "2024-01-16 09:00:00"},
+		{name: "6 段含秒", spec: "30 0 9 * * *", from: "2024-01-15 09:00:00", want: "2024-01-15 09:00:30"},
+		{name: "@yearly", spec: "@yearly", from: "2024-01-15 09:00:00", want: "2025-01-01 00:00:00"},
+		{name: "@monthly", spec: "@monthly", from: "2024-01-15 09:00:00", want: "2024-02-01 00:00:00"},
+		{name: "@weekly 为周日", spec: "@weekly", from: "2024-01-15 09:00:00", want: "2024-01-21 00:00:00"},
+		{name: "@daily", spec: "@daily", from: "2024-01-15 09:00:00", want: "2024-01-16 00:00:00"},
+		{name: "@hourly", spec: "@hourly", from: "2024-01-15 09:00:00", want: "2024-01-15 10:00:00"},
+		{name: "简写不区分大小写", spec: "@Daily", from: "2024-01-15 09:00:00", want: "2024-01-16 00:00:00"},
+		{name: "*/step", spec: "*/15 * * * *", from: "2024-01-15 09:07:00", want: "2024-01-15 09:15:00"},
+		{name: "n/step 从 n 到最大值", spec: "5/20 * * * *", from: "2024-01-15 09:30:00", want: "2024-01-15 09:45:00"},
+		{name: "n/step 超过最大值后进位", spec: "5/20 * * * *", from: "2024-01-15 09:45:00", want: "2024-01-15 10:05:00"},
+		{name: "范围加步长", spec: "0 9-17/4 * * *", from: "2024-01-15 10:00:00", want: "2024-01-15 13:00:00"},
+		{name: "列表", spec: "0 8,12,18 * * *", from: "2024-01-15 12:00:00", want: "2024-01-15 18:00:00"},
+		{name: "月份缩写", spec: "0 0 1 jan,jul *", from: "2024-02-01 00:00:00", want: "2024-07-01 00:00:00"},
+		{name: "星期 7 为周日", spec: "0 0 * * 7", from: "2024-01-15 00:00:00", want: "2024-01-21 00:00:00"},
+		{name: "星期范围含 7", spec: "0 0 * * 5-7", from: "2024-01-20 00:00:00", want: "2024-01-21 00:00:00"},
+		{name: "星期缩写", spec: "0 0 * * sun", from: "2024-01-15 00:00:00", want: "2024-01-21 00:00:00"},
+		{name: "星期 * 加步长不重复周日", spec: "0 0 * * */2", from: "2024-01-20 00:00:00", want: "2024-01-21 00:00:00"},
+		{name: "日和周取并集：周先到", spec: "0 0 13 * 5", from: "2024-01-15 00:00:00", want: "2024-01-19 00:00:00"},
+		{name: "日和周取并集：日先到", spec: "0 0 13 * 5", from: "2024-02-10 00:00:00", want: "2024-02-13 00:00:00"},
+		{name: "周为 ? 时只看日", spec: "0 0 0 13 * ?", from: "2024-01-15 00:00:00", want: "2024-02-13 00:00:00"},
+		{name: "日为 * 时只看周", spec: "0 0 * * 5", from: "2024-02-10 00:00:00", want: "2024-02-16 00:00:00"},
+		{name: "2 月 29 日", spec: "0 0 29 2 *", from: "2024-03-01 00:00:00", want: "2028-02-29 00:00:00"},
+		{name: "跨年", spec: "0 0 1 1 *", from: "2024-12-31 23:59:59", want: "2025-01-01 00:00:00"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			schedule, err := Parse(tc.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tc.spec, err)
+			}
+			got := schedule.Next(at(t, time.UTC, tc.from))
+			if want := at(t, time.UTC, tc.want); !got.Equal(want) {
+				t.Fatalf("Next(%s) = %s, want %s", tc.from, got, want)
+			}
+		})
+	}
+}
+
+func TestNextNeverMatches(t *testing.T) {
+	for _, spec := range []string{"0 0 30 2 *", "0 0 31 4,6,9,11 *"} {
+		schedule, err := Parse(spec)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", spec, err)
+		}
+		if got := schedule.Next(at(t, time.UTC, "2024-01-01 00:00:00")); !got.IsZero() {
+			t.Errorf("%s: Next = %s, want zero time", spec, got)
+		}
+	}
+}
+
+func TestNextDaylightSaving(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2024-03-10 02:00 EST 跳到 03:00 EDT；2024-11-03 02:00 EDT 回到 01:00 EST
+	cases := []struct {
+		name string
+		spec string
+		from time.Time
+		want []time.Time
+	}{
+		{
+			name: "每日任务跨夏令时开始保持钟面时间",
+			spec: "0 9 * * *",
+			from: at(t, ny, "2024-03-09 09:00:00"),
+			want: []time.Time{at(t, ny, "2024-03-10 09:00:00"), at(t, ny, "2024-03-11 09:00:00")},
+		},
+		{
+			name: "跳过时段内的任务改在切换后执行",
+			spec: "30 2 * * *",
+			from: at(t, ny, "2024-03-09 03:00:00"),
+			want: []time.Time{
+				time.Date(2024, 3, 10, 7, 30, 0, 0, time.UTC), // 03:30 EDT
+				at(t, ny, "2024-03-11 02:30:00"),
+			},
+		},
+		{
+			name: "重复时段内的任务只执行一次",
+			spec: "30 1 * * *",
+			from: at(t, ny, "2024-11-03 00:00:00"),
+			want: []time.Time{
+				time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC), // 01:30 EDT
+				at(t, ny, "2024-11-04 01:30:00"),
+			},
+		},
+		{
+			name: "小时为 * 的任务按实际时间执行",
+			spec: "0 * * * *",
+			from: time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC), // 01:30 EDT
+			want: []time.Time{
+				time.Date(2024, 11, 3, 6, 0, 0, 0, time.UTC), // 01:00 EST
+				time.Date(2024, 11, 3, 7, 0, 0, 0, time.UTC), // 02:00 EST
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			schedule, err := Parse(tc.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tc.spec, err)
+			}
+			from := tc.from.In(ny)
+			for _, want := range tc.want {
+				got := schedule.Next(from)
+				if !got.Equal(want) {
+					t.Fatalf("Next(%s) = %s, want %s", from, got, want.In(ny))
+				}
+				if got.Location() != ny {
+					t.Fatalf("Next(%s) 时区为 %s", from, got.Location())
+				}
+				from = got
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalidSpecs(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"@every 5m",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"1-2-3 * * * *",
+		"*-5 * * * *",
+		"a * * * *",
+		"1/2/3 * * * *",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) 应返回错误", spec)
+		}
+	}
+}
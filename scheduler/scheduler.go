@@ -0,0 +1,429 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/config"
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/scheduler/cron"
+	"github.com/BerniceZTT/crm_end/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrCodeJobRunning 任务正在执行（本实例或其他实例），不能重复执行
+const ErrCodeJobRunning = "JOB_RUNNING"
+
+// finishTimeout 任务结束后写入执行记录和释放锁的最长时间，不受任务上下文取消的影响
+const finishTimeout = 10 * time.Second
+
+// JobFunc 任务执行函数，返回的计数（如处理、失败的客户数）写入执行记录
+type JobFunc func(ctx context.Context) (map[string]int64, error)
+
+// Job 按名称注册的定时任务
+type Job struct {
+	Name        string
+	Description string
+	Schedule    string        // cron 表达式
+	Timeout     time.Duration // 单次执行的最长时间，为 0 时不限制
+	Run         JobFunc
+}
+
+// Options 调度器配置
+type Options struct {
+	// Instance 当前实例标识，写入锁和执行记录，为空时使用 DefaultInstance
+	Instance string
+	// Enabled 是否按计划执行，停用时仍可手动执行
+	Enabled bool
+	// CatchUp 启动时对错过的执行的处理，取值同 config.CatchUpOnce/CatchUpSkip
+	CatchUp string
+	// CatchUpWindow 只补执行此时长内错过的执行
+	CatchUpWindow time.Duration
+	// LockTTL 任务锁的有效期，执行期间每 LockTTL/3 续期一次
+	LockTTL time.Duration
+}
+
+type registeredJob struct {
+	Job
+	schedule *cron.Schedule
+}
+
+// Scheduler 定时任务调度器
+// 每个任务的执行以 MongoDB 中的任务状态作为分布式锁，多实例部署时同一计划时间只有一个实例执行
+type Scheduler struct {
+	opts Options
+
+	mu    sync.RWMutex
+	jobs  map[string]*registeredJob
+	order []string
+	// baseCtx Start 传入的上下文，手动执行的任务也在其下运行，不随请求结束而取消
+	baseCtx context.Context
+}
+
+// New 创建调度器
+func New(opts Options) *Scheduler {
+	if opts.Instance == "" {
+		opts.Instance = DefaultInstance()
+	}
+	if opts.LockTTL <= 0 {
+		opts.LockTTL = 5 * time.Minute
+	}
+	return &Scheduler{
+		opts:    opts,
+		jobs:    make(map[string]*registeredJob),
+		baseCtx: context.Background(),
+	}
+}
+
+// DefaultInstance 由主机名、进程号和随机串组成的实例标识
+func DefaultInstance() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	suffix := make([]byte, 3)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// Register 注册任务，任务名不能重复，需在 Start 之前调用
+func (s *Scheduler) Register(job Job) error {
+	if job.Name == "" || job.Run == nil {
+		return fmt.Errorf("定时任务名称和执行函数不能为空")
+	}
+	schedule, err := cron.Parse(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("定时任务 %s: %w", job.Name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.jobs[job.Name]; exists {
+		return fmt.Errorf("定时任务 %s 重复注册", job.Name)
+	}
+	s.jobs[job.Name] = &registeredJob{Job: job, schedule: schedule}
+	s.order = append(s.order, job.Name)
+	return nil
+}
+
+// Start 启动调度，每个任务一个协程按 cron 表达式等待执行；ctx 取消后停止调度，进行中的任务随之中止
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	s.baseCtx = ctx
+	jobs := s.registered()
+	s.mu.Unlock()
+
+	if !s.opts.Enabled {
+		return
+	}
+	for _, job := range jobs {
+		go s.loop(ctx, job)
+	}
+}
+
+// registered 按注册顺序返回任务，调用方需持有锁
+func (s *Scheduler) registered() []*registeredJob {
+	jobs := make([]*registeredJob, 0, len(s.order))
+	for _, name := range s.order {
+		jobs = append(jobs, s.jobs[name])
+	}
+	return jobs
+}
+
+func (s *Scheduler) job(name string) (*registeredJob, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	job, ok := s.jobs[name]
+	return job, ok
+}
+
+// loop 单个任务的调度循环，执行耗时超过计划间隔时，期间错过的计划时间不再执行
+func (s *Scheduler) loop(ctx context.Context, job *registeredJob) {
+	state, err := repository.ScheduledJobs().Ensure(ctx, job.Name, time.Now())
+	if err != nil {
+		utils.Logger.Error().Err(err).Str("job", job.Name).Msg("初始化定时任务状态失败，任务未启动")
+		return
+	}
+	utils.Logger.Info().Str("job", job.Name).Str("schedule", job.Schedule).Msg("已启动定时任务")
+
+	if s.opts.CatchUp == config.CatchUpOnce {
+		if missed := s.lastMissed(job, state.LastScheduledAt, time.Now()); !missed.IsZero() {
+			utils.Logger.Info().Str("job", job.Name).Time("scheduledAt", missed).Msg("补执行停机期间错过的定时任务")
+			s.runScheduled(ctx, job, missed, models.JobTriggerCatchUp)
+		}
+	}
+
+	for {
+		next := job.schedule.Next(time.Now())
+		if next.IsZero() {
+			utils.Logger.Warn().Str("job", job.Name).Str("schedule", job.Schedule).Msg("定时任务没有下一次执行时间，停止调度")
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.runScheduled(ctx, job, next, models.JobTriggerSchedule)
+	}
+}
+
+// lastMissed 上次领取的计划时间之后、now 之前（不早于补执行窗口）最近一次应执行的时间，没有时返回零值
+func (s *Scheduler) lastMissed(job *registeredJob, lastScheduledAt time.Time, now time.Time) time.Time {
+	from := lastScheduledAt
+	if windowStart := now.Add(-s.opts.CatchUpWindow); from.Before(windowStart) {
+		from = windowStart
+	}
+	var missed time.Time
+	for t := job.schedule.Next(from); !t.IsZero() && !t.After(now); t = job.schedule.Next(t) {
+		missed = t
+	}
+	return missed
+}
+
+// runScheduled 领取计划时间并执行，该时间已被其他实例领取或任务正在执行时跳过
+func (s *Scheduler) runScheduled(ctx context.Context, job *registeredJob, scheduledAt time.Time, trigger string) {
+	now := time.Now()
+	acquired, err := repository.ScheduledJobs().TryLock(ctx, job.Name, s.opts.Instance, now, now.Add(s.opts.LockTTL), scheduledAt)
+	if err != nil {
+		utils.Logger.Error().Err(err).Str("job", job.Name).Msg("获取定时任务锁失败")
+		return
+	}
+	if !acquired {
+		utils.Logger.Info().Str("job", job.Name).Time("scheduledAt", scheduledAt).Msg("定时任务已由其他实例执行或正在执行，跳过")
+		return
+	}
+
+	run := &models.JobRun{JobName: job.Name, Trigger: trigger, ScheduledAt: &scheduledAt}
+	if err := s.begin(ctx, run); err != nil {
+		utils.Logger.Error().Err(err).Str("job", job.Name).Msg("写入定时任务执行记录失败")
+		s.unlock(ctx, job.Name, run)
+		return
+	}
+	s.execute(ctx, job, run)
+}
+
+// Trigger 手动执行任务：同步获取锁并写入执行记录后在后台执行，返回执行中的记录
+func (s *Scheduler) Trigger(ctx context.Context, name string, user *utils.LoginUser) (*models.JobRun, error) {
+	job, ok := s.job(name)
+	if !ok {
+		return nil, utils.CreateNotFoundError("定时任务")
+	}
+	now := time.Now()
+	if _, err := repository.ScheduledJobs().Ensure(ctx, job.Name, now); err != nil {
+		return nil, fmt.Errorf("初始化定时任务状态失败: %w", err)
+	}
+	acquired, err := repository.ScheduledJobs().TryLock(ctx, job.Name, s.opts.Instance, now, now.Add(s.opts.LockTTL), time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("获取定时任务锁失败: %w", err)
+	}
+	if !acquired {
+		return nil, utils.NewApiError("该任务正在执行中，请稍后再试", http.StatusConflict, ErrCodeJobRunning)
+	}
+
+	run := &models.JobRun{
+		JobName:         job.Name,
+		Trigger:         models.JobTriggerManual,
+		TriggeredByID:   user.ID,
+		TriggeredByName: user.Username,
+	}
+	if err := s.begin(ctx, run); err != nil {
+		s.unlock(ctx, job.Name, run)
+		return nil, fmt.Errorf("写入定时任务执行记录失败: %w", err)
+	}
+	started := *run
+
+	s.mu.RLock()
+	baseCtx := s.baseCtx
+	s.mu.RUnlock()
+	go s.execute(baseCtx, job, run)
+
+	utils.Logger.Info().Str("job", job.Name).Str("runId", run.ID.Hex()).Str("operator", user.Username).Msg("手动执行定时任务")
+	return &started, nil
+}
+
+// begin 持有锁时写入执行中的记录，并将之前退出的实例遗留的执行中记录标记为中断
+func (s *Scheduler) begin(ctx context.Context, run *models.JobRun) error {
+	now := time.Now()
+	if result, err := repository.JobRuns().MarkInterrupted(ctx, run.JobName, now); err != nil {
+		return err
+	} else if result.ModifiedCount > 0 {
+		utils.Logger.Warn().Str("job", run.JobName).Int64("runs", result.ModifiedCount).Msg("已将中途退出的定时任务执行记录标记为中断")
+	}
+	run.Instance = s.opts.Instance
+	run.Status = models.JobRunStatusRunning
+	run.StartedAt = now
+	id, err := repository.JobRuns().Insert(ctx, run)
+	if err != nil {
+		return err
+	}
+	run.ID = id
+	return nil
+}
+
+// execute 执行任务并记录结果，执行期间定期续期锁，结束后释放
+func (s *Scheduler) execute(ctx context.Context, job *registeredJob, run *models.JobRun) {
+	jobCtx, cancel := ctx, context.CancelFunc(func() {})
+	if job.Timeout > 0 {
+		jobCtx, cancel = context.WithTimeout(ctx, job.Timeout)
+	}
+	stopHeartbeat := s.heartbeat(jobCtx, job.Name)
+
+	counts, err := s.invoke(jobCtx, job)
+	stopHeartbeat()
+	cancel()
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.DurationMs = finishedAt.Sub(run.StartedAt).Milliseconds()
+	run.Counts = counts
+	switch {
+	case err == nil:
+		run.Status = models.JobRunStatusSuccess
+	case ctx.Err() != nil:
+		// 服务关闭导致中止
+		run.Status = models.JobRunStatusInterrupted
+		run.Error = err.Error()
+	default:
+		run.Status = models.JobRunStatusFailed
+		run.Error = err.Error()
+	}
+
+	finishCtx, cancelFinish := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+	defer cancelFinish()
+	if _, updateErr := repository.JobRuns().UpdateByID(finishCtx, run.ID, bson.M{"$set": bson.M{
+		"status":     run.Status,
+		"finishedAt": finishedAt,
+		"durationMs": run.DurationMs,
+		"counts":     run.Counts,
+		"error":      run.Error,
+	}}); updateErr != nil {
+		utils.Logger.Error().Err(updateErr).Str("job", job.Name).Str("runId", run.ID.Hex()).Msg("更新定时任务执行记录失败")
+	}
+	s.unlock(finishCtx, job.Name, run)
+
+	event := utils.Logger.Info()
+	if err != nil {
+		event = utils.Logger.Error().Err(err)
+	}
+	event.Str("job", job.Name).
+		Str("runId", run.ID.Hex()).
+		Str("trigger", run.Trigger).
+		Str("status", run.Status).
+		Int64("durationMs", run.DurationMs).
+		Interface("counts", run.Counts).
+		Msg("定时任务执行结束")
+}
+
+// invoke 调用任务函数，任务 panic 时记为失败
+func (s *Scheduler) invoke(ctx context.Context, job *registeredJob) (counts map[string]int64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			utils.Logger.Error().Str("job", job.Name).Str("stack", string(debug.Stack())).Msgf("定时任务 panic: %v", r)
+			err = fmt.Errorf("任务异常退出: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+// heartbeat 定期续期任务锁，返回的函数停止续期
+func (s *Scheduler) heartbeat(ctx context.Context, name string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(s.opts.LockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				held, err := repository.ScheduledJobs().ExtendLock(ctx, name, s.opts.Instance, time.Now().Add(s.opts.LockTTL))
+				if err != nil {
+					utils.Logger.Error().Err(err).Str("job", name).Msg("定时任务锁续期失败")
+				} else if !held {
+					utils.Logger.Warn().Str("job", name).Msg("定时任务锁已不由本实例持有")
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// unlock 释放锁并记录最近一次执行
+func (s *Scheduler) unlock(ctx context.Context, name string, run *models.JobRun) {
+	if err := repository.ScheduledJobs().Unlock(context.WithoutCancel(ctx), name, s.opts.Instance, run); err != nil {
+		utils.Logger.Error().Err(err).Str("job", name).Msg("释放定时任务锁失败")
+	}
+}
+
+// Jobs 已注册任务及其状态，按注册顺序返回
+func (s *Scheduler) Jobs(ctx context.Context) ([]models.ScheduledJobInfo, error) {
+	s.mu.RLock()
+	jobs := s.registered()
+	s.mu.RUnlock()
+
+	now := time.Now()
+	infos := make([]models.ScheduledJobInfo, 0, len(jobs))
+	for _, job := range jobs {
+		info := models.ScheduledJobInfo{
+			Name:        job.Name,
+			Description: job.Description,
+			Schedule:    job.Schedule,
+			Enabled:     s.opts.Enabled,
+		}
+		if s.opts.Enabled {
+			if next := job.schedule.Next(now); !next.IsZero() {
+				info.NextRunAt = &next
+			}
+		}
+		state, err := repository.ScheduledJobs().FindByName(ctx, job.Name)
+		if err != nil && err != repository.ErrNotFound {
+			return nil, err
+		}
+		if state != nil {
+			if state.LockedBy != "" && state.LockedUntil.After(now) {
+				info.Running = true
+				info.RunningOn = state.LockedBy
+			}
+			if !state.LastRunAt.IsZero() {
+				lastRunAt := state.LastRunAt
+				info.LastRunAt = &lastRunAt
+				info.LastStatus = state.LastStatus
+				info.LastRunID = state.LastRunID.Hex()
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// NextRunAt 任务在 after 之后的下一次计划执行时间，定时执行停用或任务不存在时返回零值
+func (s *Scheduler) NextRunAt(name string, after time.Time) time.Time {
+	job, ok := s.job(name)
+	if !ok || !s.opts.Enabled {
+		return time.Time{}
+	}
+	return job.schedule.Next(after)
+}
+
+// HasJob 任务是否已注册
+func (s *Scheduler) HasJob(name string) bool {
+	_, ok := s.job(name)
+	return ok
+}
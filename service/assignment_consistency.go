@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CheckAssignmentConsistency 检查客户当前归属与最近一条分配历史是否一致
+// 有归属但没有分配历史的客户同样列出；公海客户没有分配历史时视为一致
+func CheckAssignmentConsistency(ctx context.Context) (*models.AssignmentConsistencyReport, error) {
+	customers, err := repository.Customers().Find(ctx, bson.M{}, repository.NewFindOptions().SetProjection(bson.M{
+		"name":             1,
+		"isInPublicPool":   1,
+		"relatedSalesId":   1,
+		"relatedSalesName": 1,
+		"relatedAgentId":   1,
+		"relatedAgentName": 1,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("读取客户数据失败: %w", err)
+	}
+
+	// 按时间正序遍历，保留每个客户的最后一条分配历史
+	histories, err := repository.AssignmentHistory().Find(ctx, bson.M{}, repository.NewFindOptions().
+		SetSort("createdAt", 1).
+		SetSort("_id", 1).
+		SetProjection(bson.M{
+			"customerId":         1,
+			"toRelatedSalesId":   1,
+			"toRelatedSalesName": 1,
+			"toRelatedAgentId":   1,
+			"toRelatedAgentName": 1,
+			"operationType":      1,
+			"createdAt":          1,
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("读取分配历史失败: %w", err)
+	}
+	latest := make(map[string]models.CustomerAssignmentHistory, len(customers))
+	for _, history := range histories {
+		latest[history.CustomerID] = history
+	}
+
+	report := &models.AssignmentConsistencyReport{
+		CheckedAt:       time.Now(),
+		Checked:         len(customers),
+		Inconsistencies: []models.AssignmentInconsistency{},
+	}
+	for _, customer := range customers {
+		current := models.AssignmentOwner{
+			SalesID:   customer.RelatedSalesID,
+			SalesName: customer.RelatedSalesName,
+			AgentID:   customer.RelatedAgentID,
+			AgentName: customer.RelatedAgentName,
+		}
+		item := models.AssignmentInconsistency{
+			CustomerID:     customer.ID.Hex(),
+			CustomerName:   customer.Name,
+			IsInPublicPool: customer.IsInPublicPool,
+			Current:        current,
+		}
+
+		history, ok := latest[item.CustomerID]
+		if !ok {
+			if current.SalesID != "" || current.AgentID != "" {
+				item.Reason = "客户有归属但没有分配历史"
+				report.Inconsistencies = append(report.Inconsistencies, item)
+			}
+			continue
+		}
+
+		item.History = models.AssignmentOwner{
+			SalesID:   history.ToRelatedSalesID,
+			SalesName: history.ToRelatedSalesName,
+			AgentID:   history.ToRelatedAgentID,
+			AgentName: history.ToRelatedAgentName,
+		}
+		item.HistoryID = history.ID.Hex()
+		item.OperationType = history.OperationType
+		createdAt := history.CreatedAt
+		item.HistoryAt = &createdAt
+
+		switch {
+		case current.SalesID != item.History.SalesID:
+			item.Reason = "当前销售与最近分配历史不一致"
+		case current.AgentID != item.History.AgentID:
+			item.Reason = "当前代理商与最近分配历史不一致"
+		default:
+			continue
+		}
+		report.Inconsistencies = append(report.Inconsistencies, item)
+	}
+	return report, nil
+}
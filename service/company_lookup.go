@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/config"
+)
+
+// ErrCompanyLookupDisabled 未配置阿里云 AppCode 时公司名称补全不可用
+var ErrCompanyLookupDisabled = errors.New("公司信息查询服务未配置")
+
+// CompanyInfo 公司信息结构体
+type CompanyInfo struct {
+	RegNumber      string `json:"regNumber"`
+	RegType        string `json:"regType"`
+	CompanyName    string `json:"companyName"`
+	CompanyType    string `json:"companyType"`
+	RegMoney       string `json:"regMoney"`
+	FaRen          string `json:"faRen"`
+	IssueTime      string `json:"issueTime"`
+	CreditCode     string `json:"creditCode"`
+	ProvinceName   string `json:"provinceName"`
+	BusinessStatus string `json:"businessStatus"`
+}
+
+// ResultWithData 包含 total 和 data 的结构体
+type ResultWithData struct {
+	Total int           `json:"total"`
+	Data  []CompanyInfo `json:"data"`
+}
+
+// aliCloudResponse 阿里云API响应结构体
+type aliCloudResponse struct {
+	ErrorCode int             `json:"error_code"`
+	Reason    string          `json:"reason"`
+	Result    json.RawMessage `json:"result"` // 使用 json.RawMessage 延迟解析
+}
+
+// CompanyLookupClient 阿里云企业信息查询客户端
+type CompanyLookupClient struct {
+	searchURL  string
+	appCode    string
+	httpClient *http.Client
+}
+
+// NewCompanyLookupClient 按配置创建企业信息查询客户端
+func NewCompanyLookupClient(cfg config.AliCloudConfig) *CompanyLookupClient {
+	return &CompanyLookupClient{
+		searchURL:  cfg.CompanySearchURL,
+		appCode:    cfg.AppCode,
+		httpClient: &http.Client{Timeout: time.Duration(cfg.Timeout)},
+	}
+}
+
+var companyLookup *CompanyLookupClient
+
+// InitCompanyLookup 注入企业信息查询配置，AppCode 为空时不启用
+func InitCompanyLookup(cfg config.AliCloudConfig) {
+	if cfg.AppCode == "" {
+		companyLookup = nil
+		log.Printf("未配置阿里云 AppCode，公司名称补全功能已停用")
+		return
+	}
+	companyLookup = NewCompanyLookupClient(cfg)
+}
+
+// CompanyLookup 返回企业信息查询客户端，未启用时返回 nil（调用方法会返回 ErrCompanyLookupDisabled）
+func CompanyLookup() *CompanyLookupClient {
+	return companyLookup
+}
+
+// SearchCompanies 按公司名称前缀查询企业信息
+func (c *CompanyLookupClient) SearchCompanies(ctx context.Context, prefix string) ([]CompanyInfo, error) {
+	if c == nil {
+		return nil, ErrCompanyLookupDisabled
+	}
+
+	// 构建查询参数
+	params := url.Values{}
+	params.Set("com", strings.TrimSpace(prefix))
+	params.Set("page", "1")
+	params.Set("query", "all")
+
+	apiURL := fmt.Sprintf("%s?%s", c.searchURL, params.Encode())
+	log.Printf("[API] 调用阿里云API: %s", apiURL)
+
+	// 创建HTTP请求
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	// 设置请求头
+	req.Header.Set("Authorization", "APPCODE "+c.appCode)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	// 发送请求
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("网络连接失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 检查响应状态
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("阿里云API请求失败: %s", resp.Status)
+	}
+
+	// 读取响应体
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应体失败: %w", err)
+	}
+
+	log.Printf("[API] 阿里云API原始响应: %s", string(body))
+
+	// 解析响应
+	return parseAliCloudResponse(body)
+}
+
+func parseAliCloudResponse(body []byte) ([]CompanyInfo, error) {
+	var response aliCloudResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	// 检查错误码
+	if response.ErrorCode != 0 {
+		// 如果是查询无结果的情况，返回空切片和 nil 错误
+		if response.ErrorCode == 50002 && response.Reason == "查询无结果" {
+			return []CompanyInfo{}, nil
+		}
+		// 其他错误情况，返回错误信息
+		return nil, errors.New(response.Reason)
+	}
+
+	// 尝试解析 Result 为 ResultWithData
+	var resultWithData ResultWithData
+	if err := json.Unmarshal(response.Result, &resultWithData); err == nil {
+		return resultWithData.Data, nil
+	}
+
+	// 尝试解析 Result 为空数组
+	var emptyArray []interface{}
+	if err := json.Unmarshal(response.Result, &emptyArray); err == nil {
+		return []CompanyInfo{}, nil
+	}
+
+	// 如果无法解析，返回错误
+	return nil, errors.New("无法解析 Result 字段")
+}
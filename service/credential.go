@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// GetPasswordHashReport 统计用户和代理商的密码存储格式
+// 旧格式账户会在下次登录时自动升级；无法识别的格式（如明文）需管理员重置密码
+func GetPasswordHashReport(ctx context.Context) (*models.PasswordHashReport, error) {
+	report := &models.PasswordHashReport{
+		Users:          map[string]int64{},
+		Agents:         map[string]int64{},
+		LegacyAccounts: []models.LegacyPasswordAccount{},
+		GeneratedAt:    time.Now(),
+	}
+
+	users, err := repository.Users().Find(ctx, bson.M{},
+		repository.NewFindOptions().SetProjection(bson.M{"username": 1, "password": 1}))
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		scheme := utils.DetectPasswordScheme(user.Password)
+		report.Users[string(scheme)]++
+		if scheme != utils.PasswordSchemeBcrypt {
+			report.LegacyAccounts = append(report.LegacyAccounts, models.LegacyPasswordAccount{
+				ID:          user.ID.Hex(),
+				Name:        user.Username,
+				AccountType: "user",
+				Scheme:      string(scheme),
+			})
+		}
+	}
+
+	agents, err := repository.Agents().Find(ctx, bson.M{},
+		repository.NewFindOptions().SetProjection(bson.M{"companyName": 1, "password": 1}))
+	if err != nil {
+		return nil, err
+	}
+	for _, agent := range agents {
+		scheme := utils.DetectPasswordScheme(agent.Password)
+		report.Agents[string(scheme)]++
+		if scheme != utils.PasswordSchemeBcrypt {
+			report.LegacyAccounts = append(report.LegacyAccounts, models.LegacyPasswordAccount{
+				ID:          agent.ID.Hex(),
+				Name:        agent.CompanyName,
+				AccountType: "agent",
+				Scheme:      string(scheme),
+			})
+		}
+	}
+
+	report.LegacyTotal = int64(len(report.LegacyAccounts))
+	return report, nil
+}
+
+// LogPasswordHashReport 启动时输出旧格式密码统计，便于跟踪迁移进度
+func LogPasswordHashReport(ctx context.Context) {
+	report, err := GetPasswordHashReport(ctx)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("统计密码存储格式失败")
+		return
+	}
+	if report.LegacyTotal == 0 {
+		utils.Logger.Info().Msg("所有账户密码均已使用 bcrypt 存储")
+		return
+	}
+	utils.Logger.Warn().
+		Interface("users", report.Users).
+		Interface("agents", report.Agents).
+		Int64("legacyTotal", report.LegacyTotal).
+		Msg("仍有账户使用旧格式密码，将在下次登录时自动升级")
+}
@@ -9,13 +9,12 @@ import (
 	"time"
 
 	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/policy"
 	"github.com/BerniceZTT/crm_end/repository"
 	"github.com/BerniceZTT/crm_end/utils"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func HasProjects(ctx context.Context, customerID primitive.ObjectID) (bool, error) {
@@ -24,68 +23,24 @@ func HasProjects(ctx context.Context, customerID primitive.ObjectID) (bool, erro
 		"webHidden":  false,
 	}
 
-	collection := repository.Collection(repository.ProjectsCollection)
-
-	findOptions := options.Find().SetLimit(1)
-
-	cursor, err := collection.Find(ctx, filter, findOptions)
+	projects, err := repository.Projects().Find(ctx, filter, repository.NewFindOptions().SetLimit(1))
 	if err != nil {
 		log.Printf("查询测试中的项目失败: %v", err)
 		return false, fmt.Errorf("查询测试中的项目失败: %v", err)
 	}
-	defer cursor.Close(ctx)
-
-	var projects []models.Project
-	if err = cursor.All(ctx, &projects); err != nil {
-		log.Printf("解析项目失败: %v", err)
-		return false, fmt.Errorf("解析项目失败: %v", err)
-	}
 
 	return len(projects) > 0, nil
 }
 
-func UpdateCustomerProgress(ctx context.Context, customerObjID primitive.ObjectID, progress string) error {
-	customersCollection := repository.Collection(repository.CustomersCollection)
-	updateData := bson.M{
-		"progress":       progress,
-		"lastupdatetime": time.Now(),
-		"updatedAt":      time.Now(),
-	}
-	_, err := customersCollection.UpdateOne(
-		ctx,
-		bson.M{"_id": customerObjID},
-		bson.M{"$set": updateData},
-	)
-	return err
-}
-
-func UpdateCustomerProgressByName(ctx context.Context, name string, progress string) error {
-	customersCollection := repository.Collection(repository.CustomersCollection)
-	updateData := bson.M{
-		"progress":       progress,
-		"lastupdatetime": time.Now(),
-		"updatedAt":      time.Now(),
-	}
-	_, err := customersCollection.UpdateMany(
-		ctx,
-		bson.M{"name": name, "progress": models.CustomerProgressInitialContact},
-		bson.M{"$set": updateData},
-	)
-	return err
-}
-
 // 解析请求数据
 type AssignRequest struct {
 	SalesId string `json:"salesId" binding:"required"`
 	AgentId string `json:"agentId"`
 }
 
-func AssignCustomer(ctx context.Context, c *gin.Context, customerId string, assignRequest AssignRequest, user *utils.LoginUser) (error, int, gin.H) {
-	// 获取相关集合
-	customersCollection := repository.Collection(repository.CustomersCollection)
-	usersCollection := repository.Collection(repository.UsersCollection)
-	agentsCollection := repository.Collection(repository.AgentsCollection)
-
+// AssignCustomer 将客户分配给指定销售和(可选)代理商
+// 客户更新、同名客户禁用、进展历史和分配历史在同一事务中写入，任一步失败全部回滚
+func AssignCustomer(ctx context.Context, customerId string, assignRequest AssignRequest, user *utils.LoginUser) (error, int, gin.H) {
 	// 将客户ID转换为ObjectID
 	customerObjID, err := primitive.ObjectIDFromHex(customerId)
 	if err != nil {
@@ -93,30 +48,33 @@ func AssignCustomer(ctx context.Context, c *gin.Context, customerId string, assi
 	}
 
 	// 查询客户
-	var customer models.Customer
-	err = customersCollection.FindOne(ctx, bson.M{"_id": customerObjID}).Decode(&customer)
+	customer, err := repository.Customers().FindByID(ctx, customerObjID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			return err, http.StatusNotFound, gin.H{"error": "客户不存在"}
 		} else {
-			utils.HandleError(c, err)
+			utils.LogError(err, map[string]interface{}{"customerId": customerId}, "查询客户失败")
 			return err, http.StatusInternalServerError, gin.H{"error": "分配客户失败"}
 		}
 	}
 
+	// 权限检查
+	if !policy.CanAccessCustomer(user, customer, policy.ActionAssign) {
+		return fmt.Errorf("无权分配该客户"), http.StatusForbidden, gin.H{"error": "无权分配该客户"}
+	}
+
 	// 查询销售信息
 	salesObjID, err := primitive.ObjectIDFromHex(assignRequest.SalesId)
 	if err != nil {
 		return err, http.StatusBadRequest, gin.H{"error": "无效的销售ID格式"}
 	}
 
-	var salesUser models.User
-	err = usersCollection.FindOne(ctx, bson.M{"_id": salesObjID}).Decode(&salesUser)
+	salesUser, err := repository.Users().FindByID(ctx, salesObjID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == repository.ErrNotFound {
 			return err, http.StatusNotFound, gin.H{"error": "指定的销售人员不存在"}
 		} else {
-			utils.HandleError(c, err)
+			utils.LogError(err, map[string]interface{}{"salesId": assignRequest.SalesId}, "查询销售失败")
 			return err, http.StatusInternalServerError, gin.H{"error": "分配客户失败"}
 		}
 	}
@@ -125,7 +83,7 @@ func AssignCustomer(ctx context.Context, c *gin.Context, customerId string, assi
 	progress := models.CustomerProgressInitialContact
 	had, err := HasProjects(ctx, customerObjID)
 	if err != nil {
-		utils.HandleError(c, err)
+		utils.LogError(err, map[string]interface{}{"customerId": customerId}, "获取客户项目状态失败")
 		return err, http.StatusInternalServerError, gin.H{"error": "获取客户项目状态失败"}
 	}
 	if had {
@@ -134,7 +92,7 @@ func AssignCustomer(ctx context.Context, c *gin.Context, customerId string, assi
 	updateData := bson.M{
 		"relatedSalesId":   assignRequest.SalesId,
 		"relatedSalesName": salesUser.Username,
-		"lastupdatetime":   time.Now(),
+		"lastUpdateTime":   time.Now(),
 		"updatedAt":        time.Now(),
 		"isInPublicPool":   false,
 		"progress":         progress,
@@ -143,7 +101,7 @@ func AssignCustomer(ctx context.Context, c *gin.Context, customerId string, assi
 		updateData = bson.M{
 			"relatedSalesId":     assignRequest.SalesId,
 			"relatedSalesName":   salesUser.Username,
-			"lastupdatetime":     time.Now(),
+			"lastUpdateTime":     time.Now(),
 			"updatedAt":          time.Now(),
 			"isInPublicPool":     false,
 			"progress":           progress,
@@ -158,13 +116,12 @@ func AssignCustomer(ctx context.Context, c *gin.Context, customerId string, assi
 		if err != nil {
 			return err, http.StatusBadRequest, gin.H{"error": "无效的代理商ID格式"}
 		}
-		var agent models.Agent
-		err = agentsCollection.FindOne(ctx, bson.M{"_id": agentObjID}).Decode(&agent)
+		agent, err := repository.Agents().FindByID(ctx, agentObjID)
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
+			if err == repository.ErrNotFound {
 				return err, http.StatusNotFound, gin.H{"error": "指定的代理商不存在"}
 			} else {
-				utils.HandleError(c, err)
+				utils.LogError(err, map[string]interface{}{"agentId": assignRequest.AgentId}, "查询代理商失败")
 				return err, http.StatusInternalServerError, gin.H{"error": "分配客户失败"}
 			}
 		}
@@ -182,44 +139,58 @@ func AssignCustomer(ctx context.Context, c *gin.Context, customerId string, assi
 		updateData["relatedAgentName"] = nil
 	}
 
-	// 更新客户数据
-	result, err := customersCollection.UpdateOne(
-		ctx,
-		bson.M{"_id": customerObjID},
-		bson.M{"$set": updateData},
-	)
-
-	if err != nil {
-		utils.HandleError(c, err)
-		return err, http.StatusInternalServerError, gin.H{"error": "分配客户失败"}
+	// 检查是否需要记录分配历史
+	salesChanged := assignRequest.SalesId != customer.RelatedSalesID
+	agentChanged := assignRequest.AgentId != customer.RelatedAgentID
+	operationType := "分配"
+	// 判断是否为认领：当前用户是被分配的销售或代理商
+	if (user.Role == "FACTORY_SALES" && user.ID == assignRequest.SalesId) ||
+		(user.Role == "AGENT" && user.ID == assignRequest.AgentId) {
+		operationType = operationClaim
 	}
-	if progress == models.CustomerProgressNormal {
-		// 更改其他客户状态
-		err1 := UpdateCustomerProgressByName(ctx, customer.Name, models.CustomerProgressDisabled)
-		if err1 != nil {
-			utils.HandleError(c, err1)
-			return err1, http.StatusInternalServerError, gin.H{"error": "客户为正常推进状态，修改其他同名客户信息报错"}
-		}
+	// 从公海分配时以客户仍在公海为条件更新，避免与并发的认领重复分配
+	var condition bson.M
+	if customer.IsInPublicPool {
+		condition = bson.M{"isInPublicPool": true}
+		// 分配后不能再恢复原归属，移入公海前的联系人信息一并清除
+		updateData["poolSnapshot"] = nil
 	}
 
-	if result.MatchedCount == 0 {
-		return fmt.Errorf("result.MatchedCount == 0"), http.StatusNotFound, gin.H{"error": "客户不存在或已被修改"}
-	}
+	err = repository.WithTransaction(ctx, func(txCtx context.Context) error {
+		// 销售和代理商通过分配接口取走公海客户时同样受认领额度和冷却期限制，分配给他人也计入操作人的额度
+		if customer.IsInPublicPool && policy.IsOwnerScoped(user.Role) {
+			if err := checkPublicPoolClaim(txCtx, user, customer); err != nil {
+				return err
+			}
+		}
 
-	// 检查是否需要记录分配历史
-	salesChanged := assignRequest.SalesId != customer.RelatedSalesID
-	agentChanged := assignRequest.AgentId != customer.RelatedAgentID
+		// 事务遇到临时错误会整体重试，每次都从查询时的客户状态开始
+		current := *customer
+
+		// 更新客户数据，进展状态按状态机校验并记录历史
+		if _, err := ChangeCustomerProgress(txCtx, ProgressChange{
+			Customer:  &current,
+			To:        progress,
+			Operator:  user,
+			Remark:    "分配客户",
+			Fields:    updateData,
+			Condition: condition,
+		}); err != nil {
+			return err
+		}
+
+		if progress == models.CustomerProgressNormal {
+			// 更改其他客户状态
+			if err := UpdateCustomerProgressByName(txCtx, customer.Name, models.CustomerProgressDisabled, user, "同名客户已正常推进"); err != nil {
+				return fmt.Errorf("客户为正常推进状态，修改其他同名客户信息报错: %w", err)
+			}
+		}
 
-	// 只在有变化时记录历史
-	if salesChanged || agentChanged {
-		operationType := "分配"
-		// 判断是否为认领：当前用户是被分配的销售或代理商
-		if (user.Role == "FACTORY_SALES" && user.ID == assignRequest.SalesId) ||
-			(user.Role == "AGENT" && user.ID == assignRequest.AgentId) {
-			operationType = "认领"
+		// 只在有变化时记录历史
+		if !salesChanged && !agentChanged {
+			return nil
 		}
-		// 记录分配历史
-		err = AddAssignmentHistory(ctx, models.CustomerAssignmentHistory{
+		return AddAssignmentHistory(txCtx, models.CustomerAssignmentHistory{
 			CustomerID:   customerId,
 			CustomerName: customer.Name,
 			// 销售信息
@@ -237,10 +208,16 @@ func AssignCustomer(ctx context.Context, c *gin.Context, customerId string, assi
 			OperatorName:  user.Username,
 			OperationType: operationType,
 		})
-
-		if err != nil {
-			utils.HandleError(c, err)
+	})
+	if err != nil {
+		if apiErr, ok := err.(*utils.ApiError); ok {
+			return err, apiErr.StatusCode, gin.H{"error": apiErr.Message}
 		}
+		utils.LogError(err, map[string]interface{}{
+			"customerId": customerId,
+			"salesId":    assignRequest.SalesId,
+		}, "分配客户失败，已回滚")
+		return err, http.StatusInternalServerError, gin.H{"error": "分配客户失败"}
 	}
 
 	return nil, http.StatusOK, gin.H{
@@ -256,8 +233,6 @@ func AssignCustomer(ctx context.Context, c *gin.Context, customerId string, assi
 
 // AddAssignmentHistory 添加客户分配历史记录
 func AddAssignmentHistory(ctx context.Context, historyData models.CustomerAssignmentHistory) error {
-	collection := repository.Collection(repository.CustAssignCollection)
-
 	// 确保有创建时间字段
 	if historyData.CreatedAt.IsZero() {
 		historyData.CreatedAt = time.Now()
@@ -271,7 +246,7 @@ func AddAssignmentHistory(ctx context.Context, historyData models.CustomerAssign
 	}, "添加客户分配历史记录成功")
 
 	// 插入历史记录
-	result, err := collection.InsertOne(ctx, historyData)
+	insertedID, err := repository.AssignmentHistory().Insert(ctx, &historyData)
 	if err != nil {
 		utils.LogError2("添加客户分配历史记录", err, map[string]interface{}{
 			"function": "AddAssignmentHistory",
@@ -279,16 +254,7 @@ func AddAssignmentHistory(ctx context.Context, historyData models.CustomerAssign
 
 		return err
 	}
-	if result == nil {
-		utils.LogError2("添加客户分配历史记录", fmt.Errorf("result == nil"), map[string]interface{}{
-			"function": "AddAssignmentHistory",
-		})
-
-		return fmt.Errorf("result == nil")
-	}
-
-	var insertedDoc models.CustomerAssignmentHistory
-	err = collection.FindOne(ctx, bson.M{"_id": result.InsertedID}).Decode(&insertedDoc)
+	insertedDoc, err := repository.AssignmentHistory().FindByID(ctx, insertedID)
 	if err != nil {
 		utils.LogError2("查询刚插入的记录失败", err, nil)
 	} else {
@@ -301,8 +267,99 @@ func AddAssignmentHistory(ctx context.Context, historyData models.CustomerAssign
 		"customerId":    historyData.CustomerID,
 		"customerName":  historyData.CustomerName,
 		"operationType": historyData.OperationType,
-		"_id":           result.InsertedID,
+		"_id":           insertedID,
 	}, "添加客户分配历史记录成功")
 
 	return nil
 }
+
+// ownerUnchanged 客户归属仍为读取时的状态：关联销售和代理商未变且不在公海
+func ownerUnchanged(customer *models.Customer) bson.M {
+	condition := bson.M{"isInPublicPool": bson.M{"$ne": true}}
+	for field, id := range map[string]string{
+		"relatedSalesId": customer.RelatedSalesID,
+		"relatedAgentId": customer.RelatedAgentID,
+	} {
+		if id == "" {
+			condition[field] = bson.M{"$in": bson.A{"", nil}}
+		} else {
+			condition[field] = id
+		}
+	}
+	return condition
+}
+
+// MoveCustomerToPublicPool 将客户移入公海：清空关联销售、代理商和联系人（移入前的信息保存在 poolSnapshot 中），记录进展历史和分配历史，
+// 并隐藏客户下的全部项目，所有写入在同一事务中完成；读取客户后归属已被修改时返回进展冲突错误
+func MoveCustomerToPublicPool(ctx context.Context, customer *models.Customer, operator *utils.LoginUser, remark string) error {
+	return repository.WithTransaction(ctx, func(txCtx context.Context) error {
+		// 记录将被隐藏的项目，恢复原归属时只重新展示这些项目
+		visibleProjects, err := repository.Projects().FindVisibleByCustomerID(txCtx, customer.ID,
+			repository.NewFindOptions().SetProjection(bson.M{"_id": 1}))
+		if err != nil {
+			return fmt.Errorf("查询客户项目失败: %w", err)
+		}
+		snapshot := &models.CustomerPoolSnapshot{
+			RelatedSalesID:   customer.RelatedSalesID,
+			RelatedSalesName: customer.RelatedSalesName,
+			RelatedAgentID:   customer.RelatedAgentID,
+			RelatedAgentName: customer.RelatedAgentName,
+			ContactPerson:    customer.ContactPerson,
+			ContactPhone:     customer.ContactPhone,
+			Progress:         customer.Progress,
+			MovedAt:          time.Now(),
+			MovedByID:        operator.ID,
+			MovedByName:      operator.Username,
+			Remark:           remark,
+		}
+		for _, project := range visibleProjects {
+			snapshot.HiddenProjectIDs = append(snapshot.HiddenProjectIDs, project.ID)
+		}
+		previousOwnerID, previousOwnerName, previousOwnerType := snapshot.PreviousOwner()
+
+		// 事务遇到临时错误会整体重试，每次都从查询时的客户状态开始
+		current := *customer
+		if _, err := ChangeCustomerProgress(txCtx, ProgressChange{
+			Customer:  &current,
+			To:        models.CustomerProgressPublicPool,
+			Operator:  operator,
+			Remark:    remark,
+			Condition: ownerUnchanged(customer),
+			Fields: bson.M{
+				"isInPublicPool":    true,
+				"relatedSalesId":    nil,
+				"relatedSalesName":  nil,
+				"relatedAgentId":    nil,
+				"relatedAgentName":  nil,
+				"contactPerson":     "",
+				"contactPhone":      "",
+				"poolSnapshot":      snapshot,
+				"previousOwnerId":   previousOwnerID,
+				"previousOwnerName": previousOwnerName,
+				"previousOwnerType": string(previousOwnerType),
+			},
+		}); err != nil {
+			return err
+		}
+
+		if err := AddAssignmentHistory(txCtx, models.CustomerAssignmentHistory{
+			CustomerID:           customer.ID.Hex(),
+			CustomerName:         customer.Name,
+			FromRelatedSalesID:   customer.RelatedSalesID,
+			FromRelatedSalesName: customer.RelatedSalesName,
+			FromRelatedAgentID:   customer.RelatedAgentID,
+			FromRelatedAgentName: customer.RelatedAgentName,
+			OperatorID:           operator.ID,
+			OperatorName:         operator.Username,
+			OperationType:        operationMoveToPublicPool,
+		}); err != nil {
+			return err
+		}
+
+		// 客户下项目全部变成前端不可展示
+		if _, err := repository.Projects().HideByCustomerID(txCtx, customer.ID); err != nil {
+			return fmt.Errorf("隐藏客户项目失败: %w", err)
+		}
+		return nil
+	})
+}
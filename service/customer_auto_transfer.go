@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// autoTransferSaveTimeout 保存轮流分配位置的最长时间，任务超时后仍会保存
+const autoTransferSaveTimeout = 10 * time.Second
+
+// autoTransferTarget 目标销售池中的在职销售
+type autoTransferTarget struct {
+	id   string
+	name string
+}
+
+// autoTransferRule 已校验的自动转移规则，cursor 为轮流分配的当前位置
+type autoTransferRule struct {
+	models.AutoTransferRule
+	configID primitive.ObjectID
+	key      string
+	targets  []autoTransferTarget
+	cursor   int
+	moved    bool
+}
+
+// matches 客户是否在规则的适用范围内
+func (r *autoTransferRule) matches(customer *models.Customer) bool {
+	return matchesAny(r.Importances, customer.Importance) &&
+		matchesAny(r.Natures, customer.Nature) &&
+		matchesAny(r.ApplicationFields, customer.ApplicationField) &&
+		matchesAny(r.CurrentSalesIDs, customer.RelatedSalesID)
+}
+
+// matchesAny 未限定取值或取值在列表中
+func matchesAny(values []string, value string) bool {
+	return len(values) == 0 || slices.Contains(values, value)
+}
+
+// next 从当前位置起轮流选择目标销售，跳过客户当前的销售；池中只有当前销售时返回 false
+func (r *autoTransferRule) next(currentSalesID string) (autoTransferTarget, bool) {
+	for i := range r.targets {
+		index := (r.cursor + i) % len(r.targets)
+		if r.targets[index].id == currentSalesID {
+			continue
+		}
+		r.cursor = (index + 1) % len(r.targets)
+		r.moved = true
+		return r.targets[index], true
+	}
+	return autoTransferTarget{}, false
+}
+
+// loadAutoTransferRules 读取已启用的自动转移规则并按优先级排序，无法使用的规则单独返回，不影响其他规则
+func loadAutoTransferRules(ctx context.Context) ([]*autoTransferRule, []models.AutoTransferRuleError, error) {
+	configs, err := repository.SystemConfigs().FindEnabledByType(ctx, models.ConfigTypeCustomerAutoTransfer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("查询自动转移配置失败: %w", err)
+	}
+
+	var rules []*autoTransferRule
+	invalid := []models.AutoTransferRuleError{}
+	for _, config := range configs {
+		rule, err := parseAutoTransferRule(ctx, config)
+		if err != nil {
+			invalid = append(invalid, models.AutoTransferRuleError{ConfigID: config.ID, ConfigKey: config.ConfigKey, Error: err.Error()})
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority < rules[j].Priority
+		}
+		return rules[i].key < rules[j].key
+	})
+	return rules, invalid, nil
+}
+
+// parseAutoTransferRule 解析并校验单条规则，目标销售池中已停用的销售被跳过
+func parseAutoTransferRule(ctx context.Context, config models.SystemConfig) (*autoTransferRule, error) {
+	// configValue 经 JSON 写入后是任意文档类型，统一通过 BSON 往返转换
+	data, err := bson.Marshal(config.ConfigValue)
+	if err != nil {
+		return nil, fmt.Errorf("规则格式错误: %w", err)
+	}
+	rule := &autoTransferRule{configID: config.ID, key: config.ConfigKey, cursor: config.RoundRobinCursor}
+	if err := bson.Unmarshal(data, &rule.AutoTransferRule); err != nil {
+		return nil, fmt.Errorf("规则格式错误: %w", err)
+	}
+	if rule.DaysWithoutProgress <= 0 {
+		return nil, fmt.Errorf("daysWithoutProgress 必须大于0")
+	}
+
+	poolIDs := rule.TargetSalesIDs
+	if len(poolIDs) == 0 && rule.TargetSalesID != "" {
+		poolIDs = []string{rule.TargetSalesID}
+	}
+	if len(poolIDs) == 0 {
+		return nil, fmt.Errorf("缺少目标销售，请配置 targetSalesIds")
+	}
+	objIDs := make([]primitive.ObjectID, 0, len(poolIDs))
+	for _, id := range poolIDs {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, fmt.Errorf("目标销售ID %q 无效", id)
+		}
+		objIDs = append(objIDs, objID)
+	}
+	users, err := repository.Users().Find(ctx, bson.M{
+		"_id":    bson.M{"$in": objIDs},
+		"role":   models.UserRoleFACTORY_SALES,
+		"status": models.UserStatusAPPROVED,
+	}, repository.NewFindOptions().SetProjection(bson.M{"_id": 1, "username": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("查询目标销售失败: %w", err)
+	}
+	names := make(map[string]string, len(users))
+	for _, user := range users {
+		names[user.ID.Hex()] = user.Username
+	}
+	// 保持配置中的顺序，重复的销售只保留一次
+	for _, id := range poolIDs {
+		if name, ok := names[id]; ok {
+			rule.targets = append(rule.targets, autoTransferTarget{id: id, name: name})
+			delete(names, id)
+		}
+	}
+	if len(rule.targets) == 0 {
+		return nil, fmt.Errorf("目标销售均不存在或已停用")
+	}
+	if rule.cursor < 0 || rule.cursor >= len(rule.targets) {
+		rule.cursor = 0
+	}
+	return rule, nil
+}
+
+// planAutoTransfers 按规则计算在 at 时间点应转移的初步接触客户及其目标销售
+// 客户只适用优先级最高的一条匹配规则，未达到该规则的天数时不再尝试其他规则
+func planAutoTransfers(ctx context.Context, at time.Time) (*models.AutoTransferPlan, []*autoTransferRule, error) {
+	rules, invalid, err := loadAutoTransferRules(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	plan := &models.AutoTransferPlan{At: at, Items: []models.AutoTransferPlanItem{}, InvalidRules: invalid}
+	if len(rules) == 0 {
+		return plan, rules, nil
+	}
+
+	customers, err := repository.Customers().Find(ctx, bson.M{
+		"progress": models.CustomerProgressInitialContact,
+	}, repository.NewFindOptions().SetSort("_id", 1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("查询客户失败: %w", err)
+	}
+	plan.Checked = len(customers)
+
+	for i := range customers {
+		customer := &customers[i]
+		index := slices.IndexFunc(rules, func(rule *autoTransferRule) bool { return rule.matches(customer) })
+		if index < 0 {
+			continue
+		}
+		rule := rules[index]
+
+		// 确定参考时间 (InitialContactTime 或 CreatedAt)
+		referenceTime := customer.InitialContactTime
+		if referenceTime.IsZero() {
+			referenceTime = customer.CreatedAt
+		}
+		days := int(at.Sub(referenceTime).Hours() / 24)
+		if days < rule.DaysWithoutProgress {
+			continue
+		}
+		target, ok := rule.next(customer.RelatedSalesID)
+		if !ok {
+			// 池中只有当前销售时，关联代理商的客户仍转为该销售直接跟进，解除代理商关联
+			if customer.RelatedAgentID == "" {
+				continue
+			}
+			target = rule.targets[0]
+		}
+		plan.Items = append(plan.Items, models.AutoTransferPlanItem{
+			CustomerID:          customer.ID.Hex(),
+			CustomerName:        customer.Name,
+			Importance:          customer.Importance,
+			FromSalesID:         customer.RelatedSalesID,
+			FromSalesName:       customer.RelatedSalesName,
+			FromAgentName:       customer.RelatedAgentName,
+			ToSalesID:           target.id,
+			ToSalesName:         target.name,
+			RuleKey:             rule.key,
+			ReferenceTime:       referenceTime,
+			DaysWithoutProgress: days,
+		})
+	}
+	return plan, rules, nil
+}
+
+// PreviewAutoTransfer 预览下一次自动转移任务将转移的客户，不做任何修改
+// 定时执行停用时按当前时间计算
+func PreviewAutoTransfer(ctx context.Context) (*models.AutoTransferPlan, error) {
+	at := time.Now()
+	if jobScheduler != nil {
+		if next := jobScheduler.NextRunAt(JobCustomerAutoTransfer, at); !next.IsZero() {
+			at = next
+		}
+	}
+	plan, _, err := planAutoTransfers(ctx, at)
+	return plan, err
+}
+
+// ProcessInitialContactCustomers 按自动转移规则将长期无进展的初步接触客户转移给目标销售，
+// 以系统身份执行并记录分配历史，返回检查、转移和转移失败的客户数
+func ProcessInitialContactCustomers(ctx context.Context) (map[string]int64, error) {
+	counts := map[string]int64{"checked": 0, "transferred": 0, "failed": 0, "invalidRules": 0}
+	plan, rules, err := planAutoTransfers(ctx, time.Now())
+	if err != nil {
+		return counts, err
+	}
+	counts["checked"] = int64(plan.Checked)
+	counts["invalidRules"] = int64(len(plan.InvalidRules))
+	for _, invalid := range plan.InvalidRules {
+		utils.Logger.Warn().Str("configKey", invalid.ConfigKey).Str("error", invalid.Error).Msg("自动转移规则无效，已跳过")
+	}
+	if len(rules) == 0 {
+		utils.Logger.Info().Msg("未找到有效的自动转移规则")
+		return counts, nil
+	}
+
+	actor := SystemActor()
+	for _, item := range plan.Items {
+		if err := ctx.Err(); err != nil {
+			return counts, err
+		}
+		if err, _, _ := AssignCustomer(ctx, item.CustomerID, AssignRequest{SalesId: item.ToSalesID}, actor); err != nil {
+			utils.LogError(err, map[string]interface{}{
+				"customerId": item.CustomerID,
+				"customer":   item.CustomerName,
+				"toSalesId":  item.ToSalesID,
+				"rule":       item.RuleKey,
+			}, "客户自动转移失败")
+			counts["failed"]++
+			continue
+		}
+		counts["transferred"]++
+		utils.LogInfo(map[string]interface{}{
+			"customer": item.CustomerName,
+			"from":     item.FromSalesName,
+			"to":       item.ToSalesName,
+			"days":     item.DaysWithoutProgress,
+			"rule":     item.RuleKey,
+		}, "客户已自动转移")
+	}
+
+	// 保存各规则的轮流分配位置，下次从下一位销售开始
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), autoTransferSaveTimeout)
+	defer cancel()
+	for _, rule := range rules {
+		if !rule.moved {
+			continue
+		}
+		if _, err := repository.SystemConfigs().UpdateByID(saveCtx, rule.configID, bson.M{"$set": bson.M{"roundRobinCursor": rule.cursor}}); err != nil {
+			utils.LogError(err, map[string]interface{}{"configKey": rule.key}, "保存自动转移轮流分配位置失败")
+		}
+	}
+	return counts, nil
+}
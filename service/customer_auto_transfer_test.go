@@ -0,0 +1,163 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAutoTransferRoundRobin(t *testing.T) {
+	ctx := useMemoryRepositories(t)
+	salesA := insertSales(t, ctx, "销售甲")
+	salesB := insertSales(t, ctx, "销售乙")
+	salesC := insertSales(t, ctx, "销售丙")
+	vipSales := insertSales(t, ctx, "大客户销售")
+	oldOwner := insertSales(t, ctx, "原销售")
+	if _, err := repository.Users().UpdateByID(ctx, salesC.ID, bson.M{"$set": bson.M{"status": models.UserStatusREJECTED}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 销售池中已停用的销售被跳过
+	poolRule := insertConfig(t, ctx, models.ConfigTypeCustomerAutoTransfer, "pool", bson.M{
+		"priority":            2,
+		"daysWithoutProgress": 10,
+		"targetSalesIds":      bson.A{salesA.ID.Hex(), salesC.ID.Hex(), salesB.ID.Hex()},
+	})
+	// 旧版配置的单个目标销售，优先级更高
+	insertConfig(t, ctx, models.ConfigTypeCustomerAutoTransfer, "vip", bson.M{
+		"priority":            1,
+		"daysWithoutProgress": 30,
+		"importances":         bson.A{"重要"},
+		"targetSalesId":       vipSales.ID.Hex(),
+	})
+	insertConfig(t, ctx, models.ConfigTypeCustomerAutoTransfer, "broken", bson.M{"daysWithoutProgress": 0})
+
+	customer := func(name string, owner models.User, importance string, days int) models.Customer {
+		return insertCustomer(t, ctx, models.Customer{
+			Name:               name,
+			Importance:         importance,
+			Progress:           models.CustomerProgressInitialContact,
+			RelatedSalesID:     owner.ID.Hex(),
+			RelatedSalesName:   owner.Username,
+			InitialContactTime: time.Now().AddDate(0, 0, -days),
+		})
+	}
+	first := customer("客户一", oldOwner, "一般", 20)
+	second := customer("客户二", oldOwner, "一般", 20)
+	ownedByA := customer("客户三", salesA, "一般", 20)
+	fourth := customer("客户四", oldOwner, "一般", 20)
+	// 匹配高优先级规则但未达到其天数时，不再按其他规则转移
+	customer("重要客户未到期", oldOwner, "重要", 20)
+	vip := customer("重要客户", oldOwner, "重要", 40)
+	customer("新客户", oldOwner, "一般", 5)
+
+	want := map[string]string{
+		first.ID.Hex():    salesA.ID.Hex(),
+		second.ID.Hex():   salesB.ID.Hex(),
+		ownedByA.ID.Hex(): salesB.ID.Hex(), // 轮到当前销售时跳过
+		fourth.ID.Hex():   salesA.ID.Hex(),
+		vip.ID.Hex():      vipSales.ID.Hex(),
+	}
+
+	plan, err := PreviewAutoTransfer(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Checked != 7 || len(plan.Items) != len(want) || len(plan.InvalidRules) != 1 || plan.InvalidRules[0].ConfigKey != "broken" {
+		t.Fatalf("plan = %+v", plan)
+	}
+	for _, item := range plan.Items {
+		if want[item.CustomerID] != item.ToSalesID {
+			t.Fatalf("%s 转移给 %s, want %s", item.CustomerName, item.ToSalesName, want[item.CustomerID])
+		}
+	}
+	if got := findCustomer(t, ctx, first.ID).RelatedSalesID; got != oldOwner.ID.Hex() {
+		t.Fatal("预览不应修改客户")
+	}
+
+	counts, err := ProcessInitialContactCustomers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts["checked"] != 7 || counts["transferred"] != int64(len(want)) || counts["failed"] != 0 || counts["invalidRules"] != 1 {
+		t.Fatalf("counts = %v", counts)
+	}
+	for customerID, salesID := range want {
+		histories, err := repository.AssignmentHistory().FindByCustomerID(ctx, customerID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(histories) != 1 || histories[0].ToRelatedSalesID != salesID || histories[0].OperatorID != SystemActor().ID {
+			t.Fatalf("客户 %s 的分配历史 = %+v", customerID, histories)
+		}
+	}
+	transferred := findCustomer(t, ctx, first.ID)
+	if transferred.RelatedSalesID != salesA.ID.Hex() || transferred.RelatedSalesName != salesA.Username {
+		t.Fatalf("转移后 = %+v", transferred)
+	}
+	if time.Since(transferred.InitialContactTime) > time.Minute {
+		t.Fatal("转移后应重新计算初步接触时间")
+	}
+
+	// 轮流分配位置已保存，下次从销售乙开始
+	config, err := repository.SystemConfigs().FindByID(ctx, poolRule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.RoundRobinCursor != 1 {
+		t.Fatalf("roundRobinCursor = %d, want 1", config.RoundRobinCursor)
+	}
+	next := customer("客户五", oldOwner, "一般", 20)
+	if _, err := ProcessInitialContactCustomers(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := findCustomer(t, ctx, next.ID).RelatedSalesID; got != salesB.ID.Hex() {
+		t.Fatalf("下一次转移给 %s, want %s", got, salesB.ID.Hex())
+	}
+}
+
+func TestAutoTransferClearsAgentOfTargetSales(t *testing.T) {
+	ctx := useMemoryRepositories(t)
+	target := insertSales(t, ctx, "目标销售")
+	insertConfig(t, ctx, models.ConfigTypeCustomerAutoTransfer, "single", bson.M{
+		"daysWithoutProgress": 10,
+		"targetSalesId":       target.ID.Hex(),
+	})
+	customer := func(name string, agentID string) models.Customer {
+		return insertCustomer(t, ctx, models.Customer{
+			Name:               name,
+			Progress:           models.CustomerProgressInitialContact,
+			RelatedSalesID:     target.ID.Hex(),
+			RelatedSalesName:   target.Username,
+			RelatedAgentID:     agentID,
+			RelatedAgentName:   "某代理商",
+			InitialContactTime: time.Now().AddDate(0, 0, -20),
+		})
+	}
+	// 已归属目标销售但仍关联代理商的客户转为目标销售直接跟进，未关联代理商的不转移
+	withAgent := customer("代理商客户", primitive.NewObjectID().Hex())
+	customer("直属客户", "")
+
+	counts, err := ProcessInitialContactCustomers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts["checked"] != 2 || counts["transferred"] != 1 || counts["failed"] != 0 {
+		t.Fatalf("counts = %v", counts)
+	}
+	transferred := findCustomer(t, ctx, withAgent.ID)
+	if transferred.RelatedSalesID != target.ID.Hex() || transferred.RelatedAgentID != "" || transferred.RelatedAgentName != "" {
+		t.Fatalf("转移后 = %+v", transferred)
+	}
+	histories, err := repository.AssignmentHistory().FindByCustomerID(ctx, withAgent.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(histories) != 1 || histories[0].FromRelatedAgentID != withAgent.RelatedAgentID || histories[0].ToRelatedAgentID != "" {
+		t.Fatalf("分配历史 = %+v", histories)
+	}
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 查重评分阈值
+const (
+	// DuplicateBlockScore 达到该分数视为重复，创建和导入时需确认后才能继续
+	DuplicateBlockScore = 90
+	// DuplicateHintScore 达到该分数作为疑似重复返回提示
+	DuplicateHintScore = 60
+	// similarNameThreshold 规范化名称相似度达到该值才参与评分
+	similarNameThreshold = 0.8
+	// minPhoneDigits 参与比对的电话号码最少位数
+	minPhoneDigits = 7
+	// maxDuplicateCandidates 单个客户返回的疑似重复数量上限
+	maxDuplicateCandidates = 20
+)
+
+// duplicateEntry 参与查重的客户及其规范化名称和电话
+type duplicateEntry struct {
+	customer models.Customer
+	rawName  string
+	name     string
+	phone    string
+}
+
+func newDuplicateEntry(customer models.Customer) duplicateEntry {
+	return duplicateEntry{
+		customer: customer,
+		rawName:  strings.TrimSpace(customer.Name),
+		name:     utils.NormalizeCompanyName(customer.Name),
+		phone:    utils.NormalizePhone(customer.ContactPhone),
+	}
+}
+
+func (e duplicateEntry) candidate(score int, reasons []string) models.DuplicateCandidate {
+	if reasons == nil {
+		reasons = []string{}
+	}
+	return models.DuplicateCandidate{
+		CustomerID:       e.customer.ID.Hex(),
+		Name:             e.customer.Name,
+		Progress:         e.customer.Progress,
+		RelatedSalesName: e.customer.RelatedSalesName,
+		IsInPublicPool:   e.customer.IsInPublicPool,
+		Score:            score,
+		Reasons:          reasons,
+	}
+}
+
+// scoreDuplicate 计算两个客户的重复评分：名称相同 100，规范化名称相同 95，
+// 名称相似按相似度折算，电话相同 85；名称与电话同时命中时加 10 分（最高 100）
+func scoreDuplicate(a, b duplicateEntry) (int, []string) {
+	var reasons []string
+	nameScore := 0
+	switch {
+	case a.rawName != "" && a.rawName == b.rawName:
+		nameScore = 100
+		reasons = append(reasons, "名称相同")
+	case a.name != "" && a.name == b.name:
+		nameScore = 95
+		reasons = append(reasons, "规范化后名称相同")
+	default:
+		if similarity := utils.NameSimilarity(a.name, b.name); similarity >= similarNameThreshold {
+			nameScore = int(math.Round(similarity * 85))
+			reasons = append(reasons, fmt.Sprintf("名称相似度 %.0f%%", similarity*100))
+		}
+	}
+
+	phoneScore := 0
+	if len(a.phone) >= minPhoneDigits && a.phone == b.phone {
+		phoneScore = 85
+		reasons = append(reasons, "联系电话相同")
+	}
+
+	score := max(nameScore, phoneScore)
+	if nameScore > 0 && phoneScore > 0 {
+		score = min(100, score+10)
+	}
+	return score, reasons
+}
+
+// loadDuplicateEntries 读取参与查重的客户，只取查重和展示需要的字段
+func loadDuplicateEntries(ctx context.Context) ([]duplicateEntry, error) {
+	customers, err := repository.Customers().Find(ctx, bson.M{}, repository.NewFindOptions().SetProjection(bson.M{
+		"name":             1,
+		"contactPhone":     1,
+		"progress":         1,
+		"relatedSalesName": 1,
+		"isInPublicPool":   1,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("读取客户数据失败: %w", err)
+	}
+	entries := make([]duplicateEntry, len(customers))
+	for i, customer := range customers {
+		entries[i] = newDuplicateEntry(customer)
+	}
+	return entries, nil
+}
+
+// matchDuplicates 在已有客户中查找与 target 疑似重复的客户，按分数倒序
+func matchDuplicates(target duplicateEntry, entries []duplicateEntry, minScore int) []models.DuplicateCandidate {
+	candidates := []models.DuplicateCandidate{}
+	for _, entry := range entries {
+		if !target.customer.ID.IsZero() && entry.customer.ID == target.customer.ID {
+			continue
+		}
+		if score, reasons := scoreDuplicate(target, entry); score >= minScore {
+			candidates = append(candidates, entry.candidate(score, reasons))
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	if len(candidates) > maxDuplicateCandidates {
+		candidates = candidates[:maxDuplicateCandidates]
+	}
+	return candidates
+}
+
+// FindDuplicateCandidates 按名称和联系电话查找疑似重复的客户
+func FindDuplicateCandidates(ctx context.Context, name string, phone string, minScore int) ([]models.DuplicateCandidate, error) {
+	entries, err := loadDuplicateEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	target := newDuplicateEntry(models.Customer{Name: name, ContactPhone: phone})
+	return matchDuplicates(target, entries, minScore), nil
+}
+
+// DuplicateInput 待查重的客户名称和联系电话
+type DuplicateInput struct {
+	Name  string
+	Phone string
+	// Row 结果和提示中使用的行号，为0时使用在批次中的序号（从1开始）
+	Row int
+}
+
+// FindBulkDuplicates 批量查重：每行与已有客户以及同批次中排在前面的行比对，只返回存在疑似重复的行
+func FindBulkDuplicates(ctx context.Context, rows []DuplicateInput, minScore int) ([]models.BulkImportDuplicate, error) {
+	entries, err := loadDuplicateEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []models.BulkImportDuplicate{}
+	batch := make([]duplicateEntry, 0, len(rows))
+	batchRows := make([]int, 0, len(rows))
+	for i, row := range rows {
+		rowNumber := row.Row
+		if rowNumber == 0 {
+			rowNumber = i + 1
+		}
+		target := newDuplicateEntry(models.Customer{Name: row.Name, ContactPhone: row.Phone})
+		candidates := matchDuplicates(target, entries, minScore)
+		for j, previous := range batch {
+			if score, reasons := scoreDuplicate(target, previous); score >= minScore {
+				candidates = append(candidates, models.DuplicateCandidate{
+					Name:    previous.customer.Name,
+					Score:   score,
+					Reasons: append(reasons, fmt.Sprintf("与导入数据第%d行重复", batchRows[j])),
+				})
+			}
+		}
+		batch = append(batch, target)
+		batchRows = append(batchRows, rowNumber)
+		if len(candidates) > 0 {
+			sort.SliceStable(candidates, func(a, b int) bool { return candidates[a].Score > candidates[b].Score })
+			result = append(result, models.BulkImportDuplicate{Row: rowNumber, Name: row.Name, Candidates: candidates})
+		}
+	}
+	return result, nil
+}
+
+// HasBlockingDuplicate 判断疑似重复中是否存在达到拦截分数的客户
+func HasBlockingDuplicate(candidates []models.DuplicateCandidate) bool {
+	for _, candidate := range candidates {
+		if candidate.Score >= DuplicateBlockScore {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildDuplicateReport 扫描全部客户生成查重报告，疑似重复的客户按连通关系分组
+// 为控制计算量，只比对规范化名称相同、电话相同或规范化名称前两个字相同的客户
+func BuildDuplicateReport(ctx context.Context, minScore int) (*models.DuplicateReport, error) {
+	entries, err := loadDuplicateEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make(map[string][]int)
+	for i, entry := range entries {
+		keys := map[string]bool{}
+		if prefix := []rune(entry.name); len(prefix) >= 2 {
+			keys["name:"+string(prefix[:2])] = true
+		} else if entry.name != "" {
+			keys["name:"+entry.name] = true
+		}
+		if len(entry.phone) >= minPhoneDigits {
+			keys["phone:"+entry.phone] = true
+		}
+		for key := range keys {
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	type pairKey struct{ a, b int }
+	pairs := make(map[pairKey]models.DuplicatePair)
+	for _, members := range buckets {
+		for x := 0; x < len(members); x++ {
+			for y := x + 1; y < len(members); y++ {
+				a, b := members[x], members[y]
+				key := pairKey{a, b}
+				if _, done := pairs[key]; done {
+					continue
+				}
+				score, reasons := scoreDuplicate(entries[a], entries[b])
+				if score < minScore {
+					continue
+				}
+				pairs[key] = models.DuplicatePair{
+					CustomerID:      entries[a].customer.ID.Hex(),
+					OtherCustomerID: entries[b].customer.ID.Hex(),
+					Score:           score,
+					Reasons:         reasons,
+				}
+			}
+		}
+	}
+
+	// 并查集按连通关系分组
+	parent := make([]int, len(entries))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for key := range pairs {
+		parent[find(key.a)] = find(key.b)
+	}
+
+	groupIndex := make(map[int]int)
+	groups := []models.DuplicateGroup{}
+	bestScore := make(map[int]int)
+	for key, pair := range pairs {
+		bestScore[key.a] = max(bestScore[key.a], pair.Score)
+		bestScore[key.b] = max(bestScore[key.b], pair.Score)
+	}
+	for i := range entries {
+		if _, ok := bestScore[i]; !ok {
+			continue
+		}
+		root := find(i)
+		index, ok := groupIndex[root]
+		if !ok {
+			index = len(groups)
+			groupIndex[root] = index
+			groups = append(groups, models.DuplicateGroup{})
+		}
+		groups[index].Customers = append(groups[index].Customers, entries[i].candidate(bestScore[i], nil))
+		groups[index].Score = max(groups[index].Score, bestScore[i])
+	}
+	for key, pair := range pairs {
+		index := groupIndex[find(key.a)]
+		groups[index].Pairs = append(groups[index].Pairs, pair)
+	}
+	for i := range groups {
+		sort.Slice(groups[i].Pairs, func(a, b int) bool { return groups[i].Pairs[a].Score > groups[i].Pairs[b].Score })
+	}
+	sort.SliceStable(groups, func(a, b int) bool { return groups[a].Score > groups[b].Score })
+
+	return &models.DuplicateReport{
+		GeneratedAt: time.Now(),
+		Scanned:     len(entries),
+		MinScore:    minScore,
+		Groups:      groups,
+	}, nil
+}
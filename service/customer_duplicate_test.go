@@ -0,0 +1,116 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/BerniceZTT/crm_end/models"
+)
+
+func TestScoreDuplicate(t *testing.T) {
+	cases := []struct {
+		name        string
+		a, b        models.Customer
+		wantScore   int
+		wantReasons []string
+	}{
+		{
+			name:        "名称相同",
+			a:           models.Customer{Name: "深圳某某科技有限公司"},
+			b:           models.Customer{Name: " 深圳某某科技有限公司 "},
+			wantScore:   100,
+			wantReasons: []string{"名称相同"},
+		},
+		{
+			name:        "规范化后名称相同",
+			a:           models.Customer{Name: "深圳某某科技有限公司"},
+			b:           models.Customer{Name: "某某科技（深圳）有限公司"},
+			wantScore:   95,
+			wantReasons: []string{"规范化后名称相同"},
+		},
+		{
+			name:        "名称相似",
+			a:           models.Customer{Name: "某某科技有限公司"},
+			b:           models.Customer{Name: "某某科技园有限公司"},
+			wantScore:   73,
+			wantReasons: []string{"名称相似度 86%"},
+		},
+		{
+			name:      "名称相似度不足",
+			a:         models.Customer{Name: "某某科技有限公司"},
+			b:         models.Customer{Name: "某某电子有限公司"},
+			wantScore: 0,
+		},
+		{
+			name:        "电话相同",
+			a:           models.Customer{Name: "甲公司", ContactPhone: "138-0013-8000"},
+			b:           models.Customer{Name: "乙公司", ContactPhone: "+86 13800138000"},
+			wantScore:   85,
+			wantReasons: []string{"联系电话相同"},
+		},
+		{
+			name:      "电话过短不参与比对",
+			a:         models.Customer{Name: "甲公司", ContactPhone: "12345"},
+			b:         models.Customer{Name: "乙公司", ContactPhone: "12345"},
+			wantScore: 0,
+		},
+		{
+			name:        "名称和电话同时命中加分",
+			a:           models.Customer{Name: "某某科技有限公司", ContactPhone: "13800138000"},
+			b:           models.Customer{Name: "某某科技园有限公司", ContactPhone: "13800138000"},
+			wantScore:   95,
+			wantReasons: []string{"名称相似度 86%", "联系电话相同"},
+		},
+		{
+			name:        "加分后最高 100",
+			a:           models.Customer{Name: "深圳某某科技有限公司", ContactPhone: "13800138000"},
+			b:           models.Customer{Name: "某某科技有限公司", ContactPhone: "13800138000"},
+			wantScore:   100,
+			wantReasons: []string{"规范化后名称相同", "联系电话相同"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			score, reasons := scoreDuplicate(newDuplicateEntry(tc.a), newDuplicateEntry(tc.b))
+			if score != tc.wantScore || !reflect.DeepEqual(reasons, tc.wantReasons) {
+				t.Fatalf("scoreDuplicate = %d %q, want %d %q", score, reasons, tc.wantScore, tc.wantReasons)
+			}
+		})
+	}
+}
+
+func TestFindDuplicateCandidates(t *testing.T) {
+	ctx := useMemoryRepositories(t)
+	same := insertCustomer(t, ctx, models.Customer{Name: "某某科技(深圳)有限公司"})
+	phone := insertCustomer(t, ctx, models.Customer{Name: "甲乙丙丁有限公司", ContactPhone: "13800138000"})
+	insertCustomer(t, ctx, models.Customer{Name: "某某电子有限公司"})
+
+	candidates, err := FindDuplicateCandidates(ctx, "深圳市某某科技有限公司", "138 0013 8000", DuplicateHintScore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("candidates = %+v", candidates)
+	}
+	if candidates[0].CustomerID != same.ID.Hex() || candidates[0].Score != 95 {
+		t.Fatalf("第一个候选 = %+v", candidates[0])
+	}
+	if candidates[1].CustomerID != phone.ID.Hex() || candidates[1].Score != 85 {
+		t.Fatalf("第二个候选 = %+v", candidates[1])
+	}
+	if !HasBlockingDuplicate(candidates) || HasBlockingDuplicate(candidates[1:]) {
+		t.Fatal("只有达到拦截分数的候选才阻止创建")
+	}
+
+	// 回收站中的客户不参与查重
+	if err := SoftDeleteCustomer(ctx, &same, SystemActor()); err != nil {
+		t.Fatal(err)
+	}
+	candidates, err = FindDuplicateCandidates(ctx, "深圳市某某科技有限公司", "", DuplicateHintScore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 0 {
+		t.Fatalf("candidates = %+v", candidates)
+	}
+}
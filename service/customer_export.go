@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// customerExportBatchSize 导出时每批解析关联名称的客户数
+const customerExportBatchSize = 500
+
+// customerExportHeaders 客户导出的列，前12列与导入模板一致，导出的文件可直接用于导入
+var customerExportHeaders = []string{
+	"客户名称", "客户性质", "重要程度", "应用领域", "产品需求", "联系人", "联系电话", "地址",
+	"进展状态", "年需求量", "关联销售", "关联代理商",
+	"创建人", "创建人类型", "初次接触时间", "最后更新时间", "创建时间",
+}
+
+// publicPoolExportHeaders 公海客户导出的列，与公海列表可见的字段一致
+var publicPoolExportHeaders = []string{"客户名称", "地址", "创建时间"}
+
+// formatExportTime 导出文件中的时间格式，零值输出为空
+func formatExportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// ExportCustomers 按条件逐条读取客户并写入表格，按最后更新时间倒序，返回导出的客户数
+// 关联销售和代理商的名称按批次查询，与客户列表一致以当前名称为准；
+// publicPool 为 true 时只导出公海列表可见的字段
+func ExportCustomers(ctx context.Context, filter bson.M, publicPool bool, writer utils.SpreadsheetWriter) (int, error) {
+	headers := customerExportHeaders
+	if publicPool {
+		headers = publicPoolExportHeaders
+	}
+	if err := writer.WriteRow(headers); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	batch := make([]models.Customer, 0, customerExportBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := writeCustomerExportBatch(ctx, batch, publicPool, writer); err != nil {
+			return err
+		}
+		count += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	err := repository.Customers().Each(ctx, filter, func(customer *models.Customer) error {
+		batch = append(batch, *customer)
+		if len(batch) < customerExportBatchSize {
+			return nil
+		}
+		return flush()
+	}, repository.NewFindOptions().SetSort("lastUpdateTime", -1))
+	if err != nil {
+		return count, fmt.Errorf("读取客户数据失败: %w", err)
+	}
+	if err := flush(); err != nil {
+		return count, err
+	}
+	return count, writer.Close()
+}
+
+// writeCustomerExportBatch 解析一批客户的关联名称并写出
+func writeCustomerExportBatch(ctx context.Context, customers []models.Customer, publicPool bool, writer utils.SpreadsheetWriter) error {
+	if publicPool {
+		for _, customer := range customers {
+			if err := writer.WriteRow([]string{customer.Name, customer.Address, formatExportTime(customer.CreatedAt)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	salesIDs := []string{}
+	agentIDs := []string{}
+	for _, customer := range customers {
+		if customer.OwnerType == string(models.UserRoleFACTORY_SALES) {
+			salesIDs = append(salesIDs, customer.OwnerID)
+		} else if customer.OwnerType == string(models.UserRoleAGENT) {
+			agentIDs = append(agentIDs, customer.OwnerID)
+		}
+		if customer.RelatedSalesID != "" {
+			salesIDs = append(salesIDs, customer.RelatedSalesID)
+		}
+		if customer.RelatedAgentID != "" {
+			agentIDs = append(agentIDs, customer.RelatedAgentID)
+		}
+	}
+	salesNames, err := repository.Users().NamesByIDs(ctx, salesIDs)
+	if err != nil {
+		return fmt.Errorf("查询销售名称失败: %w", err)
+	}
+	agentNames, err := repository.Agents().NamesByIDs(ctx, agentIDs)
+	if err != nil {
+		return fmt.Errorf("查询代理商名称失败: %w", err)
+	}
+	nameOf := func(names map[string]string, id string) string {
+		if id == "" {
+			return ""
+		}
+		if name := names[id]; name != "" {
+			return name
+		}
+		return "未知"
+	}
+
+	for _, customer := range customers {
+		ownerName, ownerType := customer.OwnerName, customer.OwnerType
+		switch customer.OwnerType {
+		case string(models.UserRoleFACTORY_SALES):
+			ownerName, ownerType = nameOf(salesNames, customer.OwnerID), "原厂销售"
+		case string(models.UserRoleAGENT):
+			ownerName, ownerType = nameOf(agentNames, customer.OwnerID), "代理商"
+		}
+		annualDemand := ""
+		if customer.AnnualDemand != 0 {
+			annualDemand = strconv.FormatFloat(customer.AnnualDemand, 'f', -1, 64)
+		}
+		row := []string{
+			customer.Name,
+			customer.Nature,
+			customer.Importance,
+			customer.ApplicationField,
+			strings.Join(customer.ProductNeeds, "、"),
+			customer.ContactPerson,
+			customer.ContactPhone,
+			customer.Address,
+			customer.Progress,
+			annualDemand,
+			nameOf(salesNames, customer.RelatedSalesID),
+			nameOf(agentNames, customer.RelatedAgentID),
+			ownerName,
+			ownerType,
+			formatExportTime(customer.InitialContactTime),
+			formatExportTime(customer.LastUpdateTime),
+			formatExportTime(customer.CreatedAt),
+		}
+		if err := writer.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
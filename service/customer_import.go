@@ -0,0 +1,681 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// MaxCustomerImportFileSize 导入文件的最大字节数
+	MaxCustomerImportFileSize = 10 << 20
+	// MaxCustomerImportRows 单个文件最多导入的客户行数
+	MaxCustomerImportRows = 5000
+	// customerImportTTL 预览结果的保留时间，过期后需重新上传
+	customerImportTTL = 24 * time.Hour
+	// importSuggestionThreshold 名称相似度达到该值时在错误中提示可能的名称
+	importSuggestionThreshold = 0.6
+)
+
+// customerImportFields 导入模板的列，按模板中的顺序排列
+var customerImportFields = []models.CustomerImportField{
+	{Field: "name", Label: "客户名称", Required: true, Aliases: []string{"客户", "公司名称", "企业名称", "名称"}},
+	{Field: "nature", Label: "客户性质", Required: true, Aliases: []string{"性质", "客户类型"}},
+	{Field: "importance", Label: "重要程度", Required: true, Aliases: []string{"客户重要程度", "重要性", "客户级别"}},
+	{Field: "applicationField", Label: "应用领域", Aliases: []string{"领域", "行业"}},
+	{Field: "productNeeds", Label: "产品需求", Aliases: []string{"需求产品", "产品"}},
+	{Field: "contactPerson", Label: "联系人", Aliases: []string{"联系人姓名"}},
+	{Field: "contactPhone", Label: "联系电话", Aliases: []string{"电话", "手机", "手机号", "联系方式"}},
+	{Field: "address", Label: "地址", Aliases: []string{"公司地址", "客户地址"}},
+	{Field: "progress", Label: "进展状态", Aliases: []string{"客户进展", "进展"}},
+	{Field: "annualDemand", Label: "年需求量", Aliases: []string{"年需求", "需求量"}},
+	{Field: "relatedSalesName", Label: "关联销售", Aliases: []string{"销售", "所属销售", "销售名称"}},
+	{Field: "relatedAgentName", Label: "关联代理商", Aliases: []string{"代理商", "所属代理商", "代理商名称"}},
+}
+
+// productNeedsSeparator 产品需求单元格中多个产品的分隔符
+var productNeedsSeparator = regexp.MustCompile(`[,，、;；\n]+`)
+
+// CustomerImportFields 导入模板的列定义
+func CustomerImportFields() []models.CustomerImportField {
+	return customerImportFields
+}
+
+// CustomerImportTemplateHeaders 导入模板的标题行
+func CustomerImportTemplateHeaders() []string {
+	headers := make([]string, len(customerImportFields))
+	for i, field := range customerImportFields {
+		headers[i] = field.Label
+	}
+	return headers
+}
+
+// resolveImportColumns 识别每列对应的字段：mapping（列标题 -> 字段，字段为空表示忽略该列）优先，
+// 其余列按模板标题或别名自动识别，无法识别的列忽略
+func resolveImportColumns(headers []string, mapping map[string]string) ([]string, error) {
+	byName := make(map[string]string)
+	known := make(map[string]bool, len(customerImportFields))
+	for _, field := range customerImportFields {
+		known[field.Field] = true
+		byName[utils.NormalizeLookupName(field.Label)] = field.Field
+		byName[strings.ToLower(field.Field)] = field.Field
+		for _, alias := range field.Aliases {
+			byName[utils.NormalizeLookupName(alias)] = field.Field
+		}
+	}
+	for header, field := range mapping {
+		if field != "" && !known[field] {
+			return nil, utils.CreateBadRequestError(fmt.Sprintf("列「%s」映射到未知字段: %s", header, field))
+		}
+	}
+
+	columns := make([]string, len(headers))
+	used := make(map[string]string)
+	for i, header := range headers {
+		field, mapped := mapping[header]
+		if !mapped {
+			field = byName[utils.NormalizeLookupName(header)]
+		}
+		if field == "" {
+			continue
+		}
+		if previous, exists := used[field]; exists {
+			return nil, utils.CreateBadRequestError(fmt.Sprintf("列「%s」和「%s」对应同一字段，请指定列映射", previous, header))
+		}
+		used[field] = header
+		columns[i] = field
+	}
+
+	var missing []string
+	for _, field := range customerImportFields {
+		if _, ok := used[field.Field]; field.Required && !ok {
+			missing = append(missing, field.Label)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, utils.CreateBadRequestError(fmt.Sprintf("缺少必填列: %s", strings.Join(missing, "、")))
+	}
+	return columns, nil
+}
+
+// importLookup 校验导入行时使用的参考数据，销售和代理商按规范化名称索引
+type importLookup struct {
+	sales         map[string][]models.User
+	agents        map[string][]models.Agent
+	products      map[string]bool
+	existingNames map[string]bool
+}
+
+// loadImportLookup 读取销售、代理商、产品和与导入数据同名的已有客户
+func loadImportLookup(ctx context.Context, rows []models.CustomerImportRow) (*importLookup, error) {
+	lookup := &importLookup{
+		sales:         make(map[string][]models.User),
+		agents:        make(map[string][]models.Agent),
+		products:      make(map[string]bool),
+		existingNames: make(map[string]bool),
+	}
+
+	users, err := repository.Users().Find(ctx, bson.M{"role": models.UserRoleFACTORY_SALES},
+		repository.NewFindOptions().SetProjection(bson.M{"_id": 1, "username": 1, "role": 1, "status": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("读取销售数据失败: %w", err)
+	}
+	for _, user := range users {
+		key := utils.NormalizeLookupName(user.Username)
+		lookup.sales[key] = append(lookup.sales[key], user)
+	}
+
+	agents, err := repository.Agents().Find(ctx, bson.M{},
+		repository.NewFindOptions().SetProjection(bson.M{"_id": 1, "companyName": 1, "relatedSalesId": 1, "relatedSalesName": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("读取代理商数据失败: %w", err)
+	}
+	for _, agent := range agents {
+		key := utils.NormalizeLookupName(agent.CompanyName)
+		lookup.agents[key] = append(lookup.agents[key], agent)
+	}
+
+	products, err := repository.Products().Find(ctx, bson.M{},
+		repository.NewFindOptions().SetProjection(bson.M{"_id": 1, "modelName": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("读取产品数据失败: %w", err)
+	}
+	for _, product := range products {
+		lookup.products[product.ModelName] = true
+	}
+
+	names := []string{}
+	for _, row := range rows {
+		if row.Data.Name != "" {
+			names = append(names, row.Data.Name)
+		}
+	}
+	if len(names) > 0 {
+		existing, err := repository.Customers().Find(ctx, bson.M{"name": bson.M{"$in": names}},
+			repository.NewFindOptions().SetProjection(bson.M{"name": 1}))
+		if err != nil {
+			return nil, fmt.Errorf("读取客户数据失败: %w", err)
+		}
+		for _, customer := range existing {
+			lookup.existingNames[customer.Name] = true
+		}
+	}
+	return lookup, nil
+}
+
+// suggestName 在候选名称中查找与 name 最相似的名称，相似度不足时返回空字符串
+func suggestName(name string, candidates []string) string {
+	target := utils.NormalizeLookupName(name)
+	best, bestScore := "", importSuggestionThreshold
+	for _, candidate := range candidates {
+		if score := utils.NameSimilarity(target, utils.NormalizeLookupName(candidate)); score >= bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	return best
+}
+
+// notFoundMessage 名称不存在时的错误信息，附带相似名称提示
+func notFoundMessage(kind, name, suggestion string) string {
+	if suggestion != "" {
+		return fmt.Sprintf("%s「%s」不存在，是否为「%s」", kind, name, suggestion)
+	}
+	return fmt.Sprintf("%s「%s」不存在", kind, name)
+}
+
+func (l *importLookup) resolveSales(name string) (*models.User, string) {
+	matches := l.sales[utils.NormalizeLookupName(name)]
+	switch len(matches) {
+	case 0:
+		candidates := []string{}
+		for _, users := range l.sales {
+			for _, user := range users {
+				candidates = append(candidates, user.Username)
+			}
+		}
+		return nil, notFoundMessage("销售", name, suggestName(name, candidates))
+	case 1:
+		return &matches[0], ""
+	}
+	return nil, fmt.Sprintf("销售「%s」对应多个用户，请在系统中区分后再导入", name)
+}
+
+func (l *importLookup) resolveAgent(name string) (*models.Agent, string) {
+	matches := l.agents[utils.NormalizeLookupName(name)]
+	switch len(matches) {
+	case 0:
+		candidates := []string{}
+		for _, agents := range l.agents {
+			for _, agent := range agents {
+				candidates = append(candidates, agent.CompanyName)
+			}
+		}
+		return nil, notFoundMessage("代理商", name, suggestName(name, candidates))
+	case 1:
+		return &matches[0], ""
+	}
+	return nil, fmt.Sprintf("代理商「%s」对应多个代理商，请在系统中区分后再导入", name)
+}
+
+// parseImportRow 按列映射读取一行的单元格，只做与数据库无关的格式校验
+func parseImportRow(row *models.CustomerImportRow, columns []string) {
+	data := models.CustomerImportData{ProductNeeds: []string{}}
+	for i, field := range columns {
+		if field == "" || i >= len(row.Values) {
+			continue
+		}
+		value := strings.TrimSpace(row.Values[i])
+		if value == "" {
+			continue
+		}
+		switch field {
+		case "name":
+			data.Name = value
+		case "nature":
+			data.Nature = value
+		case "importance":
+			data.Importance = value
+		case "applicationField":
+			data.ApplicationField = value
+		case "productNeeds":
+			for _, product := range productNeedsSeparator.Split(value, -1) {
+				if product = strings.TrimSpace(product); product != "" && !slices.Contains(data.ProductNeeds, product) {
+					data.ProductNeeds = append(data.ProductNeeds, product)
+				}
+			}
+		case "contactPerson":
+			data.ContactPerson = value
+		case "contactPhone":
+			phone := utils.NormalizePhone(value)
+			if !utils.IsValidPhone(phone) {
+				row.Errors = append(row.Errors, fmt.Sprintf("联系电话「%s」格式不正确", value))
+			}
+			data.ContactPhone = phone
+		case "address":
+			data.Address = value
+		case "progress":
+			data.Progress = value
+		case "annualDemand":
+			demand, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64)
+			if err != nil || demand < 0 {
+				row.Errors = append(row.Errors, fmt.Sprintf("年需求量「%s」不是有效的数字", value))
+			}
+			data.AnnualDemand = demand
+		case "relatedSalesName":
+			data.RelatedSalesName = value
+		case "relatedAgentName":
+			data.RelatedAgentName = value
+		}
+	}
+
+	required := map[string]string{"name": data.Name, "nature": data.Nature, "importance": data.Importance}
+	for _, field := range customerImportFields {
+		if field.Required && required[field.Field] == "" {
+			row.Errors = append(row.Errors, fmt.Sprintf("%s不能为空", field.Label))
+		}
+	}
+
+	if data.Progress == "" {
+		data.Progress = models.CustomerProgressInitialContact
+	}
+	if data.Progress == models.CustomerProgressPublicPool {
+		row.Errors = append(row.Errors, "导入的客户不能直接进入公海")
+	} else if err := ValidateProgressTransition("", data.Progress); err != nil {
+		row.Errors = append(row.Errors, err.Error())
+	}
+	row.Data = data
+}
+
+// validateImportRows 校验全部导入行并设置每行的状态：
+// 格式或引用数据错误、客户名称与已有客户或文件中前面的行相同时为 invalid，
+// 与已有客户或其他行疑似重复达到拦截分数时为 warning，其余为 valid
+func validateImportRows(ctx context.Context, rows []models.CustomerImportRow, columns []string) error {
+	for i := range rows {
+		rows[i].Errors = []string{}
+		rows[i].Warnings = []string{}
+		rows[i].Duplicates = nil
+		parseImportRow(&rows[i], columns)
+	}
+
+	lookup, err := loadImportLookup(ctx, rows)
+	if err != nil {
+		return err
+	}
+
+	seenNames := make(map[string]int)
+	for i := range rows {
+		row := &rows[i]
+		data := &row.Data
+
+		for _, product := range data.ProductNeeds {
+			if !lookup.products[product] {
+				row.Errors = append(row.Errors, fmt.Sprintf("产品「%s」不存在", product))
+			}
+		}
+
+		if data.RelatedAgentName != "" {
+			agent, message := lookup.resolveAgent(data.RelatedAgentName)
+			if agent != nil {
+				data.RelatedAgentID = agent.ID.Hex()
+				data.RelatedAgentName = agent.CompanyName
+				// 未填写销售时沿用代理商的关联销售
+				if data.RelatedSalesName == "" && agent.RelatedSalesID != "" {
+					data.RelatedSalesName = agent.RelatedSalesName
+				}
+			} else {
+				row.Errors = append(row.Errors, message)
+			}
+		}
+		if data.RelatedSalesName != "" {
+			sales, message := lookup.resolveSales(data.RelatedSalesName)
+			if sales != nil {
+				data.RelatedSalesID = sales.ID.Hex()
+				data.RelatedSalesName = sales.Username
+				if sales.Status != models.UserStatusAPPROVED {
+					row.Errors = append(row.Errors, fmt.Sprintf("销售「%s」未通过审批", sales.Username))
+				}
+			} else {
+				row.Errors = append(row.Errors, message)
+			}
+		}
+
+		if data.Name != "" {
+			if lookup.existingNames[data.Name] {
+				row.Errors = append(row.Errors, "客户名称已存在")
+			} else if previous, ok := seenNames[data.Name]; ok {
+				row.Errors = append(row.Errors, fmt.Sprintf("与第%d行客户名称相同", previous))
+			} else {
+				seenNames[data.Name] = row.Row
+			}
+		}
+	}
+
+	// 模糊查重只在格式正确的行之间进行，避免无效行产生干扰提示
+	inputs := []DuplicateInput{}
+	byRow := make(map[int]*models.CustomerImportRow)
+	for i := range rows {
+		if len(rows[i].Errors) == 0 {
+			inputs = append(inputs, DuplicateInput{Name: rows[i].Data.Name, Phone: rows[i].Data.ContactPhone, Row: rows[i].Row})
+			byRow[rows[i].Row] = &rows[i]
+		}
+	}
+	duplicates, err := FindBulkDuplicates(ctx, inputs, DuplicateHintScore)
+	if err != nil {
+		return err
+	}
+	for _, duplicate := range duplicates {
+		row := byRow[duplicate.Row]
+		row.Duplicates = duplicate.Candidates
+		if HasBlockingDuplicate(duplicate.Candidates) {
+			names := []string{}
+			for _, candidate := range duplicate.Candidates {
+				if candidate.Score >= DuplicateBlockScore {
+					names = append(names, fmt.Sprintf("%s（%d分）", candidate.Name, candidate.Score))
+				}
+			}
+			row.Warnings = append(row.Warnings, fmt.Sprintf("疑似重复客户: %s", strings.Join(names, "、")))
+		}
+	}
+
+	for i := range rows {
+		switch {
+		case len(rows[i].Errors) > 0:
+			rows[i].Status = models.ImportRowInvalid
+		case len(rows[i].Warnings) > 0:
+			rows[i].Status = models.ImportRowWarning
+		default:
+			rows[i].Status = models.ImportRowValid
+		}
+	}
+	return nil
+}
+
+// summarizeImport 统计导入批次各状态的行数
+func summarizeImport(rows []models.CustomerImportRow) models.CustomerImportSummary {
+	summary := models.CustomerImportSummary{Total: len(rows)}
+	for _, row := range rows {
+		switch row.Status {
+		case models.ImportRowValid:
+			summary.Valid++
+		case models.ImportRowWarning:
+			summary.Warning++
+		case models.ImportRowInvalid:
+			summary.Invalid++
+		case models.ImportRowImported:
+			summary.Imported++
+		case models.ImportRowFailed:
+			summary.Failed++
+		}
+	}
+	return summary
+}
+
+// PreviewCustomerImport 解析上传的 xlsx/csv 文件并逐行校验，保存为待确认的导入批次
+// 第一个非空行为标题行；mapping 为列标题到字段的映射，未指定的列按模板标题自动识别；超出模板列数的列被忽略
+func PreviewCustomerImport(ctx context.Context, fileName string, content []byte, mapping map[string]string, operator *utils.LoginUser) (*models.CustomerImport, error) {
+	format := utils.SpreadsheetFormat(fileName)
+	if format == "" {
+		return nil, utils.CreateBadRequestError("不支持的文件格式，请上传 .xlsx 或 .csv 文件")
+	}
+	// 解析时即限制行数（含标题行）和列数（模板列数），避免超大文件全部读入内存后才报错
+	table, err := utils.ReadSpreadsheet(format, content, MaxCustomerImportRows+1, len(customerImportFields))
+	if err != nil {
+		if errors.Is(err, utils.ErrSpreadsheetTooManyRows) {
+			return nil, utils.CreateBadRequestError(fmt.Sprintf("单个文件最多导入 %d 个客户", MaxCustomerImportRows))
+		}
+		return nil, utils.CreateBadRequestError(err.Error())
+	}
+
+	headerIndex := -1
+	for i, values := range table {
+		if !isBlankRow(values) {
+			headerIndex = i
+			break
+		}
+	}
+	if headerIndex < 0 {
+		return nil, utils.CreateBadRequestError("文件内容为空")
+	}
+	headers := make([]string, len(table[headerIndex]))
+	for i, header := range table[headerIndex] {
+		headers[i] = strings.TrimSpace(header)
+	}
+	columns, err := resolveImportColumns(headers, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []models.CustomerImportRow{}
+	for i := headerIndex + 1; i < len(table); i++ {
+		if isBlankRow(table[i]) {
+			continue
+		}
+		if len(rows) == MaxCustomerImportRows {
+			return nil, utils.CreateBadRequestError(fmt.Sprintf("单个文件最多导入 %d 个客户", MaxCustomerImportRows))
+		}
+		values := make([]string, len(headers))
+		copy(values, table[i])
+		rows = append(rows, models.CustomerImportRow{Row: i + 1, Values: values})
+	}
+	if len(rows) == 0 {
+		return nil, utils.CreateBadRequestError("文件中没有客户数据")
+	}
+
+	if err := validateImportRows(ctx, rows, columns); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	record := &models.CustomerImport{
+		ID:            primitive.NewObjectID(),
+		FileName:      fileName,
+		Headers:       headers,
+		Columns:       columns,
+		Rows:          rows,
+		Summary:       summarizeImport(rows),
+		Status:        models.CustomerImportStatusPreviewed,
+		CreatedByID:   operator.ID,
+		CreatedByName: operator.Username,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(customerImportTTL),
+	}
+	if _, err := repository.CustomerImports().Insert(ctx, record); err != nil {
+		return nil, err
+	}
+
+	utils.LogInfo(map[string]interface{}{
+		"importId": record.ID.Hex(),
+		"fileName": fileName,
+		"total":    record.Summary.Total,
+		"valid":    record.Summary.Valid,
+		"warning":  record.Summary.Warning,
+		"invalid":  record.Summary.Invalid,
+		"operator": operator.Username,
+	}, "客户导入文件校验完成")
+	return record, nil
+}
+
+func isBlankRow(values []string) bool {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// GetCustomerImport 读取导入批次，只有上传人和超级管理员可以查看
+func GetCustomerImport(ctx context.Context, id primitive.ObjectID, user *utils.LoginUser) (*models.CustomerImport, error) {
+	record, err := repository.CustomerImports().FindByID(ctx, id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, utils.NewApiError("导入批次不存在或已过期，请重新上传", http.StatusNotFound, "RESOURCE_NOT_FOUND")
+		}
+		return nil, err
+	}
+	if record.CreatedByID != user.ID && models.UserRole(user.Role) != models.UserRoleSUPER_ADMIN {
+		return nil, utils.CreateForbiddenError()
+	}
+	return record, nil
+}
+
+// CommitCustomerImport 导入批次中可导入的行：导入前按当前数据重新校验，
+// 校验通过的行逐个导入，疑似重复的行在 IgnoreDuplicates 时导入；单行失败不影响其他行
+func CommitCustomerImport(ctx context.Context, id primitive.ObjectID, req models.CustomerImportCommitRequest, operator *utils.LoginUser) (*models.CustomerImport, error) {
+	record, err := GetCustomerImport(ctx, id, operator)
+	if err != nil {
+		return nil, err
+	}
+	if record.Status != models.CustomerImportStatusPreviewed {
+		return nil, utils.NewApiError("该批次已导入，请勿重复提交", http.StatusConflict, "IMPORT_ALREADY_COMMITTED")
+	}
+
+	// 预览后可能已有其他人新建了同名客户或调整了销售、代理商，导入前重新校验
+	if err := validateImportRows(ctx, record.Rows, record.Columns); err != nil {
+		return nil, err
+	}
+
+	selected := make(map[int]bool, len(req.Rows))
+	for _, row := range req.Rows {
+		selected[row] = true
+	}
+	for i := range record.Rows {
+		row := &record.Rows[i]
+		if len(selected) > 0 && !selected[row.Row] {
+			continue
+		}
+		if row.Status != models.ImportRowValid && !(row.Status == models.ImportRowWarning && req.IgnoreDuplicates) {
+			continue
+		}
+		customerID, err := importCustomerRow(ctx, row.Data, operator)
+		if err != nil {
+			row.Status = models.ImportRowFailed
+			row.Errors = append(row.Errors, fmt.Sprintf("导入失败: %s", err.Error()))
+			utils.LogError(err, map[string]interface{}{
+				"importId": record.ID.Hex(),
+				"row":      row.Row,
+				"name":     row.Data.Name,
+			}, "导入客户失败")
+			continue
+		}
+		row.Status = models.ImportRowImported
+		row.CustomerID = customerID.Hex()
+	}
+
+	now := time.Now()
+	record.Summary = summarizeImport(record.Rows)
+	record.Status = models.CustomerImportStatusImported
+	record.ImportedAt = &now
+	if _, err := repository.CustomerImports().UpdateByID(ctx, record.ID, bson.M{"$set": bson.M{
+		"rows":       record.Rows,
+		"summary":    record.Summary,
+		"status":     record.Status,
+		"importedAt": now,
+	}}); err != nil {
+		return nil, fmt.Errorf("保存导入结果失败: %w", err)
+	}
+
+	utils.LogInfo(map[string]interface{}{
+		"importId": record.ID.Hex(),
+		"imported": record.Summary.Imported,
+		"failed":   record.Summary.Failed,
+		"operator": operator.Username,
+	}, "客户文件导入完成")
+	return record, nil
+}
+
+// importCustomerRow 新建一个导入的客户，客户、分配历史和进展历史在同一事务中写入
+func importCustomerRow(ctx context.Context, data models.CustomerImportData, operator *utils.LoginUser) (primitive.ObjectID, error) {
+	now := time.Now()
+	customer := models.Customer{
+		ID:                 primitive.NewObjectID(),
+		Name:               data.Name,
+		Nature:             data.Nature,
+		Importance:         data.Importance,
+		ApplicationField:   data.ApplicationField,
+		ProductNeeds:       data.ProductNeeds,
+		ContactPerson:      data.ContactPerson,
+		ContactPhone:       data.ContactPhone,
+		Address:            data.Address,
+		Progress:           data.Progress,
+		InitialContactTime: now,
+		AnnualDemand:       data.AnnualDemand,
+		OwnerID:            operator.ID,
+		OwnerName:          operator.Username,
+		OwnerType:          operator.Role,
+		RelatedSalesID:     data.RelatedSalesID,
+		RelatedSalesName:   data.RelatedSalesName,
+		RelatedAgentID:     data.RelatedAgentID,
+		RelatedAgentName:   data.RelatedAgentName,
+		IsInPublicPool:     false,
+		LastUpdateTime:     now,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+
+	err := repository.WithTransaction(ctx, func(txCtx context.Context) error {
+		current := customer
+		if _, err := repository.Customers().Insert(txCtx, &current); err != nil {
+			return err
+		}
+		if current.RelatedSalesID != "" || current.RelatedAgentID != "" {
+			operationType := "新建分配"
+			if (operator.Role == string(models.UserRoleFACTORY_SALES) && operator.ID == current.RelatedSalesID) ||
+				(operator.Role == string(models.UserRoleAGENT) && operator.ID == current.RelatedAgentID) {
+				operationType = "新建认领"
+			}
+			if err := AddAssignmentHistory(txCtx, models.CustomerAssignmentHistory{
+				CustomerID:         current.ID.Hex(),
+				CustomerName:       current.Name,
+				ToRelatedSalesID:   current.RelatedSalesID,
+				ToRelatedSalesName: current.RelatedSalesName,
+				ToRelatedAgentID:   current.RelatedAgentID,
+				ToRelatedAgentName: current.RelatedAgentName,
+				OperatorID:         operator.ID,
+				OperatorName:       operator.Username,
+				OperationType:      operationType,
+			}); err != nil {
+				return fmt.Errorf("添加客户分配历史失败: %w", err)
+			}
+		}
+		if err := RecordProgressTransition(txCtx, &current, "", current.Progress, operator, "文件导入"); err != nil {
+			return fmt.Errorf("添加客户进展历史失败: %w", err)
+		}
+		return nil
+	})
+	return customer.ID, err
+}
+
+// WriteCustomerImportErrors 写出导入错误报告：行号、原始单元格和错误原因，
+// 包含校验未通过、疑似重复未导入和导入失败的行，修改后可直接重新上传
+func WriteCustomerImportErrors(writer utils.SpreadsheetWriter, record *models.CustomerImport) error {
+	header := append([]string{"行号"}, record.Headers...)
+	header = append(header, "错误原因")
+	if err := writer.WriteRow(header); err != nil {
+		return err
+	}
+	for _, row := range record.Rows {
+		if row.Status == models.ImportRowValid || row.Status == models.ImportRowImported {
+			continue
+		}
+		reasons := append(append([]string{}, row.Errors...), row.Warnings...)
+		values := append([]string{strconv.Itoa(row.Row)}, row.Values...)
+		values = append(values, strings.Join(reasons, "；"))
+		if err := writer.WriteRow(values); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
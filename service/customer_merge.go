@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/policy"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxMergeDuplicates 单次合并的重复客户数量上限
+const maxMergeDuplicates = 50
+
+// MergeCustomers 将重复客户并入保留客户：迁移跟进记录、项目、分配历史和进展历史，
+// 合并产品需求，写入审计记录后删除重复客户。DryRun 时只统计将要迁移的数据
+// 写入顺序保证中途失败时重复客户仍然存在，重新执行即可继续合并
+func MergeCustomers(ctx context.Context, req models.CustomerMergeRequest, operator *utils.LoginUser) (*models.CustomerMergeResult, error) {
+	survivorID, err := primitive.ObjectIDFromHex(req.SurvivorID)
+	if err != nil {
+		return nil, utils.CreateBadRequestError("无效的保留客户ID")
+	}
+	duplicateIDs, err := parseMergeDuplicateIDs(req.DuplicateIDs, survivorID)
+	if err != nil {
+		return nil, err
+	}
+
+	survivor, err := repository.Customers().FindByID(ctx, survivorID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, utils.CreateNotFoundError("保留客户")
+		}
+		return nil, err
+	}
+	duplicates, err := repository.Customers().FindByIDs(ctx, duplicateIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(duplicates) != len(duplicateIDs) {
+		return nil, utils.NewApiError("部分重复客户不存在或已被合并", http.StatusNotFound, "RESOURCE_NOT_FOUND")
+	}
+
+	if !policy.CanAccessCustomer(operator, survivor, policy.ActionUpdate) {
+		return nil, utils.NewApiError("无权修改保留客户", http.StatusForbidden, "FORBIDDEN")
+	}
+	for i := range duplicates {
+		if !policy.CanAccessCustomer(operator, &duplicates[i], policy.ActionDelete) {
+			return nil, utils.NewApiError(fmt.Sprintf("无权合并客户 %s", duplicates[i].Name), http.StatusForbidden, "FORBIDDEN")
+		}
+	}
+	if survivor.Progress == models.CustomerProgressDisabled {
+		return nil, utils.NewApiError("保留客户已被禁用，请选择其他客户作为保留客户", http.StatusConflict, ErrCodeIllegalProgressTransition)
+	}
+
+	duplicateHexIDs := make([]string, len(duplicateIDs))
+	for i, id := range duplicateIDs {
+		duplicateHexIDs[i] = id.Hex()
+	}
+	byHexID := bson.M{"customerId": bson.M{"$in": duplicateHexIDs}}
+	byObjectID := bson.M{"customerId": bson.M{"$in": duplicateIDs}}
+
+	result := &models.CustomerMergeResult{
+		DryRun:       req.DryRun,
+		Survivor:     *survivor,
+		Duplicates:   duplicates,
+		ProductNeeds: unionProductNeeds(survivor.ProductNeeds, duplicates),
+	}
+	if result.Moved, err = countMergeRecords(ctx, byHexID, byObjectID); err != nil {
+		return nil, err
+	}
+
+	// 迁入项目后初步接触的客户转为正常推进
+	result.ProgressAfter = survivor.Progress
+	if result.Moved.Projects > 0 && survivor.Progress == models.CustomerProgressInitialContact {
+		result.ProgressAfter = models.CustomerProgressNormal
+	}
+
+	if req.DryRun {
+		return result, nil
+	}
+
+	// 1. 迁移关联记录
+	survivorHexID := survivorID.Hex()
+	if _, err := repository.FollowUps().UpdateMany(ctx, byHexID, bson.M{"$set": bson.M{"customerId": survivorHexID}}); err != nil {
+		return nil, fmt.Errorf("迁移跟进记录失败: %w", err)
+	}
+	if _, err := repository.Projects().UpdateMany(ctx, byObjectID, bson.M{"$set": bson.M{
+		"customerId":   survivorID,
+		"customerName": survivor.Name,
+	}}); err != nil {
+		return nil, fmt.Errorf("迁移项目失败: %w", err)
+	}
+	if _, err := repository.AssignmentHistory().UpdateMany(ctx, byHexID, bson.M{"$set": bson.M{"customerId": survivorHexID}}); err != nil {
+		return nil, fmt.Errorf("迁移分配历史失败: %w", err)
+	}
+	if _, err := repository.CustomerProgress().UpdateMany(ctx, byHexID, bson.M{"$set": bson.M{"customerId": survivorHexID}}); err != nil {
+		return nil, fmt.Errorf("迁移进展历史失败: %w", err)
+	}
+
+	// 2. 更新保留客户的产品需求和进展状态
+	remark := mergeRemark(duplicates)
+	fields := bson.M{"productNeeds": result.ProductNeeds}
+	if result.ProgressAfter != survivor.Progress {
+		_, err = ChangeCustomerProgress(ctx, ProgressChange{
+			Customer: survivor,
+			To:       result.ProgressAfter,
+			Operator: operator,
+			Remark:   remark,
+			Fields:   fields,
+		})
+	} else {
+		now := time.Now()
+		fields["lastUpdateTime"] = now
+		fields["updatedAt"] = now
+		_, err = repository.Customers().SetFields(ctx, survivorID, fields)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("更新保留客户失败: %w", err)
+	}
+
+	// 3. 写入审计记录，保存重复客户快照
+	record := models.CustomerMergeRecord{
+		SurvivorID:   survivorHexID,
+		SurvivorName: survivor.Name,
+		Duplicates:   duplicates,
+		Moved:        result.Moved,
+		ProductNeeds: result.ProductNeeds,
+		OperatorID:   operator.ID,
+		OperatorName: operator.Username,
+		Remark:       req.Remark,
+		CreatedAt:    time.Now(),
+	}
+	mergeID, err := repository.CustomerMerges().Insert(ctx, &record)
+	if err != nil {
+		return nil, fmt.Errorf("写入合并记录失败: %w", err)
+	}
+	result.MergeID = mergeID.Hex()
+
+	// 4. 删除重复客户
+	if _, err := repository.Customers().DeleteMany(ctx, bson.M{"_id": bson.M{"$in": duplicateIDs}}); err != nil {
+		return nil, fmt.Errorf("删除重复客户失败: %w", err)
+	}
+
+	updated, err := repository.Customers().FindByID(ctx, survivorID)
+	if err == nil {
+		result.Survivor = *updated
+	}
+
+	utils.LogInfo(map[string]interface{}{
+		"mergeId":      result.MergeID,
+		"survivorId":   survivorHexID,
+		"duplicateIds": duplicateHexIDs,
+		"moved":        result.Moved,
+		"operator":     operator.Username,
+	}, "客户合并成功")
+
+	return result, nil
+}
+
+// parseMergeDuplicateIDs 解析并去重重复客户ID
+func parseMergeDuplicateIDs(ids []string, survivorID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	seen := make(map[primitive.ObjectID]bool, len(ids))
+	result := make([]primitive.ObjectID, 0, len(ids))
+	for _, raw := range ids {
+		id, err := primitive.ObjectIDFromHex(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, utils.CreateBadRequestError(fmt.Sprintf("无效的重复客户ID: %s", raw))
+		}
+		if id == survivorID {
+			return nil, utils.CreateBadRequestError("保留客户不能同时作为重复客户")
+		}
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	if len(result) == 0 {
+		return nil, utils.CreateBadRequestError("请至少选择一个重复客户")
+	}
+	if len(result) > maxMergeDuplicates {
+		return nil, utils.CreateBadRequestError(fmt.Sprintf("单次最多合并 %d 个客户", maxMergeDuplicates))
+	}
+	return result, nil
+}
+
+// countMergeRecords 统计重复客户名下将被迁移的记录数
+func countMergeRecords(ctx context.Context, byHexID bson.M, byObjectID bson.M) (models.CustomerMergeCounts, error) {
+	var counts models.CustomerMergeCounts
+	var err error
+	if counts.FollowUps, err = repository.FollowUps().Count(ctx, byHexID); err != nil {
+		return counts, err
+	}
+	if counts.Projects, err = repository.Projects().Count(ctx, byObjectID); err != nil {
+		return counts, err
+	}
+	if counts.AssignmentHistory, err = repository.AssignmentHistory().Count(ctx, byHexID); err != nil {
+		return counts, err
+	}
+	if counts.ProgressHistory, err = repository.CustomerProgress().Count(ctx, byHexID); err != nil {
+		return counts, err
+	}
+	return counts, nil
+}
+
+// unionProductNeeds 合并产品需求，保持保留客户原有顺序并去重
+func unionProductNeeds(base []string, duplicates []models.Customer) []string {
+	seen := make(map[string]bool)
+	result := []string{}
+	add := func(needs []string) {
+		for _, need := range needs {
+			need = strings.TrimSpace(need)
+			if need == "" || seen[need] {
+				continue
+			}
+			seen[need] = true
+			result = append(result, need)
+		}
+	}
+	add(base)
+	for _, duplicate := range duplicates {
+		add(duplicate.ProductNeeds)
+	}
+	return result
+}
+
+// mergeRemark 进展历史中的合并说明
+func mergeRemark(duplicates []models.Customer) string {
+	names := make([]string, len(duplicates))
+	for i, duplicate := range duplicates {
+		names[i] = duplicate.Name
+	}
+	return "合并客户: " + strings.Join(names, ", ")
+}
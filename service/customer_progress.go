@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 进展状态错误码
+const (
+	ErrCodeInvalidProgress           = "INVALID_PROGRESS"
+	ErrCodeIllegalProgressTransition = "ILLEGAL_PROGRESS_TRANSITION"
+	ErrCodeProgressConflict          = "PROGRESS_CONFLICT"
+)
+
+// progressNone 新建客户时进展历史中的起始状态
+const progressNone = "无"
+
+// customerProgressTransitions 客户进展状态机：当前状态 -> 允许变更到的状态
+// 空字符串表示新建客户（尚无状态）
+var customerProgressTransitions = map[string][]string{
+	"": {
+		models.CustomerProgressInitialContact,
+		models.CustomerProgressNormal,
+		models.CustomerProgressPublicPool,
+	},
+	// 创建项目后转为正常推进；同名客户正常推进后被禁用
+	models.CustomerProgressInitialContact: {
+		models.CustomerProgressNormal,
+		models.CustomerProgressPublicPool,
+		models.CustomerProgressDisabled,
+	},
+	// 重新分配且没有项目时回到初步接触
+	models.CustomerProgressNormal: {
+		models.CustomerProgressInitialContact,
+		models.CustomerProgressPublicPool,
+	},
+	// 从公海分配或认领
+	models.CustomerProgressPublicPool: {
+		models.CustomerProgressInitialContact,
+		models.CustomerProgressNormal,
+	},
+	// 禁用客户只能移入公海，不能直接恢复跟进
+	models.CustomerProgressDisabled: {
+		models.CustomerProgressPublicPool,
+	},
+}
+
+// IsValidCustomerProgress 判断是否为合法的客户进展状态
+func IsValidCustomerProgress(progress string) bool {
+	switch progress {
+	case models.CustomerProgressInitialContact, models.CustomerProgressNormal,
+		models.CustomerProgressPublicPool, models.CustomerProgressDisabled:
+		return true
+	}
+	return false
+}
+
+// ValidateProgressTransition 校验客户进展状态变更是否合法，状态不变视为合法
+func ValidateProgressTransition(from, to string) error {
+	if !IsValidCustomerProgress(to) {
+		return utils.NewApiError(fmt.Sprintf("无效的客户进展状态: %s", to), http.StatusBadRequest, ErrCodeInvalidProgress)
+	}
+	if from == to {
+		return nil
+	}
+	allowed, known := customerProgressTransitions[from]
+	if !known {
+		// 早期数据中可能存在非标准状态，允许变更到任一合法状态
+		return nil
+	}
+	if !slices.Contains(allowed, to) {
+		return utils.NewApiError(
+			fmt.Sprintf("客户进展状态不允许从「%s」变更为「%s」", progressLabel(from), to),
+			http.StatusConflict,
+			ErrCodeIllegalProgressTransition,
+		)
+	}
+	return nil
+}
+
+// progressLabel 进展状态的显示名称
+func progressLabel(progress string) string {
+	if progress == "" {
+		return progressNone
+	}
+	return progress
+}
+
+// ProgressChange 客户进展状态变更请求
+type ProgressChange struct {
+	Customer *models.Customer
+	To       string
+	Operator *utils.LoginUser
+	Remark   string
+	// Fields 与进展状态一同更新的其他字段
+	Fields bson.M
+	// Condition 更新的附加条件，不满足时与状态被并发修改一样返回冲突错误
+	Condition bson.M
+}
+
+// ChangeCustomerProgress 校验并变更客户进展状态，同时更新其他字段；状态实际变化时记录进展历史
+// 更新以客户当前状态为条件，状态已被并发修改时返回冲突错误；需要与其他写入一同回滚时在事务中调用
+func ChangeCustomerProgress(ctx context.Context, change ProgressChange) (*repository.UpdateResult, error) {
+	customer := change.Customer
+	from := customer.Progress
+	if err := ValidateProgressTransition(from, change.To); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	fields := bson.M{
+		"lastUpdateTime": now,
+		"updatedAt":      now,
+	}
+	for key, value := range change.Fields {
+		fields[key] = value
+	}
+	fields["progress"] = change.To
+
+	filter := bson.M{}
+	for key, value := range change.Condition {
+		filter[key] = value
+	}
+	filter["_id"] = customer.ID
+	filter["progress"] = from
+	if from == "" {
+		filter["progress"] = bson.M{"$in": bson.A{"", nil}}
+	}
+	result, err := repository.Customers().UpdateOne(ctx, filter, bson.M{"$set": fields})
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return result, utils.NewApiError("客户进展状态已被修改，请刷新后重试", http.StatusConflict, ErrCodeProgressConflict)
+	}
+	customer.Progress = change.To
+
+	if from != change.To {
+		if err := RecordProgressTransition(ctx, customer, from, change.To, change.Operator, change.Remark); err != nil {
+			return result, fmt.Errorf("记录客户进展历史失败: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// UpdateCustomerProgress 变更客户进展状态
+func UpdateCustomerProgress(ctx context.Context, customer *models.Customer, progress string, operator *utils.LoginUser, remark string) error {
+	_, err := ChangeCustomerProgress(ctx, ProgressChange{
+		Customer: customer,
+		To:       progress,
+		Operator: operator,
+		Remark:   remark,
+	})
+	return err
+}
+
+// UpdateCustomerProgressByName 将同名的初步接触客户变更为指定进展状态，逐个记录进展历史
+func UpdateCustomerProgressByName(ctx context.Context, name string, progress string, operator *utils.LoginUser, remark string) error {
+	customers, err := repository.Customers().Find(ctx, bson.M{"name": name, "progress": models.CustomerProgressInitialContact})
+	if err != nil {
+		return err
+	}
+	for i := range customers {
+		if err := UpdateCustomerProgress(ctx, &customers[i], progress, operator, remark); err != nil {
+			return fmt.Errorf("变更同名客户 %s 进展状态失败: %w", customers[i].ID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// RecordProgressTransition 记录一次客户进展状态变更
+func RecordProgressTransition(ctx context.Context, customer *models.Customer, from, to string, operator *utils.LoginUser, remark string) error {
+	now := time.Now()
+	history := models.CustomerProgressHistory{
+		CustomerID:   customer.ID.Hex(),
+		CustomerName: customer.Name,
+		FromProgress: progressLabel(from),
+		ToProgress:   to,
+		Remark:       remark,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if operator != nil {
+		history.OperatorID = operator.ID
+		history.OperatorName = operator.Username
+	}
+	_, err := repository.CustomerProgress().Insert(ctx, &history)
+	return err
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestValidateProgressTransition(t *testing.T) {
+	const (
+		initial  = models.CustomerProgressInitialContact
+		normal   = models.CustomerProgressNormal
+		pool     = models.CustomerProgressPublicPool
+		disabled = models.CustomerProgressDisabled
+	)
+	cases := []struct {
+		name     string
+		from, to string
+		wantCode string
+	}{
+		{name: "新建为初步接触", from: "", to: initial},
+		{name: "新建为正常推进", from: "", to: normal},
+		{name: "新建直接进入公海", from: "", to: pool},
+		{name: "新建不能为禁用", from: "", to: disabled, wantCode: ErrCodeIllegalProgressTransition},
+		{name: "初步接触转正常推进", from: initial, to: normal},
+		{name: "初步接触被禁用", from: initial, to: disabled},
+		{name: "正常推进回到初步接触", from: normal, to: initial},
+		{name: "正常推进不能禁用", from: normal, to: disabled, wantCode: ErrCodeIllegalProgressTransition},
+		{name: "公海认领", from: pool, to: initial},
+		{name: "公海不能禁用", from: pool, to: disabled, wantCode: ErrCodeIllegalProgressTransition},
+		{name: "禁用后移入公海", from: disabled, to: pool},
+		{name: "禁用不能直接恢复", from: disabled, to: initial, wantCode: ErrCodeIllegalProgressTransition},
+		{name: "禁用不能正常推进", from: disabled, to: normal, wantCode: ErrCodeIllegalProgressTransition},
+		{name: "状态不变", from: disabled, to: disabled},
+		{name: "早期非标准状态", from: "已签约", to: disabled},
+		{name: "无效的目标状态", from: initial, to: "已签约", wantCode: ErrCodeInvalidProgress},
+		{name: "目标状态不能为空", from: initial, to: "", wantCode: ErrCodeInvalidProgress},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateProgressTransition(tc.from, tc.to)
+			if tc.wantCode == "" {
+				if err != nil {
+					t.Fatalf("ValidateProgressTransition(%q, %q) = %v", tc.from, tc.to, err)
+				}
+				return
+			}
+			wantErrorCode(t, err, tc.wantCode)
+		})
+	}
+}
+
+func TestChangeCustomerProgress(t *testing.T) {
+	ctx := useMemoryRepositories(t)
+	operator := SystemActor()
+	customer := insertCustomer(t, ctx, models.Customer{Name: "某某科技"})
+
+	t.Run("新建客户记录起始状态为无", func(t *testing.T) {
+		if err := UpdateCustomerProgress(ctx, &customer, models.CustomerProgressInitialContact, operator, "新建"); err != nil {
+			t.Fatal(err)
+		}
+		if got := findCustomer(t, ctx, customer.ID).Progress; got != models.CustomerProgressInitialContact {
+			t.Fatalf("progress = %s", got)
+		}
+		histories, err := repository.CustomerProgress().FindByCustomerID(ctx, customer.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(histories) != 1 || histories[0].FromProgress != progressNone || histories[0].ToProgress != models.CustomerProgressInitialContact {
+			t.Fatalf("histories = %+v", histories)
+		}
+		if histories[0].OperatorID != operator.ID || histories[0].Remark != "新建" {
+			t.Fatalf("history = %+v", histories[0])
+		}
+	})
+
+	t.Run("状态不变时只更新字段不记录历史", func(t *testing.T) {
+		if _, err := ChangeCustomerProgress(ctx, ProgressChange{
+			Customer: &customer,
+			To:       models.CustomerProgressInitialContact,
+			Operator: operator,
+			Fields:   bson.M{"contactPerson": "张三"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if got := findCustomer(t, ctx, customer.ID).ContactPerson; got != "张三" {
+			t.Fatalf("contactPerson = %q", got)
+		}
+		if count, _ := repository.CustomerProgress().Count(ctx, bson.M{"customerId": customer.ID.Hex()}); count != 1 {
+			t.Fatalf("history count = %d, want 1", count)
+		}
+	})
+
+	t.Run("非法变更不修改客户", func(t *testing.T) {
+		stale := customer
+		if err := UpdateCustomerProgress(ctx, &customer, models.CustomerProgressNormal, operator, ""); err != nil {
+			t.Fatal(err)
+		}
+		err := UpdateCustomerProgress(ctx, &customer, models.CustomerProgressDisabled, operator, "")
+		wantErrorCode(t, err, ErrCodeIllegalProgressTransition)
+		if got := findCustomer(t, ctx, customer.ID).Progress; got != models.CustomerProgressNormal {
+			t.Fatalf("progress = %s", got)
+		}
+
+		// 基于过期状态的变更视为并发修改
+		err = UpdateCustomerProgress(ctx, &stale, models.CustomerProgressPublicPool, operator, "")
+		wantErrorCode(t, err, ErrCodeProgressConflict)
+		if stale.Progress != models.CustomerProgressInitialContact {
+			t.Fatalf("冲突时不应修改传入的客户状态: %s", stale.Progress)
+		}
+	})
+
+	t.Run("附加条件不满足时返回冲突", func(t *testing.T) {
+		_, err := ChangeCustomerProgress(ctx, ProgressChange{
+			Customer:  &customer,
+			To:        models.CustomerProgressPublicPool,
+			Operator:  operator,
+			Fields:    bson.M{"isInPublicPool": true},
+			Condition: bson.M{"isInPublicPool": true},
+		})
+		wantErrorCode(t, err, ErrCodeProgressConflict)
+		stored := findCustomer(t, ctx, customer.ID)
+		if stored.Progress != models.CustomerProgressNormal || stored.IsInPublicPool {
+			t.Fatalf("customer = %+v", stored)
+		}
+		if count, _ := repository.CustomerProgress().Count(ctx, bson.M{"customerId": customer.ID.Hex()}); count != 2 {
+			t.Fatalf("history count = %d, want 2", count)
+		}
+	})
+}
@@ -0,0 +1,660 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/policy"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 客户搜索使用进程内的倒排索引生成候选客户，再从数据库读取候选客户的最新数据评分、筛选和统计分面：
+// 索引只影响召回，返回的数据和权限范围始终以数据库为准
+const (
+	// searchIndexRebuildInterval 全量重建索引的间隔，清理已删除客户和过期的倒排记录
+	searchIndexRebuildInterval = 10 * time.Minute
+	// searchIndexRebuildTimeout 单次全量重建的最长时间
+	searchIndexRebuildTimeout = 5 * time.Minute
+	// searchIndexSyncInterval 增量同步的最短间隔
+	searchIndexSyncInterval = 2 * time.Second
+	// searchIndexSyncSkew 增量同步时向前多取的时间，容忍应用服务器之间的时钟误差
+	searchIndexSyncSkew = 5 * time.Second
+	// searchLoadBatchSize 每批从数据库读取的候选客户数
+	searchLoadBatchSize = 1000
+	// maxSearchKeywordLength 搜索关键词的最大字符数
+	maxSearchKeywordLength = 50
+)
+
+// 单个字段的匹配得分，拼音匹配只对字母数字关键词生效
+const (
+	matchExact           = 100
+	matchPrefix          = 80
+	matchContains        = 60
+	matchPinyinPrefix    = 50
+	matchInitialsPrefix  = 45
+	matchPinyinContains  = 40
+	matchInitialsContain = 30
+)
+
+// searchField 参与搜索的客户字段，weight 为得分权重（十分制）
+type searchField struct {
+	name   string
+	weight int
+	pinyin bool
+	value  func(customer *models.Customer) string
+}
+
+var customerSearchFields = []searchField{
+	{name: "name", weight: 10, pinyin: true, value: func(c *models.Customer) string { return c.Name }},
+	{name: "contactPerson", weight: 6, pinyin: true, value: func(c *models.Customer) string { return c.ContactPerson }},
+	{name: "applicationField", weight: 4, value: func(c *models.Customer) string { return c.ApplicationField }},
+}
+
+// searchText 字段的检索文本：规范化文本、全拼和首字母，分别记录每个字符在原文中的位置，用于高亮
+type searchText struct {
+	original    []rune
+	norm        []rune
+	normPos     []int
+	pinyin      string
+	pinyinPos   []int
+	initials    string
+	initialsPos []int
+}
+
+func newSearchText(value string, withPinyin bool) searchText {
+	text := searchText{original: []rune(value)}
+	var pinyin, initials strings.Builder
+	for i, r := range text.original {
+		normalized := []rune(utils.NormalizeLookupName(string(r)))
+		for _, n := range normalized {
+			text.norm = append(text.norm, n)
+			text.normPos = append(text.normPos, i)
+		}
+		if !withPinyin || len(normalized) == 0 {
+			continue
+		}
+		syllable := utils.CharPinyin(r)
+		if syllable == "" && normalized[0] < unicode.MaxASCII && (unicode.IsLetter(normalized[0]) || unicode.IsDigit(normalized[0])) {
+			syllable = string(normalized[0])
+		}
+		if syllable == "" {
+			continue
+		}
+		pinyin.WriteString(syllable)
+		for range syllable {
+			text.pinyinPos = append(text.pinyinPos, i)
+		}
+		initials.WriteByte(syllable[0])
+		text.initialsPos = append(text.initialsPos, i)
+	}
+	text.pinyin = pinyin.String()
+	text.initials = initials.String()
+	return text
+}
+
+// indexTerms 文本中用于倒排索引的词：规范化文本、全拼和首字母的单字和二元组
+func (t searchText) indexTerms(terms map[string]struct{}) {
+	for _, s := range [][]rune{t.norm, []rune(t.pinyin), []rune(t.initials)} {
+		for _, gram := range searchGrams(s) {
+			terms[gram] = struct{}{}
+		}
+	}
+}
+
+// searchGrams 单字和相邻二元组
+func searchGrams(s []rune) []string {
+	grams := make([]string, 0, len(s)*2)
+	for i := range s {
+		grams = append(grams, string(s[i]))
+		if i+1 < len(s) {
+			grams = append(grams, string(s[i:i+2]))
+		}
+	}
+	return grams
+}
+
+// queryGrams 查询词对应的倒排词：单字查询用单字，否则用全部二元组
+func queryGrams(term []rune) []string {
+	if len(term) == 1 {
+		return []string{string(term)}
+	}
+	grams := make([]string, 0, len(term)-1)
+	for i := 0; i+1 < len(term); i++ {
+		grams = append(grams, string(term[i:i+2]))
+	}
+	return grams
+}
+
+// isPinyinTerm 查询词只包含小写字母和数字时尝试拼音匹配
+func isPinyinTerm(term string) bool {
+	for _, r := range term {
+		if !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return term != ""
+}
+
+// textMatch 查询词在字段中的最佳匹配，start/end 为原文中的字符区间
+type textMatch struct {
+	score      int
+	start, end int
+}
+
+// indexRunes 在 s 中查找 sub 第一次出现的位置
+func indexRunes(s, sub []rune) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		match := true
+		for j := range sub {
+			if s[i+j] != sub[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+// match 计算查询词与字段的最佳匹配
+func (t searchText) match(term string) (textMatch, bool) {
+	runes := []rune(term)
+	if i := indexRunes(t.norm, runes); i >= 0 {
+		score := matchContains
+		switch {
+		case i == 0 && len(runes) == len(t.norm):
+			score = matchExact
+		case i == 0:
+			score = matchPrefix
+		}
+		return textMatch{score: score, start: t.normPos[i], end: t.normPos[i+len(runes)-1] + 1}, true
+	}
+	if !isPinyinTerm(term) {
+		return textMatch{}, false
+	}
+
+	// 全拼只在音节开头处匹配，避免 "henz" 之类跨音节的片段命中
+	best := textMatch{}
+	for offset := 0; offset < len(t.pinyin); {
+		i := strings.Index(t.pinyin[offset:], term)
+		if i < 0 {
+			break
+		}
+		i += offset
+		if i == 0 || t.pinyinPos[i-1] != t.pinyinPos[i] {
+			score := matchPinyinContains
+			if i == 0 {
+				score = matchPinyinPrefix
+			}
+			best = textMatch{score: score, start: t.pinyinPos[i], end: t.pinyinPos[i+len(term)-1] + 1}
+			break
+		}
+		offset = i + 1
+	}
+	if len(term) >= 2 {
+		if i := strings.Index(t.initials, term); i >= 0 {
+			score := matchInitialsContain
+			if i == 0 {
+				score = matchInitialsPrefix
+			}
+			if score > best.score {
+				best = textMatch{score: score, start: t.initialsPos[i], end: t.initialsPos[i+len(term)-1] + 1}
+			}
+		}
+	}
+	return best, best.score > 0
+}
+
+// highlight 用 <em> 标记命中的字符区间，其余文本做 HTML 转义
+func (t searchText) highlight(ranges [][2]int) string {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	var b strings.Builder
+	pos := 0
+	for _, r := range ranges {
+		start, end := max(r[0], pos), r[1]
+		if start >= end {
+			continue
+		}
+		b.WriteString(html.EscapeString(string(t.original[pos:start])))
+		b.WriteString("<em>")
+		b.WriteString(html.EscapeString(string(t.original[start:end])))
+		b.WriteString("</em>")
+		pos = end
+	}
+	b.WriteString(html.EscapeString(string(t.original[pos:])))
+	return b.String()
+}
+
+// scoreCustomer 计算客户与全部查询词的相关度，任一查询词未命中时返回 false
+func scoreCustomer(customer *models.Customer, terms []string) (int, map[string]string, bool) {
+	texts := make([]searchText, len(customerSearchFields))
+	for i, field := range customerSearchFields {
+		texts[i] = newSearchText(field.value(customer), field.pinyin)
+	}
+	ranges := make([][][2]int, len(customerSearchFields))
+	total := 0
+	for _, term := range terms {
+		bestScore, bestField := 0, -1
+		var bestMatch textMatch
+		for i, field := range customerSearchFields {
+			m, ok := texts[i].match(term)
+			if !ok {
+				continue
+			}
+			if score := m.score * field.weight / 10; score > bestScore {
+				bestScore, bestField, bestMatch = score, i, m
+			}
+		}
+		if bestField < 0 {
+			return 0, nil, false
+		}
+		total += bestScore
+		ranges[bestField] = append(ranges[bestField], [2]int{bestMatch.start, bestMatch.end})
+	}
+
+	highlights := make(map[string]string)
+	for i, field := range customerSearchFields {
+		if len(ranges[i]) > 0 {
+			highlights[field.name] = texts[i].highlight(ranges[i])
+		}
+	}
+	return total, highlights, true
+}
+
+// customerSearchIndex 客户倒排索引：倒排记录只追加，客户更新后旧的文档号失效，全量重建时清理
+type customerSearchIndex struct {
+	mu       sync.RWMutex
+	ids      []primitive.ObjectID
+	current  map[primitive.ObjectID]int32
+	postings map[string][]int32
+	builtAt  time.Time
+	syncedAt time.Time
+
+	// syncMu 保证同一时间只有一个增量同步在读取数据库
+	syncMu sync.Mutex
+
+	// rebuildMu 保护正在进行的全量重建，同一时间只有一个重建
+	rebuildMu   sync.Mutex
+	rebuildDone chan struct{}
+	rebuildErr  error
+}
+
+var customerIndex = &customerSearchIndex{}
+
+func newCustomerSearchIndexData() *customerSearchIndex {
+	return &customerSearchIndex{
+		current:  make(map[primitive.ObjectID]int32),
+		postings: make(map[string][]int32),
+	}
+}
+
+// add 加入或更新一个客户，调用方持有写锁
+func (idx *customerSearchIndex) add(customer *models.Customer) {
+	doc := int32(len(idx.ids))
+	idx.ids = append(idx.ids, customer.ID)
+	idx.current[customer.ID] = doc
+	terms := make(map[string]struct{})
+	for _, field := range customerSearchFields {
+		newSearchText(field.value(customer), field.pinyin).indexTerms(terms)
+	}
+	for term := range terms {
+		idx.postings[term] = append(idx.postings[term], doc)
+	}
+}
+
+// searchIndexProjection 建立索引需要的字段
+var searchIndexProjection = bson.M{"_id": 1, "name": 1, "contactPerson": 1, "applicationField": 1}
+
+// WarmCustomerSearchIndex 启动时在后台建立客户搜索索引，避免第一次搜索等待全量建立
+func WarmCustomerSearchIndex(ctx context.Context) {
+	customerIndex.startRebuild(ctx)
+}
+
+// rebuild 从数据库全量建立索引，完成后整体替换，建立期间查询继续使用原索引
+func (idx *customerSearchIndex) rebuild(ctx context.Context) error {
+	start := time.Now()
+	fresh := newCustomerSearchIndexData()
+	err := repository.Customers().Each(ctx, bson.M{}, func(customer *models.Customer) error {
+		fresh.add(customer)
+		return nil
+	}, repository.NewFindOptions().SetProjection(searchIndexProjection))
+	if err != nil {
+		return fmt.Errorf("建立客户搜索索引失败: %w", err)
+	}
+
+	// 建立期间更新的客户由下一次增量同步补上
+	idx.mu.Lock()
+	idx.ids, idx.current, idx.postings = fresh.ids, fresh.current, fresh.postings
+	idx.builtAt, idx.syncedAt = start, start
+	idx.mu.Unlock()
+
+	utils.LogInfo(map[string]interface{}{
+		"customers": len(fresh.ids),
+		"terms":     len(fresh.postings),
+		"took":      time.Since(start).String(),
+	}, "客户搜索索引已重建")
+	return nil
+}
+
+// startRebuild 在后台全量重建索引，已有重建进行时不重复启动；返回的通道在本次重建结束时关闭
+// 重建不随发起请求取消，只受 searchIndexRebuildTimeout 限制
+func (idx *customerSearchIndex) startRebuild(ctx context.Context) <-chan struct{} {
+	idx.rebuildMu.Lock()
+	defer idx.rebuildMu.Unlock()
+	if idx.rebuildDone != nil {
+		return idx.rebuildDone
+	}
+	done := make(chan struct{})
+	idx.rebuildDone = done
+	go func() {
+		rebuildCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), searchIndexRebuildTimeout)
+		defer cancel()
+		err := idx.rebuild(rebuildCtx)
+		if err != nil {
+			utils.Logger.Error().Err(err).Msg("重建客户搜索索引失败")
+		}
+
+		idx.rebuildMu.Lock()
+		idx.rebuildDone, idx.rebuildErr = nil, err
+		idx.rebuildMu.Unlock()
+		close(done)
+	}()
+	return done
+}
+
+// ensureFresh 尚无索引时等待全量建立；索引过期时在后台重建，期间继续使用现有索引并增量同步最近更新的客户
+func (idx *customerSearchIndex) ensureFresh(ctx context.Context) error {
+	idx.mu.RLock()
+	builtAt := idx.builtAt
+	idx.mu.RUnlock()
+
+	if builtAt.IsZero() {
+		select {
+		case <-idx.startRebuild(ctx):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		idx.rebuildMu.Lock()
+		defer idx.rebuildMu.Unlock()
+		return idx.rebuildErr
+	}
+	if time.Since(builtAt) > searchIndexRebuildInterval {
+		idx.startRebuild(ctx)
+	}
+
+	idx.syncMu.Lock()
+	defer idx.syncMu.Unlock()
+
+	idx.mu.RLock()
+	builtAt, syncedAt := idx.builtAt, idx.syncedAt
+	idx.mu.RUnlock()
+
+	now := time.Now()
+	if now.Sub(syncedAt) < searchIndexSyncInterval {
+		return nil
+	}
+
+	since := syncedAt.Add(-searchIndexSyncSkew)
+	var changed []models.Customer
+	err := repository.Customers().Each(ctx, bson.M{"$or": []bson.M{
+		{"updatedAt": bson.M{"$gte": since}},
+		{"lastUpdateTime": bson.M{"$gte": since}},
+		{"createdAt": bson.M{"$gte": since}},
+	}}, func(customer *models.Customer) error {
+		changed = append(changed, *customer)
+		return nil
+	}, repository.NewFindOptions().SetProjection(searchIndexProjection))
+	if err != nil {
+		return fmt.Errorf("同步客户搜索索引失败: %w", err)
+	}
+
+	idx.mu.Lock()
+	for i := range changed {
+		idx.add(&changed[i])
+	}
+	// 同步期间索引被重建替换时保留重建的时间点，下次同步补上重建期间的更新
+	if idx.builtAt.Equal(builtAt) {
+		idx.syncedAt = now
+	}
+	idx.mu.Unlock()
+	return nil
+}
+
+// candidates 返回包含全部查询词倒排词的客户ID
+func (idx *customerSearchIndex) candidates(terms []string) []primitive.ObjectID {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var result []int32
+	first := true
+	for _, term := range terms {
+		for _, gram := range queryGrams([]rune(term)) {
+			postings := idx.postings[gram]
+			if first {
+				result = append([]int32{}, postings...)
+				first = false
+			} else {
+				result = intersectPostings(result, postings)
+			}
+			if len(result) == 0 {
+				return nil
+			}
+		}
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(result))
+	for _, doc := range result {
+		id := idx.ids[doc]
+		if idx.current[id] == doc {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// intersectPostings 求两个升序文档号列表的交集
+func intersectPostings(a, b []int32) []int32 {
+	result := a[:0]
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			result = append(result, a[i])
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return result
+}
+
+// searchTerms 拆分并规范化搜索关键词
+func searchTerms(keyword string) []string {
+	terms := []string{}
+	seen := make(map[string]bool)
+	for _, word := range strings.Fields(keyword) {
+		if term := utils.NormalizeLookupName(word); term != "" && !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// searchHit 评分后的候选客户
+type searchHit struct {
+	customer   models.Customer
+	score      int
+	highlights map[string]string
+}
+
+// facetCounter 统计一个分面的取值数量，保持首次出现的顺序
+type facetCounter struct {
+	order  []string
+	counts map[string]int
+}
+
+func (f *facetCounter) add(value string) {
+	if f.counts == nil {
+		f.counts = make(map[string]int)
+	}
+	if _, ok := f.counts[value]; !ok {
+		f.order = append(f.order, value)
+	}
+	f.counts[value]++
+}
+
+// values 按数量倒序输出，label 为空时使用取值本身
+func (f *facetCounter) values(label func(string) string) []models.CustomerSearchFacetValue {
+	values := make([]models.CustomerSearchFacetValue, 0, len(f.order))
+	for _, value := range f.order {
+		values = append(values, models.CustomerSearchFacetValue{Value: value, Label: label(value), Count: f.counts[value]})
+	}
+	sort.SliceStable(values, func(i, j int) bool { return values[i].Count > values[j].Count })
+	return values
+}
+
+// SearchCustomers 按关键词搜索当前用户可访问的非公海客户：名称、联系人和应用领域支持子串、
+// 全拼和首字母匹配，按相关度排序，返回高亮和性质、重要程度、进展状态、关联销售的分面统计
+func SearchCustomers(ctx context.Context, query models.CustomerSearchQuery, user *utils.LoginUser) (*models.CustomerSearchResult, error) {
+	if len([]rune(query.Keyword)) > maxSearchKeywordLength {
+		return nil, utils.CreateBadRequestError(fmt.Sprintf("搜索关键词不能超过%d个字", maxSearchKeywordLength))
+	}
+	terms := searchTerms(query.Keyword)
+	if len(terms) == 0 {
+		return nil, utils.CreateBadRequestError("请输入搜索关键词")
+	}
+	scope, err := policy.OwnerFilter(user)
+	if err != nil {
+		return nil, utils.CreateForbiddenError()
+	}
+
+	if err := customerIndex.ensureFresh(ctx); err != nil {
+		return nil, err
+	}
+	candidateIDs := customerIndex.candidates(terms)
+
+	hits := []searchHit{}
+	base := policy.Merge(bson.M{"isInPublicPool": false}, scope)
+	for start := 0; start < len(candidateIDs); start += searchLoadBatchSize {
+		batch := candidateIDs[start:min(start+searchLoadBatchSize, len(candidateIDs))]
+		filter := bson.M{"$and": []bson.M{{"_id": bson.M{"$in": batch}}, base}}
+		err := repository.Customers().Each(ctx, filter, func(customer *models.Customer) error {
+			if score, highlights, ok := scoreCustomer(customer, terms); ok {
+				hits = append(hits, searchHit{customer: *customer, score: score, highlights: highlights})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("读取客户数据失败: %w", err)
+		}
+	}
+
+	// 每个分面按除自身以外的筛选条件统计
+	type facetFilter struct {
+		want    string
+		value   func(c *models.Customer) string
+		counter *facetCounter
+	}
+	filters := []*facetFilter{
+		{want: query.Nature, value: func(c *models.Customer) string { return c.Nature }, counter: &facetCounter{}},
+		{want: query.Importance, value: func(c *models.Customer) string { return c.Importance }, counter: &facetCounter{}},
+		{want: query.Progress, value: func(c *models.Customer) string { return c.Progress }, counter: &facetCounter{}},
+		{want: query.RelatedSalesID, value: func(c *models.Customer) string { return c.RelatedSalesID }, counter: &facetCounter{}},
+	}
+	matched := []searchHit{}
+	for _, hit := range hits {
+		failed := -1
+		for i, f := range filters {
+			if f.want != "" && f.value(&hit.customer) != f.want {
+				if failed >= 0 {
+					failed = len(filters)
+					break
+				}
+				failed = i
+			}
+		}
+		switch {
+		case failed < 0:
+			matched = append(matched, hit)
+			for _, f := range filters {
+				f.counter.add(f.value(&hit.customer))
+			}
+		case failed < len(filters):
+			filters[failed].counter.add(filters[failed].value(&hit.customer))
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].score != matched[j].score {
+			return matched[i].score > matched[j].score
+		}
+		return matched[i].customer.LastUpdateTime.After(matched[j].customer.LastUpdateTime)
+	})
+	total := int64(len(matched))
+	from := min((query.Page-1)*query.Limit, total)
+	page := matched[from:min(from+query.Limit, total)]
+
+	// 与客户列表一致，关联销售和代理商名称以当前名称为准
+	salesIDs := append([]string{}, filters[3].counter.order...)
+	agentIDs := []string{}
+	for _, hit := range page {
+		salesIDs = append(salesIDs, hit.customer.RelatedSalesID)
+		agentIDs = append(agentIDs, hit.customer.RelatedAgentID)
+	}
+	salesNames, err := repository.Users().NamesByIDs(ctx, salesIDs)
+	if err != nil {
+		return nil, err
+	}
+	agentNames, err := repository.Agents().NamesByIDs(ctx, agentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.CustomerSearchResult{
+		Keyword:    query.Keyword,
+		Hits:       make([]models.CustomerSearchHit, 0, len(page)),
+		Pagination: models.NewPagination(total, query.Page, query.Limit),
+	}
+	for _, hit := range page {
+		customer := hit.customer
+		if name, ok := salesNames[customer.RelatedSalesID]; ok {
+			customer.RelatedSalesName = name
+		}
+		if name, ok := agentNames[customer.RelatedAgentID]; ok {
+			customer.RelatedAgentName = name
+		}
+		result.Hits = append(result.Hits, models.CustomerSearchHit{Customer: customer, Score: hit.score, Highlights: hit.highlights})
+	}
+
+	same := func(value string) string { return value }
+	result.Facets = models.CustomerSearchFacets{
+		Nature:     filters[0].counter.values(same),
+		Importance: filters[1].counter.values(same),
+		Progress:   filters[2].counter.values(same),
+		Sales: filters[3].counter.values(func(id string) string {
+			if id == "" {
+				return "未分配"
+			}
+			if name, ok := salesNames[id]; ok {
+				return name
+			}
+			return "未知"
+		}),
+	}
+	return result, nil
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+)
+
+func TestCustomerSearchIndexRebuild(t *testing.T) {
+	ctx := useMemoryRepositories(t)
+	idx := &customerSearchIndex{}
+	first := insertCustomer(t, ctx, models.Customer{Name: "华为技术"})
+
+	// 尚无索引时等待全量建立
+	if err := idx.ensureFresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ids := idx.candidates([]string{"华为"}); len(ids) != 1 || ids[0] != first.ID {
+		t.Fatalf("candidates = %v", ids)
+	}
+
+	// 索引过期时在后台重建，当前请求继续使用已有索引并增量同步
+	idx.mu.Lock()
+	idx.builtAt = idx.builtAt.Add(-2 * searchIndexRebuildInterval)
+	idx.syncedAt = idx.builtAt
+	stale := idx.builtAt
+	idx.mu.Unlock()
+	second := insertCustomer(t, ctx, models.Customer{Name: "华为终端"})
+	if err := idx.ensureFresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ids := idx.candidates([]string{"华为"}); len(ids) != 2 {
+		t.Fatalf("candidates = %v, want %s %s", ids, first.ID.Hex(), second.ID.Hex())
+	}
+
+	select {
+	case <-idx.startRebuild(ctx):
+	case <-time.After(5 * time.Second):
+		t.Fatal("后台重建未完成")
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if !idx.builtAt.After(stale) || len(idx.ids) != 2 {
+		t.Fatalf("重建后 builtAt = %v, ids = %v", idx.builtAt, idx.ids)
+	}
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TrashPurgeReport 回收站清理结果
+type TrashPurgeReport struct {
+	Customers         int64 `json:"customers"`
+	Projects          int64 `json:"projects"`
+	FollowUps         int64 `json:"followUps"`
+	AssignmentHistory int64 `json:"assignmentHistory"`
+	ProgressHistory   int64 `json:"progressHistory"`
+}
+
+// SoftDeleteCustomer 将客户移入回收站，客户的项目、跟进记录和分配历史使用相同的删除时间一并标记，
+// 恢复时只恢复这一批记录，所有写入在同一事务中完成
+func SoftDeleteCustomer(ctx context.Context, customer *models.Customer, operator *utils.LoginUser) error {
+	// 与 MongoDB 的毫秒精度保持一致，恢复时按删除时间精确匹配
+	deletedAt := time.Now().Truncate(time.Millisecond)
+	customerID := customer.ID.Hex()
+
+	return repository.WithTransaction(ctx, func(txCtx context.Context) error {
+		result, err := repository.Customers().UpdateOne(txCtx,
+			bson.M{"_id": customer.ID, repository.DeletedAtField: bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{
+				repository.DeletedAtField: deletedAt,
+				"deletedById":             operator.ID,
+				"deletedByName":           operator.Username,
+			}},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return utils.CreateNotFoundError("客户")
+		}
+
+		mark := bson.M{"$set": bson.M{repository.DeletedAtField: deletedAt}}
+		if _, err := repository.Projects().UpdateMany(txCtx,
+			bson.M{"customerId": customer.ID, repository.DeletedAtField: bson.M{"$exists": false}}, mark); err != nil {
+			return fmt.Errorf("标记客户项目失败: %w", err)
+		}
+		if _, err := repository.FollowUps().UpdateMany(txCtx,
+			bson.M{"customerId": customerID, repository.DeletedAtField: bson.M{"$exists": false}}, mark); err != nil {
+			return fmt.Errorf("标记客户跟进记录失败: %w", err)
+		}
+		if _, err := repository.AssignmentHistory().UpdateMany(txCtx,
+			bson.M{"customerId": customerID, repository.DeletedAtField: bson.M{"$exists": false}}, mark); err != nil {
+			return fmt.Errorf("标记客户分配历史失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// RestoreCustomer 从回收站恢复客户及随其删除的关联记录，所有写入在同一事务中完成
+func RestoreCustomer(ctx context.Context, id primitive.ObjectID) (*models.Customer, error) {
+	customer, err := repository.Customers().Unscoped().FindByID(ctx, id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, utils.CreateNotFoundError("客户")
+		}
+		return nil, err
+	}
+	if customer.DeletedAt == nil {
+		return nil, utils.CreateBadRequestError("客户不在回收站中")
+	}
+	// 删除期间可能已新建同名客户并正常推进，恢复后会出现两个正常推进的同名客户
+	if customer.Progress == models.CustomerProgressNormal {
+		exists, err := repository.Customers().ExistsByNameAndProgress(ctx, customer.Name, models.CustomerProgressNormal)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, utils.NewApiError("已存在正常推进的同名客户，请先处理后再恢复", http.StatusConflict, "DUPLICATE_CUSTOMER")
+		}
+	}
+
+	deletedAt := *customer.DeletedAt
+	customerID := customer.ID.Hex()
+	unmark := bson.M{"$unset": bson.M{repository.DeletedAtField: ""}}
+
+	err = repository.WithTransaction(ctx, func(txCtx context.Context) error {
+		// 按删除时间匹配，并发恢复时只有一次能成功
+		result, err := repository.Customers().Unscoped().UpdateOne(txCtx,
+			bson.M{"_id": customer.ID, repository.DeletedAtField: deletedAt},
+			bson.M{
+				"$unset": bson.M{repository.DeletedAtField: "", "deletedById": "", "deletedByName": ""},
+				"$set":   bson.M{"updatedAt": time.Now()},
+			})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return utils.CreateBadRequestError("客户不在回收站中")
+		}
+
+		if _, err := repository.Projects().UpdateMany(txCtx,
+			bson.M{"customerId": customer.ID, repository.DeletedAtField: deletedAt}, unmark); err != nil {
+			return fmt.Errorf("恢复客户项目失败: %w", err)
+		}
+		if _, err := repository.FollowUps().UpdateMany(txCtx,
+			bson.M{"customerId": customerID, repository.DeletedAtField: deletedAt}, unmark); err != nil {
+			return fmt.Errorf("恢复客户跟进记录失败: %w", err)
+		}
+		if _, err := repository.AssignmentHistory().UpdateMany(txCtx,
+			bson.M{"customerId": customerID, repository.DeletedAtField: deletedAt}, unmark); err != nil {
+			return fmt.Errorf("恢复客户分配历史失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	customer.DeletedAt = nil
+	customer.DeletedByID = ""
+	customer.DeletedByName = ""
+	return customer, nil
+}
+
+// ListDeletedCustomers 分页查询回收站中的客户，按删除时间倒序
+func ListDeletedCustomers(ctx context.Context, page int64, limit int64) ([]models.Customer, int64, error) {
+	repo := repository.Customers().Unscoped()
+	filter := repository.DeletedFilter()
+	total, err := repo.Count(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	customers, err := repo.Find(ctx, filter, repository.NewFindOptions().
+		SetSort(repository.DeletedAtField, -1).
+		SetSkip((page-1)*limit).
+		SetLimit(limit))
+	if err != nil {
+		return nil, 0, err
+	}
+	return customers, total, nil
+}
+
+// PurgeDeletedCustomers 永久删除在回收站中超过保留期的客户及其项目、跟进记录、分配历史和进展历史
+func PurgeDeletedCustomers(ctx context.Context, retention time.Duration) (TrashPurgeReport, error) {
+	var report TrashPurgeReport
+	cutoff := time.Now().Add(-retention)
+	expired, err := repository.Customers().Unscoped().Find(ctx,
+		bson.M{repository.DeletedAtField: bson.M{"$lt": cutoff}},
+		repository.NewFindOptions().SetProjection(bson.M{"_id": 1, "name": 1, repository.DeletedAtField: 1}))
+	if err != nil {
+		return report, err
+	}
+
+	for _, customer := range expired {
+		counts, err := purgeCustomer(ctx, customer)
+		if err != nil {
+			return report, fmt.Errorf("清理客户 %s 失败: %w", customer.ID.Hex(), err)
+		}
+		report.Customers++
+		report.Projects += counts.Projects
+		report.FollowUps += counts.FollowUps
+		report.AssignmentHistory += counts.AssignmentHistory
+		report.ProgressHistory += counts.ProgressHistory
+	}
+	return report, nil
+}
+
+// purgeCustomer 永久删除单个客户及其全部关联数据（含项目的跟进记录和进展历史），最后删除客户本身
+func purgeCustomer(ctx context.Context, customer models.Customer) (TrashPurgeReport, error) {
+	var counts TrashPurgeReport
+	customerID := customer.ID.Hex()
+
+	projects, err := repository.Projects().Unscoped().Find(ctx, bson.M{"customerId": customer.ID},
+		repository.NewFindOptions().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return counts, err
+	}
+	if len(projects) > 0 {
+		projectIDs := make([]string, len(projects))
+		for i, project := range projects {
+			projectIDs[i] = project.ID.Hex()
+		}
+		byProject := bson.M{"projectId": bson.M{"$in": projectIDs}}
+		if _, err := repository.ProjectFollowUps().DeleteMany(ctx, byProject); err != nil {
+			return counts, err
+		}
+		if _, err := repository.ProjectProgress().DeleteMany(ctx, byProject); err != nil {
+			return counts, err
+		}
+		if counts.Projects, err = repository.Projects().DeleteMany(ctx, bson.M{"customerId": customer.ID}); err != nil {
+			return counts, err
+		}
+	}
+
+	byCustomer := bson.M{"customerId": customerID}
+	if counts.FollowUps, err = repository.FollowUps().DeleteMany(ctx, byCustomer); err != nil {
+		return counts, err
+	}
+	if counts.AssignmentHistory, err = repository.AssignmentHistory().DeleteMany(ctx, byCustomer); err != nil {
+		return counts, err
+	}
+	if counts.ProgressHistory, err = repository.CustomerProgress().DeleteMany(ctx, byCustomer); err != nil {
+		return counts, err
+	}
+	if _, err := repository.Customers().DeleteMany(ctx, bson.M{"_id": customer.ID, repository.DeletedAtField: bson.M{"$exists": true}}); err != nil {
+		return counts, err
+	}
+
+	utils.LogInfo(map[string]interface{}{
+		"customerId": customerID,
+		"name":       customer.Name,
+		"deletedAt":  customer.DeletedAt,
+	}, "回收站客户已永久删除")
+	return counts, nil
+}
+
+// RunTrashPurge 定时清理回收站，返回各类数据的删除数量
+func RunTrashPurge(ctx context.Context, retention time.Duration) (map[string]int64, error) {
+	report, err := PurgeDeletedCustomers(ctx, retention)
+	counts := map[string]int64{
+		"customers":         report.Customers,
+		"projects":          report.Projects,
+		"followUps":         report.FollowUps,
+		"assignmentHistory": report.AssignmentHistory,
+		"progressHistory":   report.ProgressHistory,
+	}
+	if err != nil {
+		return counts, fmt.Errorf("清理回收站失败: %w", err)
+	}
+	return counts, nil
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// useMemoryRepositories 每个测试使用独立的内存仓储
+func useMemoryRepositories(t *testing.T) context.Context {
+	t.Helper()
+	repository.SetRepositories(repository.NewMemoryRepositories())
+	return context.Background()
+}
+
+// insertCustomer 保存客户并返回带ID的客户
+func insertCustomer(t *testing.T, ctx context.Context, customer models.Customer) models.Customer {
+	t.Helper()
+	if customer.CreatedAt.IsZero() {
+		customer.CreatedAt = time.Now()
+	}
+	id, err := repository.Customers().Insert(ctx, &customer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	customer.ID = id
+	return customer
+}
+
+// findCustomer 重新读取客户
+func findCustomer(t *testing.T, ctx context.Context, id primitive.ObjectID) *models.Customer {
+	t.Helper()
+	customer, err := repository.Customers().FindByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return customer
+}
+
+// insertProject 保存客户下的项目
+func insertProject(t *testing.T, ctx context.Context, customer models.Customer, hidden bool) primitive.ObjectID {
+	t.Helper()
+	id, err := repository.Projects().Insert(ctx, &models.Project{
+		ProjectName:  customer.Name + "项目",
+		CustomerID:   customer.ID,
+		CustomerName: customer.Name,
+		WebHidden:    hidden,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// insertSales 保存一个在职销售
+func insertSales(t *testing.T, ctx context.Context, username string) models.User {
+	t.Helper()
+	user := models.User{Username: username, Role: models.UserRoleFACTORY_SALES, Status: models.UserStatusAPPROVED}
+	id, err := repository.Users().Insert(ctx, &user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.ID = id
+	return user
+}
+
+// loginUser 账户对应的登录用户
+func loginUser(user models.User) *utils.LoginUser {
+	return &utils.LoginUser{ID: user.ID.Hex(), Role: string(user.Role), Username: user.Username}
+}
+
+// insertConfig 保存一条已启用的系统配置
+func insertConfig(t *testing.T, ctx context.Context, configType models.ConfigType, key string, value bson.M) primitive.ObjectID {
+	t.Helper()
+	id, err := repository.SystemConfigs().Insert(ctx, &models.SystemConfig{
+		ConfigType:  configType,
+		ConfigKey:   key,
+		ConfigValue: value,
+		IsEnabled:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// wantErrorCode 断言错误为指定错误码的 ApiError
+func wantErrorCode(t *testing.T, err error, code string) {
+	t.Helper()
+	apiErr, ok := err.(*utils.ApiError)
+	if !ok {
+		t.Fatalf("err = %v, want ApiError %s", err, code)
+	}
+	if apiErr.ErrorCode != code {
+		t.Fatalf("ErrorCode = %s, want %s（%s）", apiErr.ErrorCode, code, apiErr.Message)
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+)
+
+// 登录防爆破策略
+const (
+	// loginFailureWindow 最后一次失败后计数保留的时长
+	loginFailureWindow = 30 * time.Minute
+	// usernameLockThreshold 同一登录名连续失败达到该次数后锁定
+	usernameLockThreshold = 5
+	// ipLockThreshold 同一IP连续失败达到该次数后锁定
+	ipLockThreshold = 20
+	// loginLockBase 首次锁定时长，之后每次锁定翻倍
+	loginLockBase = 15 * time.Minute
+	// loginLockMax 锁定时长上限
+	loginLockMax = 24 * time.Hour
+	// loginDelayFreeFailures 连续失败超过该次数后开始延迟响应
+	loginDelayFreeFailures = 2
+	// loginDelayBase 首次延迟时长，之后每次失败翻倍
+	loginDelayBase = 500 * time.Millisecond
+	// loginDelayMax 单次延迟上限
+	loginDelayMax = 5 * time.Second
+)
+
+// LoginLockedMessage 账户或IP锁定时的统一提示，不区分账户是否存在
+const LoginLockedMessage = "登录失败次数过多，请稍后再试"
+
+// usernameKey 登录名计数键，忽略大小写和首尾空白
+func usernameKey(username string) string {
+	return "username:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+// ipKey 客户端IP计数键
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// CheckLoginAllowed 检查登录名和IP是否处于锁定期，返回剩余锁定时长（0 表示允许登录）
+func CheckLoginAllowed(ctx context.Context, username string, ip string) (time.Duration, error) {
+	now := time.Now()
+	var remaining time.Duration
+	for _, key := range []string{usernameKey(username), ipKey(ip)} {
+		attempt, err := repository.LoginAttempts().FindByKey(ctx, key)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				continue
+			}
+			return 0, err
+		}
+		if attempt.LockedUntil != nil && attempt.LockedUntil.After(now) {
+			if d := attempt.LockedUntil.Sub(now); d > remaining {
+				remaining = d
+			}
+		}
+	}
+	return remaining, nil
+}
+
+// RecordLoginFailure 记录一次登录失败，达到阈值时锁定并写入操作日志
+// 返回本次失败应延迟响应的时长，用于减缓暴力破解
+func RecordLoginFailure(ctx context.Context, username string, ip string, userAgent string) (time.Duration, error) {
+	now := time.Now()
+	expiresAt := now.Add(loginFailureWindow)
+
+	userAttempt, err := repository.LoginAttempts().RecordFailure(ctx, usernameKey(username), models.LoginAttemptKeyUsername, now, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	if userAttempt.Failures >= usernameLockThreshold {
+		if err := lockLoginKey(ctx, userAttempt, username, ip, userAgent, now); err != nil {
+			return 0, err
+		}
+	}
+
+	ipAttempt, err := repository.LoginAttempts().RecordFailure(ctx, ipKey(ip), models.LoginAttemptKeyIP, now, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	if ipAttempt.Failures >= ipLockThreshold {
+		if err := lockLoginKey(ctx, ipAttempt, username, ip, userAgent, now); err != nil {
+			return 0, err
+		}
+	}
+
+	failures := userAttempt.Failures
+	if ipAttempt.Failures > failures {
+		failures = ipAttempt.Failures
+	}
+	return loginFailureDelay(failures), nil
+}
+
+// lockLoginKey 锁定计数键，锁定时长随锁定次数翻倍
+func lockLoginKey(ctx context.Context, attempt *models.LoginAttempt, username string, ip string, userAgent string, now time.Time) error {
+	duration := loginLockBase
+	for i := 0; i < attempt.LockCount && duration < loginLockMax; i++ {
+		duration *= 2
+	}
+	if duration > loginLockMax {
+		duration = loginLockMax
+	}
+	until := now.Add(duration)
+
+	// 锁定期结束后仍保留计数一段时间，期间再次失败会立即以更长时长锁定
+	if err := repository.LoginAttempts().Lock(ctx, attempt.Key, until, until.Add(loginFailureWindow)); err != nil {
+		return err
+	}
+
+	utils.Logger.Warn().
+		Str("key", attempt.Key).
+		Int("failures", attempt.Failures).
+		Time("lockedUntil", until).
+		Msg("登录失败次数过多，已临时锁定")
+
+	log := &models.OperationLog{
+		Method:        "LOCKOUT",
+		Path:          "/api/auth/login",
+		OperatorName:  username,
+		OperatorType:  "SYSTEM",
+		RequestBody:   map[string]interface{}{"key": attempt.Key, "failures": attempt.Failures, "lockedUntil": until},
+		StatusCode:    429,
+		Success:       false,
+		ErrorMessage:  fmt.Sprintf("连续登录失败 %d 次，锁定 %s", attempt.Failures, duration),
+		OperationTime: now,
+		IPAddress:     ip,
+		UserAgent:     userAgent,
+	}
+	if _, err := repository.OperationLogs().Insert(ctx, log); err != nil {
+		utils.Logger.Error().Err(err).Str("key", attempt.Key).Msg("记录锁定日志失败")
+	}
+	return nil
+}
+
+// loginFailureDelay 按连续失败次数计算延迟时长
+func loginFailureDelay(failures int) time.Duration {
+	if failures <= loginDelayFreeFailures {
+		return 0
+	}
+	delay := loginDelayBase
+	for i := loginDelayFreeFailures + 1; i < failures && delay < loginDelayMax; i++ {
+		delay *= 2
+	}
+	if delay > loginDelayMax {
+		delay = loginDelayMax
+	}
+	return delay
+}
+
+// ResetLoginFailures 登录成功后清除该登录名的失败计数
+func ResetLoginFailures(ctx context.Context, username string) error {
+	_, err := repository.LoginAttempts().DeleteByKey(ctx, usernameKey(username))
+	return err
+}
+
+// UnlockLogin 管理员解除登录名的锁定，返回是否存在锁定/失败记录
+func UnlockLogin(ctx context.Context, username string) (bool, error) {
+	deleted, err := repository.LoginAttempts().DeleteByKey(ctx, usernameKey(username))
+	if err != nil {
+		return false, err
+	}
+	return deleted > 0, nil
+}
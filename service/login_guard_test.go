@@ -0,0 +1,107 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/repository"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestLoginFailureDelay(t *testing.T) {
+	cases := map[int]time.Duration{
+		0:  0,
+		2:  0,
+		3:  500 * time.Millisecond,
+		4:  time.Second,
+		6:  4 * time.Second,
+		7:  5 * time.Second,
+		50: 5 * time.Second,
+	}
+	for failures, want := range cases {
+		if got := loginFailureDelay(failures); got != want {
+			t.Errorf("loginFailureDelay(%d) = %s, want %s", failures, got, want)
+		}
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	ctx := useMemoryRepositories(t)
+	const ip = "10.0.0.1"
+
+	for i := 1; i < usernameLockThreshold; i++ {
+		if _, err := RecordLoginFailure(ctx, "Sales", ip, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if remaining, err := CheckLoginAllowed(ctx, "sales", ip); err != nil || remaining != 0 {
+		t.Fatalf("未达到阈值: remaining = %s, err = %v", remaining, err)
+	}
+
+	// 登录名忽略大小写和首尾空白
+	delay, err := RecordLoginFailure(ctx, " SALES ", ip, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delay != loginFailureDelay(usernameLockThreshold) {
+		t.Fatalf("delay = %s", delay)
+	}
+	remaining, err := CheckLoginAllowed(ctx, "sales", "10.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining <= loginLockBase-time.Minute || remaining > loginLockBase {
+		t.Fatalf("首次锁定剩余 %s, want 约 %s", remaining, loginLockBase)
+	}
+	if remaining, _ := CheckLoginAllowed(ctx, "other", ip); remaining != 0 {
+		t.Fatalf("IP 未达到阈值时其他登录名不应锁定: %s", remaining)
+	}
+	if count, _ := repository.OperationLogs().Count(ctx, bson.M{"method": "LOCKOUT"}); count != 1 {
+		t.Fatalf("锁定日志数 = %d, want 1", count)
+	}
+
+	// 锁定期内再次失败时锁定时长翻倍
+	if _, err := RecordLoginFailure(ctx, "sales", ip, ""); err != nil {
+		t.Fatal(err)
+	}
+	if remaining, _ := CheckLoginAllowed(ctx, "sales", ip); remaining <= loginLockBase {
+		t.Fatalf("再次锁定剩余 %s, want 约 %s", remaining, 2*loginLockBase)
+	}
+
+	unlocked, err := UnlockLogin(ctx, "SALES")
+	if err != nil || !unlocked {
+		t.Fatalf("UnlockLogin = %v, %v", unlocked, err)
+	}
+	if remaining, _ := CheckLoginAllowed(ctx, "sales", ip); remaining != 0 {
+		t.Fatalf("解锁后剩余 %s", remaining)
+	}
+	if unlocked, _ := UnlockLogin(ctx, "sales"); unlocked {
+		t.Fatal("没有锁定记录时应返回 false")
+	}
+}
+
+func TestLoginLockoutByIP(t *testing.T) {
+	ctx := useMemoryRepositories(t)
+	const ip = "10.0.0.1"
+
+	// 轮换登录名的失败按 IP 累计
+	for i := 0; i < ipLockThreshold; i++ {
+		if _, err := RecordLoginFailure(ctx, "user"+string(rune('a'+i)), ip, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if remaining, _ := CheckLoginAllowed(ctx, "new-user", ip); remaining == 0 {
+		t.Fatal("IP 达到阈值后应锁定")
+	}
+	if remaining, _ := CheckLoginAllowed(ctx, "new-user", "10.0.0.2"); remaining != 0 {
+		t.Fatalf("其他 IP 不应锁定: %s", remaining)
+	}
+
+	// 登录成功只清除登录名计数，不解除 IP 锁定
+	if err := ResetLoginFailures(ctx, "usera"); err != nil {
+		t.Fatal(err)
+	}
+	if remaining, _ := CheckLoginAllowed(ctx, "usera", ip); remaining == 0 {
+		t.Fatal("IP 锁定不应被登录成功清除")
+	}
+}
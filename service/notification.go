@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Notify 发送站内通知，DedupeKey 非空时同一接收人同一键只发送一次，返回是否实际发送
+func Notify(ctx context.Context, notification models.Notification) (bool, error) {
+	if notification.RecipientID == "" {
+		return false, nil
+	}
+	if notification.DedupeKey != "" {
+		count, err := repository.Notifications().Count(ctx, bson.M{
+			"recipientId": notification.RecipientID,
+			"dedupeKey":   notification.DedupeKey,
+		})
+		if err != nil {
+			return false, fmt.Errorf("查询通知记录失败: %w", err)
+		}
+		if count > 0 {
+			return false, nil
+		}
+	}
+
+	notification.Read = false
+	notification.ReadAt = nil
+	notification.CreatedAt = time.Now()
+	if _, err := repository.Notifications().Insert(ctx, &notification); err != nil {
+		return false, fmt.Errorf("发送通知失败: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// roleCacheTTL 角色缓存有效期；本进程内的修改会立即失效缓存，
+// TTL 用于多实例部署时同步其他实例的修改
+const roleCacheTTL = 5 * time.Minute
+
+var roleCache = struct {
+	sync.RWMutex
+	roles     map[models.UserRole]models.Role
+	expiresAt time.Time
+}{}
+
+// InvalidateRoleCache 使角色缓存失效，角色增删改后调用
+func InvalidateRoleCache() {
+	roleCache.Lock()
+	roleCache.roles = nil
+	roleCache.expiresAt = time.Time{}
+	roleCache.Unlock()
+}
+
+// loadRoles 返回全部角色，优先读取缓存
+func loadRoles(ctx context.Context) map[models.UserRole]models.Role {
+	roleCache.RLock()
+	if roleCache.roles != nil && time.Now().Before(roleCache.expiresAt) {
+		roles := roleCache.roles
+		roleCache.RUnlock()
+		return roles
+	}
+	roleCache.RUnlock()
+
+	roleCache.Lock()
+	defer roleCache.Unlock()
+	if roleCache.roles != nil && time.Now().Before(roleCache.expiresAt) {
+		return roleCache.roles
+	}
+
+	list, err := repository.Roles().Find(ctx, bson.M{})
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("加载角色失败")
+		// 数据库暂不可用时沿用旧缓存，避免全部请求被拒绝
+		if roleCache.roles != nil {
+			return roleCache.roles
+		}
+	}
+	if len(list) == 0 {
+		// 角色尚未初始化时使用内置角色
+		list = models.DefaultRoles()
+	}
+
+	roles := make(map[models.UserRole]models.Role, len(list))
+	for _, role := range list {
+		roles[role.Name] = role
+	}
+	roleCache.roles = roles
+	roleCache.expiresAt = time.Now().Add(roleCacheTTL)
+	return roles
+}
+
+// GetRole 按角色标识获取角色
+func GetRole(ctx context.Context, name models.UserRole) (*models.Role, bool) {
+	role, ok := loadRoles(ctx)[name]
+	if !ok {
+		return nil, false
+	}
+	return &role, true
+}
+
+// RoleExists 判断角色是否已定义
+func RoleExists(ctx context.Context, name models.UserRole) bool {
+	_, ok := GetRole(ctx, name)
+	return ok
+}
+
+// HasPermission 检查角色是否拥有资源上的操作权限
+func HasPermission(ctx context.Context, role models.UserRole, resource string, action string) bool {
+	r, ok := GetRole(ctx, role)
+	if !ok {
+		return false
+	}
+	return r.Allows(resource, action)
+}
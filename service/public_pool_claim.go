@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/policy"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 分配历史中的操作类型
+const (
+	operationClaim            = "认领"
+	operationMoveToPublicPool = models.MoveToPublicPoolOperationType
+)
+
+// 公海认领错误码
+const (
+	ErrCodeClaimQuotaExceeded     = "CLAIM_QUOTA_EXCEEDED"
+	ErrCodeClaimCooldown          = "CLAIM_COOLDOWN"
+	ErrCodeCustomerAlreadyClaimed = "CUSTOMER_ALREADY_CLAIMED"
+	ErrCodeClaimConflict          = "CLAIM_CONFLICT"
+)
+
+// defaultClaimCooldownDays 未配置公海认领规则时的冷却天数，额度默认不限
+const defaultClaimCooldownDays = 7
+
+// LoadPublicPoolClaimConfig 读取已启用的公海认领配置，未配置时使用默认规则
+func LoadPublicPoolClaimConfig(ctx context.Context) (*models.PublicPoolClaimConfig, error) {
+	configs, err := repository.SystemConfigs().FindEnabledByType(ctx, models.ConfigTypePublicPoolClaim)
+	if err != nil {
+		return nil, fmt.Errorf("查询公海认领配置失败: %w", err)
+	}
+	if len(configs) == 0 {
+		return &models.PublicPoolClaimConfig{
+			Quotas:       map[string]models.PublicPoolClaimQuota{},
+			CooldownDays: defaultClaimCooldownDays,
+		}, nil
+	}
+
+	// configValue 经 JSON 写入后是任意文档类型，统一通过 BSON 往返转换
+	data, err := bson.Marshal(configs[0].ConfigValue)
+	if err != nil {
+		return nil, fmt.Errorf("公海认领配置格式错误: %w", err)
+	}
+	config := &models.PublicPoolClaimConfig{}
+	if err := bson.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("公海认领配置格式错误: %w", err)
+	}
+	if config.CooldownDays < 0 {
+		return nil, fmt.Errorf("公海认领配置格式错误: cooldownDays 不能为负数")
+	}
+	for role, quota := range config.Quotas {
+		if quota.DailyLimit < 0 || quota.TotalLimit < 0 {
+			return nil, fmt.Errorf("公海认领配置格式错误: 角色 %s 的额度不能为负数", role)
+		}
+	}
+	if config.Quotas == nil {
+		config.Quotas = map[string]models.PublicPoolClaimQuota{}
+	}
+	return config, nil
+}
+
+// startOfToday 当天零点（服务器时区），每日认领额度按此统计
+func startOfToday() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// countClaimUsage 统计用户当天计入额度的认领次数（与额度校验使用同一计数）和名下的非公海客户数
+func countClaimUsage(ctx context.Context, user *utils.LoginUser) (daily int64, owned int64, err error) {
+	daily, err = repository.ClaimCounters().ClaimedOn(ctx, user.ID, startOfToday())
+	if err != nil {
+		return 0, 0, fmt.Errorf("统计今日认领次数失败: %w", err)
+	}
+	owned, err = countOwnedCustomers(ctx, user)
+	if err != nil {
+		return 0, 0, err
+	}
+	return daily, owned, nil
+}
+
+// countOwnedCustomers 统计用户名下的非公海客户数
+func countOwnedCustomers(ctx context.Context, user *utils.LoginUser) (int64, error) {
+	scope, err := policy.OwnerFilter(user)
+	if err != nil {
+		return 0, utils.CreateForbiddenError()
+	}
+	owned, err := repository.Customers().Count(ctx, policy.Merge(bson.M{"isInPublicPool": false}, scope))
+	if err != nil {
+		return 0, fmt.Errorf("统计名下客户数失败: %w", err)
+	}
+	return owned, nil
+}
+
+// checkPublicPoolClaim 校验用户能否认领该公海客户：原归属人冷却期、每日额度和名下客户总数额度
+// 需要在与认领写入相同的事务中调用：先按每日额度条件递增用户当天的认领计数，同一用户的并发认领在计数上产生写冲突，
+// 事务重试后再统计名下客户数，额度不会被并发认领突破；校验失败时事务回滚，计数一并撤销
+func checkPublicPoolClaim(ctx context.Context, user *utils.LoginUser, customer *models.Customer) error {
+	config, err := LoadPublicPoolClaimConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	if config.CooldownDays > 0 {
+		lastMove, err := repository.AssignmentHistory().FindOne(ctx, bson.M{
+			"customerId":    customer.ID.Hex(),
+			"operationType": operationMoveToPublicPool,
+		}, repository.NewFindOptions().SetSort("createdAt", -1))
+		if err != nil && err != repository.ErrNotFound {
+			return fmt.Errorf("查询客户移入公海记录失败: %w", err)
+		}
+		if lastMove != nil && (lastMove.FromRelatedSalesID == user.ID || lastMove.FromRelatedAgentID == user.ID) {
+			availableAt := lastMove.CreatedAt.AddDate(0, 0, config.CooldownDays)
+			if time.Now().Before(availableAt) {
+				return utils.NewApiError(
+					fmt.Sprintf("该客户从您名下移入公海不足%d天，%s 后可重新认领", config.CooldownDays, availableAt.Local().Format("2006-01-02 15:04")),
+					http.StatusForbidden,
+					ErrCodeClaimCooldown,
+				)
+			}
+		}
+	}
+
+	quota, ok := config.Quotas[user.Role]
+	if !ok || (quota.DailyLimit == 0 && quota.TotalLimit == 0) {
+		return nil
+	}
+	allowed, err := repository.ClaimCounters().Increment(ctx, user.ID, startOfToday(), quota.DailyLimit)
+	if err == repository.ErrClaimCounterConflict {
+		return utils.NewApiError(err.Error(), http.StatusConflict, ErrCodeClaimConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("更新今日认领次数失败: %w", err)
+	}
+	if !allowed {
+		return utils.NewApiError(fmt.Sprintf("今日认领已达上限（%d个），请明天再试", quota.DailyLimit), http.StatusForbidden, ErrCodeClaimQuotaExceeded)
+	}
+	if quota.TotalLimit == 0 {
+		return nil
+	}
+	owned, err := countOwnedCustomers(ctx, user)
+	if err != nil {
+		return err
+	}
+	if owned >= int64(quota.TotalLimit) {
+		return utils.NewApiError(fmt.Sprintf("名下客户已达上限（%d个），请先释放客户再认领", quota.TotalLimit), http.StatusForbidden, ErrCodeClaimQuotaExceeded)
+	}
+	return nil
+}
+
+// GetPublicPoolClaimQuota 查询当前用户的认领额度使用情况
+func GetPublicPoolClaimQuota(ctx context.Context, user *utils.LoginUser) (*models.PublicPoolClaimQuotaStatus, error) {
+	config, err := LoadPublicPoolClaimConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	daily, owned, err := countClaimUsage(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	quota := config.Quotas[user.Role]
+	return &models.PublicPoolClaimQuotaStatus{
+		Role:         user.Role,
+		DailyLimit:   quota.DailyLimit,
+		DailyClaimed: daily,
+		TotalLimit:   quota.TotalLimit,
+		TotalOwned:   owned,
+		CooldownDays: config.CooldownDays,
+	}, nil
+}
+
+// ClaimPublicPoolCustomer 销售或代理商认领公海客户：代理商认领时同时关联其所属销售
+// 额度校验、客户更新（以客户仍在公海为条件）和分配历史在同一事务中完成，两人同时认领时只有一人成功
+func ClaimPublicPoolCustomer(ctx context.Context, customerID string, user *utils.LoginUser) (*models.Customer, error) {
+	role := models.UserRole(user.Role)
+	if role != models.UserRoleFACTORY_SALES && role != models.UserRoleAGENT {
+		return nil, utils.NewApiError("仅销售和代理商可以认领公海客户，管理员请使用分配功能", http.StatusForbidden, "FORBIDDEN")
+	}
+
+	objID, err := primitive.ObjectIDFromHex(customerID)
+	if err != nil {
+		return nil, utils.CreateBadRequestError("无效的客户ID格式")
+	}
+	customer, err := repository.Customers().FindByID(ctx, objID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, utils.CreateNotFoundError("客户")
+		}
+		return nil, err
+	}
+	if !customer.IsInPublicPool {
+		return nil, utils.NewApiError("该客户已不在公海中", http.StatusConflict, ErrCodeCustomerAlreadyClaimed)
+	}
+
+	fields := bson.M{
+		"isInPublicPool":   false,
+		"relatedSalesId":   nil,
+		"relatedSalesName": nil,
+		"relatedAgentId":   nil,
+		"relatedAgentName": nil,
+		// 认领后不能再恢复原归属，移入公海前的联系人信息一并清除
+		"poolSnapshot": nil,
+	}
+	history := models.CustomerAssignmentHistory{
+		CustomerID:           customer.ID.Hex(),
+		CustomerName:         customer.Name,
+		FromRelatedSalesID:   customer.RelatedSalesID,
+		FromRelatedSalesName: customer.RelatedSalesName,
+		FromRelatedAgentID:   customer.RelatedAgentID,
+		FromRelatedAgentName: customer.RelatedAgentName,
+		OperatorID:           user.ID,
+		OperatorName:         user.Username,
+		OperationType:        operationClaim,
+	}
+	if role == models.UserRoleFACTORY_SALES {
+		fields["relatedSalesId"] = user.ID
+		fields["relatedSalesName"] = user.Username
+		history.ToRelatedSalesID, history.ToRelatedSalesName = user.ID, user.Username
+	} else {
+		agentObjID, err := primitive.ObjectIDFromHex(user.ID)
+		if err != nil {
+			return nil, utils.CreateForbiddenError()
+		}
+		agent, err := repository.Agents().FindByID(ctx, agentObjID)
+		if err != nil {
+			return nil, fmt.Errorf("查询代理商信息失败: %w", err)
+		}
+		fields["relatedAgentId"] = user.ID
+		fields["relatedAgentName"] = agent.CompanyName
+		history.ToRelatedAgentID, history.ToRelatedAgentName = user.ID, agent.CompanyName
+		if agent.RelatedSalesID != "" {
+			salesNames, err := repository.Users().NamesByIDs(ctx, []string{agent.RelatedSalesID})
+			if err != nil {
+				return nil, err
+			}
+			fields["relatedSalesId"] = agent.RelatedSalesID
+			fields["relatedSalesName"] = salesNames[agent.RelatedSalesID]
+			history.ToRelatedSalesID, history.ToRelatedSalesName = agent.RelatedSalesID, salesNames[agent.RelatedSalesID]
+		}
+	}
+
+	progress := models.CustomerProgressInitialContact
+	hasProjects, err := HasProjects(ctx, customer.ID)
+	if err != nil {
+		return nil, err
+	}
+	if hasProjects {
+		progress = models.CustomerProgressNormal
+	} else {
+		fields["initialContactTime"] = time.Now()
+	}
+
+	err = repository.WithTransaction(ctx, func(txCtx context.Context) error {
+		if err := checkPublicPoolClaim(txCtx, user, customer); err != nil {
+			return err
+		}
+
+		// 事务遇到临时错误会整体重试，每次都从查询时的客户状态开始
+		current := *customer
+		if _, err := ChangeCustomerProgress(txCtx, ProgressChange{
+			Customer:  &current,
+			To:        progress,
+			Operator:  user,
+			Remark:    "认领公海客户",
+			Fields:    fields,
+			Condition: bson.M{"isInPublicPool": true},
+		}); err != nil {
+			return err
+		}
+		if progress == models.CustomerProgressNormal {
+			if err := UpdateCustomerProgressByName(txCtx, customer.Name, models.CustomerProgressDisabled, user, "同名客户已正常推进"); err != nil {
+				return fmt.Errorf("客户为正常推进状态，修改其他同名客户信息报错: %w", err)
+			}
+		}
+		return AddAssignmentHistory(txCtx, history)
+	})
+	if err != nil {
+		if apiErr, ok := err.(*utils.ApiError); ok && apiErr.ErrorCode == ErrCodeProgressConflict {
+			return nil, utils.NewApiError("该客户已被其他人认领", http.StatusConflict, ErrCodeCustomerAlreadyClaimed)
+		}
+		return nil, err
+	}
+
+	utils.LogInfo(map[string]interface{}{
+		"customerId": customerID,
+		"customer":   customer.Name,
+		"user":       user.Username,
+		"role":       user.Role,
+	}, "认领公海客户成功")
+
+	return repository.Customers().FindByID(ctx, objID)
+}
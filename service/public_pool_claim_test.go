@@ -0,0 +1,155 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestClaimPublicPoolCustomer(t *testing.T) {
+	ctx := useMemoryRepositories(t)
+	insertConfig(t, ctx, models.ConfigTypePublicPoolClaim, "claim", bson.M{
+		"quotas":       bson.M{"FACTORY_SALES": bson.M{"dailyLimit": 2, "totalLimit": 3}},
+		"cooldownDays": 7,
+	})
+	salesA := loginUser(insertSales(t, ctx, "销售甲"))
+	salesB := loginUser(insertSales(t, ctx, "销售乙"))
+	pooled := make([]models.Customer, 3)
+	for i := range pooled {
+		pooled[i] = insertCustomer(t, ctx, models.Customer{
+			Name:           "公海客户" + string(rune('一'+i)),
+			Progress:       models.CustomerProgressPublicPool,
+			IsInPublicPool: true,
+		})
+	}
+
+	t.Run("认领后归属认领人", func(t *testing.T) {
+		customer, err := ClaimPublicPoolCustomer(ctx, pooled[0].ID.Hex(), salesA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if customer.IsInPublicPool || customer.RelatedSalesID != salesA.ID || customer.RelatedSalesName != salesA.Username {
+			t.Fatalf("customer = %+v", customer)
+		}
+		if customer.Progress != models.CustomerProgressInitialContact || customer.InitialContactTime.IsZero() {
+			t.Fatalf("progress = %s, initialContactTime = %s", customer.Progress, customer.InitialContactTime)
+		}
+		histories, err := repository.AssignmentHistory().FindByCustomerID(ctx, pooled[0].ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(histories) != 1 || histories[0].OperationType != operationClaim || histories[0].ToRelatedSalesID != salesA.ID {
+			t.Fatalf("histories = %+v", histories)
+		}
+	})
+
+	t.Run("已被认领的客户不能重复认领", func(t *testing.T) {
+		_, err := ClaimPublicPoolCustomer(ctx, pooled[0].ID.Hex(), salesB)
+		wantErrorCode(t, err, ErrCodeCustomerAlreadyClaimed)
+	})
+
+	t.Run("管理员不能认领", func(t *testing.T) {
+		_, err := ClaimPublicPoolCustomer(ctx, pooled[1].ID.Hex(), SystemActor())
+		wantErrorCode(t, err, "FORBIDDEN")
+	})
+
+	t.Run("每日额度", func(t *testing.T) {
+		if _, err := ClaimPublicPoolCustomer(ctx, pooled[1].ID.Hex(), salesA); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ClaimPublicPoolCustomer(ctx, pooled[2].ID.Hex(), salesA)
+		wantErrorCode(t, err, ErrCodeClaimQuotaExceeded)
+		if customer := findCustomer(t, ctx, pooled[2].ID); !customer.IsInPublicPool {
+			t.Fatal("额度不足时客户应留在公海")
+		}
+		counter, err := repository.ClaimCounters().FindOne(ctx, bson.M{"userId": salesA.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counter.Claimed != 2 {
+			t.Fatalf("claimed = %d, want 2", counter.Claimed)
+		}
+
+		quota, err := GetPublicPoolClaimQuota(ctx, salesA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if quota.DailyClaimed != 2 || quota.DailyLimit != 2 || quota.TotalOwned != 2 || quota.TotalLimit != 3 {
+			t.Fatalf("quota = %+v", quota)
+		}
+	})
+
+	t.Run("名下客户总数额度不足时回滚当天计数", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			insertCustomer(t, ctx, models.Customer{Name: "已有客户", RelatedSalesID: salesB.ID, Progress: models.CustomerProgressInitialContact})
+		}
+		_, err := ClaimPublicPoolCustomer(ctx, pooled[2].ID.Hex(), salesB)
+		wantErrorCode(t, err, ErrCodeClaimQuotaExceeded)
+		if count, _ := repository.ClaimCounters().Count(ctx, bson.M{"userId": salesB.ID}); count != 0 {
+			t.Fatalf("认领失败后不应保留计数: %d", count)
+		}
+	})
+
+	t.Run("原归属人冷却期", func(t *testing.T) {
+		owner := loginUser(insertSales(t, ctx, "销售丙"))
+		customer := insertCustomer(t, ctx, models.Customer{
+			Name:             "冷却客户",
+			Progress:         models.CustomerProgressInitialContact,
+			RelatedSalesID:   owner.ID,
+			RelatedSalesName: owner.Username,
+		})
+		if err := MoveCustomerToPublicPool(ctx, &customer, SystemActor(), "测试"); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ClaimPublicPoolCustomer(ctx, customer.ID.Hex(), owner)
+		wantErrorCode(t, err, ErrCodeClaimCooldown)
+
+		other := loginUser(insertSales(t, ctx, "销售丁"))
+		if _, err := ClaimPublicPoolCustomer(ctx, customer.ID.Hex(), other); err != nil {
+			t.Fatalf("其他销售认领: %v", err)
+		}
+	})
+}
+
+func TestAssignPublicPoolCustomerChargesClaimQuota(t *testing.T) {
+	ctx := useMemoryRepositories(t)
+	insertConfig(t, ctx, models.ConfigTypePublicPoolClaim, "claim", bson.M{
+		"quotas": bson.M{"FACTORY_SALES": bson.M{"dailyLimit": 1}},
+	})
+	salesA := loginUser(insertSales(t, ctx, "销售甲"))
+	salesB := loginUser(insertSales(t, ctx, "销售乙"))
+	first := insertCustomer(t, ctx, models.Customer{Name: "公海客户一", Progress: models.CustomerProgressPublicPool, IsInPublicPool: true})
+	second := insertCustomer(t, ctx, models.Customer{Name: "公海客户二", Progress: models.CustomerProgressPublicPool, IsInPublicPool: true})
+
+	// 把公海客户分配给他人同样计入操作人的认领额度
+	if err, _, _ := AssignCustomer(ctx, first.ID.Hex(), AssignRequest{SalesId: salesB.ID}, salesA); err != nil {
+		t.Fatal(err)
+	}
+	if customer := findCustomer(t, ctx, first.ID); customer.IsInPublicPool || customer.RelatedSalesID != salesB.ID {
+		t.Fatalf("customer = %+v", customer)
+	}
+	err, status, _ := AssignCustomer(ctx, second.ID.Hex(), AssignRequest{SalesId: salesB.ID}, salesA)
+	wantErrorCode(t, err, ErrCodeClaimQuotaExceeded)
+	if status != http.StatusForbidden {
+		t.Fatalf("status = %d", status)
+	}
+	if !findCustomer(t, ctx, second.ID).IsInPublicPool {
+		t.Fatal("额度不足时客户应留在公海")
+	}
+	// 额度查询与额度校验使用同一计数
+	quota, err := GetPublicPoolClaimQuota(ctx, salesA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quota.DailyClaimed != 1 {
+		t.Fatalf("dailyClaimed = %d, want 1", quota.DailyClaimed)
+	}
+
+	// 管理员分配不受认领额度限制
+	if err, _, _ := AssignCustomer(ctx, second.ID.Hex(), AssignRequest{SalesId: salesB.ID}, SystemActor()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,465 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// recycleBatchSize 每批评估的客户数，跟进和项目记录按批查询
+const recycleBatchSize = 200
+
+// recycleReportTimeout 保存回收报告的最长时间，任务超时后仍会保存已处理的部分
+const recycleReportTimeout = 30 * time.Second
+
+// recycleReport 回收报告：报告文档只记录数量，客户明细逐批写入明细集合
+type recycleReport struct {
+	run     *models.PublicPoolRecycleRun
+	pending []models.PublicPoolRecycleItem
+}
+
+// add 记录一个客户明细并累计对应类型的数量
+func (r *recycleReport) add(kind string, item models.PublicPoolRecycleItem) {
+	item.RunID = r.run.ID
+	item.Kind = kind
+	switch kind {
+	case models.RecycleItemKindRecycled:
+		r.run.RecycledCount++
+	case models.RecycleItemKindWarned:
+		r.run.WarnedCount++
+	case models.RecycleItemKindFailed:
+		r.run.FailedCount++
+	}
+	r.pending = append(r.pending, item)
+}
+
+// flush 写入尚未保存的客户明细
+func (r *recycleReport) flush(ctx context.Context) error {
+	if len(r.pending) == 0 {
+		return nil
+	}
+	if _, err := repository.RecycleItems().InsertMany(ctx, r.pending); err != nil {
+		return fmt.Errorf("保存回收客户明细失败: %w", err)
+	}
+	r.pending = r.pending[:0]
+	return nil
+}
+
+// LoadPublicPoolRecycleConfig 读取已启用的公海回收配置，未配置或没有任何有效规则时返回 nil
+func LoadPublicPoolRecycleConfig(ctx context.Context) (*models.PublicPoolRecycleConfig, error) {
+	configs, err := repository.SystemConfigs().FindEnabledByType(ctx, models.ConfigTypePublicPoolRecycle)
+	if err != nil {
+		return nil, fmt.Errorf("查询公海回收配置失败: %w", err)
+	}
+	if len(configs) == 0 {
+		return nil, nil
+	}
+
+	// configValue 经 JSON 写入后是任意文档类型，统一通过 BSON 往返转换
+	data, err := bson.Marshal(configs[0].ConfigValue)
+	if err != nil {
+		return nil, fmt.Errorf("公海回收配置格式错误: %w", err)
+	}
+	config := &models.PublicPoolRecycleConfig{}
+	if err := bson.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("公海回收配置格式错误: %w", err)
+	}
+	if config.WarningDays < 0 {
+		return nil, fmt.Errorf("公海回收配置格式错误: warningDays 不能为负数")
+	}
+
+	rules := make([]models.PublicPoolRecycleRule, 0, len(config.Rules))
+	seen := make(map[string]bool, len(config.Rules))
+	for _, rule := range config.Rules {
+		if rule.NoFollowUpDays < 0 || rule.NoProjectProgressDays < 0 {
+			return nil, fmt.Errorf("公海回收配置格式错误: 重要程度「%s」的天数不能为负数", rule.Importance)
+		}
+		if seen[rule.Importance] {
+			return nil, fmt.Errorf("公海回收配置格式错误: 重要程度「%s」配置了多条规则", rule.Importance)
+		}
+		seen[rule.Importance] = true
+		if rule.NoFollowUpDays == 0 && rule.NoProjectProgressDays == 0 {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	config.Rules = rules
+	return config, nil
+}
+
+// recycleRuleFor 客户适用的回收规则：优先匹配重要程度，其次使用默认规则（importance 为空）
+func recycleRuleFor(config *models.PublicPoolRecycleConfig, importance string) *models.PublicPoolRecycleRule {
+	var fallback *models.PublicPoolRecycleRule
+	for i := range config.Rules {
+		switch config.Rules[i].Importance {
+		case importance:
+			return &config.Rules[i]
+		case "":
+			fallback = &config.Rules[i]
+		}
+	}
+	return fallback
+}
+
+// recycleActivity 客户在当前归属人名下的最近活动时间
+type recycleActivity struct {
+	lastFollowUp time.Time
+	lastProgress time.Time
+}
+
+// laterTime 返回两个时间中较晚的一个
+func laterTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// loadRecycleActivity 批量查询客户最近的跟进和项目进展时间
+// 两者都不早于客户最近一次分配、认领或交接的时间，之前的记录属于上一任归属人，不计入当前归属人
+func loadRecycleActivity(ctx context.Context, customers []models.Customer) (map[string]*recycleActivity, error) {
+	activity := make(map[string]*recycleActivity, len(customers))
+	ids := make([]string, 0, len(customers))
+	objIDs := make([]primitive.ObjectID, 0, len(customers))
+	for _, customer := range customers {
+		baseline := laterTime(customer.CreatedAt, customer.InitialContactTime)
+		activity[customer.ID.Hex()] = &recycleActivity{lastFollowUp: baseline, lastProgress: baseline}
+		ids = append(ids, customer.ID.Hex())
+		objIDs = append(objIDs, customer.ID)
+	}
+	timeOnly := repository.NewFindOptions().SetProjection(bson.M{"customerId": 1, "projectId": 1, "createdAt": 1})
+
+	histories, err := repository.AssignmentHistory().Find(ctx, bson.M{"customerId": bson.M{"$in": ids}}, timeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("查询客户分配历史失败: %w", err)
+	}
+	for _, history := range histories {
+		if a := activity[history.CustomerID]; a != nil {
+			a.lastFollowUp = laterTime(a.lastFollowUp, history.CreatedAt)
+			a.lastProgress = laterTime(a.lastProgress, history.CreatedAt)
+		}
+	}
+
+	followUps, err := repository.FollowUps().Find(ctx, bson.M{"customerId": bson.M{"$in": ids}}, timeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("查询客户跟进记录失败: %w", err)
+	}
+	for _, record := range followUps {
+		if a := activity[record.CustomerId]; a != nil {
+			a.lastFollowUp = laterTime(a.lastFollowUp, record.CreatedAt)
+		}
+	}
+
+	projects, err := repository.Projects().Find(ctx, bson.M{"customerId": bson.M{"$in": objIDs}},
+		repository.NewFindOptions().SetProjection(bson.M{"_id": 1, "customerId": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("查询客户项目失败: %w", err)
+	}
+	if len(projects) == 0 {
+		return activity, nil
+	}
+	projectCustomer := make(map[string]string, len(projects))
+	projectIDs := make([]string, 0, len(projects))
+	for _, project := range projects {
+		projectCustomer[project.ID.Hex()] = project.CustomerID.Hex()
+		projectIDs = append(projectIDs, project.ID.Hex())
+	}
+
+	projectFollowUps, err := repository.ProjectFollowUps().Find(ctx, bson.M{"projectId": bson.M{"$in": projectIDs}}, timeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("查询项目跟进记录失败: %w", err)
+	}
+	for _, record := range projectFollowUps {
+		if a := activity[projectCustomer[record.ProjectID]]; a != nil {
+			a.lastFollowUp = laterTime(a.lastFollowUp, record.CreatedAt)
+		}
+	}
+
+	progress, err := repository.ProjectProgress().Find(ctx, bson.M{"projectId": bson.M{"$in": projectIDs}}, timeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("查询项目进展历史失败: %w", err)
+	}
+	for _, record := range progress {
+		if a := activity[projectCustomer[record.ProjectID]]; a != nil {
+			a.lastProgress = laterTime(a.lastProgress, record.CreatedAt)
+		}
+	}
+	return activity, nil
+}
+
+// recycleDueAt 按规则计算客户满足回收条件的时间（各条件超期时间中最晚的一个）和回收原因
+func recycleDueAt(rule *models.PublicPoolRecycleRule, activity *recycleActivity) (time.Time, string) {
+	var dueAt time.Time
+	var reasons []string
+	if rule.NoFollowUpDays > 0 {
+		dueAt = laterTime(dueAt, activity.lastFollowUp.AddDate(0, 0, rule.NoFollowUpDays))
+		reasons = append(reasons, fmt.Sprintf("%d天无跟进", rule.NoFollowUpDays))
+	}
+	if rule.NoProjectProgressDays > 0 {
+		dueAt = laterTime(dueAt, activity.lastProgress.AddDate(0, 0, rule.NoProjectProgressDays))
+		reasons = append(reasons, fmt.Sprintf("%d天无项目进展", rule.NoProjectProgressDays))
+	}
+	return dueAt, strings.Join(reasons, "且")
+}
+
+// newRecycleItem 生成回收报告中的客户条目
+func newRecycleItem(customer *models.Customer, activity *recycleActivity, dueAt time.Time, reason string) models.PublicPoolRecycleItem {
+	return models.PublicPoolRecycleItem{
+		CustomerID:            customer.ID.Hex(),
+		CustomerName:          customer.Name,
+		Importance:            customer.Importance,
+		RelatedSalesID:        customer.RelatedSalesID,
+		RelatedSalesName:      customer.RelatedSalesName,
+		RelatedAgentID:        customer.RelatedAgentID,
+		RelatedAgentName:      customer.RelatedAgentName,
+		LastFollowUpAt:        activity.lastFollowUp,
+		LastProjectProgressAt: activity.lastProgress,
+		DueAt:                 dueAt,
+		Reason:                reason,
+	}
+}
+
+// notifyOwners 给客户的关联销售和关联代理商发送通知
+func notifyOwners(ctx context.Context, item models.PublicPoolRecycleItem, notification models.Notification) (bool, error) {
+	sent := false
+	for _, recipientID := range []string{item.RelatedSalesID, item.RelatedAgentID} {
+		if recipientID == "" {
+			continue
+		}
+		notification.RecipientID = recipientID
+		ok, err := Notify(ctx, notification)
+		if err != nil {
+			return sent, err
+		}
+		sent = sent || ok
+	}
+	return sent, nil
+}
+
+// recycleCustomer 回收一个已超期的客户：回收前重新读取客户和活动时间，扫描后有新跟进或已被转移的客户不再回收
+func recycleCustomer(ctx context.Context, config *models.PublicPoolRecycleConfig, customerID primitive.ObjectID, now time.Time) (*models.PublicPoolRecycleItem, error) {
+	customer, err := repository.Customers().FindByID(ctx, customerID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if customer.IsInPublicPool || !isRecyclableProgress(customer.Progress) {
+		return nil, nil
+	}
+	rule := recycleRuleFor(config, customer.Importance)
+	if rule == nil {
+		return nil, nil
+	}
+	activities, err := loadRecycleActivity(ctx, []models.Customer{*customer})
+	if err != nil {
+		return nil, err
+	}
+	activity := activities[customer.ID.Hex()]
+	dueAt, reason := recycleDueAt(rule, activity)
+	if now.Before(dueAt) {
+		return nil, nil
+	}
+
+	item := newRecycleItem(customer, activity, dueAt, reason)
+	if err := MoveCustomerToPublicPool(ctx, customer, SystemActor(), "自动回收："+reason); err != nil {
+		// 读取后客户被重新分配、移入公海或变更进展，本次不回收
+		if apiErr, ok := err.(*utils.ApiError); ok && apiErr.ErrorCode == ErrCodeProgressConflict {
+			return nil, nil
+		}
+		return &item, err
+	}
+	return &item, nil
+}
+
+// isRecyclableProgress 只回收初步接触和正常推进的客户，禁用的同名客户不参与
+func isRecyclableProgress(progress string) bool {
+	return progress == models.CustomerProgressInitialContact || progress == models.CustomerProgressNormal
+}
+
+// recycleBatch 评估一批客户：超期的移入公海，即将超期的提醒归属人
+func recycleBatch(ctx context.Context, config *models.PublicPoolRecycleConfig, customers []models.Customer, now time.Time, report *recycleReport) error {
+	activities, err := loadRecycleActivity(ctx, customers)
+	if err != nil {
+		return err
+	}
+
+	for i := range customers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		customer := &customers[i]
+		rule := recycleRuleFor(config, customer.Importance)
+		if rule == nil {
+			continue
+		}
+		activity := activities[customer.ID.Hex()]
+		dueAt, reason := recycleDueAt(rule, activity)
+
+		if !now.Before(dueAt) {
+			item, err := recycleCustomer(ctx, config, customer.ID, now)
+			if err != nil {
+				failed := newRecycleItem(customer, activity, dueAt, reason)
+				if item != nil {
+					failed = *item
+				}
+				failed.Error = err.Error()
+				report.add(models.RecycleItemKindFailed, failed)
+				utils.LogError(err, map[string]interface{}{"customerId": failed.CustomerID, "customer": failed.CustomerName}, "自动回收客户失败")
+				continue
+			}
+			if item == nil {
+				continue
+			}
+			report.add(models.RecycleItemKindRecycled, *item)
+			if _, err := notifyOwners(ctx, *item, models.Notification{
+				Type:         models.NotificationTypeRecycled,
+				Title:        "客户已回收至公海",
+				Content:      fmt.Sprintf("客户「%s」因%s，已自动回收至公海", item.CustomerName, item.Reason),
+				CustomerID:   item.CustomerID,
+				CustomerName: item.CustomerName,
+			}); err != nil {
+				utils.LogError(err, map[string]interface{}{"customerId": item.CustomerID}, "发送客户回收通知失败")
+			}
+			continue
+		}
+
+		if config.WarningDays <= 0 || now.Before(dueAt.AddDate(0, 0, -config.WarningDays)) {
+			continue
+		}
+		item := newRecycleItem(customer, activity, dueAt, reason)
+		// 以回收时间去重：期间有新跟进时回收时间后移，会重新提醒
+		sent, err := notifyOwners(ctx, item, models.Notification{
+			Type:         models.NotificationTypeRecycleWarning,
+			Title:        "客户即将回收至公海",
+			Content:      fmt.Sprintf("客户「%s」将于 %s 自动回收至公海（回收规则：%s），请及时跟进", item.CustomerName, dueAt.Local().Format("2006-01-02 15:04"), reason),
+			CustomerID:   item.CustomerID,
+			CustomerName: item.CustomerName,
+			DedupeKey:    fmt.Sprintf("recycle-warning:%s:%d", item.CustomerID, dueAt.Unix()),
+		})
+		if err != nil {
+			return err
+		}
+		if sent {
+			report.add(models.RecycleItemKindWarned, item)
+		}
+	}
+	return nil
+}
+
+// RecycleStaleCustomers 按公海回收规则扫描非公海客户，回收超期客户并提前提醒归属人，执行报告保存到数据库
+// 报告保存失败时返回错误，任务按执行失败处理
+func RecycleStaleCustomers(ctx context.Context) (*models.PublicPoolRecycleRun, error) {
+	now := time.Now()
+	run := &models.PublicPoolRecycleRun{StartedAt: now, Status: models.RecycleRunStatusRunning}
+	// 先创建报告，客户明细按报告ID关联
+	id, err := repository.RecycleRuns().Insert(ctx, run)
+	if err != nil {
+		return run, fmt.Errorf("创建公海回收报告失败: %w", err)
+	}
+	run.ID = id
+	report := &recycleReport{run: run}
+
+	runErr := func() error {
+		config, err := LoadPublicPoolRecycleConfig(ctx)
+		if err != nil {
+			return err
+		}
+		if config == nil {
+			run.Status = models.RecycleRunStatusSkipped
+			return nil
+		}
+		run.Config = *config
+
+		customers, err := repository.Customers().Find(ctx, bson.M{
+			"isInPublicPool": false,
+			"progress":       bson.M{"$in": []string{models.CustomerProgressInitialContact, models.CustomerProgressNormal}},
+		}, repository.NewFindOptions().SetSort("_id", 1).SetProjection(bson.M{
+			"_id": 1, "name": 1, "importance": 1, "progress": 1, "createdAt": 1, "initialContactTime": 1,
+			"relatedSalesId": 1, "relatedSalesName": 1, "relatedAgentId": 1, "relatedAgentName": 1,
+		}))
+		if err != nil {
+			return fmt.Errorf("查询客户失败: %w", err)
+		}
+		run.Scanned = len(customers)
+
+		for start := 0; start < len(customers); start += recycleBatchSize {
+			end := min(start+recycleBatchSize, len(customers))
+			if err := recycleBatch(ctx, config, customers[start:end], now, report); err != nil {
+				return err
+			}
+			if err := report.flush(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+
+	// 任务因超时中止时也要保存已处理部分的报告
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recycleReportTimeout)
+	defer cancel()
+	if err := report.flush(saveCtx); err != nil {
+		utils.LogError(err, map[string]interface{}{"runId": run.ID.Hex()}, "保存公海回收客户明细失败")
+		if runErr == nil {
+			runErr = err
+		}
+	}
+
+	run.FinishedAt = time.Now()
+	switch {
+	case runErr != nil:
+		run.Status = models.RecycleRunStatusFailed
+		run.Error = runErr.Error()
+	case run.Status == models.RecycleRunStatusSkipped:
+	case run.FailedCount > 0:
+		run.Status = models.RecycleRunStatusPartial
+	default:
+		run.Status = models.RecycleRunStatusSuccess
+	}
+
+	if _, err := repository.RecycleRuns().UpdateByID(saveCtx, run.ID, bson.M{"$set": bson.M{
+		"finishedAt":    run.FinishedAt,
+		"status":        run.Status,
+		"config":        run.Config,
+		"scanned":       run.Scanned,
+		"recycledCount": run.RecycledCount,
+		"warnedCount":   run.WarnedCount,
+		"failedCount":   run.FailedCount,
+		"error":         run.Error,
+	}}); err != nil {
+		utils.LogError(err, map[string]interface{}{"runId": run.ID.Hex(), "recycled": run.RecycledCount}, "保存公海回收报告失败")
+		if runErr == nil {
+			runErr = fmt.Errorf("保存公海回收报告失败: %w", err)
+		}
+	}
+	return run, runErr
+}
+
+// RunPublicPoolRecycle 定时执行公海自动回收，返回扫描、回收、提醒和失败的客户数，详情见回收报告
+func RunPublicPoolRecycle(ctx context.Context) (map[string]int64, error) {
+	run, err := RecycleStaleCustomers(ctx)
+	if run.Status == models.RecycleRunStatusSkipped {
+		utils.Logger.Info().Msg("未配置公海回收规则，跳过自动回收")
+	}
+	counts := map[string]int64{
+		"scanned":  int64(run.Scanned),
+		"recycled": int64(run.RecycledCount),
+		"warned":   int64(run.WarnedCount),
+		"failed":   int64(run.FailedCount),
+	}
+	if err != nil {
+		return counts, fmt.Errorf("公海自动回收失败: %w", err)
+	}
+	return counts, nil
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRecycleStaleCustomers(t *testing.T) {
+	ctx := useMemoryRepositories(t)
+	insertConfig(t, ctx, models.ConfigTypePublicPoolRecycle, "recycle", bson.M{
+		"rules":       bson.A{bson.M{"importance": "", "noFollowUpDays": 30}},
+		"warningDays": 5,
+	})
+	sales := insertSales(t, ctx, "销售甲")
+	daysAgo := func(days int) time.Time { return time.Now().AddDate(0, 0, -days) }
+	owned := func(name string, createdAt time.Time, progress string) models.Customer {
+		return insertCustomer(t, ctx, models.Customer{
+			Name:             name,
+			Progress:         progress,
+			RelatedSalesID:   sales.ID.Hex(),
+			RelatedSalesName: sales.Username,
+			ContactPerson:    "张三",
+			CreatedAt:        createdAt,
+		})
+	}
+	stale := owned("超期客户", daysAgo(40), models.CustomerProgressInitialContact)
+	expiring := owned("即将超期客户", daysAgo(27), models.CustomerProgressNormal)
+	followed := owned("近期跟进客户", daysAgo(40), models.CustomerProgressInitialContact)
+	disabled := owned("禁用客户", daysAgo(40), models.CustomerProgressDisabled)
+	if _, err := repository.FollowUps().Insert(ctx, &models.FollowUpRecord{CustomerId: followed.ID.Hex(), CreatedAt: daysAgo(1)}); err != nil {
+		t.Fatal(err)
+	}
+
+	run, err := RecycleStaleCustomers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != models.RecycleRunStatusSuccess || run.Scanned != 3 || run.RecycledCount != 1 || run.WarnedCount != 1 || run.FailedCount != 0 {
+		t.Fatalf("run = %+v", run)
+	}
+
+	stored, err := repository.RecycleRuns().FindByID(ctx, run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != run.Status || stored.RecycledCount != 1 || stored.WarnedCount != 1 || stored.FinishedAt.IsZero() {
+		t.Fatalf("保存的报告 = %+v", stored)
+	}
+	items, err := repository.RecycleItems().Find(ctx, bson.M{"runId": run.ID}, repository.NewFindOptions().SetSort("dueAt", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 ||
+		items[0].Kind != models.RecycleItemKindRecycled || items[0].CustomerID != stale.ID.Hex() ||
+		items[1].Kind != models.RecycleItemKindWarned || items[1].CustomerID != expiring.ID.Hex() {
+		t.Fatalf("items = %+v", items)
+	}
+
+	recycled := findCustomer(t, ctx, stale.ID)
+	if !recycled.IsInPublicPool || recycled.Progress != models.CustomerProgressPublicPool || recycled.RelatedSalesID != "" {
+		t.Fatalf("回收后的客户 = %+v", recycled)
+	}
+	if recycled.PoolSnapshot == nil || recycled.PoolSnapshot.RelatedSalesID != sales.ID.Hex() || recycled.PoolSnapshot.ContactPerson != "张三" {
+		t.Fatalf("poolSnapshot = %+v", recycled.PoolSnapshot)
+	}
+	for _, customer := range []models.Customer{expiring, followed, disabled} {
+		if findCustomer(t, ctx, customer.ID).IsInPublicPool {
+			t.Fatalf("%s 不应被回收", customer.Name)
+		}
+	}
+	for _, notificationType := range []string{models.NotificationTypeRecycled, models.NotificationTypeRecycleWarning} {
+		if count, _ := repository.Notifications().Count(ctx, bson.M{"recipientId": sales.ID.Hex(), "type": notificationType}); count != 1 {
+			t.Fatalf("%s 通知数 = %d, want 1", notificationType, count)
+		}
+	}
+
+	// 回收时间未变化时不重复提醒
+	run, err = RecycleStaleCustomers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Scanned != 2 || run.RecycledCount != 0 || run.WarnedCount != 0 {
+		t.Fatalf("再次执行 run = %+v", run)
+	}
+	if count, _ := repository.RecycleItems().Count(ctx, bson.M{"runId": run.ID}); count != 0 {
+		t.Fatalf("再次执行的明细数 = %d", count)
+	}
+}
+
+func TestRecycleStaleCustomersWithoutConfig(t *testing.T) {
+	ctx := useMemoryRepositories(t)
+	insertCustomer(t, ctx, models.Customer{Name: "超期客户", Progress: models.CustomerProgressInitialContact, CreatedAt: time.Now().AddDate(-1, 0, 0)})
+
+	counts, err := RunPublicPoolRecycle(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts["scanned"] != 0 || counts["recycled"] != 0 {
+		t.Fatalf("counts = %v", counts)
+	}
+	runs, err := repository.RecycleRuns().Find(ctx, bson.M{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Status != models.RecycleRunStatusSkipped {
+		t.Fatalf("runs = %+v", runs)
+	}
+}
+
+func TestMoveCustomerToPublicPoolRechecksOwner(t *testing.T) {
+	ctx := useMemoryRepositories(t)
+	salesA := insertSales(t, ctx, "销售甲")
+	salesB := insertSales(t, ctx, "销售乙")
+	customer := insertCustomer(t, ctx, models.Customer{
+		Name:           "某某科技",
+		Progress:       models.CustomerProgressInitialContact,
+		RelatedSalesID: salesA.ID.Hex(),
+	})
+
+	// 读取客户后被重新分配，按读取时的归属移入公海失败
+	if _, err := repository.Customers().UpdateByID(ctx, customer.ID, bson.M{"$set": bson.M{"relatedSalesId": salesB.ID.Hex()}}); err != nil {
+		t.Fatal(err)
+	}
+	err := MoveCustomerToPublicPool(ctx, &customer, SystemActor(), "自动回收")
+	wantErrorCode(t, err, ErrCodeProgressConflict)
+	if stored := findCustomer(t, ctx, customer.ID); stored.IsInPublicPool || stored.RelatedSalesID != salesB.ID.Hex() {
+		t.Fatalf("客户 = %+v", stored)
+	}
+	if count, _ := repository.AssignmentHistory().Count(ctx, bson.M{"customerId": customer.ID.Hex()}); count != 0 {
+		t.Fatalf("分配历史数 = %d", count)
+	}
+}
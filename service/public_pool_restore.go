@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrCodeNoPreviousOwner 公海客户没有可恢复的原归属
+const ErrCodeNoPreviousOwner = "NO_PREVIOUS_OWNER"
+
+// RestoreCustomerFromPublicPool 将公海客户恢复给移入公海前的销售和代理商，
+// 同时恢复联系人和移入公海时被隐藏的项目；以客户仍在公海为条件在同一事务中写入
+func RestoreCustomerFromPublicPool(ctx context.Context, customerID string, user *utils.LoginUser) (*models.Customer, error) {
+	objID, err := primitive.ObjectIDFromHex(customerID)
+	if err != nil {
+		return nil, utils.CreateBadRequestError("无效的客户ID格式")
+	}
+	customer, err := repository.Customers().FindByID(ctx, objID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, utils.CreateNotFoundError("客户")
+		}
+		return nil, err
+	}
+	if !customer.IsInPublicPool {
+		return nil, utils.NewApiError("该客户已不在公海中", http.StatusConflict, ErrCodeCustomerAlreadyClaimed)
+	}
+	snapshot := customer.PoolSnapshot
+	if snapshot == nil || (snapshot.RelatedSalesID == "" && snapshot.RelatedAgentID == "") {
+		return nil, utils.NewApiError("该客户没有可恢复的原归属信息，请使用分配功能", http.StatusConflict, ErrCodeNoPreviousOwner)
+	}
+
+	// 原归属人需仍然有效，名称以当前信息为准
+	fields := bson.M{
+		"isInPublicPool":   false,
+		"relatedSalesId":   nil,
+		"relatedSalesName": nil,
+		"relatedAgentId":   nil,
+		"relatedAgentName": nil,
+		"contactPerson":    snapshot.ContactPerson,
+		"contactPhone":     snapshot.ContactPhone,
+		"poolSnapshot":     nil,
+	}
+	history := models.CustomerAssignmentHistory{
+		CustomerID:    customer.ID.Hex(),
+		CustomerName:  customer.Name,
+		OperatorID:    user.ID,
+		OperatorName:  user.Username,
+		OperationType: models.RestoreFromPublicPoolOperationType,
+	}
+	if snapshot.RelatedSalesID != "" {
+		salesName, err := activeSalesName(ctx, snapshot.RelatedSalesID)
+		if err != nil {
+			return nil, err
+		}
+		fields["relatedSalesId"], fields["relatedSalesName"] = snapshot.RelatedSalesID, salesName
+		history.ToRelatedSalesID, history.ToRelatedSalesName = snapshot.RelatedSalesID, salesName
+	}
+	if snapshot.RelatedAgentID != "" {
+		agentName, err := activeAgentName(ctx, snapshot.RelatedAgentID)
+		if err != nil {
+			return nil, err
+		}
+		fields["relatedAgentId"], fields["relatedAgentName"] = snapshot.RelatedAgentID, agentName
+		history.ToRelatedAgentID, history.ToRelatedAgentName = snapshot.RelatedAgentID, agentName
+	}
+
+	// 只恢复移入公海时被隐藏且仍然存在的项目，有项目时为正常推进
+	restoreProjects := bson.M{"_id": bson.M{"$in": snapshot.HiddenProjectIDs}, "customerId": customer.ID, "webHidden": true}
+	projectCount := int64(0)
+	if len(snapshot.HiddenProjectIDs) > 0 {
+		if projectCount, err = repository.Projects().Count(ctx, restoreProjects); err != nil {
+			return nil, fmt.Errorf("查询客户项目失败: %w", err)
+		}
+	}
+	progress := models.CustomerProgressNormal
+	if projectCount == 0 {
+		progress = models.CustomerProgressInitialContact
+		fields["initialContactTime"] = time.Now()
+	}
+
+	err = repository.WithTransaction(ctx, func(txCtx context.Context) error {
+		// 事务遇到临时错误会整体重试，每次都从查询时的客户状态开始
+		current := *customer
+		if _, err := ChangeCustomerProgress(txCtx, ProgressChange{
+			Customer:  &current,
+			To:        progress,
+			Operator:  user,
+			Remark:    "恢复原归属",
+			Fields:    fields,
+			Condition: bson.M{"isInPublicPool": true},
+		}); err != nil {
+			return err
+		}
+		if projectCount > 0 {
+			if _, err := repository.Projects().UpdateMany(txCtx, restoreProjects, bson.M{"$set": bson.M{"webHidden": false}}); err != nil {
+				return fmt.Errorf("恢复客户项目失败: %w", err)
+			}
+		}
+		if progress == models.CustomerProgressNormal {
+			if err := UpdateCustomerProgressByName(txCtx, customer.Name, models.CustomerProgressDisabled, user, "同名客户已正常推进"); err != nil {
+				return fmt.Errorf("客户为正常推进状态，修改其他同名客户信息报错: %w", err)
+			}
+		}
+		return AddAssignmentHistory(txCtx, history)
+	})
+	if err != nil {
+		if apiErr, ok := err.(*utils.ApiError); ok && apiErr.ErrorCode == ErrCodeProgressConflict {
+			return nil, utils.NewApiError("该客户已被其他人认领", http.StatusConflict, ErrCodeCustomerAlreadyClaimed)
+		}
+		return nil, err
+	}
+
+	utils.LogInfo(map[string]interface{}{
+		"customerId": customerID,
+		"customer":   customer.Name,
+		"salesId":    snapshot.RelatedSalesID,
+		"agentId":    snapshot.RelatedAgentID,
+		"projects":   projectCount,
+		"operator":   user.Username,
+	}, "公海客户已恢复原归属")
+
+	return repository.Customers().FindByID(ctx, objID)
+}
+
+// activeSalesName 查询仍在职（已审核）的销售名称
+func activeSalesName(ctx context.Context, salesID string) (string, error) {
+	objID, err := primitive.ObjectIDFromHex(salesID)
+	if err != nil {
+		return "", utils.NewApiError("原销售ID无效，请使用分配功能", http.StatusConflict, ErrCodeNoPreviousOwner)
+	}
+	sales, err := repository.Users().FindByID(ctx, objID)
+	if err != nil && err != repository.ErrNotFound {
+		return "", fmt.Errorf("查询原销售失败: %w", err)
+	}
+	if sales == nil || sales.Role != models.UserRoleFACTORY_SALES || sales.Status != models.UserStatusAPPROVED {
+		return "", utils.NewApiError("原销售已不存在或已停用，请使用分配功能", http.StatusConflict, ErrCodeNoPreviousOwner)
+	}
+	return sales.Username, nil
+}
+
+// activeAgentName 查询仍有效（已审核）的代理商名称
+func activeAgentName(ctx context.Context, agentID string) (string, error) {
+	objID, err := primitive.ObjectIDFromHex(agentID)
+	if err != nil {
+		return "", utils.NewApiError("原代理商ID无效，请使用分配功能", http.StatusConflict, ErrCodeNoPreviousOwner)
+	}
+	agent, err := repository.Agents().FindByID(ctx, objID)
+	if err != nil && err != repository.ErrNotFound {
+		return "", fmt.Errorf("查询原代理商失败: %w", err)
+	}
+	if agent == nil || agent.Status != models.UserStatusAPPROVED {
+		return "", utils.NewApiError("原代理商已不存在或已停用，请使用分配功能", http.StatusConflict, ErrCodeNoPreviousOwner)
+	}
+	return agent.CompanyName, nil
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRestoreCustomerFromPublicPool(t *testing.T) {
+	ctx := useMemoryRepositories(t)
+	sales := insertSales(t, ctx, "销售甲")
+	customer := insertCustomer(t, ctx, models.Customer{
+		Name:             "某某科技",
+		Progress:         models.CustomerProgressNormal,
+		RelatedSalesID:   sales.ID.Hex(),
+		RelatedSalesName: sales.Username,
+		ContactPerson:    "张三",
+		ContactPhone:     "13800138000",
+	})
+	visible := insertProject(t, ctx, customer, false)
+	// 移入公海前已隐藏的项目恢复后仍然隐藏
+	hidden := insertProject(t, ctx, customer, true)
+
+	if err := MoveCustomerToPublicPool(ctx, &customer, SystemActor(), "测试"); err != nil {
+		t.Fatal(err)
+	}
+	pooled := findCustomer(t, ctx, customer.ID)
+	if !pooled.IsInPublicPool || pooled.ContactPerson != "" || pooled.RelatedSalesID != "" {
+		t.Fatalf("移入公海后 = %+v", pooled)
+	}
+	if pooled.PoolSnapshot == nil || len(pooled.PoolSnapshot.HiddenProjectIDs) != 1 || pooled.PoolSnapshot.HiddenProjectIDs[0] != visible {
+		t.Fatalf("poolSnapshot = %+v", pooled.PoolSnapshot)
+	}
+
+	restored, err := RestoreCustomerFromPublicPool(ctx, customer.ID.Hex(), SystemActor())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.IsInPublicPool || restored.RelatedSalesID != sales.ID.Hex() || restored.RelatedSalesName != sales.Username {
+		t.Fatalf("恢复后归属 = %+v", restored)
+	}
+	if restored.ContactPerson != "张三" || restored.ContactPhone != "13800138000" || restored.PoolSnapshot != nil {
+		t.Fatalf("恢复后联系人 = %+v", restored)
+	}
+	if restored.Progress != models.CustomerProgressNormal {
+		t.Fatalf("有项目时应为正常推进: %s", restored.Progress)
+	}
+	for id, wantHidden := range map[primitive.ObjectID]bool{visible: false, hidden: true} {
+		project, err := repository.Projects().FindByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if project.WebHidden != wantHidden {
+			t.Fatalf("项目 %s webHidden = %v, want %v", id.Hex(), project.WebHidden, wantHidden)
+		}
+	}
+	if count, _ := repository.AssignmentHistory().Count(ctx, bson.M{
+		"customerId":    customer.ID.Hex(),
+		"operationType": models.RestoreFromPublicPoolOperationType,
+	}); count != 1 {
+		t.Fatalf("恢复记录数 = %d", count)
+	}
+
+	_, err = RestoreCustomerFromPublicPool(ctx, customer.ID.Hex(), SystemActor())
+	wantErrorCode(t, err, ErrCodeCustomerAlreadyClaimed)
+}
+
+func TestRestoreCustomerFromPublicPoolWithoutOwner(t *testing.T) {
+	ctx := useMemoryRepositories(t)
+
+	t.Run("没有原归属", func(t *testing.T) {
+		customer := insertCustomer(t, ctx, models.Customer{Name: "公海客户", Progress: models.CustomerProgressPublicPool, IsInPublicPool: true})
+		_, err := RestoreCustomerFromPublicPool(ctx, customer.ID.Hex(), SystemActor())
+		wantErrorCode(t, err, ErrCodeNoPreviousOwner)
+	})
+
+	t.Run("原销售已停用", func(t *testing.T) {
+		sales := insertSales(t, ctx, "销售乙")
+		customer := insertCustomer(t, ctx, models.Customer{
+			Name:           "某某电子",
+			Progress:       models.CustomerProgressInitialContact,
+			RelatedSalesID: sales.ID.Hex(),
+		})
+		if err := MoveCustomerToPublicPool(ctx, &customer, SystemActor(), "测试"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repository.Users().UpdateByID(ctx, sales.ID, bson.M{"$set": bson.M{"status": models.UserStatusREJECTED}}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := RestoreCustomerFromPublicPool(ctx, customer.ID.Hex(), SystemActor())
+		wantErrorCode(t, err, ErrCodeNoPreviousOwner)
+		if !findCustomer(t, ctx, customer.ID).IsInPublicPool {
+			t.Fatal("恢复失败时客户应留在公海")
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrInvalidRefreshToken 刷新令牌不存在、已过期或已被吊销
+	ErrInvalidRefreshToken = errors.New("刷新令牌无效或已过期，请重新登录")
+	// ErrSessionRevoked 账户令牌版本已变化（修改角色/密码、删除、审核拒绝等），已签发的令牌失效
+	ErrSessionRevoked = errors.New("登录状态已失效，请重新登录")
+)
+
+// SessionMeta 登录会话的客户端信息
+type SessionMeta struct {
+	ClientIP  string
+	UserAgent string
+}
+
+// sessionAccount 登录账户的公共信息
+type sessionAccount struct {
+	account      interface{} // models.User 或 models.Agent，用于生成访问令牌
+	id           primitive.ObjectID
+	role         models.UserRole
+	status       models.UserStatus
+	tokenVersion int64
+}
+
+// loadSessionAccount 按角色加载账户，角色为 AGENT 时对应代理商
+func loadSessionAccount(ctx context.Context, id primitive.ObjectID, role models.UserRole) (*sessionAccount, error) {
+	if role == models.UserRoleAGENT {
+		agent, err := repository.Agents().FindByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return &sessionAccount{
+			account:      *agent,
+			id:           agent.ID,
+			role:         models.UserRoleAGENT,
+			status:       agent.Status,
+			tokenVersion: agent.TokenVersion,
+		}, nil
+	}
+
+	user, err := repository.Users().FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &sessionAccount{
+		account:      *user,
+		id:           user.ID,
+		role:         user.Role,
+		status:       user.Status,
+		tokenVersion: user.TokenVersion,
+	}, nil
+}
+
+// IssueTokens 为登录成功的账户签发访问令牌和新的刷新令牌家族
+func IssueTokens(ctx context.Context, account interface{}, meta SessionMeta) (*models.TokenPair, error) {
+	var acc *sessionAccount
+	switch a := account.(type) {
+	case models.User:
+		acc = &sessionAccount{account: a, id: a.ID, role: a.Role, status: a.Status, tokenVersion: a.TokenVersion}
+	case models.Agent:
+		acc = &sessionAccount{account: a, id: a.ID, role: models.UserRoleAGENT, status: a.Status, tokenVersion: a.TokenVersion}
+	default:
+		return nil, errors.New("不支持的账户类型")
+	}
+	return issueTokens(ctx, acc, primitive.NewObjectID().Hex(), meta)
+}
+
+// issueTokens 签发访问令牌，并在指定家族中保存新的刷新令牌
+func issueTokens(ctx context.Context, acc *sessionAccount, familyID string, meta SessionMeta) (*models.TokenPair, error) {
+	accessToken, err := utils.GenerateToken(acc.account)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, tokenHash, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	record := models.RefreshToken{
+		TokenHash:    tokenHash,
+		FamilyID:     familyID,
+		AccountID:    acc.id.Hex(),
+		Role:         acc.role,
+		TokenVersion: acc.tokenVersion,
+		ClientIP:     meta.ClientIP,
+		UserAgent:    meta.UserAgent,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(utils.RefreshTokenTTL),
+	}
+	if _, err := repository.RefreshTokens().Insert(ctx, &record); err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(utils.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// RefreshTokens 使用刷新令牌换取新的令牌对，旧刷新令牌随即失效
+// 已使用过的刷新令牌再次出现说明令牌可能泄露，此时吊销整个家族
+func RefreshTokens(ctx context.Context, refreshToken string, meta SessionMeta) (*models.TokenPair, error) {
+	record, err := repository.RefreshTokens().FindByHash(ctx, utils.HashRefreshToken(refreshToken))
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if record.RevokedAt != nil || now.After(record.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if record.UsedAt != nil {
+		utils.Logger.Warn().
+			Str("accountId", record.AccountID).
+			Str("familyId", record.FamilyID).
+			Msg("检测到刷新令牌重复使用，吊销该登录会话")
+		if _, err := repository.RefreshTokens().RevokeFamily(ctx, record.FamilyID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// 并发刷新时只有一个请求能标记成功
+	marked, err := repository.RefreshTokens().MarkUsed(ctx, record.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !marked {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	accountID, err := primitive.ObjectIDFromHex(record.AccountID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	acc, err := loadSessionAccount(ctx, accountID, record.Role)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, ErrSessionRevoked
+		}
+		return nil, err
+	}
+	if acc.status != models.UserStatusAPPROVED || acc.tokenVersion != record.TokenVersion {
+		return nil, ErrSessionRevoked
+	}
+
+	return issueTokens(ctx, acc, record.FamilyID, meta)
+}
+
+// Logout 退出登录，吊销刷新令牌所在的会话；allDevices 为 true 时使该账户的全部会话失效
+func Logout(ctx context.Context, refreshToken string, allDevices bool) error {
+	record, err := repository.RefreshTokens().FindByHash(ctx, utils.HashRefreshToken(refreshToken))
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return ErrInvalidRefreshToken
+		}
+		return err
+	}
+
+	if allDevices {
+		return InvalidateSessions(ctx, record.AccountID, record.Role)
+	}
+	_, err = repository.RefreshTokens().RevokeFamily(ctx, record.FamilyID, time.Now())
+	return err
+}
+
+// InvalidateSessions 递增账户的令牌版本并吊销全部刷新令牌，已签发的访问令牌随之失效
+// 在修改角色/密码、删除账户、审核拒绝等场景调用
+func InvalidateSessions(ctx context.Context, accountID string, role models.UserRole) error {
+	id, err := primitive.ObjectIDFromHex(accountID)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{"$inc": bson.M{"tokenVersion": 1}}
+	if role == models.UserRoleAGENT {
+		_, err = repository.Agents().UpdateByID(ctx, id, update)
+	} else {
+		_, err = repository.Users().UpdateByID(ctx, id, update)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := repository.RefreshTokens().RevokeByAccount(ctx, accountID, time.Now()); err != nil {
+		return err
+	}
+
+	utils.Logger.Info().Str("accountId", accountID).Str("role", string(role)).Msg("账户登录会话已全部失效")
+	return nil
+}
+
+// ValidateAccessToken 校验访问令牌对应的账户仍然有效，且令牌版本与账户一致
+func ValidateAccessToken(ctx context.Context, accountID string, role models.UserRole, tokenVersion int64) error {
+	id, err := primitive.ObjectIDFromHex(accountID)
+	if err != nil {
+		return ErrSessionRevoked
+	}
+
+	acc, err := loadSessionAccount(ctx, id, role)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return ErrSessionRevoked
+		}
+		return err
+	}
+	if acc.status != models.UserStatusAPPROVED || acc.tokenVersion != tokenVersion || acc.role != role {
+		return ErrSessionRevoked
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/config"
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// useTestAuth 初始化签发令牌所需的密钥和有效期
+func useTestAuth() {
+	utils.InitAuth(config.AuthConfig{
+		JWTKey:          "test-secret",
+		AccessTokenTTL:  config.Duration(time.Hour),
+		RefreshTokenTTL: config.Duration(24 * time.Hour),
+	})
+}
+
+func TestRefreshTokensRotation(t *testing.T) {
+	ctx := useMemoryRepositories(t)
+	useTestAuth()
+	user := insertSales(t, ctx, "sales")
+
+	first, err := IssueTokens(ctx, user, SessionMeta{ClientIP: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := RefreshTokens(ctx, first.RefreshToken, SessionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("刷新后应签发新的刷新令牌")
+	}
+
+	// 旧令牌再次使用视为泄露，整个家族被吊销
+	if _, err := RefreshTokens(ctx, first.RefreshToken, SessionMeta{}); err != ErrInvalidRefreshToken {
+		t.Fatalf("重复使用旧令牌: err = %v", err)
+	}
+	if _, err := RefreshTokens(ctx, second.RefreshToken, SessionMeta{}); err != ErrInvalidRefreshToken {
+		t.Fatalf("家族吊销后使用新令牌: err = %v", err)
+	}
+
+	// 其他会话不受影响
+	other, err := IssueTokens(ctx, user, SessionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RefreshTokens(ctx, other.RefreshToken, SessionMeta{}); err != nil {
+		t.Fatalf("其他会话: err = %v", err)
+	}
+	if _, err := RefreshTokens(ctx, "unknown", SessionMeta{}); err != ErrInvalidRefreshToken {
+		t.Fatalf("不存在的令牌: err = %v", err)
+	}
+}
+
+func TestInvalidateSessions(t *testing.T) {
+	ctx := useMemoryRepositories(t)
+	useTestAuth()
+	user := insertSales(t, ctx, "sales")
+
+	tokens, err := IssueTokens(ctx, user, SessionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := utils.ParseToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := claims["tv"].(float64); int64(version) != user.TokenVersion {
+		t.Fatalf("令牌版本 = %v, want %d", claims["tv"], user.TokenVersion)
+	}
+	if err := ValidateAccessToken(ctx, user.ID.Hex(), user.Role, user.TokenVersion); err != nil {
+		t.Fatalf("ValidateAccessToken: %v", err)
+	}
+
+	if err := InvalidateSessions(ctx, user.ID.Hex(), user.Role); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateAccessToken(ctx, user.ID.Hex(), user.Role, user.TokenVersion); err != ErrSessionRevoked {
+		t.Fatalf("版本变化后: err = %v", err)
+	}
+	if err := ValidateAccessToken(ctx, user.ID.Hex(), user.Role, user.TokenVersion+1); err != nil {
+		t.Fatalf("新版本: err = %v", err)
+	}
+	if _, err := RefreshTokens(ctx, tokens.RefreshToken, SessionMeta{}); err != ErrInvalidRefreshToken {
+		t.Fatalf("会话失效后刷新: err = %v", err)
+	}
+
+	// 角色变化或账户停用同样使令牌失效
+	if err := ValidateAccessToken(ctx, user.ID.Hex(), models.UserRoleSUPER_ADMIN, user.TokenVersion+1); err != ErrSessionRevoked {
+		t.Fatalf("角色不一致: err = %v", err)
+	}
+	if _, err := repository.Users().UpdateByID(ctx, user.ID, bson.M{
+		"$set": bson.M{"status": models.UserStatusREJECTED},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateAccessToken(ctx, user.ID.Hex(), user.Role, user.TokenVersion+1); err != ErrSessionRevoked {
+		t.Fatalf("账户停用: err = %v", err)
+	}
+	if err := ValidateAccessToken(context.Background(), "invalid", user.Role, 0); err != ErrSessionRevoked {
+		t.Fatalf("无效的账户ID: err = %v", err)
+	}
+}
+
+func TestRefreshTokensRejectsStaleVersion(t *testing.T) {
+	ctx := useMemoryRepositories(t)
+	useTestAuth()
+	user := insertSales(t, ctx, "sales")
+
+	tokens, err := IssueTokens(ctx, user, SessionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只递增版本而未吊销刷新令牌时，刷新同样失败
+	if _, err := repository.Users().UpdateByID(ctx, user.ID, bson.M{
+		"$inc": bson.M{"tokenVersion": 1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RefreshTokens(ctx, tokens.RefreshToken, SessionMeta{}); err != ErrSessionRevoked {
+		t.Fatalf("err = %v, want ErrSessionRevoked", err)
+	}
+}
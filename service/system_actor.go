@@ -0,0 +1,18 @@
+package service
+
+import (
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/utils"
+)
+
+// SystemActorID 系统自动操作在进展历史、分配历史中记录的操作人ID，不对应任何真实用户
+const SystemActorID = "system"
+
+// SystemActor 定时任务等系统自动操作使用的操作人，按超级管理员权限执行
+func SystemActor() *utils.LoginUser {
+	return &utils.LoginUser{
+		ID:       SystemActorID,
+		Role:     string(models.UserRoleSUPER_ADMIN),
+		Username: "系统",
+	}
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/BerniceZTT/crm_end/models"
+	"github.com/BerniceZTT/crm_end/repository"
+	"github.com/BerniceZTT/crm_end/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrCodeHandoverRequired 用户名下仍有未交接的数据
+const ErrCodeHandoverRequired = "HANDOVER_REQUIRED"
+
+// openProjectsFilter 指定用户创建的进行中项目（非批量出货、非废弃）
+func openProjectsFilter(userID primitive.ObjectID) bson.M {
+	return bson.M{
+		"creatorId": userID,
+		"projectProgress": bson.M{"$nin": bson.A{
+			string(models.ProgressMassProduction),
+			string(models.ProgressAbandoned),
+		}},
+	}
+}
+
+// PendingHandover 统计用户名下尚未交接的客户、代理商和进行中的项目
+// 回收站中的客户和项目同样计入，恢复后不会仍挂在已离职的销售名下
+func PendingHandover(ctx context.Context, userID primitive.ObjectID) (models.UserHandoverPending, error) {
+	var pending models.UserHandoverPending
+	var err error
+	if pending.Customers, err = repository.Customers().Unscoped().Count(ctx, bson.M{"relatedSalesId": userID.Hex()}); err != nil {
+		return pending, err
+	}
+	if pending.Agents, err = repository.Agents().Count(ctx, bson.M{"relatedSalesId": userID.Hex()}); err != nil {
+		return pending, err
+	}
+	if pending.Projects, err = repository.Projects().Unscoped().Count(ctx, openProjectsFilter(userID)); err != nil {
+		return pending, err
+	}
+	return pending, nil
+}
+
+// handoverPlanner 按规则和轮询为交接数据选择接收销售
+type handoverPlanner struct {
+	targets []models.User
+	byID    map[string]*models.User
+	rules   []models.UserHandoverRule
+	next    int
+	summary map[string]*models.UserHandoverTargetSummary
+}
+
+// roundRobin 轮流选择下一个接收销售
+func (p *handoverPlanner) roundRobin() *models.User {
+	target := &p.targets[p.next%len(p.targets)]
+	p.next++
+	return target
+}
+
+// forCustomer 先按规则匹配客户，未匹配时轮询
+func (p *handoverPlanner) forCustomer(customer models.Customer) *models.User {
+	for _, rule := range p.rules {
+		if rule.Importance != "" && rule.Importance != customer.Importance {
+			continue
+		}
+		if rule.Nature != "" && rule.Nature != customer.Nature {
+			continue
+		}
+		return p.byID[rule.TargetSalesID]
+	}
+	return p.roundRobin()
+}
+
+func (p *handoverPlanner) count(target *models.User) *models.UserHandoverTargetSummary {
+	return p.summary[target.ID.Hex()]
+}
+
+// HandoverUser 将离职销售名下的代理商、客户和进行中的项目交接给接收销售
+// 代理商轮流分配；挂靠代理商的客户跟随代理商的新销售，其余客户按规则或轮流分配；
+// 项目跟随客户的新销售，客户不在交接范围内时轮流分配。
+// 每个客户的归属变更和分配历史在同一事务中写入，单条失败不影响其他数据，重新执行可继续交接
+func HandoverUser(ctx context.Context, userID primitive.ObjectID, req models.UserHandoverRequest, operator *utils.LoginUser) (*models.UserHandoverResult, error) {
+	leaving, err := repository.Users().FindByID(ctx, userID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, utils.CreateNotFoundError("用户")
+		}
+		return nil, err
+	}
+	if leaving.Role != models.UserRoleFACTORY_SALES {
+		return nil, utils.CreateBadRequestError("只有原厂销售需要离职交接")
+	}
+
+	planner, err := newHandoverPlanner(ctx, req, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	leavingID := userID.Hex()
+	agents, err := repository.Agents().Find(ctx, bson.M{"relatedSalesId": leavingID}, repository.NewFindOptions().SetSort("createdAt", 1))
+	if err != nil {
+		return nil, fmt.Errorf("查询代理商失败: %w", err)
+	}
+	customers, err := repository.Customers().Unscoped().Find(ctx, bson.M{"relatedSalesId": leavingID}, repository.NewFindOptions().SetSort("createdAt", 1))
+	if err != nil {
+		return nil, fmt.Errorf("查询客户失败: %w", err)
+	}
+	projects, err := repository.Projects().Unscoped().Find(ctx, openProjectsFilter(userID), repository.NewFindOptions().SetSort("createdAt", 1))
+	if err != nil {
+		return nil, fmt.Errorf("查询项目失败: %w", err)
+	}
+
+	result := &models.UserHandoverResult{
+		DryRun:      req.DryRun,
+		UserID:      leavingID,
+		Username:    leaving.Username,
+		Assignments: []models.UserHandoverAssignment{},
+	}
+	record := func(kind string, id primitive.ObjectID, name string, target *models.User, err error) {
+		assignment := models.UserHandoverAssignment{
+			Type:        kind,
+			ID:          id.Hex(),
+			Name:        name,
+			ToSalesID:   target.ID.Hex(),
+			ToSalesName: target.Username,
+		}
+		if err != nil {
+			assignment.Error = err.Error()
+			result.Failed++
+			utils.LogError(err, map[string]interface{}{
+				"type":      kind,
+				"id":        assignment.ID,
+				"toSalesId": assignment.ToSalesID,
+			}, "离职交接失败")
+		}
+		result.Assignments = append(result.Assignments, assignment)
+	}
+	now := time.Now()
+
+	// 1. 代理商
+	agentTargets := make(map[string]*models.User, len(agents))
+	for _, agent := range agents {
+		target := planner.roundRobin()
+		agentTargets[agent.ID.Hex()] = target
+		planner.count(target).Agents++
+		var err error
+		if !req.DryRun {
+			_, err = repository.Agents().UpdateOne(ctx,
+				bson.M{"_id": agent.ID, "relatedSalesId": leavingID},
+				bson.M{"$set": bson.M{
+					"relatedSalesId":   target.ID.Hex(),
+					"relatedSalesName": target.Username,
+					"updatedAt":        now,
+				}})
+		}
+		record("agent", agent.ID, agent.CompanyName, target, err)
+	}
+
+	// 2. 客户
+	customerTargets := make(map[primitive.ObjectID]*models.User, len(customers))
+	for _, customer := range customers {
+		target, ok := agentTargets[customer.RelatedAgentID]
+		if !ok {
+			target = planner.forCustomer(customer)
+		}
+		customerTargets[customer.ID] = target
+		planner.count(target).Customers++
+		var err error
+		if !req.DryRun {
+			err = handoverCustomer(ctx, customer, target, operator)
+		}
+		record("customer", customer.ID, customer.Name, target, err)
+	}
+
+	// 3. 进行中的项目
+	for _, project := range projects {
+		target, ok := customerTargets[project.CustomerID]
+		if !ok {
+			target = planner.roundRobin()
+		}
+		planner.count(target).Projects++
+		var err error
+		if !req.DryRun {
+			_, err = repository.Projects().Unscoped().UpdateOne(ctx,
+				bson.M{"_id": project.ID, "creatorId": userID},
+				bson.M{"$set": bson.M{
+					"creatorId":   target.ID,
+					"creatorName": target.Username,
+					"updatedAt":   now,
+				}})
+		}
+		record("project", project.ID, project.ProjectName, target, err)
+	}
+
+	for _, target := range planner.targets {
+		result.Targets = append(result.Targets, *planner.summary[target.ID.Hex()])
+	}
+
+	if !req.DryRun {
+		pending, err := PendingHandover(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		result.Completed = result.Failed == 0 && pending.Empty()
+
+		utils.LogInfo(map[string]interface{}{
+			"userId":    leavingID,
+			"username":  leaving.Username,
+			"agents":    len(agents),
+			"customers": len(customers),
+			"projects":  len(projects),
+			"failed":    result.Failed,
+			"operator":  operator.Username,
+		}, "离职交接完成")
+	}
+	return result, nil
+}
+
+// newHandoverPlanner 校验接收销售和交接规则
+func newHandoverPlanner(ctx context.Context, req models.UserHandoverRequest, leavingID primitive.ObjectID) (*handoverPlanner, error) {
+	strategy := req.Strategy
+	if strategy == "" {
+		strategy = models.HandoverStrategyRoundRobin
+	}
+	if strategy != models.HandoverStrategyRoundRobin && strategy != models.HandoverStrategyRule {
+		return nil, utils.CreateBadRequestError(fmt.Sprintf("无效的分配策略: %s", req.Strategy))
+	}
+	if strategy == models.HandoverStrategyRule && len(req.Rules) == 0 {
+		return nil, utils.CreateBadRequestError("按规则分配时至少需要一条规则")
+	}
+
+	planner := &handoverPlanner{
+		byID:    make(map[string]*models.User),
+		summary: make(map[string]*models.UserHandoverTargetSummary),
+	}
+	for _, raw := range req.TargetSalesIDs {
+		id, err := primitive.ObjectIDFromHex(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, utils.CreateBadRequestError(fmt.Sprintf("无效的接收销售ID: %s", raw))
+		}
+		if id == leavingID {
+			return nil, utils.CreateBadRequestError("接收销售不能是离职用户本人")
+		}
+		if _, exists := planner.summary[id.Hex()]; exists {
+			continue
+		}
+		target, err := repository.Users().FindByID(ctx, id)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				return nil, utils.NewApiError(fmt.Sprintf("接收销售不存在: %s", raw), http.StatusNotFound, "RESOURCE_NOT_FOUND")
+			}
+			return nil, err
+		}
+		if target.Role != models.UserRoleFACTORY_SALES || target.Status != models.UserStatusAPPROVED {
+			return nil, utils.CreateBadRequestError(fmt.Sprintf("%s 不是已审批的原厂销售", target.Username))
+		}
+		planner.targets = append(planner.targets, *target)
+		planner.summary[id.Hex()] = &models.UserHandoverTargetSummary{SalesID: id.Hex(), SalesName: target.Username}
+	}
+	if len(planner.targets) == 0 {
+		return nil, utils.CreateBadRequestError("请至少选择一个接收销售")
+	}
+	for i := range planner.targets {
+		planner.byID[planner.targets[i].ID.Hex()] = &planner.targets[i]
+	}
+
+	if strategy == models.HandoverStrategyRule {
+		for _, rule := range req.Rules {
+			if _, ok := planner.byID[rule.TargetSalesID]; !ok {
+				return nil, utils.CreateBadRequestError("规则中的接收销售必须在接收销售列表中")
+			}
+		}
+		planner.rules = req.Rules
+	}
+	return planner, nil
+}
+
+// handoverCustomer 变更客户的关联销售并记录分配历史，两者在同一事务中写入；客户已不属于离职销售时跳过
+func handoverCustomer(ctx context.Context, customer models.Customer, target *models.User, operator *utils.LoginUser) error {
+	return repository.WithTransaction(ctx, func(txCtx context.Context) error {
+		now := time.Now()
+		result, err := repository.Customers().Unscoped().UpdateOne(txCtx,
+			bson.M{"_id": customer.ID, "relatedSalesId": customer.RelatedSalesID},
+			bson.M{"$set": bson.M{
+				"relatedSalesId":   target.ID.Hex(),
+				"relatedSalesName": target.Username,
+				"lastUpdateTime":   now,
+				"updatedAt":        now,
+			}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return nil
+		}
+		return AddAssignmentHistory(txCtx, models.CustomerAssignmentHistory{
+			CustomerID:           customer.ID.Hex(),
+			CustomerName:         customer.Name,
+			FromRelatedSalesID:   customer.RelatedSalesID,
+			FromRelatedSalesName: customer.RelatedSalesName,
+			ToRelatedSalesID:     target.ID.Hex(),
+			ToRelatedSalesName:   target.Username,
+			FromRelatedAgentID:   customer.RelatedAgentID,
+			FromRelatedAgentName: customer.RelatedAgentName,
+			ToRelatedAgentID:     customer.RelatedAgentID,
+			ToRelatedAgentName:   customer.RelatedAgentName,
+			OperatorID:           operator.ID,
+			OperatorName:         operator.Username,
+			OperationType:        models.HandoverOperationType,
+		})
+	})
+}
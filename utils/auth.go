@@ -1,8 +1,11 @@
 package utils
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 
@@ -12,82 +15,50 @@ import (
 	"github.com/dgrijalva/jwt-go"
 )
 
-var jwtSecret = []byte(config.LoadConfig().JWTKey)
+// jwtSecret 签名密钥，启动时由 InitAuth 注入
+var jwtSecret []byte
 
-// SpecialUserPasswords 特殊用户账号的密码映射表
-var SpecialUserPasswords = map[string]string{
-	"admin":   "admin123",
-}
-
-// HashPassword 哈希密码
-func HashPassword(password string) string {
-	hash := sha256.Sum256([]byte(password))
-	return hex.EncodeToString(hash[:])
-}
-
-// SimpleHash 简单哈希 (sha256 + 盐值)
-func SimpleHash(password string, salt string) string {
-	if salt == "" {
-		salt = "69dc6ee0"
-	}
-	hash := sha256.Sum256([]byte(password + salt))
-	return fmt.Sprintf("sha256$%s$%s", salt, hex.EncodeToString(hash[:]))
-}
-
-// VerifyPassword 验证密码 - 支持多种密码验证方式
-func VerifyPassword(password string, hashedPassword string) bool {
-	Logger.Info().Msg("验证密码: 处理密码验证请求")
-
-	// 步骤1: 检查特殊用户账号列表
-	for username, correctPassword := range SpecialUserPasswords {
-		if password == correctPassword {
-			Logger.Info().Str("username", username).Msg("检测到特殊用户密码匹配")
-			return true
-		}
-	}
+var (
+	// AccessTokenTTL 访问令牌有效期，过期后使用刷新令牌换取新令牌
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL 刷新令牌有效期
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
 
-	// 步骤2: 尝试直接比较（有些测试账号可能存储的是明文密码）
-	if password == hashedPassword {
-		Logger.Info().Msg("明文密码匹配成功")
-		return true
-	}
+// errJWTSecretNotSet 未调用 InitAuth 时拒绝签发和解析令牌，避免使用空密钥
+var errJWTSecretNotSet = errors.New("JWT密钥未初始化")
 
-	// 步骤3: 尝试使用标准SHA-256哈希验证
-	standardHashed := HashPassword(password)
-	if standardHashed == hashedPassword {
-		Logger.Info().Msg("标准SHA-256哈希验证成功")
-		return true
-	}
-
-	// 步骤4: 尝试格式化的哈希验证 (如 sha256$salt$hash)
-	parts := splitString(hashedPassword, "$")
-	if len(parts) == 3 && parts[0] == "sha256" {
-		salt := parts[1]
-		hashParts := splitString(SimpleHash(password, salt), "$")
-		if len(hashParts) == 3 && hashParts[2] == parts[2] {
-			Logger.Info().Msg("盐值哈希验证成功")
-			return true
-		}
-	}
+// AccessTokenType 访问令牌类型标识
+const AccessTokenType = "access"
 
-	Logger.Info().Msg("所有密码验证方法均失败")
-	return false
+// InitAuth 注入认证配置，须在签发或解析令牌前调用
+func InitAuth(cfg config.AuthConfig) {
+	jwtSecret = []byte(cfg.JWTKey)
+	AccessTokenTTL = time.Duration(cfg.AccessTokenTTL)
+	RefreshTokenTTL = time.Duration(cfg.RefreshTokenTTL)
 }
 
-// GenerateToken 生成JWT令牌
+// GenerateToken 生成JWT访问令牌，令牌中携带账户当前的令牌版本
 func GenerateToken(user interface{}) (string, error) {
+	if len(jwtSecret) == 0 {
+		return "", errJWTSecretNotSet
+	}
+
 	// 提取用户信息
 	var userId, username, role string
+	var tokenVersion int64
 
 	switch u := user.(type) {
 	case models.User:
 		userId = u.ID.Hex()
 		username = u.Username
 		role = string(u.Role)
+		tokenVersion = u.TokenVersion
 	case models.Agent:
 		userId = u.ID.Hex()
 		username = u.CompanyName
 		role = string(models.UserRoleAGENT)
+		tokenVersion = u.TokenVersion
 	default:
 		return "", fmt.Errorf("不支持的用户类型")
 	}
@@ -103,7 +74,9 @@ func GenerateToken(user interface{}) (string, error) {
 		"id":       userId,
 		"username": username,
 		"role":     role,
-		"exp":      time.Now().Add(time.Hour * 24 * 30).Unix(), // 30天有效期
+		"tv":       tokenVersion,
+		"typ":      AccessTokenType,
+		"exp":      time.Now().Add(AccessTokenTTL).Unix(),
 		"iat":      time.Now().Unix(),
 	}
 
@@ -125,8 +98,28 @@ func GenerateToken(user interface{}) (string, error) {
 	return tokenString, nil
 }
 
+// GenerateRefreshToken 生成随机刷新令牌，返回令牌原文及其哈希（数据库只保存哈希）
+func GenerateRefreshToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken 计算刷新令牌的哈希
+func HashRefreshToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
 // ParseToken 解析和验证JWT令牌
 func ParseToken(tokenString string) (jwt.MapClaims, error) {
+	if len(jwtSecret) == 0 {
+		return nil, errJWTSecretNotSet
+	}
+
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		// 验证签名方法
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -146,57 +139,3 @@ func ParseToken(tokenString string) (jwt.MapClaims, error) {
 
 	return nil, fmt.Errorf("无效的token")
 }
-
-// HasPermission 检查用户是否有权限
-func HasPermission(role models.UserRole, resource string, action string) bool {
-	// 超级管理员拥有所有权限
-	if role == models.UserRoleSUPER_ADMIN {
-		return true
-	}
-
-	// 定义各角色权限
-	permissions := map[models.UserRole]map[string][]string{
-		models.UserRoleFACTORY_SALES: {
-			"customers": {"read", "create", "update"},
-			"products":  {"read"},
-			"agents":    {"read", "create"},
-		},
-		models.UserRoleINVENTORY_MANAGER: {
-			"products":  {"read", "update"},
-			"inventory": {"read", "create"},
-		},
-		models.UserRoleAGENT: {
-			"customers": {"read", "create"},
-			"products":  {"read"},
-		},
-	}
-
-	// 检查特定角色的权限
-	if resourceActions, exists := permissions[role]; exists {
-		if actions, hasResource := resourceActions[resource]; hasResource {
-			for _, a := range actions {
-				if a == action {
-					return true
-				}
-			}
-		}
-	}
-
-	return false
-}
-
-// splitString 按分隔符拆分字符串
-func splitString(s, sep string) []string {
-	var result []string
-	start := 0
-	for i := 0; i < len(s); i++ {
-		if string(s[i]) == sep {
-			result = append(result, s[start:i])
-			start = i + 1
-		}
-	}
-	if start < len(s) {
-		result = append(result, s[start:])
-	}
-	return result
-}
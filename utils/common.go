@@ -100,16 +100,11 @@ func GetUser(c *gin.Context) (*LoginUser, error) {
 	}, nil
 }
 
-func PaginatedResponse(c *gin.Context, data interface{}, total int64, page int64, limit int64) {
+func PaginatedResponse(c *gin.Context, data interface{}, pagination models.Pagination) {
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data":    data,
-		"pagination": gin.H{
-			"total": total,
-			"page":  page,
-			"limit": limit,
-			"pages": (total + limit - 1) / limit,
-		},
+		"success":    true,
+		"data":       data,
+		"pagination": pagination,
 	})
 }
 
@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// companyRegions 公司名称中常见的地区前缀（省级行政区及主要城市）
+var companyRegions = []string{
+	"北京", "天津", "上海", "重庆", "河北", "山西", "辽宁", "吉林", "黑龙江", "江苏", "浙江", "安徽",
+	"福建", "江西", "山东", "河南", "湖北", "湖南", "广东", "海南", "四川", "贵州", "云南", "陕西",
+	"甘肃", "青海", "台湾", "内蒙古", "广西", "西藏", "宁夏", "新疆", "香港", "澳门",
+	"深圳", "广州", "东莞", "佛山", "珠海", "中山", "惠州", "江门", "汕头", "杭州", "宁波", "温州",
+	"绍兴", "嘉兴", "金华", "台州", "苏州", "无锡", "常州", "南京", "南通", "扬州", "徐州", "昆山",
+	"合肥", "厦门", "福州", "泉州", "青岛", "济南", "烟台", "郑州", "武汉", "长沙", "成都", "西安",
+	"昆明", "南宁", "南昌", "大连", "沈阳", "长春", "哈尔滨", "石家庄", "太原", "贵阳", "兰州", "海口",
+}
+
+// companyRegionSuffixes 地区名后可能跟随的行政区划后缀，按长度优先匹配
+var companyRegionSuffixes = []string{"特别行政区", "自治区", "新区", "省", "市", "区", "县"}
+
+// companySuffixes 公司名称中常见的组织形式后缀，按长度优先匹配
+var companySuffixes = []string{
+	"股份有限公司", "有限责任公司", "集团有限公司", "有限公司", "股份公司", "责任公司", "集团", "公司", "股份", "有限",
+}
+
+// companyMinCoreLength 去除前后缀后至少保留的字数，避免把名称剥成过短的通用词
+const companyMinCoreLength = 2
+
+// NormalizeCompanyName 规范化公司名称用于查重：
+// 全角转半角、去除括号中的地区、去除地区前缀和公司组织形式后缀、去除标点和空白，英文转小写
+// 例如 “深圳某某科技有限公司” 与 “某某科技(深圳)有限公司” 规范化后均为 “某某科技”
+func NormalizeCompanyName(name string) string {
+	name = strings.ToLower(toHalfWidth(name))
+	name = stripBracketRegions(name)
+	name = keepLettersAndDigits(name)
+
+	for changed := true; changed; {
+		changed = false
+		if trimmed, ok := trimCompanySuffix(name); ok {
+			name, changed = trimmed, true
+		}
+		if trimmed, ok := trimRegionPrefix(name); ok {
+			name, changed = trimmed, true
+		}
+	}
+	return name
+}
+
+// NormalizePhone 规范化电话号码：只保留数字并去除 86 国家码
+func NormalizePhone(phone string) string {
+	var b strings.Builder
+	for _, r := range toHalfWidth(phone) {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	digits := b.String()
+	if len(digits) == 13 && strings.HasPrefix(digits, "86") {
+		digits = digits[2:]
+	}
+	return digits
+}
+
+// NormalizeLookupName 规范化按名称查找用的文本：全角转半角、去除空白、英文转小写
+func NormalizeLookupName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(toHalfWidth(name)), ""))
+}
+
+// NameSimilarity 计算两个规范化名称的相似度（字符二元组 Dice 系数），取值 0~1
+func NameSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) < 2 || len(rb) < 2 {
+		return 0
+	}
+	bigrams := make(map[string]int, len(ra))
+	for i := 0; i+1 < len(ra); i++ {
+		bigrams[string(ra[i:i+2])]++
+	}
+	shared := 0
+	for i := 0; i+1 < len(rb); i++ {
+		key := string(rb[i : i+2])
+		if bigrams[key] > 0 {
+			bigrams[key]--
+			shared++
+		}
+	}
+	return float64(2*shared) / float64(len(ra)-1+len(rb)-1)
+}
+
+// toHalfWidth 全角字符转半角
+func toHalfWidth(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '　':
+			return ' '
+		case r >= '！' && r <= '～':
+			return r - 0xfee0
+		}
+		return r
+	}, s)
+}
+
+// stripBracketRegions 去除括号及其中的地区名，其他括号内容保留
+func stripBracketRegions(s string) string {
+	var b strings.Builder
+	var inner strings.Builder
+	depth := 0
+	for _, r := range s {
+		switch r {
+		case '(', '[', '{', '【', '〔', '「':
+			depth++
+			if depth == 1 {
+				inner.Reset()
+			}
+			continue
+		case ')', ']', '}', '】', '〕', '」':
+			if depth > 0 {
+				depth--
+				if depth == 0 && !isRegion(inner.String()) {
+					b.WriteString(inner.String())
+				}
+			}
+			continue
+		}
+		if depth > 0 {
+			inner.WriteRune(r)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	if depth > 0 {
+		b.WriteString(inner.String())
+	}
+	return b.String()
+}
+
+// isRegion 判断文本是否为地区名（可带行政区划后缀）
+func isRegion(s string) bool {
+	s = strings.TrimSpace(s)
+	for _, suffix := range companyRegionSuffixes {
+		if trimmed := strings.TrimSuffix(s, suffix); trimmed != s {
+			s = trimmed
+			break
+		}
+	}
+	for _, region := range companyRegions {
+		if s == region {
+			return true
+		}
+	}
+	return false
+}
+
+// keepLettersAndDigits 去除标点、符号和空白
+func keepLettersAndDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// trimCompanySuffix 去除一个组织形式后缀
+func trimCompanySuffix(s string) (string, bool) {
+	for _, suffix := range companySuffixes {
+		if trimmed := strings.TrimSuffix(s, suffix); trimmed != s && utf8.RuneCountInString(trimmed) >= companyMinCoreLength {
+			return trimmed, true
+		}
+	}
+	return s, false
+}
+
+// trimRegionPrefix 去除一个地区前缀（含行政区划后缀）
+func trimRegionPrefix(s string) (string, bool) {
+	for _, region := range companyRegions {
+		rest, ok := strings.CutPrefix(s, region)
+		if !ok {
+			continue
+		}
+		for _, suffix := range companyRegionSuffixes {
+			if trimmed, ok := strings.CutPrefix(rest, suffix); ok {
+				rest = trimmed
+				break
+			}
+		}
+		if utf8.RuneCountInString(rest) >= companyMinCoreLength {
+			return rest, true
+		}
+	}
+	return s, false
+}
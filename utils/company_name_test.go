@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalizeCompanyName(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "地区前缀和组织形式", input: "深圳某某科技有限公司", want: "某某科技"},
+		{name: "括号中的地区", input: "某某科技(深圳)有限公司", want: "某某科技"},
+		{name: "全角括号", input: "某某科技（深圳）有限公司", want: "某某科技"},
+		{name: "带行政区划后缀的地区", input: "广东省深圳市某某科技股份有限公司", want: "某某科技"},
+		{name: "括号中非地区内容保留", input: "某某科技(集团研发)有限公司", want: "某某科技集团研发"},
+		{name: "全角英文转小写", input: "ＡＢＣ电子有限公司", want: "abc电子"},
+		{name: "去除标点和空白", input: " 某某·科技 有限公司 ", want: "某某科技"},
+		{name: "保留至少两个字", input: "深圳公司", want: "深圳"},
+		{name: "地区后剩余过短时保留地区", input: "北京市", want: "北京市"},
+		{name: "空字符串", input: "", want: ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := NormalizeCompanyName(tc.input); got != tc.want {
+				t.Fatalf("NormalizeCompanyName(%q) = %q, want %q", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	cases := map[string]string{
+		"138-0013-8000":   "13800138000",
+		"+86 13800138000": "13800138000",
+		"８６１３８００１３８０００": "13800138000",
+		"0755-12345678": "075512345678",
+		"":              "",
+	}
+	for input, want := range cases {
+		if got := NormalizePhone(input); got != want {
+			t.Errorf("NormalizePhone(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestNameSimilarity(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{name: "相同", a: "某某科技", b: "某某科技", want: 1},
+		{name: "完全不同", a: "某某科技", b: "甲乙丙丁", want: 0},
+		{name: "单字不参与比较", a: "某", b: "某某", want: 0},
+		{name: "部分相同", a: "某某科技", b: "某某电子", want: 2.0 / 6},
+		{name: "多一个字", a: "某某科技", b: "某某科技园", want: 6.0 / 7},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := NameSimilarity(tc.a, tc.b)
+			if math.Abs(got-tc.want) > 1e-9 {
+				t.Fatalf("NameSimilarity(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+			}
+			if reverse := NameSimilarity(tc.b, tc.a); math.Abs(reverse-got) > 1e-9 {
+				t.Fatalf("NameSimilarity 不对称: %v != %v", got, reverse)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHashCost bcrypt 计算成本，低于该成本的哈希会在登录时重新生成
+const PasswordHashCost = 12
+
+// PasswordScheme 密码存储格式
+type PasswordScheme string
+
+const (
+	PasswordSchemeBcrypt       PasswordScheme = "bcrypt"        // 当前格式
+	PasswordSchemeSaltedSHA256 PasswordScheme = "sha256_salted" // 旧格式 sha256$salt$hash
+	PasswordSchemeSHA256       PasswordScheme = "sha256"        // 旧格式 无盐 SHA-256
+	PasswordSchemeUnknown      PasswordScheme = "unknown"       // 明文或无法识别，需管理员重置
+)
+
+var (
+	bcryptHashPattern = regexp.MustCompile(`^\$2[aby]\$\d{2}\$`)
+	sha256HexPattern  = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// 密码强度规则
+const (
+	passwordMinLength = 8
+	passwordMaxLength = 64
+	passwordMaxBytes  = 72 // bcrypt 只处理前 72 字节
+)
+
+// weakPasswords 常见弱密码，禁止直接使用
+var weakPasswords = map[string]bool{
+	"12345678":    true,
+	"123456789":   true,
+	"1234567890":  true,
+	"password":    true,
+	"password1":   true,
+	"password123": true,
+	"admin123":    true,
+	"admin1234":   true,
+	"qwerty123":   true,
+	"abc12345":    true,
+	"abcd1234":    true,
+	"11111111":    true,
+	"88888888":    true,
+	"a1234567":    true,
+}
+
+// HashPassword 使用 bcrypt 生成密码哈希
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), PasswordHashCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// GenerateRandomPassword 生成满足强度规则的随机密码
+func GenerateRandomPassword(length int) (string, error) {
+	const letters = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ"
+	const digits = "23456789"
+	const charset = letters + digits
+
+	if length < passwordMinLength {
+		length = passwordMinLength
+	}
+	for {
+		buf := make([]byte, length)
+		for i := range buf {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+			if err != nil {
+				return "", err
+			}
+			buf[i] = charset[n.Int64()]
+		}
+		password := string(buf)
+		if ValidatePasswordStrength(password, "") == nil {
+			return password, nil
+		}
+	}
+}
+
+// DetectPasswordScheme 识别已存储密码的格式
+func DetectPasswordScheme(hashedPassword string) PasswordScheme {
+	switch {
+	case bcryptHashPattern.MatchString(hashedPassword):
+		return PasswordSchemeBcrypt
+	case strings.HasPrefix(hashedPassword, "sha256$") && len(strings.Split(hashedPassword, "$")) == 3:
+		return PasswordSchemeSaltedSHA256
+	case sha256HexPattern.MatchString(hashedPassword):
+		return PasswordSchemeSHA256
+	}
+	return PasswordSchemeUnknown
+}
+
+// VerifyPassword 验证密码
+// needsRehash 为 true 表示密码正确但存储格式已过时，调用方应使用 HashPassword 重新生成并保存
+func VerifyPassword(password string, hashedPassword string) (ok bool, needsRehash bool) {
+	switch DetectPasswordScheme(hashedPassword) {
+	case PasswordSchemeBcrypt:
+		if bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)) != nil {
+			return false, false
+		}
+		cost, err := bcrypt.Cost([]byte(hashedPassword))
+		return true, err != nil || cost < PasswordHashCost
+
+	case PasswordSchemeSaltedSHA256:
+		parts := strings.Split(hashedPassword, "$")
+		hash := sha256.Sum256([]byte(password + parts[1]))
+		ok = constantTimeEqual(hex.EncodeToString(hash[:]), parts[2])
+		return ok, ok
+
+	case PasswordSchemeSHA256:
+		hash := sha256.Sum256([]byte(password))
+		ok = constantTimeEqual(hex.EncodeToString(hash[:]), hashedPassword)
+		return ok, ok
+	}
+
+	Logger.Warn().Msg("密码存储格式无法识别，拒绝验证")
+	return false, false
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// SimulatePasswordCheck 账户不存在时执行一次同等耗时的哈希校验，避免通过响应时间判断账户是否存在
+func SimulatePasswordCheck(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password-0"), PasswordHashCost)
+	})
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+// constantTimeEqual 常量时间比较字符串，避免时序攻击
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// ValidatePasswordStrength 校验密码强度
+// 规则：8-64 位，同时包含字母和数字，不包含用户名，且不是常见弱密码
+func ValidatePasswordStrength(password string, username string) error {
+	length := utf8.RuneCountInString(password)
+	if length < passwordMinLength {
+		return errors.New("密码长度不能少于8位")
+	}
+	if length > passwordMaxLength || len(password) > passwordMaxBytes {
+		return errors.New("密码长度不能超过64位")
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsSpace(r):
+			return errors.New("密码不能包含空白字符")
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return errors.New("密码必须同时包含字母和数字")
+	}
+
+	lower := strings.ToLower(password)
+	if weakPasswords[lower] {
+		return errors.New("密码过于简单，请更换")
+	}
+	if name := strings.ToLower(strings.TrimSpace(username)); utf8.RuneCountInString(name) >= 3 && strings.Contains(lower, name) {
+		return errors.New("密码不能包含用户名")
+	}
+	return nil
+}
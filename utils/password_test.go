@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestVerifyPassword(t *testing.T) {
+	const password = "secret123"
+
+	current, err := HashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lowCost, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	salted := sha256.Sum256([]byte(password + "salt"))
+	plain := sha256.Sum256([]byte(password))
+
+	cases := []struct {
+		name       string
+		hash       string
+		scheme     PasswordScheme
+		password   string
+		wantOK     bool
+		wantRehash bool
+	}{
+		{name: "当前成本的 bcrypt", hash: current, scheme: PasswordSchemeBcrypt, password: password, wantOK: true},
+		{name: "bcrypt 密码错误", hash: current, scheme: PasswordSchemeBcrypt, password: "wrong123"},
+		{name: "低成本 bcrypt 需要重新生成", hash: string(lowCost), scheme: PasswordSchemeBcrypt, password: password, wantOK: true, wantRehash: true},
+		{name: "加盐 SHA-256 需要重新生成", hash: "sha256$salt$" + hex.EncodeToString(salted[:]), scheme: PasswordSchemeSaltedSHA256, password: password, wantOK: true, wantRehash: true},
+		{name: "加盐 SHA-256 密码错误", hash: "sha256$salt$" + hex.EncodeToString(salted[:]), scheme: PasswordSchemeSaltedSHA256, password: "wrong123"},
+		{name: "无盐 SHA-256 需要重新生成", hash: hex.EncodeToString(plain[:]), scheme: PasswordSchemeSHA256, password: password, wantOK: true, wantRehash: true},
+		{name: "无盐 SHA-256 密码错误", hash: hex.EncodeToString(plain[:]), scheme: PasswordSchemeSHA256, password: "wrong123"},
+		{name: "明文存储拒绝验证", hash: password, scheme: PasswordSchemeUnknown, password: password},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if scheme := DetectPasswordScheme(tc.hash); scheme != tc.scheme {
+				t.Fatalf("DetectPasswordScheme = %s, want %s", scheme, tc.scheme)
+			}
+			ok, needsRehash := VerifyPassword(tc.password, tc.hash)
+			if ok != tc.wantOK || needsRehash != tc.wantRehash {
+				t.Fatalf("VerifyPassword = (%v, %v), want (%v, %v)", ok, needsRehash, tc.wantOK, tc.wantRehash)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// 表格文件格式
+const (
+	SpreadsheetCSV  = "csv"
+	SpreadsheetXLSX = "xlsx"
+)
+
+// utf8BOM Excel 依据 BOM 识别 UTF-8 编码的 CSV，否则中文会乱码
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// SpreadsheetFormat 按文件名后缀判断表格格式，不支持时返回空字符串
+func SpreadsheetFormat(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return SpreadsheetCSV
+	case ".xlsx":
+		return SpreadsheetXLSX
+	}
+	return ""
+}
+
+// SpreadsheetContentType 表格格式对应的响应类型
+func SpreadsheetContentType(format string) string {
+	if format == SpreadsheetXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// ErrSpreadsheetTooManyRows 表格行数超过读取时指定的上限
+var ErrSpreadsheetTooManyRows = errors.New("表格行数超过上限")
+
+// ReadSpreadsheet 读取 csv 或 xlsx 文件的全部行，maxRows 大于 0 时超过该行数立即返回 ErrSpreadsheetTooManyRows，
+// maxColumns 大于 0 时每行只保留前 maxColumns 列
+// csv 支持 UTF-8（可带 BOM）和 Excel 中文版默认保存的 GBK 编码
+func ReadSpreadsheet(format string, data []byte, maxRows int, maxColumns int) ([][]string, error) {
+	switch format {
+	case SpreadsheetXLSX:
+		return ReadXLSX(data, maxRows, maxColumns)
+	case SpreadsheetCSV:
+		return readCSV(data, maxRows, maxColumns)
+	}
+	return nil, fmt.Errorf("不支持的文件格式，请上传 .xlsx 或 .csv 文件")
+}
+
+func readCSV(data []byte, maxRows int, maxColumns int) ([][]string, error) {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	if !utf8.Valid(data) {
+		decoded, err := simplifiedchinese.GB18030.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("无法识别CSV文件编码，请另存为UTF-8编码: %w", err)
+		}
+		data = decoded
+	}
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	var rows [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析CSV文件失败: %w", err)
+		}
+		if maxRows > 0 && len(rows) == maxRows {
+			return nil, ErrSpreadsheetTooManyRows
+		}
+		if maxColumns > 0 && len(record) > maxColumns {
+			record = record[:maxColumns]
+		}
+		rows = append(rows, record)
+	}
+}
+
+// SpreadsheetWriter 逐行写出表格文件
+type SpreadsheetWriter interface {
+	WriteRow(values []string) error
+	// Close 写出缓冲数据和文件结尾
+	Close() error
+}
+
+// NewSpreadsheetWriter 按格式创建表格写入器，csv 带 UTF-8 BOM 以便 Excel 直接打开
+func NewSpreadsheetWriter(w io.Writer, format string, sheetName string) (SpreadsheetWriter, error) {
+	switch format {
+	case SpreadsheetXLSX:
+		return NewXLSXWriter(w, sheetName)
+	case SpreadsheetCSV:
+		if _, err := w.Write(utf8BOM); err != nil {
+			return nil, err
+		}
+		return &csvSpreadsheetWriter{w: csv.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("不支持的文件格式: %s", format)
+}
+
+type csvSpreadsheetWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvSpreadsheetWriter) WriteRow(values []string) error {
+	return c.w.Write(values)
+}
+
+func (c *csvSpreadsheetWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestReadCSVRowLimit(t *testing.T) {
+	data := []byte("名称,电话\n甲,1\n乙,2\n")
+	if rows, err := ReadSpreadsheet(SpreadsheetCSV, data, 3, 0); err != nil || len(rows) != 3 {
+		t.Fatalf("got %d rows, err %v", len(rows), err)
+	}
+	if _, err := ReadSpreadsheet(SpreadsheetCSV, data, 2, 0); !errors.Is(err, ErrSpreadsheetTooManyRows) {
+		t.Fatalf("got %v, want ErrSpreadsheetTooManyRows", err)
+	}
+}
+
+func TestReadCSVEncodings(t *testing.T) {
+	want := [][]string{{"客户名称", "联系人"}, {"华为", "张三"}}
+	utf8Data := []byte("客户名称,联系人\n华为,张三\n")
+	gbkData, err := simplifiedchinese.GBK.NewEncoder().Bytes(utf8Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{
+		"utf8":     utf8Data,
+		"utf8-bom": append(append([]byte{}, utf8BOM...), utf8Data...),
+		"gbk":      gbkData,
+	} {
+		got, err := ReadSpreadsheet(SpreadsheetCSV, data, 0, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// xlsx 文件是按 OOXML 规范组织的 zip 包，这里只实现导入导出需要的最小子集：
+// 读取第一个工作表的单元格文本，写出只含字符串单元格的单工作表文件
+
+// maxXLSXPartSize 单个 xml 部件解压后的最大字节数，防止压缩炸弹
+const maxXLSXPartSize = 64 << 20
+
+// MaxXLSXColumns xlsx 规范允许的最大列数（XFD 列）
+const MaxXLSXColumns = 16384
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxRichText `xml:"si"`
+}
+
+// xlsxRichText 共享字符串或行内字符串，纯文本在 t 中，富文本分段在 r/t 中
+type xlsxRichText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxRichText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Index int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string       `xml:"r,attr"`
+			Type   string       `xml:"t,attr"`
+			Value  string       `xml:"v"`
+			Inline xlsxRichText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// ReadXLSX 读取 xlsx 文件第一个工作表的全部行，单元格统一返回文本，空行保留为空切片
+// 行号和列号来自文件内容，解析时即校验：行号超过 maxRows（大于 0 时）返回 ErrSpreadsheetTooManyRows，
+// 列超过 MaxXLSXColumns 或单元格引用格式错误时直接失败；maxColumns 大于 0 时忽略该列及之后的单元格，
+// 避免按伪造的列号把每行补齐成超大切片
+func ReadXLSX(data []byte, maxRows int, maxColumns int) ([][]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("无法解析xlsx文件: %w", err)
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	var workbook xlsxWorkbook
+	if err := decodeXLSXPart(files, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	if len(workbook.Sheets) == 0 {
+		return nil, fmt.Errorf("xlsx文件中没有工作表")
+	}
+	var rels xlsxRelationships
+	if err := decodeXLSXPart(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	sheetPath := ""
+	for _, rel := range rels.Relationships {
+		if rel.ID == workbook.Sheets[0].RID {
+			sheetPath = resolveXLSXTarget(rel.Target)
+			break
+		}
+	}
+	if sheetPath == "" {
+		return nil, fmt.Errorf("xlsx文件中找不到工作表 %s", workbook.Sheets[0].Name)
+	}
+
+	var shared xlsxSharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodeXLSXPart(files, "xl/sharedStrings.xml", &shared); err != nil {
+			return nil, err
+		}
+	}
+
+	var sheet xlsxWorksheet
+	if err := decodeXLSXPart(files, sheetPath, &sheet); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, row := range sheet.Rows {
+		// 行号缺省时按顺序排列，跳过的行补空行，保证行号与表格一致
+		index := row.Index
+		if index <= 0 {
+			index = len(rows) + 1
+		}
+		if index <= len(rows) {
+			return nil, fmt.Errorf("工作表第 %d 行重复或顺序错误", index)
+		}
+		if maxRows > 0 && index > maxRows {
+			return nil, ErrSpreadsheetTooManyRows
+		}
+		for len(rows) < index-1 {
+			rows = append(rows, []string{})
+		}
+		values := []string{}
+		for i, cell := range row.Cells {
+			col := i
+			if cell.Ref != "" {
+				parsed, ok := xlsxColumnIndex(cell.Ref)
+				if !ok {
+					return nil, fmt.Errorf("单元格引用 %q 无效", cell.Ref)
+				}
+				col = parsed
+			}
+			if col >= MaxXLSXColumns {
+				return nil, fmt.Errorf("单元格 %s 超出最大列数 %d", cell.Ref, MaxXLSXColumns)
+			}
+			if maxColumns > 0 && col >= maxColumns {
+				continue
+			}
+			for len(values) <= col {
+				values = append(values, "")
+			}
+			switch cell.Type {
+			case "s":
+				n, err := strconv.Atoi(cell.Value)
+				if err != nil || n < 0 || n >= len(shared.Items) {
+					return nil, fmt.Errorf("单元格 %s 引用了无效的共享字符串", cell.Ref)
+				}
+				values[col] = shared.Items[n].String()
+			case "inlineStr":
+				values[col] = cell.Inline.String()
+			case "b":
+				values[col] = map[string]string{"1": "TRUE", "0": "FALSE"}[cell.Value]
+			default:
+				values[col] = cell.Value
+			}
+		}
+		rows = append(rows, values)
+	}
+	return rows, nil
+}
+
+// decodeXLSXPart 解压并解析 zip 包中的 xml 部件
+func decodeXLSXPart(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("xlsx文件缺少 %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("读取 %s 失败: %w", name, err)
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(io.LimitReader(rc, maxXLSXPartSize)).Decode(v); err != nil {
+		return fmt.Errorf("解析 %s 失败: %w", name, err)
+	}
+	return nil
+}
+
+// resolveXLSXTarget 将关系中的目标路径转换为 zip 包内路径
+func resolveXLSXTarget(target string) string {
+	if strings.HasPrefix(target, "/") {
+		return strings.TrimPrefix(target, "/")
+	}
+	return path.Clean(path.Join("xl", target))
+}
+
+// xlsxColumnIndex 从单元格引用（如 AB12）解析列序号，从 0 开始
+// 引用必须是 1 到 3 个大写字母加正整数行号，列号不超过 MaxXLSXColumns
+func xlsxColumnIndex(ref string) (int, bool) {
+	col := 0
+	n := 0
+	for n < len(ref) && ref[n] >= 'A' && ref[n] <= 'Z' {
+		if n == 3 {
+			return 0, false
+		}
+		col = col*26 + int(ref[n]-'A'+1)
+		n++
+	}
+	if n == 0 || col > MaxXLSXColumns {
+		return 0, false
+	}
+	row, err := strconv.Atoi(ref[n:])
+	if err != nil || row <= 0 || strings.HasPrefix(ref[n:], "+") {
+		return 0, false
+	}
+	return col - 1, true
+}
+
+// xlsxColumnName 列序号（从 0 开始）转换为列名，如 0 -> A，27 -> AB
+func xlsxColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+	xlsxWorkbookTemplate = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxSheetHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetFooter = `</sheetData></worksheet>`
+)
+
+// XLSXWriter 流式写出单工作表 xlsx 文件，行数据逐行写入 zip，不在内存中缓存整张表
+type XLSXWriter struct {
+	zw    *zip.Writer
+	sheet io.Writer
+	rows  int
+	err   error
+}
+
+// NewXLSXWriter 创建 xlsx 写入器，sheetName 为工作表名称
+func NewXLSXWriter(w io.Writer, sheetName string) (*XLSXWriter, error) {
+	zw := zip.NewWriter(w)
+	var name bytes.Buffer
+	if err := xml.EscapeText(&name, []byte(sheetName)); err != nil {
+		return nil, err
+	}
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbookTemplate, name.String())},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return nil, err
+		}
+	}
+	// 工作表放在最后写入，之后的行数据可以直接追加
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, xlsxSheetHeader); err != nil {
+		return nil, err
+	}
+	return &XLSXWriter{zw: zw, sheet: sheet}, nil
+}
+
+// WriteRow 写入一行，单元格均按文本写入
+func (x *XLSXWriter) WriteRow(values []string) error {
+	if x.err != nil {
+		return x.err
+	}
+	x.rows++
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<row r="%d">`, x.rows)
+	for i, value := range values {
+		if value == "" {
+			continue
+		}
+		fmt.Fprintf(&b, `<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">`, xlsxColumnName(i), x.rows)
+		if err := xml.EscapeText(&b, []byte(sanitizeXMLText(value))); err != nil {
+			x.err = err
+			return err
+		}
+		b.WriteString(`</t></is></c>`)
+	}
+	b.WriteString(`</row>`)
+	_, x.err = x.sheet.Write(b.Bytes())
+	return x.err
+}
+
+// Close 结束工作表并写出 zip 目录，必须调用才能得到完整的文件
+func (x *XLSXWriter) Close() error {
+	if x.err != nil {
+		return x.err
+	}
+	if _, err := io.WriteString(x.sheet, xlsxSheetFooter); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
+
+// sanitizeXMLText 去除 xml 1.0 不允许的控制字符
+func sanitizeXMLText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' || r >= 0x20 {
+			return r
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	testWorkbook = `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="客户" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	testWorkbookRels = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`
+	testSheetHeader = `<?xml version="1.0" encoding="UTF-8"?><worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	testSheetFooter = `</sheetData></worksheet>`
+)
+
+// buildTestXLSX 用给定的工作表行和共享字符串拼出最小的 xlsx 文件
+func buildTestXLSX(t *testing.T, sheetRows string, sharedStrings string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	parts := map[string]string{
+		"xl/workbook.xml":            testWorkbook,
+		"xl/_rels/workbook.xml.rels": testWorkbookRels,
+		"xl/worksheets/sheet1.xml":   testSheetHeader + sheetRows + testSheetFooter,
+	}
+	if sharedStrings != "" {
+		parts["xl/sharedStrings.xml"] = `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` + sharedStrings + `</sst>`
+	}
+	for name, content := range parts {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXLSXSharedAndInlineStrings(t *testing.T) {
+	shared := `<si><t>客户名称</t></si><si><r><t>华为</t></r><r><t>技术</t></r></si>`
+	rows := `<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="inlineStr"><is><t>联系人</t></is></c></row>` +
+		`<row r="2"><c r="A2" t="s"><v>1</v></c><c r="B2" t="inlineStr"><is><r><t>张</t></r><r><t>三</t></r></is></c>` +
+		`<c r="C2"><v>42</v></c><c r="D2" t="b"><v>1</v></c></row>`
+
+	got, err := ReadXLSX(buildTestXLSX(t, rows, shared), 0, 0)
+	if err != nil {
+		t.Fatalf("ReadXLSX: %v", err)
+	}
+	want := [][]string{
+		{"客户名称", "联系人"},
+		{"华为技术", "张三", "42", "TRUE"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestReadXLSXSparseRowsAndCells(t *testing.T) {
+	rows := `<row r="2"><c r="C2" t="inlineStr"><is><t>c</t></is></c></row>` +
+		`<row r="5"><c r="A5" t="inlineStr"><is><t>a</t></is></c><c r="E5" t="inlineStr"><is><t>e</t></is></c></row>` +
+		// 行号和单元格引用缺省时按出现顺序排列
+		`<row><c t="inlineStr"><is><t>x</t></is></c><c t="inlineStr"><is><t>y</t></is></c></row>`
+
+	got, err := ReadXLSX(buildTestXLSX(t, rows, ""), 0, 0)
+	if err != nil {
+		t.Fatalf("ReadXLSX: %v", err)
+	}
+	want := [][]string{
+		{},
+		{"", "", "c"},
+		{},
+		{},
+		{"a", "", "", "", "e"},
+		{"x", "y"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestReadXLSXRejectsMalformedInput(t *testing.T) {
+	cases := []struct {
+		name    string
+		rows    string
+		shared  string
+		maxRows int
+		wantErr error
+		errText string
+	}{
+		{name: "行号超过上限", rows: `<row r="100000000"><c r="A100000000"><v>1</v></c></row>`, maxRows: 5001, wantErr: ErrSpreadsheetTooManyRows},
+		{name: "缺省行号累计超过上限", rows: `<row/><row/><row/>`, maxRows: 2, wantErr: ErrSpreadsheetTooManyRows},
+		{name: "列超过 XFD", rows: `<row r="1"><c r="XFE1"><v>1</v></c></row>`, errText: "无效"},
+		{name: "列字母超过三位", rows: `<row r="1"><c r="AAAA1"><v>1</v></c></row>`, errText: "无效"},
+		{name: "引用缺少行号", rows: `<row r="1"><c r="B"><v>1</v></c></row>`, errText: "无效"},
+		{name: "引用缺少列", rows: `<row r="1"><c r="12"><v>1</v></c></row>`, errText: "无效"},
+		{name: "行号为零", rows: `<row r="1"><c r="A0"><v>1</v></c></row>`, errText: "无效"},
+		{name: "小写列名", rows: `<row r="1"><c r="a1"><v>1</v></c></row>`, errText: "无效"},
+		{name: "行顺序错误", rows: `<row r="3"/><row r="2"/>`, errText: "顺序错误"},
+		{name: "共享字符串越界", rows: `<row r="1"><c r="A1" t="s"><v>3</v></c></row>`, shared: `<si><t>a</t></si>`, errText: "共享字符串"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ReadXLSX(buildTestXLSX(t, tc.rows, tc.shared), tc.maxRows, 0)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Fatalf("got %v, want %v", err, tc.wantErr)
+			}
+			if tc.errText != "" && !strings.Contains(err.Error(), tc.errText) {
+				t.Fatalf("error %q does not contain %q", err, tc.errText)
+			}
+		})
+	}
+}
+
+func TestReadXLSXIgnoresColumnsBeyondLimit(t *testing.T) {
+	rows := `<row r="1"><c r="A1" t="inlineStr"><is><t>a</t></is></c><c r="C1" t="inlineStr"><is><t>c</t></is></c>` +
+		`<c r="D1" t="inlineStr"><is><t>d</t></is></c><c r="XFD1" t="inlineStr"><is><t>z</t></is></c></row>` +
+		`<row r="2"><c r="XFD2" t="inlineStr"><is><t>z</t></is></c></row>`
+	got, err := ReadXLSX(buildTestXLSX(t, rows, ""), 2, 3)
+	if err != nil {
+		t.Fatalf("ReadXLSX: %v", err)
+	}
+	// 超出列数的单元格被忽略，不按其列号补齐
+	want := [][]string{{"a", "", "c"}, {}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestReadXLSXRejectsNonZip(t *testing.T) {
+	if _, err := ReadXLSX([]byte("not a zip"), 0, 0); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestXLSXWriterRoundTrip(t *testing.T) {
+	input := [][]string{
+		{"客户名称", "备注", "", "地址"},
+		{},
+		{"A&B <公司>", "第一行\n第二行", "", " 前后空格 "},
+		{"含控制字符\x01\x02", "", "", ""},
+	}
+	var buf bytes.Buffer
+	w, err := NewXLSXWriter(&buf, "客户 & 项目")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range input {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadXLSX(buf.Bytes(), 0, 0)
+	if err != nil {
+		t.Fatalf("ReadXLSX: %v", err)
+	}
+	// 空单元格不写出，读回时行尾的空单元格不存在
+	want := [][]string{
+		{"客户名称", "备注", "", "地址"},
+		{},
+		{"A&B <公司>", "第一行\n第二行", "", " 前后空格 "},
+		{"含控制字符"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestXLSXColumnName(t *testing.T) {
+	for index, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA", MaxXLSXColumns - 1: "XFD"} {
+		if got := xlsxColumnName(index); got != want {
+			t.Errorf("xlsxColumnName(%d) = %s, want %s", index, got, want)
+		}
+		if got, ok := xlsxColumnIndex(want + "1"); !ok || got != index {
+			t.Errorf("xlsxColumnIndex(%s1) = %d, %v, want %d", want, got, ok, index)
+		}
+	}
+}